@@ -6,3 +6,5 @@ require (
 	github.com/yinfei8/jrpc2 v0.13.1
 	golang.org/x/text v0.3.5
 )
+
+require golang.org/x/sync v0.0.0-20201207232520-09787c993a3a // indirect
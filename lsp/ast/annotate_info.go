@@ -2,8 +2,43 @@ package ast
 
 // AnnotateFile 单个文件的注释信息
 type AnnotateFile struct {
+	BlockList []*AnnotateBlock // 所有包含注释标记或者说明的注释块，按位置排序
+	ClassList []*Type_Class    // 文件里定义的 @class
+	AliasList []*Type_Alias    // 文件里定义的 @alias
+	EnumList  []*Type_Enum     // 文件里定义的 @enum
+	ErrList   []ParseError     // 注释的解析错误
 }
 
 type OneClassInfo struct {
 	NameAndLoc NameAndLoc
 }
+
+// AnnotateLine 一行 ---@ 开头的注释
+type AnnotateLine struct {
+	Tag   NameAndLoc // @ 后面的标签，例如 type param
+	State any        // 解析的结果，*AnnotateTypeState 等。不认识的标签为 nil
+	Loc   Location   // 整行注释的位置
+}
+
+// AnnotateBlock 连续的注释块，解析出注释标记和说明
+type AnnotateBlock struct {
+	Block    *CommentBlock
+	LineList []*AnnotateLine
+	Doc      string // 不是注释标记的行，去掉 -- 前缀后用换行连接
+	Loc      Location
+	Stat     Stat // 紧跟在注释后面的语句，或者尾注释所在的语句，可能为 nil
+	TailFlag bool // 是否为语句后面的尾注释
+}
+
+// FindState 查找第一个指定类型的注释标记
+func FindState[T any](block *AnnotateBlock) (state T, ok bool) {
+	if block == nil {
+		return state, false
+	}
+	for _, line := range block.LineList {
+		if state, ok = line.State.(T); ok {
+			return state, true
+		}
+	}
+	return state, false
+}
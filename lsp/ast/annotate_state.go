@@ -129,18 +129,22 @@ type Type_Class struct {
 	GenericParamList []Type_KeyValue // 泛型类有这个
 	FieldList        []Type_ClassField
 	Comment          string
+	Table            *TableInfo // 注释后面定义的 lua 表，例如 local Foo = {} ，可能为 nil
+	File             *FileInfo
 }
 
 type Type_Alias struct {
 	NameAndLoc NameAndLoc
 	Type       TypeBase
 	Comment    string
+	File       *FileInfo
 }
 
 type Type_Enum struct {
 	NameAndLoc NameAndLoc
 	Comment    string
-	// todo 关联 lua 定义的table，其中是实际定义的枚举成员
+	Table      *TableInfo // 关联的 lua 表，其中是实际定义的枚举成员
+	File       *FileInfo
 }
 
 /////////////////// 以下是行注释语句片段 /////////////////////////
@@ -188,9 +192,10 @@ type AnnotateReturnState struct {
 //	例如：
 //	---@class Person : Human, Animal
 type AnnotateClassState struct {
-	NameAndLoc     NameAndLoc
-	ParentTypeList []TypeBase
-	Comment        string
+	NameAndLoc       NameAndLoc
+	GenericParamList []Type_KeyValue // ---@class List<T>
+	ParentTypeList   []TypeBase
+	Comment          string
 }
 
 // ---@field field_name? TypeName
@@ -227,3 +232,11 @@ type AnnotateEnumState struct {
 	NameAndLoc NameAndLoc
 	Comment    string
 }
+
+// ---@overload fun(param_name:TypeName{,param_name:TypeName}):TypeName{,TypeName}
+//
+//	例如：
+//	---@overload fun(a: number): number
+type AnnotateOverloadState struct {
+	Fun *Type_Fun
+}
@@ -38,6 +38,10 @@ const (
 	ATokenKwEnum                         // enum 枚举段关键值
 	ATokenKwEnumStart                    // start enum后面跟着的开始关键字，例如完整的为enum start
 	ATokenKwEnumEnd                      // end enum后面跟着的结束关键字，例如完整的为enum end
+	ATokenLcurly                         // {
+	ATokenRcurly                         // }
+	ATokenNumber                         // 数字字面值
+	ATokenLiteral                        // 引号包含的字符串字面值
 )
 
 var Annotate_Keywords = map[string]ATokenType{
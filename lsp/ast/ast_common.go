@@ -20,6 +20,7 @@ type TooManyErr struct {
 type CommentLine struct {
 	Str       string          // 注释内容。如果是长注释，会做预处理。
 	StartPos  common.Position // 注释开始的位置
+	EndPos    common.Position // 注释结束的位置
 	ShortFlag bool            // 是否是短注释，true表示短注释
 	HeadFlag  bool            // 是否为头部注释，一行开头就是注释
 }
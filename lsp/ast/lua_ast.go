@@ -10,7 +10,6 @@ type LuaAstBase struct {
 
 type ExpBase struct {
 	LuaAstBase
-	Type any // 关联的类型信息
 }
 
 type Stat interface {
@@ -52,7 +52,8 @@ type FloatExp struct {
 // StringExp 字符串
 type StringExp struct {
 	ExpBase
-	Str string
+	Str    string // 转义之后的实际值
+	RawStr string // 源码里的原始文本，包含引号或者长括号。由 Name 转换来的就是 Name 本身
 }
 
 // UnopExp := unop exp
@@ -88,6 +89,8 @@ type TableConstructorExp struct {
 // parlist ::= namelist [‘,’ ‘...’] | ‘...’
 //
 // namelist ::= Name {‘,’ Name}
+//
+// 位置到 end 结束。匿名函数从 function 开始，function 语句里的从 ( 开始，function 和名字属于语句
 type FuncDefExp struct {
 	ExpBase
 	ParList  []Token
@@ -9,7 +9,9 @@ type LabelInfo struct {
 	Loc     common.Location // 位置信息
 }
 
+// ReturnInfo 函数里的一个 return 语句
 type ReturnInfo struct {
+	Stat *RetStat
 }
 
 // 定义的lua函数，语义分析的结果
@@ -17,4 +19,25 @@ type FuncInfo struct {
 	Parent        *FuncInfo     // 父函数
 	LabelInfoList []*LabelInfo  // 函数内的label标签信息
 	ReturnList    []*ReturnInfo // 函数的返回值列表，支持多返回值
+
+	FuncDef   *FuncDefExp // 函数定义，主函数为 nil
+	Name      string      // 显示用的名字，例如 M:foo ，匿名函数为空
+	NameLoc   Location    // 名字的位置，匿名函数为 function 的位置
+	File      *FileInfo
+	Scope     *ScopeInfo // 函数体的作用域，参数也在这里
+	ParamList []*VarInfo // 参数，不包含 self
+	SelfVar   *VarInfo   // : 定义的函数里隐含的 self
+	SelfTable *TableInfo // function M:foo() 里 self 对应的 M 的字段信息
+	Comment   *AnnotateBlock
+	SubFuncs  []*FuncInfo
+}
+
+// IsColon 是否为 : 定义的函数
+func (f *FuncInfo) IsColon() bool {
+	return f.FuncDef != nil && f.FuncDef.IsColon
+}
+
+// IsVararg 是否有可变参数
+func (f *FuncInfo) IsVararg() bool {
+	return f.FuncDef == nil || f.FuncDef.IsVararg
 }
@@ -13,4 +13,30 @@ type ScopeInfo struct {
 
 	// 当前作用域内的函数信息
 	FuncInfoList []*FuncInfo
+
+	Loc  Location  // 作用域的范围
+	Func *FuncInfo // 所在的函数
+}
+
+// FindVar 在当前作用域以及父作用域查找 pos 位置可见的变量
+func (s *ScopeInfo) FindVar(name string, pos Position) *VarInfo {
+	for scope := s; scope != nil; scope = scope.Parent {
+		for i := len(scope.VarInfoList) - 1; i >= 0; i-- {
+			var varInfo = scope.VarInfoList[i]
+			if varInfo.Name == name && !pos.Before(varInfo.VisiblePos) {
+				return varInfo
+			}
+		}
+	}
+	return nil
+}
+
+// FindScope 查找包含 pos 的最内层作用域
+func (s *ScopeInfo) FindScope(pos Position) *ScopeInfo {
+	for _, sub := range s.SubScopes {
+		if !pos.Before(sub.Loc.Start) && !sub.Loc.End.Before(pos) {
+			return sub.FindScope(pos)
+		}
+	}
+	return s
 }
@@ -131,3 +131,9 @@ type RetStat struct {
 	LuaAstBase
 	ExpList []Exp
 }
+
+// BadStat 无法正确解析的语句，错误恢复时跳过的单词都在这个语句的范围内
+type BadStat struct {
+	LuaAstBase
+	Exp Exp // 已经解析出来的部分表达式，可能为 nil
+}
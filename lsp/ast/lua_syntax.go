@@ -0,0 +1,147 @@
+package ast
+
+import (
+	"mylua-lsp/lsp/common"
+	"sort"
+	"strings"
+)
+
+/*
+无损的具体语法树。普通的 ast 会丢掉空白、注释、括号、分隔符和关键字的位置，
+无损模式下保留所有的单词和单词之间的杂项(trivia)，可以逐字节还原源码。
+格式化、重构以及需要关键字位置的功能依赖这个。
+*/
+
+// TriviaKind 单词之间的杂项类型
+type TriviaKind uint8
+
+const (
+	TriviaWhitespace TriviaKind = iota // 空格，制表符等
+	TriviaNewline                      // 换行符 \n 或者 \r\n
+	TriviaComment                      // 注释，包含 -- 前缀
+	TriviaShebang                      // # 开头的第一行
+)
+
+// Trivia 单词之间的杂项
+type Trivia struct {
+	Kind TriviaKind
+	Text string // 原始文本
+	Loc  common.Location
+}
+
+// SyntaxToken 无损模式下的单词
+type SyntaxToken struct {
+	Token
+	Text          string   // 单词的原始文本，EOF 为空
+	LeadingTrivia []Trivia // 单词前面的杂项，文件末尾的杂项挂在 EOF 上
+	Owner         Stat     // 直接包含这个单词的最内层节点
+}
+
+// ConcreteTree 无损的语法树
+type ConcreteTree struct {
+	Block  *Block
+	Tokens []*SyntaxToken // 所有的单词，最后一个是 EOF
+	HasBOM bool           // 原始文本开头是否有 utf-8 bom
+
+	ownTokens map[Stat][]int32 // 节点直接拥有的单词下标
+}
+
+// NewConcreteTree 创建无损语法树，并把单词挂到节点上。
+// 后序遍历，子节点先认领自己范围内的单词，父节点再认领剩下的。
+func NewConcreteTree(block *Block, tokens []*SyntaxToken, hasBOM bool) *ConcreteTree {
+	var tree = &ConcreteTree{
+		Block:     block,
+		Tokens:    tokens,
+		HasBOM:    hasBOM,
+		ownTokens: map[Stat][]int32{},
+	}
+
+	var stack []Stat
+	Inspect(block, func(node Stat) bool {
+		if node != nil {
+			stack = append(stack, node)
+			return true
+		}
+		node = stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		tree.claimTokens(node)
+		return false
+	})
+
+	// 剩下的（例如 EOF）都归根节点
+	for i, tok := range tokens {
+		if tok.Owner == nil {
+			tok.Owner = block
+			tree.ownTokens[block] = append(tree.ownTokens[block], int32(i))
+		}
+	}
+	sortOwnTokens(tree.ownTokens[block])
+	return tree
+}
+
+func (t *ConcreteTree) claimTokens(node Stat) {
+	var loc = node.GetLoc()
+	var begin = sort.Search(len(t.Tokens), func(i int) bool {
+		return !t.Tokens[i].Loc.Start.Before(loc.Start)
+	})
+	for i := begin; i < len(t.Tokens); i++ {
+		var tok = t.Tokens[i]
+		if !tok.Loc.Start.Before(loc.End) || tok.TokenKind == TkEOF {
+			break
+		}
+		if tok.Owner == nil {
+			tok.Owner = node
+			t.ownTokens[node] = append(t.ownTokens[node], int32(i))
+		}
+	}
+}
+
+func sortOwnTokens(list []int32) {
+	sort.Slice(list, func(i, j int) bool { return list[i] < list[j] })
+}
+
+// Text 还原源码
+func (t *ConcreteTree) Text() string {
+	var builder strings.Builder
+	if t.HasBOM {
+		builder.WriteString("\xEF\xBB\xBF")
+	}
+	for _, tok := range t.Tokens {
+		for _, trivia := range tok.LeadingTrivia {
+			builder.WriteString(trivia.Text)
+		}
+		builder.WriteString(tok.Text)
+	}
+	return builder.String()
+}
+
+// NodeTokens 节点直接拥有的单词，不包括子节点的
+func (t *ConcreteTree) NodeTokens(node Stat) []*SyntaxToken {
+	var idxList = t.ownTokens[node]
+	var list = make([]*SyntaxToken, 0, len(idxList))
+	for _, idx := range idxList {
+		list = append(list, t.Tokens[idx])
+	}
+	return list
+}
+
+// FindToken 查找节点直接拥有的第一个 kind 类型的单词，例如 DoStat 的 end
+func (t *ConcreteTree) FindToken(node Stat, kind TkKind) *SyntaxToken {
+	for _, idx := range t.ownTokens[node] {
+		if t.Tokens[idx].TokenKind == kind {
+			return t.Tokens[idx]
+		}
+	}
+	return nil
+}
+
+// TokenAt 查找包含 pos 的单词，没有的话返回 nil
+func (t *ConcreteTree) TokenAt(pos common.Position) *SyntaxToken {
+	var idx = sort.Search(len(t.Tokens), func(i int) bool {
+		return pos.Before(t.Tokens[i].Loc.End)
+	})
+	if idx < len(t.Tokens) && !pos.Before(t.Tokens[idx].Loc.Start) {
+		return t.Tokens[idx]
+	}
+	return nil
+}
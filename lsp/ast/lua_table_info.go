@@ -0,0 +1,55 @@
+package ast
+
+// TableInfo lua 表的字段信息，语义分析的结果。
+// 表构造表达式会创建一个，对变量的字段赋值也会创建，例如 M.a = 1
+type TableInfo struct {
+	Loc        Location     // 表构造表达式的位置，没有时为第一次使用的位置
+	File       *FileInfo    // 定义所在的文件
+	FieldList  []*FieldInfo // 按定义的先后顺序
+	GlobalPath string       // 全局变量的表的路径，例如 M.sub ，需要合并其他文件定义的字段。局部的为空
+	ClassName  string       // 关联的 @class 或者 @enum 名字
+
+	fieldMap map[string]*FieldInfo
+}
+
+// FieldInfo 表的字段
+type FieldInfo struct {
+	Name     string
+	Loc      Location   // 定义的名字的位置
+	Table    *TableInfo // 所属的表
+	DefExp   Exp        // 定义的表达式，表构造里的 key 或者赋值语句左边的 TableAccessExp
+	ValueExp Exp        // 赋的值，可能为 nil
+	ValueIdx int        // ValueExp 是函数调用时，取第几个返回值
+
+	Comment  *AnnotateBlock // 定义的注释
+	AnnType  TypeBase       // @type 标记的类型
+	SubTable *TableInfo     // 字段本身作为表使用时的字段信息
+	IsMethod bool           // 是否为 function t:m() 定义的函数
+}
+
+// FieldRef 字段的一次引用，t.k t:m() 或者表构造里的 k ，也包括 t["k"] 和 {["k"] = v} 这样用字符串写的 key
+type FieldRef struct {
+	Loc     Location   // 名字的位置，字符串是引号里面的内容，有转义或者跨行时是整个字符串
+	Prefix  Exp        // 所属的表达式，表构造里的字段为 nil
+	Table   *TableInfo // 表构造里的字段所属的表
+	IsWrite bool       // 是否为赋值
+}
+
+// GetField 查找字段
+func (t *TableInfo) GetField(name string) *FieldInfo {
+	return t.fieldMap[name]
+}
+
+// AddField 添加字段，已经存在时返回原来的
+func (t *TableInfo) AddField(field *FieldInfo) *FieldInfo {
+	if old, ok := t.fieldMap[field.Name]; ok {
+		return old
+	}
+	if t.fieldMap == nil {
+		t.fieldMap = map[string]*FieldInfo{}
+	}
+	field.Table = t
+	t.fieldMap[field.Name] = field
+	t.FieldList = append(t.FieldList, field)
+	return field
+}
@@ -12,10 +12,66 @@ type FileInfo struct {
 	MainFunc    *FuncInfo           // ast生成的主function
 	GlobalMaps  map[string]*VarInfo // 所有的全局信息, 包含没有_G的与含有_G前缀的变量
 
+	CommentMap map[int]*CommentBlock // 注释块，key 是每块的最后一行
+	Annotate   *AnnotateFile         // 注释标记
+
+	NameVarMap  map[*NameExp]*VarInfo               // 每个名字引用的变量，包括全局变量
+	TableMap    map[*TableConstructorExp]*TableInfo // 表构造表达式对应的字段信息
+	FuncMap     map[*FuncDefExp]*FuncInfo           // 函数定义对应的函数信息
+	StatComment map[Stat]*AnnotateBlock             // 语句关联的注释
+	FuncList    []*FuncInfo                         // 所有的函数，按开始位置排序，第一个是主函数
+	ReturnExp   Exp                                 // 主函数 return 的第一个值，require 这个文件得到的值
+
+	FieldRefMap map[string][]FieldRef   // 字段名对应的所有引用，包括赋值
+	TypeRefMap  map[string][]NameAndLoc // 注释里引用的类型名，不包括 @class @alias @enum 的定义
+}
+
+// GetPath 文件的路径
+func (f *FileInfo) GetPath() string {
+	return f.Source.LuaPath
+}
+
+// VarKind 变量的种类
+type VarKind uint8
+
+const (
+	VarKindLocal     VarKind = iota // local 定义的变量
+	VarKindLocalFunc                // local function 定义的函数
+	VarKindParam                    // 函数参数
+	VarKindSelf                     // : 定义的函数里隐含的 self
+	VarKindFor                      // for 循环的变量
+	VarKindGlobal                   // 全局变量
+)
+
+// VarRef 变量的一次引用
+type VarRef struct {
+	Exp     *NameExp
+	IsWrite bool // 是否为赋值
 }
 
 // VarInfo 变量信息。lua 里定义的变量
 type VarInfo struct {
+	Name     string
+	Kind     VarKind
+	Loc      Location  // 定义的名字的位置，全局变量为第一次赋值的位置
+	Attr     LocalAttr // <const> <close>
+	File     *FileInfo
+	Scope    *ScopeInfo // 所在的作用域，全局变量为 nil
+	Func     *FuncInfo  // 定义所在的函数，参数属于它的函数
+	DefStat  Stat       // 定义的语句
+	ValueExp Exp        // 定义时赋的值，可能为 nil
+	ValueIdx int        // ValueExp 是函数调用时，取第几个返回值
+
+	Comment    *AnnotateBlock // 定义的注释
+	AnnType    TypeBase       // @type @param 标记的类型
+	Table      *TableInfo     // 作为表使用时的字段信息，可能为 nil
+	RefList    []VarRef       // 所有的引用，不包含定义
+	VisiblePos Position       // 从这个位置开始可见，局部变量定义的语句结束后才可见
+}
+
+// IsLocal 是否为局部变量，包括参数
+func (v *VarInfo) IsLocal() bool {
+	return v.Kind != VarKindGlobal
 }
 
 // Lua 表达式的值，语义分析的结果。主要是抽取常量级别的信息，语义分析就能推导出来的值。
@@ -0,0 +1,195 @@
+package ast
+
+// Visitor 遍历语法树的访问者，参考 go/ast 。
+// Visit 返回的 w 不为 nil 时，会继续用 w 访问 node 的子节点，之后再调用 w.Visit(nil)
+type Visitor interface {
+	Visit(node Stat) (w Visitor)
+}
+
+// Walk 深度优先遍历语法树，子节点按照源码中的先后顺序访问
+func Walk(v Visitor, node Stat) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Block:
+		walkStatList(v, n.Stats)
+
+	// 语句
+	case *LabelStat, *GotoStat, *BreakStat:
+		// 没有子节点
+	case *DoStat:
+		walkBlock(v, n.Block)
+	case *IfStat:
+		for i, exp := range n.Exps {
+			walkExp(v, exp)
+			if i < len(n.Blocks) {
+				walkBlock(v, n.Blocks[i])
+			}
+		}
+		for i := len(n.Exps); i < len(n.Blocks); i++ {
+			walkBlock(v, n.Blocks[i])
+		}
+	case *WhileStat:
+		walkExp(v, n.Exp)
+		walkBlock(v, n.Block)
+	case *RepeatStat:
+		walkBlock(v, n.Block)
+		walkExp(v, n.Exp)
+	case *ForNumStat:
+		walkExp(v, n.InitExp)
+		walkExp(v, n.LimitExp)
+		walkExp(v, n.StepExp)
+		walkBlock(v, n.Block)
+	case *ForInStat:
+		walkExpList(v, n.ExpList)
+		walkBlock(v, n.Block)
+	case *AssignStat:
+		walkExpList(v, n.VarList)
+		walkExpList(v, n.ExpList)
+	case *LocalVarDeclStat:
+		walkExpList(v, n.ExpList)
+	case *LocalFuncDefStat:
+		if n.FuncDef != nil {
+			Walk(v, n.FuncDef)
+		}
+	case *RetStat:
+		walkExpList(v, n.ExpList)
+	case *BadStat:
+		walkExp(v, n.Exp)
+
+	// 表达式
+	case *NilExp, *TrueExp, *FalseExp, *VarargExp, *IntegerExp, *FloatExp,
+		*StringExp, *NameExp, *BadExpr:
+		// 没有子节点
+	case *UnopExp:
+		walkExp(v, n.Exp)
+	case *BinopExp:
+		walkExp(v, n.Exp1)
+		walkExp(v, n.Exp2)
+	case *TableConstructorExp:
+		for i, val := range n.ValExps {
+			if i < len(n.KeyExps) {
+				walkExp(v, n.KeyExps[i])
+			}
+			walkExp(v, val)
+		}
+	case *FuncDefExp:
+		walkBlock(v, n.Block)
+	case *ParensExp:
+		walkExp(v, n.Exp)
+	case *TableAccessExp:
+		walkExp(v, n.PrefixExp)
+		walkExp(v, n.KeyExp)
+	case *FuncCallExp:
+		walkExp(v, n.PrefixExp)
+		if n.NameExp != nil {
+			Walk(v, n.NameExp)
+		}
+		walkExpList(v, n.Args)
+	}
+
+	v.Visit(nil)
+}
+
+func walkBlock(v Visitor, block *Block) {
+	if block != nil {
+		Walk(v, block)
+	}
+}
+
+func walkExp(v Visitor, exp Exp) {
+	if exp != nil {
+		Walk(v, exp)
+	}
+}
+
+func walkExpList(v Visitor, list []Exp) {
+	for _, exp := range list {
+		walkExp(v, exp)
+	}
+}
+
+func walkStatList(v Visitor, list []Stat) {
+	for _, stat := range list {
+		if stat != nil {
+			Walk(v, stat)
+		}
+	}
+}
+
+type inspector func(Stat) bool
+
+func (f inspector) Visit(node Stat) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect 深度优先遍历语法树，f(node) 返回 true 时继续访问子节点。子节点访问完后会调用 f(nil)
+func Inspect(node Stat, f func(Stat) bool) {
+	Walk(inspector(f), node)
+}
+
+// ShiftLines 把节点以及所有子节点的位置整体移动 delta 行，增量解析复用节点时用到
+func ShiftLines(node Stat, delta int32) {
+	if delta == 0 {
+		return
+	}
+	var shiftLoc = func(loc *Location) {
+		loc.Start.Line += delta
+		loc.End.Line += delta
+	}
+	var shiftTokens = func(list []Token) {
+		for i := range list {
+			shiftLoc(&list[i].Loc)
+		}
+	}
+	Inspect(node, func(n Stat) bool {
+		if n == nil {
+			return false
+		}
+		var loc = n.GetLoc()
+		shiftLoc(&loc)
+		n.SetLoc(loc)
+		switch n := n.(type) {
+		case *LabelStat:
+			shiftLoc(&n.Name.Loc)
+		case *GotoStat:
+			shiftLoc(&n.Name.Loc)
+		case *ForNumStat:
+			shiftLoc(&n.VarName.Loc)
+		case *ForInStat:
+			shiftTokens(n.NameList)
+		case *LocalVarDeclStat:
+			shiftTokens(n.NameList)
+		case *LocalFuncDefStat:
+			shiftLoc(&n.Name.Loc)
+		case *FuncDefExp:
+			shiftTokens(n.ParList)
+		}
+		return true
+	})
+}
+
+// parentLinker 遍历时记录祖先节点，设置每个节点的父节点
+type parentLinker struct {
+	stack []Stat
+}
+
+func (l *parentLinker) Visit(node Stat) Visitor {
+	if node == nil {
+		l.stack = l.stack[:len(l.stack)-1]
+		return nil
+	}
+	node.SetParent(l.stack[len(l.stack)-1])
+	l.stack = append(l.stack, node)
+	return l
+}
+
+// SetParents 设置 node 的父节点为 parent ，并且设置所有子节点的父节点
+func SetParents(node Stat, parent Stat) {
+	Walk(&parentLinker{stack: []Stat{parent}}, node)
+}
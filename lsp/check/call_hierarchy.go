@@ -0,0 +1,239 @@
+package check
+
+import (
+	"mylua-lsp/lsp/ast"
+	"slices"
+	"sort"
+)
+
+// CallEdge 两个函数之间的调用，同一对函数的多次调用合并在一起
+type CallEdge struct {
+	Func  *ast.FuncInfo      // 对方的函数，调用的函数解析不出来时为 nil
+	Name  string             // 解析不出来时调用使用的名字
+	File  *ast.FileInfo      // 调用所在的文件
+	Calls []*ast.FuncCallExp // 所有调用的地方
+}
+
+// callSite 一次调用，callee 为 nil 时表示解析不出来的动态调用
+type callSite struct {
+	caller *ast.FuncInfo
+	callee *ast.FuncInfo
+	call   *ast.FuncCallExp
+}
+
+// callGraph 工程的调用关系。每个文件的调用在使用时才收集，修改文件时只丢掉这个文件的调用，
+// 以及其他文件里调用了这个文件的函数的调用。解析不出来的调用在所在的文件修改后才会重新解析
+type callGraph struct {
+	fileSites map[string][]callSite // 每个文件里的调用，还没有收集的文件不在里面
+	outgoing  map[*ast.FuncInfo][]callSite
+	incoming  map[*ast.FuncInfo][]callSite
+}
+
+func newCallGraph() *callGraph {
+	return &callGraph{
+		fileSites: map[string][]callSite{},
+		outgoing:  map[*ast.FuncInfo][]callSite{},
+		incoming:  map[*ast.FuncInfo][]callSite{},
+	}
+}
+
+// addFile 收集文件里的调用
+func (g *callGraph) addFile(p *Project, file *ast.FileInfo) {
+	var c = &callCollector{p: p, file: file, funcStack: []*ast.FuncInfo{file.MainFunc}}
+	ast.Walk(c, file.Block)
+	g.fileSites[file.GetPath()] = c.sites
+	for _, site := range c.sites {
+		g.outgoing[site.caller] = append(g.outgoing[site.caller], site)
+		if site.callee != nil {
+			g.incoming[site.callee] = append(g.incoming[site.callee], site)
+		}
+	}
+}
+
+// removeFile 文件删除或者替换前调用，丢掉它的调用以及调用了它的函数的其他文件的调用
+func (g *callGraph) removeFile(file *ast.FileInfo) {
+	var stale = []string{file.GetPath()}
+	for _, funcInfo := range file.FuncList {
+		for _, site := range g.incoming[funcInfo] {
+			if path := site.caller.File.GetPath(); !slices.Contains(stale, path) {
+				stale = append(stale, path)
+			}
+		}
+	}
+	for _, path := range stale {
+		g.dropFile(path)
+	}
+}
+
+// dropFile 丢掉一个文件里的调用，使用时重新收集
+func (g *callGraph) dropFile(path string) {
+	var sites, ok = g.fileSites[path]
+	if !ok {
+		return
+	}
+	delete(g.fileSites, path)
+	for _, site := range sites {
+		delete(g.outgoing, site.caller)
+		if site.callee == nil {
+			continue
+		}
+		var list = slices.DeleteFunc(g.incoming[site.callee], func(s callSite) bool {
+			return s.caller.File.GetPath() == path
+		})
+		if len(list) == 0 {
+			delete(g.incoming, site.callee)
+		} else {
+			g.incoming[site.callee] = list
+		}
+	}
+}
+
+// CallHierarchyFunc 位置上的函数，可以是函数定义的名字，也可以是引用函数的变量或者字段
+func (p *Project) CallHierarchyFunc(file *ast.FileInfo, pos Position) *ast.FuncInfo {
+	for _, funcInfo := range file.FuncList[1:] {
+		if inLoc(funcInfo.NameLoc, pos) {
+			return funcInfo
+		}
+	}
+	var sym = p.SymbolAt(file, pos)
+	if sym == nil {
+		return nil
+	}
+	switch sym.Kind {
+	case SymbolVar:
+		if funcInfo, ok := p.TypeOfVar(sym.Var).(*ast.FuncInfo); ok {
+			return funcInfo
+		}
+	case SymbolMember:
+		for _, member := range sym.Members {
+			if funcInfo, ok := p.TypeOfMember(member).(*ast.FuncInfo); ok {
+				return funcInfo
+			}
+		}
+	}
+	return nil
+}
+
+// IncomingCalls 调用了 funcInfo 的函数，文件顶层的调用属于主函数
+func (p *Project) IncomingCalls(funcInfo *ast.FuncInfo) []*CallEdge {
+	var edges []*CallEdge
+	var edgeMap = map[*ast.FuncInfo]*CallEdge{}
+	var sites = slices.Clone(p.callGraph().incoming[funcInfo])
+	sort.SliceStable(sites, func(i, j int) bool {
+		var a, b = sites[i].caller.File.GetPath(), sites[j].caller.File.GetPath()
+		return a < b || a == b && sites[i].call.Loc.Start.Before(sites[j].call.Loc.Start)
+	})
+	for _, site := range sites {
+		var edge = edgeMap[site.caller]
+		if edge == nil {
+			edge = &CallEdge{Func: site.caller, File: site.caller.File}
+			edgeMap[site.caller] = edge
+			edges = append(edges, edge)
+		}
+		edge.Calls = append(edge.Calls, site.call)
+	}
+	return edges
+}
+
+// OutgoingCalls funcInfo 里调用的函数，解析不出来的调用按名字合并后放在最后
+func (p *Project) OutgoingCalls(funcInfo *ast.FuncInfo) []*CallEdge {
+	var edges, unresolved []*CallEdge
+	var edgeMap = map[*ast.FuncInfo]*CallEdge{}
+	var nameMap = map[string]*CallEdge{}
+	for _, site := range p.callGraph().outgoing[funcInfo] {
+		var edge *CallEdge
+		if site.callee != nil {
+			edge = edgeMap[site.callee]
+			if edge == nil {
+				edge = &CallEdge{Func: site.callee, File: funcInfo.File}
+				edgeMap[site.callee] = edge
+				edges = append(edges, edge)
+			}
+		} else {
+			var name = callName(site.call)
+			edge = nameMap[name]
+			if edge == nil {
+				edge = &CallEdge{Name: name, File: funcInfo.File}
+				nameMap[name] = edge
+				unresolved = append(unresolved, edge)
+			}
+		}
+		edge.Calls = append(edge.Calls, site.call)
+	}
+	return append(edges, unresolved...)
+}
+
+// CalleeLoc 调用的函数名的位置，方法调用时是方法名
+func CalleeLoc(call *ast.FuncCallExp) Location {
+	if call.NameExp != nil {
+		return call.NameExp.Loc
+	}
+	return call.PrefixExp.GetLoc()
+}
+
+// callGraph 工程的调用关系，收集还没有收集过的文件
+func (p *Project) callGraph() *callGraph {
+	for path, file := range p.fileMap {
+		if _, ok := p.calls.fileSites[path]; !ok {
+			p.calls.addFile(p, file)
+		}
+	}
+	return p.calls
+}
+
+// callCollector 遍历语法树收集调用，记录每个调用所在的函数
+type callCollector struct {
+	p         *Project
+	file      *ast.FileInfo
+	nodes     []ast.Stat
+	funcStack []*ast.FuncInfo
+	sites     []callSite
+}
+
+// Visit 进入函数定义时压栈，子节点访问完后出栈
+func (c *callCollector) Visit(node ast.Stat) ast.Visitor {
+	if node == nil {
+		var last = c.nodes[len(c.nodes)-1]
+		c.nodes = c.nodes[:len(c.nodes)-1]
+		if _, ok := last.(*ast.FuncDefExp); ok {
+			c.funcStack = c.funcStack[:len(c.funcStack)-1]
+		}
+		return nil
+	}
+	c.nodes = append(c.nodes, node)
+	switch n := node.(type) {
+	case *ast.FuncDefExp:
+		var funcInfo = c.file.FuncMap[n]
+		if funcInfo == nil {
+			funcInfo = c.funcStack[len(c.funcStack)-1]
+		}
+		c.funcStack = append(c.funcStack, funcInfo)
+	case *ast.FuncCallExp:
+		var caller = c.funcStack[len(c.funcStack)-1]
+		var callees = c.p.calleeFuncs(c.file, n)
+		if len(callees) == 0 {
+			c.sites = append(c.sites, callSite{caller: caller, call: n})
+		}
+		for _, callee := range callees {
+			c.sites = append(c.sites, callSite{caller: caller, callee: callee, call: n})
+		}
+	}
+	return c
+}
+
+// calleeFuncs 调用的函数定义。方法调用时对象的类型可能有多个同名的方法，都算作调用
+func (p *Project) calleeFuncs(file *ast.FileInfo, call *ast.FuncCallExp) []*ast.FuncInfo {
+	if call.NameExp == nil {
+		if funcInfo, ok := p.TypeOfExp(file, call.PrefixExp).(*ast.FuncInfo); ok {
+			return []*ast.FuncInfo{funcInfo}
+		}
+		return nil
+	}
+	var list []*ast.FuncInfo
+	for _, member := range p.ExpMembers(file, call.PrefixExp, call.NameExp.Str) {
+		if funcInfo, ok := p.TypeOfMember(member).(*ast.FuncInfo); ok && !slices.Contains(list, funcInfo) {
+			list = append(list, funcInfo)
+		}
+	}
+	return list
+}
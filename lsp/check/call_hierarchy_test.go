@@ -0,0 +1,118 @@
+package check
+
+import (
+	"fmt"
+	"mylua-lsp/lsp/ast"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// funcNamed 文件里名字为 name 的函数，空名字是主函数
+func funcNamed(t *testing.T, p *Project, path string, name string) *ast.FuncInfo {
+	t.Helper()
+	var file = p.GetFile(path)
+	if name == "" {
+		return file.MainFunc
+	}
+	for _, funcInfo := range file.FuncList[1:] {
+		if funcInfo.Name == name {
+			return funcInfo
+		}
+	}
+	t.Fatalf("no function %s in %s", name, path)
+	return nil
+}
+
+// edgeStrings 调用关系的文字表示，每个是 "文件 函数名 调用的位置..." ，主函数的名字为 main
+func edgeStrings(edges []*CallEdge) []string {
+	var list []string
+	for _, edge := range edges {
+		var name = edge.Name
+		if edge.Func != nil {
+			name = edge.Func.Name
+			if edge.Func == edge.Func.File.MainFunc {
+				name = "main"
+			}
+		}
+		var calls []string
+		for _, call := range edge.Calls {
+			var loc = CalleeLoc(call)
+			calls = append(calls, fmt.Sprintf("%d:%d", loc.Start.Line, loc.Start.Column))
+		}
+		list = append(list, fmt.Sprintf("%s %s %s", edge.File.GetPath(), name, strings.Join(calls, ",")))
+	}
+	return list
+}
+
+var callFiles = map[string]string{
+	"a.lua": "local M = {}\nfunction M.helper() end\nfunction M:stop() end\nfunction M:run()\n  M.helper()\n  self:stop()\n  M.helper()\n  unknown()\n  obj.dynamic()\n  unknown()\nend\nM:run()\nLib.start()\nreturn M\n",
+	"b.lua": "Lib = {}\nfunction Lib.start() end\nlocal function boot()\n  Lib.start()\nend\nboot()\n",
+}
+
+// 同一个函数的多次调用合并，解析不出来的调用按名字合并后放在最后
+func TestOutgoingCalls(t *testing.T) {
+	var p = newTestProject(callFiles)
+	var got = edgeStrings(p.OutgoingCalls(funcNamed(t, p, "a.lua", "M:run")))
+	var want = []string{"a.lua M.helper 4:2,6:2", "a.lua M:stop 5:7", "a.lua unknown 7:2,9:2", "a.lua obj.dynamic 8:2"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	// 文件顶层的调用属于主函数，包括 : 调用的方法和其他文件里的函数
+	got = edgeStrings(p.OutgoingCalls(funcNamed(t, p, "a.lua", "")))
+	want = []string{"a.lua M:run 11:2", "a.lua Lib.start 12:0"}
+	if !slices.Equal(got, want) {
+		t.Errorf("main: got %q, want %q", got, want)
+	}
+}
+
+func TestIncomingCalls(t *testing.T) {
+	var p = newTestProject(callFiles)
+	var tests = []struct {
+		path string
+		name string
+		want []string
+	}{
+		{"a.lua", "M.helper", []string{"a.lua M:run 4:2,6:2"}},
+		{"a.lua", "M:stop", []string{"a.lua M:run 5:7"}},
+		{"a.lua", "M:run", []string{"a.lua main 11:2"}},
+		{"b.lua", "Lib.start", []string{"a.lua main 12:0", "b.lua boot 3:2"}},
+	}
+	for _, tt := range tests {
+		var got = edgeStrings(p.IncomingCalls(funcNamed(t, p, tt.path, tt.name)))
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// 修改文件后只重新收集它的调用，以及调用了它的函数的文件
+func TestCallGraphUpdate(t *testing.T) {
+	var p = newTestProject(callFiles)
+	p.IncomingCalls(funcNamed(t, p, "b.lua", "Lib.start"))
+	p.UpdateFile(analyzeText("b.lua", "Lib = {}\n\nfunction Lib.start() end\n"))
+	if _, ok := p.calls.fileSites["a.lua"]; ok {
+		t.Error("calls into the replaced file are kept")
+	}
+	var got = edgeStrings(p.IncomingCalls(funcNamed(t, p, "b.lua", "Lib.start")))
+	if want := []string{"a.lua main 12:0"}; !slices.Equal(got, want) {
+		t.Errorf("after update: got %q, want %q", got, want)
+	}
+
+	p.UpdateFile(analyzeText("c.lua", "print(1)\n"))
+	p.callGraph()
+	var sites = p.calls.fileSites["a.lua"]
+	p.UpdateFile(analyzeText("c.lua", "print(2)\n"))
+	p.callGraph()
+	if len(sites) == 0 || &p.calls.fileSites["a.lua"][0] != &sites[0] {
+		t.Error("calls of an unrelated file are collected again")
+	}
+
+	// 删除的文件里的函数解析不到了，变成按名字合并的调用
+	p.RemoveFile("b.lua")
+	var edges = p.OutgoingCalls(funcNamed(t, p, "a.lua", ""))
+	got = edgeStrings(edges)
+	if want := []string{"a.lua M:run 11:2", "a.lua Lib.start 12:0"}; !slices.Equal(got, want) || edges[1].Func != nil {
+		t.Errorf("after remove: got %q, want %q unresolved", got, want)
+	}
+}
@@ -0,0 +1,521 @@
+package check
+
+import (
+	"fmt"
+	"mylua-lsp/lsp/ast"
+	"mylua-lsp/lsp/common"
+	"strings"
+)
+
+// CodeActionKind 代码操作的种类
+type CodeActionKind uint8
+
+const (
+	CodeActionQuickFix CodeActionKind = iota // 修复问题
+	CodeActionRewrite                        // 改写代码，不改变语义
+)
+
+// TextEdit 对文件的一处修改，把 Loc 范围内的文本替换成 Text
+type TextEdit struct {
+	File *ast.FileInfo
+	Loc  Location
+	Text string
+}
+
+// CodeAction 代码操作，修改可能涉及多个文件
+type CodeAction struct {
+	Title string
+	Kind  CodeActionKind
+	Codes []string // 修复的诊断编号
+	Loc   Location // 问题所在的位置，和诊断的范围相交时关联到诊断
+	Edits []TextEdit
+}
+
+// actionCollector 收集范围内的代码操作
+type actionCollector struct {
+	p    *Project
+	file *ast.FileInfo
+	loc  Location
+	opts *DiagnosticOptions
+	list []*CodeAction
+}
+
+// overlap 两个范围是否相交，包括首尾相接
+func overlap(a, b Location) bool {
+	return !a.End.Before(b.Start) && !b.End.Before(a.Start)
+}
+
+// CodeActions 范围内可用的代码操作
+func (p *Project) CodeActions(file *ast.FileInfo, loc Location, opts *DiagnosticOptions) []*CodeAction {
+	var c = &actionCollector{p: p, file: file, loc: loc, opts: opts}
+	c.scopeActions(file.MainFunc.Scope)
+	c.paramActions()
+	ast.Inspect(file.Block, func(node ast.Stat) bool {
+		if node == nil || !overlap(node.GetLoc(), loc) {
+			return false
+		}
+		switch n := node.(type) {
+		case *ast.NameExp:
+			if varInfo := file.NameVarMap[n]; varInfo != nil && !varInfo.IsLocal() && overlap(n.Loc, loc) {
+				c.declareLocal(n, varInfo)
+			}
+		case *ast.TableAccessExp:
+			c.addClassField(n)
+		case *ast.FuncCallExp:
+			c.methodCall(n)
+		}
+		return true
+	})
+	return c.list
+}
+
+func (c *actionCollector) add(action *CodeAction) {
+	c.list = append(c.list, action)
+}
+
+// scopeActions 范围内定义的没有使用的局部变量
+func (c *actionCollector) scopeActions(scope *ast.ScopeInfo) {
+	for _, varInfo := range scope.VarInfoList {
+		if varInfo.Kind != ast.VarKindSelf && overlap(varInfo.Loc, c.loc) {
+			c.removeUnused(varInfo)
+			c.prefixUnused(varInfo)
+		}
+	}
+	// for 循环的变量在循环体的作用域里，名字不在作用域的范围内
+	for _, sub := range scope.SubScopes {
+		c.scopeActions(sub)
+	}
+}
+
+// unusedCode 没有使用的变量对应的诊断编号
+func unusedCode(varInfo *ast.VarInfo) string {
+	switch varInfo.Kind {
+	case ast.VarKindParam:
+		return DiagUnusedParam
+	case ast.VarKindLocalFunc:
+		return DiagUnusedFunction
+	}
+	return DiagUnusedLocal
+}
+
+// hasRead 变量是否被读取过，局部函数在自己的函数体里的引用不算
+func hasRead(varInfo *ast.VarInfo) bool {
+	for _, ref := range varInfo.RefList {
+		if ref.IsWrite {
+			continue
+		}
+		if stat, ok := varInfo.DefStat.(*ast.LocalFuncDefStat); ok && inLoc(stat.FuncDef.Loc, ref.Exp.Loc.Start) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// removeUnused 删除没有使用的局部变量或者局部函数的定义，变量的值有副作用时不删除
+func (c *actionCollector) removeUnused(varInfo *ast.VarInfo) {
+	if hasRead(varInfo) || varInfo.Kind == ast.VarKindLocal && len(varInfo.RefList) > 0 {
+		return
+	}
+	var title string
+	switch stat := varInfo.DefStat.(type) {
+	case *ast.LocalVarDeclStat:
+		if varInfo.Kind != ast.VarKindLocal || len(stat.NameList) != 1 {
+			return
+		}
+		for _, exp := range stat.ExpList {
+			if !isPure(exp) {
+				return
+			}
+		}
+		title = fmt.Sprintf("Remove unused local '%s'", varInfo.Name)
+	case *ast.LocalFuncDefStat:
+		title = fmt.Sprintf("Remove unused function '%s'", varInfo.Name)
+	default:
+		return
+	}
+	c.add(&CodeAction{
+		Title: title,
+		Kind:  CodeActionQuickFix,
+		Codes: []string{unusedCode(varInfo)},
+		Loc:   varInfo.Loc,
+		Edits: []TextEdit{deleteStat(c.file, varInfo.DefStat)},
+	})
+}
+
+// isPure 表达式求值是否没有副作用，函数调用和索引可能调用元方法，都算有副作用
+func isPure(exp ast.Exp) bool {
+	switch exp := exp.(type) {
+	case *ast.NilExp, *ast.TrueExp, *ast.FalseExp, *ast.IntegerExp, *ast.FloatExp, *ast.StringExp,
+		*ast.VarargExp, *ast.NameExp, *ast.FuncDefExp:
+		return true
+	case *ast.ParensExp:
+		return isPure(exp.Exp)
+	case *ast.UnopExp:
+		return isPure(exp.Exp)
+	case *ast.BinopExp:
+		return isPure(exp.Exp1) && isPure(exp.Exp2)
+	case *ast.TableConstructorExp:
+		for i, val := range exp.ValExps {
+			if key := exp.KeyExps[i]; key != nil && !isPure(key) || !isPure(val) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// deleteStat 删除语句以及前面的注释，语句独占几行时删除整行
+func deleteStat(file *ast.FileInfo, stat ast.Stat) TextEdit {
+	var source = file.Source
+	var loc = stat.GetLoc()
+	if comment := file.StatComment[stat]; comment != nil && !comment.TailFlag {
+		loc.Start = comment.Loc.Start
+	}
+	var before = source.GetOneLine(loc.Start.GetLine())[:loc.Start.GetColumn()]
+	var after = strings.TrimSpace(source.GetOneLine(loc.End.GetLine())[loc.End.GetColumn():])
+	if strings.TrimSpace(before) != "" || after != "" && !strings.HasPrefix(after, "--") {
+		return TextEdit{File: file, Loc: stat.GetLoc()}
+	}
+	loc.Start.Column = 0
+	if loc.End.GetLine()+1 < source.GetLineNum() {
+		loc.End = Position{Line: loc.End.Line + 1}
+	} else {
+		loc.End.Column = int32(len(source.GetOneLine(loc.End.GetLine())))
+	}
+	return TextEdit{File: file, Loc: loc}
+}
+
+// prefixUnused 没有读取的变量名前面加上 _ ，表示有意不使用
+func (c *actionCollector) prefixUnused(varInfo *ast.VarInfo) {
+	var newName = "_" + varInfo.Name
+	if hasRead(varInfo) || strings.HasPrefix(varInfo.Name, "_") || c.p.checkLocalRename(varInfo, newName) != nil {
+		return
+	}
+	var edits = []TextEdit{{File: c.file, Loc: varInfo.Loc, Text: newName}}
+	for _, ref := range varInfo.RefList {
+		edits = append(edits, TextEdit{File: c.file, Loc: ref.Exp.Loc, Text: newName})
+	}
+	if varInfo.Kind == ast.VarKindParam {
+		if state := FindParamState(varInfo.Func.Comment, varInfo.Name); state != nil {
+			edits = append(edits, TextEdit{File: c.file, Loc: state.NameAndLoc.Loc, Text: newName})
+		}
+	}
+	c.add(&CodeAction{
+		Title: fmt.Sprintf("Rename '%s' to '%s'", varInfo.Name, newName),
+		Kind:  CodeActionQuickFix,
+		Codes: []string{unusedCode(varInfo)},
+		Loc:   varInfo.Loc,
+		Edits: edits,
+	})
+}
+
+// declareLocal 把全局变量改成局部变量。创建全局变量的赋值前面加上 local ，
+// 读取没有定义的全局变量时在语句前面定义同名的局部变量
+func (c *actionCollector) declareLocal(exp *ast.NameExp, varInfo *ast.VarInfo) {
+	if assign, ok := varInfo.DefStat.(*ast.AssignStat); ok && inLoc(assign.Loc, exp.Loc.Start) {
+		if names := c.localNames(assign); names != nil && isTarget(assign, exp) {
+			c.add(&CodeAction{
+				Title: fmt.Sprintf("Declare %s as local", strings.Join(names, ", ")),
+				Kind:  CodeActionQuickFix,
+				Codes: []string{DiagGlobalInFunction},
+				Loc:   exp.Loc,
+				Edits: []TextEdit{{File: c.file, Loc: Location{Start: assign.Loc.Start, End: assign.Loc.Start}, Text: "local "}},
+			})
+		}
+		return
+	}
+	if isTarget(exp.GetParent(), exp) || c.p.isDefinedGlobal(exp.Name, c.opts) {
+		return
+	}
+	if similar := c.p.similarName(c.file, exp, c.opts); similar != "" {
+		c.add(&CodeAction{
+			Title: fmt.Sprintf("Change to '%s'", similar),
+			Kind:  CodeActionQuickFix,
+			Codes: []string{DiagUndefinedGlobal},
+			Loc:   exp.Loc,
+			Edits: []TextEdit{{File: c.file, Loc: exp.Loc, Text: similar}},
+		})
+	}
+	var stat = outerStat(exp)
+	var source = c.file.Source
+	var start = stat.GetLoc().Start
+	var edit TextEdit
+	if indent, ok := lineIndent(source, start); ok {
+		edit = insertLine(c.file, start.GetLine(), indent+"local "+exp.Name)
+	} else {
+		edit = TextEdit{File: c.file, Loc: Location{Start: start, End: start}, Text: "local " + exp.Name + "; "}
+	}
+	c.add(&CodeAction{
+		Title: fmt.Sprintf("Declare '%s' as local", exp.Name),
+		Kind:  CodeActionQuickFix,
+		Codes: []string{DiagUndefinedGlobal},
+		Loc:   exp.Loc,
+		Edits: []TextEdit{edit},
+	})
+}
+
+// isTarget exp 是否为赋值语句左边的一个变量
+func isTarget(stat ast.Stat, exp ast.Exp) bool {
+	var assign, ok = stat.(*ast.AssignStat)
+	if !ok {
+		return false
+	}
+	for _, target := range assign.VarList {
+		if target == exp {
+			return true
+		}
+	}
+	return false
+}
+
+// localNames 赋值语句加上 local 后定义的变量名。左边都是这个文件里第一次赋值的全局变量，
+// 并且所有的引用都在语句后面的同一个作用域里时才能改，否则返回 nil
+func (c *actionCollector) localNames(assign *ast.AssignStat) []string {
+	var scope = c.file.MainFunc.Scope.FindScope(assign.Loc.Start)
+	var names []string
+	for _, target := range assign.VarList {
+		var name, ok = target.(*ast.NameExp)
+		if !ok {
+			return nil
+		}
+		// 其他文件用到的全局变量不能改成局部的
+		var varInfo = c.file.NameVarMap[name]
+		if varInfo == nil || varInfo.IsLocal() || varInfo.DefStat != assign || len(c.p.globalMap[name.Name]) > 1 {
+			return nil
+		}
+		for _, ref := range varInfo.RefList {
+			var pos = ref.Exp.Loc.Start
+			if !assign.Loc.End.Before(pos) || !inLoc(scope.Loc, pos) {
+				return nil
+			}
+		}
+		names = append(names, "'"+name.Name+"'")
+	}
+	return names
+}
+
+// outerStat 包含节点的语句，直接属于某个语句块
+func outerStat(node ast.Stat) ast.Stat {
+	for node.GetParent() != nil {
+		if _, ok := node.GetParent().(*ast.Block); ok {
+			break
+		}
+		node = node.GetParent()
+	}
+	return node
+}
+
+// lineIndent pos 所在行前面的缩进，pos 前面有其他内容时返回 false
+func lineIndent(source *common.LuaSource, pos Position) (string, bool) {
+	var before = source.GetOneLine(pos.GetLine())[:pos.GetColumn()]
+	if strings.TrimLeft(before, " \t") != "" {
+		return "", false
+	}
+	return before, true
+}
+
+// leadingSpace 行首的空白
+func leadingSpace(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
+// lineEnding 文件使用的换行符
+func lineEnding(source *common.LuaSource) string {
+	if eol := source.GetLineEnding(0); eol != "" {
+		return eol
+	}
+	return "\n"
+}
+
+// insertLine 在第 line 行前面插入一行，line 超过最后一行时追加到文件末尾
+func insertLine(file *ast.FileInfo, line int, text string) TextEdit {
+	var source = file.Source
+	var eol = lineEnding(source)
+	if line >= source.GetLineNum() {
+		var last = source.GetLineNum() - 1
+		var pos = Position{Line: int32(last), Column: int32(len(source.GetOneLine(last)))}
+		return TextEdit{File: file, Loc: Location{Start: pos, End: pos}, Text: eol + text}
+	}
+	var pos = Position{Line: int32(line)}
+	return TextEdit{File: file, Loc: Location{Start: pos, End: pos}, Text: text + eol}
+}
+
+// paramActions 给光标所在的函数没有注释的参数加上 @param
+func (c *actionCollector) paramActions() {
+	var target *ast.FuncInfo
+	var stat ast.Stat
+	for _, funcInfo := range c.file.FuncList {
+		if funcInfo.FuncDef == nil {
+			continue
+		}
+		var parent = funcInfo.FuncDef.GetParent()
+		if _, ok := parent.GetParent().(*ast.Block); !ok {
+			continue
+		}
+		// 光标在函数定义的第一行
+		var start = parent.GetLoc().Start
+		var line = funcInfo.FuncDef.Loc.Start.GetLine()
+		var loc = Location{Start: start, End: Position{Line: int32(line), Column: int32(len(c.file.Source.GetOneLine(line)))}}
+		if overlap(loc, c.loc) {
+			target, stat = funcInfo, parent
+		}
+	}
+	if target == nil || target.Comment != nil && target.Comment.TailFlag {
+		return
+	}
+	var missing []string
+	for _, param := range target.ParamList {
+		if FindParamState(target.Comment, param.Name) == nil {
+			missing = append(missing, param.Name)
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+	var indent, ok = lineIndent(c.file.Source, stat.GetLoc().Start)
+	if !ok {
+		return
+	}
+	var line = stat.GetLoc().Start.GetLine()
+	if target.Comment != nil {
+		line = target.Comment.Loc.End.GetLine() + 1
+	}
+	var lines []string
+	for _, name := range missing {
+		lines = append(lines, indent+"---@param "+name+" any")
+	}
+	var title = fmt.Sprintf("Add @param for '%s'", missing[0])
+	if len(missing) > 1 {
+		title = "Add @param for all parameters"
+	}
+	c.add(&CodeAction{
+		Title: title,
+		Kind:  CodeActionRewrite,
+		Loc:   target.NameLoc,
+		Edits: []TextEdit{insertLine(c.file, line, strings.Join(lines, lineEnding(c.file.Source)))},
+	})
+}
+
+// addClassField 访问类里没有的字段时，在类的注释里加上 @field
+func (c *actionCollector) addClassField(exp *ast.TableAccessExp) {
+	var key, ok = exp.KeyExp.(*ast.StringExp)
+	if !ok || !isNameKey(key) || !overlap(key.Loc, c.loc) {
+		return
+	}
+	var class = c.p.classOfType(c.p.TypeOfExp(c.file, exp.PrefixExp))
+	if class == nil || len(c.p.ExpMembers(c.file, exp.PrefixExp, key.Str)) > 0 {
+		return
+	}
+	// 加在类名和已有的 @field 后面
+	var last = class.NameAndLoc.Loc.Start
+	for _, field := range class.FieldList {
+		if last.Before(field.NameAndLoc.Loc.Start) {
+			last = field.NameAndLoc.Loc.Start
+		}
+	}
+	var indent = leadingSpace(class.File.Source.GetOneLine(last.GetLine()))
+	c.add(&CodeAction{
+		Title: fmt.Sprintf("Add field '%s' to class '%s'", key.Str, class.NameAndLoc.Name),
+		Kind:  CodeActionQuickFix,
+		Codes: []string{DiagUndefinedField},
+		Loc:   key.Loc,
+		Edits: []TextEdit{insertLine(class.File, last.GetLine()+1, indent+"---@field "+key.Str+" any")},
+	})
+}
+
+// classOfType 类型对应的类，可能为 nil 的类型也算
+func (p *Project) classOfType(t ast.TypeBase) *ast.Type_Class {
+	var name string
+	switch t := removeNil(t).(type) {
+	case *ast.Type_Identifier:
+		name = t.NameAndLoc.Name
+	case *ast.Type_GenericInstance:
+		name = t.NameAndLoc.Name
+	case *ast.TableInfo:
+		name = t.ClassName
+	}
+	if list := p.GetClass(name); len(list) > 0 {
+		return list[0]
+	}
+	return nil
+}
+
+// methodCall 把 obj.m(obj, ...) 改成 obj:m(...)
+func (c *actionCollector) methodCall(call *ast.FuncCallExp) {
+	var access, ok = call.PrefixExp.(*ast.TableAccessExp)
+	if call.NameExp != nil || !ok || len(call.Args) == 0 || !overlap(access.Loc, c.loc) {
+		return
+	}
+	var key, isKey = access.KeyExp.(*ast.StringExp)
+	if !isKey || !isNameKey(key) || !sameObject(c.file, access.PrefixExp, call.Args[0]) {
+		return
+	}
+	var source = c.file.Source
+	var dotLoc = Location{Start: access.PrefixExp.GetLoc().End, End: key.Loc.Start}
+	if strings.TrimSpace(source.GetText(dotLoc)) != "." {
+		return
+	}
+	var argLoc = call.Args[0].GetLoc()
+	if len(call.Args) > 1 {
+		argLoc.End = call.Args[1].GetLoc().Start
+		var sep = source.GetText(Location{Start: call.Args[0].GetLoc().End, End: argLoc.End})
+		if strings.TrimSpace(sep) != "," {
+			return
+		}
+	}
+	c.add(&CodeAction{
+		Title: fmt.Sprintf("Convert to method call '%s'", key.Str),
+		Kind:  CodeActionRewrite,
+		Loc:   access.Loc,
+		Edits: []TextEdit{
+			{File: c.file, Loc: dotLoc, Text: ":"},
+			{File: c.file, Loc: argLoc},
+		},
+	})
+}
+
+// sameObject 两个表达式是否为同一个变量或者同一个变量的字段，例如 a.b 和 a.b
+func sameObject(file *ast.FileInfo, a, b ast.Exp) bool {
+	switch a := a.(type) {
+	case *ast.NameExp:
+		var other, ok = b.(*ast.NameExp)
+		return ok && a.Name == other.Name && file.NameVarMap[a] == file.NameVarMap[other]
+	case *ast.TableAccessExp:
+		var other, ok = b.(*ast.TableAccessExp)
+		if !ok {
+			return false
+		}
+		var key1, ok1 = a.KeyExp.(*ast.StringExp)
+		var key2, ok2 = other.KeyExp.(*ast.StringExp)
+		return ok1 && ok2 && key1.Str == key2.Str && sameObject(file, a.PrefixExp, other.PrefixExp)
+	}
+	return false
+}
+
+// SuppressAction 在 line 前面插入 ---@diagnostic disable-next-line 关闭这一行的诊断，
+// 上一行已经有这样的注释时把编号加在后面
+func SuppressAction(file *ast.FileInfo, line int, code string) *CodeAction {
+	var prefix = diagnosticPrefix + " " + string(suppressNextLine)
+	var source = file.Source
+	var action = &CodeAction{
+		Title: fmt.Sprintf("Disable '%s' for this line", code),
+		Kind:  CodeActionQuickFix,
+		Codes: []string{code},
+		Loc:   Location{Start: Position{Line: int32(line)}, End: Position{Line: int32(line), Column: int32(len(source.GetOneLine(line)))}},
+	}
+	if prev := strings.TrimRight(source.GetOneLine(line-1), " \t"); line > 0 {
+		if rest, ok := strings.CutPrefix(strings.TrimLeft(prev, " \t"), prefix); ok && (rest == "" || rest[0] == ':') {
+			var end = Position{Line: int32(line - 1), Column: int32(len(prev))}
+			var text = ": " + code
+			if rest != "" {
+				text = ", " + code
+			}
+			action.Edits = []TextEdit{{File: file, Loc: Location{Start: end, End: end}, Text: text}}
+			return action
+		}
+	}
+	action.Edits = []TextEdit{insertLine(file, line, leadingSpace(source.GetOneLine(line))+prefix+": "+code)}
+	return action
+}
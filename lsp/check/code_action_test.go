@@ -0,0 +1,74 @@
+package check
+
+import (
+	"mylua-lsp/lsp/common"
+	"slices"
+	"sort"
+	"testing"
+)
+
+// applyAction 应用代码操作的修改，返回修改后的文本
+func applyAction(action *CodeAction) string {
+	var edits = append([]TextEdit(nil), action.Edits...)
+	sort.SliceStable(edits, func(i, j int) bool {
+		return edits[j].Loc.Start.Before(edits[i].Loc.Start)
+	})
+	var source = edits[0].File.Source
+	for _, edit := range edits {
+		source = source.ApplyChange(edit.Loc, edit.Text)
+	}
+	return source.GetRawText(Location{End: Position{Line: int32(source.GetLineNum())}})
+}
+
+func TestCodeActions(t *testing.T) {
+	var tests = []struct {
+		name  string
+		text  string
+		title string
+		codes []string
+		want  string
+	}{
+		{"remove unused local", "local a|b = 1\nprint(1)\n", "Remove unused local 'ab'", []string{DiagUnusedLocal},
+			"print(1)\n"},
+		{"prefix unused local", "local a|b = 1\nprint(1)\n", "Rename 'ab' to '_ab'", []string{DiagUnusedLocal},
+			"local _ab = 1\nprint(1)\n"},
+		{"prefix unused param", "local function f(a, |b)\n  return a\nend\nreturn f\n", "Rename 'b' to '_b'", []string{DiagUnusedParam},
+			"local function f(a, _b)\n  return a\nend\nreturn f\n"},
+		{"add params", "local function f|(x, y)\n  return x + y\nend\nreturn f\n", "Add @param for all parameters", nil,
+			"---@param x any\n---@param y any\nlocal function f(x, y)\n  return x + y\nend\nreturn f\n"},
+		{"declare local", "local function f()\n  coun|ter = 1\nend\nf()\n", "Declare 'counter' as local", []string{DiagGlobalInFunction},
+			"local function f()\n  local counter = 1\nend\nf()\n"},
+		{"similar global", "pirn|t(1)\n", "Change to 'print'", []string{DiagUndefinedGlobal},
+			"print(1)\n"},
+		{"declare undefined global", "pirn|t(1)\n", "Declare 'pirnt' as local", []string{DiagUndefinedGlobal},
+			"local pirnt\npirnt(1)\n"},
+		{"add class field", "---@class P\n---@field x number\nlocal P = {}\n---@type P\nlocal q\nprint(q.|y)\n",
+			"Add field 'y' to class 'P'", []string{DiagUndefinedField},
+			"---@class P\n---@field x number\n---@field y any\nlocal P = {}\n---@type P\nlocal q\nprint(q.y)\n"},
+		{"method call", "local t = {}\nfunction t:m() end\nt.|m(t)\n", "Convert to method call 'm'", nil,
+			"local t = {}\nfunction t:m() end\nt:m()\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var text, pos = cursor(t, tt.text)
+			var p = NewProject()
+			var file = analyzeText("a.lua", text)
+			p.UpdateFile(file)
+			var titles []string
+			for _, action := range p.CodeActions(file, Location{Start: pos, End: pos}, &DiagnosticOptions{Version: common.LuaVersion54}) {
+				titles = append(titles, action.Title)
+				if action.Title != tt.title {
+					continue
+				}
+				if !slices.Equal(action.Codes, tt.codes) {
+					t.Errorf("got codes %v, want %v", action.Codes, tt.codes)
+				}
+				if got := applyAction(action); got != tt.want {
+					t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+				}
+				return
+			}
+			t.Errorf("no action %q in %q", tt.title, titles)
+		})
+	}
+}
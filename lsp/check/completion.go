@@ -0,0 +1,308 @@
+package check
+
+import (
+	"mylua-lsp/lsp/ast"
+	"mylua-lsp/lsp/common"
+	"sort"
+	"strings"
+)
+
+/*
+代码补全。输入到一半的代码经常有语法错误，补全的上下文根据光标前面的文字判断，
+例如 foo. 和 foo:ba ，前缀表达式再从错误恢复后的语法树里查找。
+*/
+
+// CompletionKind 补全项的种类
+type CompletionKind uint8
+
+const (
+	CompletionVar     CompletionKind = iota // 局部变量和全局变量
+	CompletionMember                        // 字段和方法
+	CompletionKeyword                       // 关键字
+	CompletionSnippet                       // 代码片段
+	CompletionType                          // 注释里的类型名
+	CompletionTag                           // 注释标记 ---@
+)
+
+// Completion 一个补全项
+type Completion struct {
+	Label string
+	Kind  CompletionKind
+
+	Var     *ast.VarInfo // CompletionVar
+	Members []*Member    // CompletionMember 同名的所有定义
+	Snippet string       // CompletionSnippet 插入的内容，$1 是占位符
+	Filter  string       // CompletionSnippet 用来过滤的文字
+}
+
+// annotateTags 注释里可以使用的标记
+var annotateTags = []string{"alias", "class", "deprecated", "enum", "field", "generic", "overload", "param", "return", "type", "vararg"}
+
+// builtinTypes 注释里的基础类型
+var builtinTypes = []string{"any", "boolean", "function", "integer", "nil", "number", "string", "table", "thread", "userdata"}
+
+// snippetList 语句的代码片段，Filter 是开头的关键字
+var snippetList = []Completion{
+	{Label: "function ... end", Filter: "function", Snippet: "function ${1:name}(${2})\n\t$0\nend"},
+	{Label: "local function ... end", Filter: "local", Snippet: "local function ${1:name}(${2})\n\t$0\nend"},
+	{Label: "for i = 1, n do", Filter: "for", Snippet: "for ${1:i} = ${2:1}, ${3:n} do\n\t$0\nend"},
+	{Label: "for k, v in pairs()", Filter: "for", Snippet: "for ${1:k}, ${2:v} in pairs(${3:t}) do\n\t$0\nend"},
+	{Label: "for i, v in ipairs()", Filter: "for", Snippet: "for ${1:i}, ${2:v} in ipairs(${3:t}) do\n\t$0\nend"},
+	{Label: "if ... then", Filter: "if", Snippet: "if ${1:cond} then\n\t$0\nend"},
+	{Label: "while ... do", Filter: "while", Snippet: "while ${1:cond} do\n\t$0\nend"},
+	{Label: "repeat ... until", Filter: "repeat", Snippet: "repeat\n\t$0\nuntil ${1:cond}"},
+}
+
+// isIdentByte 是否为名字里的字符
+func isIdentByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// completer 一次补全请求
+type completer struct {
+	p       *Project
+	file    *ast.FileInfo
+	pos     Position
+	version common.LuaVersion
+	list    []*Completion
+}
+
+// Complete 光标位置的补全项，wordLoc 是光标前面正在输入的名字，补全时替换它
+func (p *Project) Complete(file *ast.FileInfo, pos Position, version common.LuaVersion) (list []*Completion, wordLoc Location) {
+	var line = file.Source.GetOneLine(pos.GetLine())
+	var text = line[:min(pos.GetColumn(), len(line))]
+	var wordStart = len(text)
+	for wordStart > 0 && isIdentByte(text[wordStart-1]) {
+		wordStart--
+	}
+	wordLoc = Location{Start: Position{Line: pos.Line, Column: int32(wordStart)}, End: pos}
+	var word = text[wordStart:]
+	if word != "" && word[0] >= '0' && word[0] <= '9' {
+		return nil, wordLoc
+	}
+
+	var c = &completer{p: p, file: file, pos: pos, version: version}
+	if commentAt(file, wordLoc.Start) {
+		c.completeAnnotate(text, word)
+		return c.list, wordLoc
+	}
+	if c.inString(wordLoc.Start) {
+		return nil, wordLoc
+	}
+	var before = text[:wordStart]
+	switch {
+	case strings.HasSuffix(before, ".") && !strings.HasSuffix(before, ".."):
+		c.completeMembers(Position{Line: pos.Line, Column: int32(wordStart - 1)}, false)
+	case strings.HasSuffix(before, ":") && !strings.HasSuffix(before, "::"):
+		c.completeMembers(Position{Line: pos.Line, Column: int32(wordStart - 1)}, true)
+	case lastWord(before) == "local":
+		// 正在定义新的局部变量
+		c.list = append(c.list, &Completion{Label: "function", Kind: CompletionKeyword})
+	default:
+		c.completeNames()
+		c.completeKeywords()
+	}
+	return c.list, wordLoc
+}
+
+// lastWord 文字末尾的最后一个单词
+func lastWord(text string) string {
+	var fields = strings.Fields(text)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[len(fields)-1]
+}
+
+// commentAt pos 是否在注释里
+func commentAt(file *ast.FileInfo, pos Position) bool {
+	for _, block := range file.CommentMap {
+		for _, line := range block.List {
+			if line.StartPos.Before(pos) && !line.EndPos.Before(pos) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// inString pos 是否在字符串里，不包括引号的外面
+func (c *completer) inString(pos Position) bool {
+	var result = false
+	ast.Inspect(c.file.Block, func(node ast.Stat) bool {
+		if node == nil || result || !inLoc(node.GetLoc(), pos) {
+			return false
+		}
+		if str, ok := node.(*ast.StringExp); ok && !isNameKey(str) {
+			result = str.Loc.Start.Before(pos) && pos.Before(str.Loc.End)
+		}
+		return true
+	})
+	return result
+}
+
+// completeAnnotate 注释里 ---@ 后面的标记名，以及标记后面的类型名
+func (c *completer) completeAnnotate(text string, word string) {
+	var trimmed = strings.TrimLeft(text, " \t")
+	if !strings.HasPrefix(trimmed, "---@") {
+		return
+	}
+	var rest = trimmed[len("---@"):]
+	if rest == word {
+		for _, tag := range annotateTags {
+			c.list = append(c.list, &Completion{Label: tag, Kind: CompletionTag})
+		}
+		return
+	}
+	var tag, args, found = strings.Cut(rest, " ")
+	if !found {
+		return
+	}
+	args = args[:len(args)-len(word)]
+	var wantType bool
+	switch tag {
+	case "type", "return", "overload", "vararg":
+		wantType = true
+	case "param", "field", "alias":
+		// 第一个是名字，后面是类型
+		wantType = len(strings.Fields(args)) > 0
+	case "class", "generic":
+		wantType = strings.Contains(args, ":")
+	}
+	if wantType {
+		c.completeTypes()
+	}
+}
+
+// completeTypes 所有的注释类型名
+func (c *completer) completeTypes() {
+	var nameSet = map[string]bool{}
+	var add = func(name string) {
+		if !nameSet[name] {
+			nameSet[name] = true
+			c.list = append(c.list, &Completion{Label: name, Kind: CompletionType})
+		}
+	}
+	for _, name := range builtinTypes {
+		add(name)
+	}
+	for _, name := range sortedKeys(c.p.classMap) {
+		add(name)
+	}
+	for _, name := range sortedKeys(c.p.aliasMap) {
+		add(name)
+	}
+	for _, name := range sortedKeys(c.p.enumMap) {
+		add(name)
+	}
+}
+
+// sortedKeys map 排序后的 key
+func sortedKeys[T any](m map[string]T) []string {
+	var keys = make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// findPrefixExp 分隔符 . 或者 : 前面的表达式，sepPos 是分隔符的位置
+func (c *completer) findPrefixExp(sepPos Position) ast.Exp {
+	var result ast.Exp
+	// 分隔符在前缀和名字之间，foo. 后面没有名字时名字的位置就是分隔符
+	var match = func(prefix ast.Exp, keyLoc Location) bool {
+		return !sepPos.Before(prefix.GetLoc().End) && !keyLoc.Start.Before(sepPos)
+	}
+	ast.Inspect(c.file.Block, func(node ast.Stat) bool {
+		if node == nil || result != nil {
+			return false
+		}
+		switch n := node.(type) {
+		case *ast.TableAccessExp:
+			if match(n.PrefixExp, n.KeyExp.GetLoc()) {
+				result = n.PrefixExp
+			}
+		case *ast.FuncCallExp:
+			if n.NameExp != nil && match(n.PrefixExp, n.NameExp.Loc) {
+				result = n.PrefixExp
+			}
+		}
+		return true
+	})
+	return result
+}
+
+// completeMembers 前缀表达式的字段，isColon 时只要方法
+func (c *completer) completeMembers(sepPos Position, isColon bool) {
+	var prefix = c.findPrefixExp(sepPos)
+	if prefix == nil {
+		return
+	}
+	var nameMap = map[string]*Completion{}
+	for _, member := range c.p.ExpMembers(c.file, prefix, "") {
+		if isColon && !c.p.IsMethod(member) {
+			continue
+		}
+		var item = nameMap[member.Name]
+		if item == nil {
+			item = &Completion{Label: member.Name, Kind: CompletionMember}
+			nameMap[member.Name] = item
+			c.list = append(c.list, item)
+		}
+		item.Members = append(item.Members, member)
+	}
+}
+
+// IsMethod 成员是否为可以用 : 调用的方法，第一个参数是 self
+func (p *Project) IsMethod(member *Member) bool {
+	if member.Field != nil && member.Field.IsMethod {
+		return true
+	}
+	switch t := p.TypeOfMember(member).(type) {
+	case *ast.FuncInfo:
+		return t.IsColon() || len(t.ParamList) > 0 && t.ParamList[0].Name == "self"
+	case *ast.Type_Fun:
+		return t != nil && len(t.ParamList) > 0 && t.ParamList[0].NameAndLoc.Name == "self"
+	}
+	return false
+}
+
+// completeNames 可见的局部变量和工程里的全局变量，同名时里面的遮住外面的
+func (c *completer) completeNames() {
+	var nameSet = map[string]bool{}
+	for scope := c.file.MainFunc.Scope.FindScope(c.pos); scope != nil; scope = scope.Parent {
+		for i := len(scope.VarInfoList) - 1; i >= 0; i-- {
+			var varInfo = scope.VarInfoList[i]
+			if nameSet[varInfo.Name] || c.pos.Before(varInfo.VisiblePos) {
+				continue
+			}
+			nameSet[varInfo.Name] = true
+			c.list = append(c.list, &Completion{Label: varInfo.Name, Kind: CompletionVar, Var: varInfo})
+		}
+	}
+	for _, name := range sortedKeys(c.p.globalMap) {
+		if nameSet[name] {
+			continue
+		}
+		if varInfo := c.p.GetGlobal(name); varInfo != nil {
+			nameSet[name] = true
+			c.list = append(c.list, &Completion{Label: name, Kind: CompletionVar, Var: varInfo})
+		}
+	}
+}
+
+// completeKeywords 关键字和代码片段
+func (c *completer) completeKeywords() {
+	for _, keyword := range sortedKeys(ast.Keywords) {
+		if keyword == "goto" && !c.version.SupportGoto() {
+			continue
+		}
+		c.list = append(c.list, &Completion{Label: keyword, Kind: CompletionKeyword})
+	}
+	for i := range snippetList {
+		var item = snippetList[i]
+		item.Kind = CompletionSnippet
+		c.list = append(c.list, &item)
+	}
+}
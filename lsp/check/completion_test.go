@@ -0,0 +1,69 @@
+package check
+
+import (
+	"mylua-lsp/lsp/common"
+	"slices"
+	"testing"
+)
+
+// complete 光标位置的补全项的名字，只保留 kind 种类的
+func complete(t *testing.T, text string, kind CompletionKind) ([]string, Location) {
+	t.Helper()
+	var content, pos = cursor(t, text)
+	var p = NewProject()
+	var file = analyzeText("a.lua", content)
+	p.UpdateFile(file)
+	var list, wordLoc = p.Complete(file, pos, common.LuaVersion54)
+	var labels []string
+	for _, item := range list {
+		if item.Kind == kind {
+			labels = append(labels, item.Label)
+		}
+	}
+	return labels, wordLoc
+}
+
+func TestComplete(t *testing.T) {
+	var tests = []struct {
+		name string
+		text string
+		kind CompletionKind
+		want []string
+		word string
+	}{
+		{"fields", "local point = { x = 1, y = 2 }\nfunction point:len() end\nprint(point.|)\n", CompletionMember,
+			[]string{"x", "y", "len"}, "2:12-2:12"},
+		{"methods", "local point = { x = 1 }\nfunction point:len() end\npoint:|\n", CompletionMember,
+			[]string{"len"}, "2:6-2:6"},
+		{"partial member", "local point = { xa = 1, xb = 2 }\nprint(point.x|)\n", CompletionMember,
+			[]string{"xa", "xb"}, "1:12-1:13"},
+		{"locals", "local alpha, alpine = 1, 2\nprint(al|)\n", CompletionVar,
+			[]string{"alpine", "alpha"}, "1:6-1:8"},
+		{"type names", "---@type str|\nlocal s\n", CompletionType,
+			[]string{"any", "boolean", "function", "integer", "nil", "number", "string", "table", "thread", "userdata"}, "0:9-0:12"},
+		{"annotation tags", "---@cl|\n", CompletionTag,
+			[]string{"alias", "class", "deprecated", "enum", "field", "generic", "overload", "param", "return", "type", "vararg"}, "0:4-0:6"},
+		{"keywords", "local x = 1\nwhi|\n", CompletionSnippet,
+			[]string{"function ... end", "local function ... end", "for i = 1, n do", "for k, v in pairs()", "for i, v in ipairs()",
+				"if ... then", "while ... do", "repeat ... until"}, "1:0-1:3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var labels, wordLoc = complete(t, tt.text, tt.kind)
+			if !slices.Equal(labels, tt.want) {
+				t.Errorf("got %v, want %v", labels, tt.want)
+			}
+			if got := locString(wordLoc); got != tt.word {
+				t.Errorf("got word %s, want %s", got, tt.word)
+			}
+		})
+	}
+}
+
+// 注释里没有类型名的位置不补全代码
+func TestCompleteInComment(t *testing.T) {
+	var labels, _ = complete(t, "local alpha = 1\n-- al|\n", CompletionVar)
+	if len(labels) != 0 {
+		t.Errorf("got %v in comment", labels)
+	}
+}
@@ -0,0 +1,116 @@
+package check
+
+import (
+	"mylua-lsp/lsp/ast"
+)
+
+// DefLoc 符号定义的位置
+type DefLoc struct {
+	File  *ast.FileInfo
+	Loc   Location // 定义的名字的位置
+	Range Location // 整个定义的范围，例如赋值语句
+}
+
+// Definitions 符号定义的位置。declaration 为 true 时成员优先使用 @field 的声明，否则优先使用 lua 代码里的赋值
+func (p *Project) Definitions(sym *Symbol, declaration bool) []DefLoc {
+	switch sym.Kind {
+	case SymbolVar:
+		return p.varDefinitions(sym.Var)
+	case SymbolMember:
+		return memberDefinitions(sym.Members, declaration)
+	case SymbolType:
+		return p.typeDefinitions(sym.Name)
+	case SymbolLabel:
+		if sym.Label != nil {
+			return []DefLoc{{File: sym.File, Loc: sym.Label.Loc, Range: sym.Label.Loc}}
+		}
+	case SymbolModule:
+		if sym.Module != nil {
+			return []DefLoc{{File: sym.Module}}
+		}
+	}
+	return nil
+}
+
+// varDefinitions 局部变量跳到定义的名字，全局变量跳到工程里第一个有定义的文件
+func (p *Project) varDefinitions(varInfo *ast.VarInfo) []DefLoc {
+	if varInfo == nil {
+		return nil
+	}
+	if !varInfo.IsLocal() {
+		// 当前文件里只有引用没有定义
+		if def := p.GetGlobal(varInfo.Name); def != nil {
+			varInfo = def
+		} else if varInfo.DefStat == nil {
+			return nil
+		}
+	}
+	if varInfo.Kind == ast.VarKindSelf {
+		if funcInfo := varInfo.Func; funcInfo != nil && funcInfo.FuncDef != nil {
+			return []DefLoc{{File: varInfo.File, Loc: funcInfo.NameLoc, Range: funcInfo.FuncDef.Loc}}
+		}
+	}
+	var def = DefLoc{File: varInfo.File, Loc: varInfo.Loc, Range: varInfo.Loc}
+	if varInfo.DefStat != nil {
+		var statLoc = varInfo.DefStat.GetLoc()
+		if inLoc(statLoc, varInfo.Loc.Start) {
+			def.Range = statLoc
+		}
+	}
+	return []DefLoc{def}
+}
+
+// memberDefinitions 成员的所有定义，同时有 lua 字段和 @field 时只返回优先的一种
+func memberDefinitions(members []*Member, declaration bool) []DefLoc {
+	var fieldList, classFieldList []DefLoc
+	// 静态的表和推导出的类型可能是同一个表
+	var visited = map[DefLoc]bool{}
+	for _, member := range members {
+		var file = member.GetFile()
+		if file == nil {
+			continue
+		}
+		var loc = member.GetLoc()
+		var key = DefLoc{File: file, Loc: loc}
+		if visited[key] {
+			continue
+		}
+		visited[key] = true
+		var def = DefLoc{File: file, Loc: loc, Range: loc}
+		if member.Field == nil {
+			classFieldList = append(classFieldList, def)
+			continue
+		}
+		if member.Field.DefExp != nil {
+			def.Range = member.Field.DefExp.GetLoc()
+		}
+		if member.Field.ValueExp != nil {
+			def.Range.End = member.Field.ValueExp.GetLoc().End
+		}
+		fieldList = append(fieldList, def)
+	}
+	if declaration {
+		fieldList, classFieldList = classFieldList, fieldList
+	}
+	if len(fieldList) > 0 {
+		return fieldList
+	}
+	return classFieldList
+}
+
+// typeDefinitions @class @alias @enum 定义的位置，同名的类可能在多个地方定义
+func (p *Project) typeDefinitions(name string) (list []DefLoc) {
+	var add = func(file *ast.FileInfo, loc Location) {
+		list = append(list, DefLoc{File: file, Loc: loc, Range: loc})
+	}
+	for _, class := range p.GetClass(name) {
+		add(class.File, class.NameAndLoc.Loc)
+	}
+	for _, alias := range p.GetAlias(name) {
+		add(alias.File, alias.NameAndLoc.Loc)
+	}
+	for _, enum := range p.GetEnum(name) {
+		add(enum.File, enum.NameAndLoc.Loc)
+	}
+	return list
+}
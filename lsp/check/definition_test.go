@@ -0,0 +1,46 @@
+package check
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// libFiles 定义和引用测试里被引用的文件
+var libFiles = map[string]string{
+	"lib.lua": "---@class Shape\n---@field area number\nShape = {}\n\nfunction Shape:draw() end\n\nConfig = { debug = true }\n" +
+		"---@class Point\n---@field x number\nPoint = {}\nPoint.x = 0\n",
+}
+
+func TestDefinitions(t *testing.T) {
+	var tests = []struct {
+		name        string
+		text        string
+		declaration bool
+		want        []string
+	}{
+		{"local", "local count = 1\nprint(cou|nt)\n", false, []string{"a.lua 0:6-0:11"}},
+		{"global in other file", "local s = Sha|pe\n", false, []string{"lib.lua 2:0-2:5"}},
+		{"method", "---@type Shape\nlocal s\ns:dr|aw()\n", false, []string{"lib.lua 4:15-4:19"}},
+		{"field declaration", "---@type Shape\nlocal s\nprint(s.ar|ea)\n", false, []string{"lib.lua 1:10-1:14"}},
+		{"assigned field", "print(Point.|x)\n", false, []string{"lib.lua 10:6-10:7"}},
+		{"declared field", "print(Point.|x)\n", true, []string{"lib.lua 8:10-8:11"}},
+		{"table field", "print(Config.deb|ug)\n", false, []string{"lib.lua 6:11-6:16"}},
+		{"type name", "---@type Sh|ape\nlocal s\n", false, []string{"lib.lua 0:10-0:15"}},
+		{"module", "local M = require('l|ib')\n", false, []string{"lib.lua 0:0-0:0"}},
+		{"label", "do\n  goto do|ne\n  ::done::\nend\n", false, []string{"a.lua 2:4-2:8"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p = newTestProject(libFiles)
+			var sym = symbolAt(t, p, "a.lua", tt.text)
+			var got []string
+			for _, def := range p.Definitions(sym, tt.declaration) {
+				got = append(got, fmt.Sprintf("%s %s", def.File.GetPath(), locString(def.Loc)))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
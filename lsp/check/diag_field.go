@@ -0,0 +1,56 @@
+package check
+
+import (
+	"fmt"
+	"mylua-lsp/lsp/ast"
+)
+
+// checkUndefinedFields 读取 @class 类型的对象上没有定义的字段，写入的不算。
+// 类型可能不只是这个类，或者类继承了不是 @class 的类型时，字段可能来自别的地方，不检查
+func checkUndefinedFields(p *Project, file *ast.FileInfo, opts *DiagnosticOptions) []Diagnostic {
+	var list []Diagnostic
+	ast.Inspect(file.Block, func(node ast.Stat) bool {
+		var exp, ok = node.(*ast.TableAccessExp)
+		if !ok || exp.IsWriteExp {
+			return node != nil
+		}
+		var key, isKey = exp.KeyExp.(*ast.StringExp)
+		if !isKey || !isNameKey(key) {
+			return true
+		}
+		var class = p.classOfType(p.TypeOfExp(file, exp.PrefixExp))
+		if class == nil || !p.isClosedClass(class.NameAndLoc.Name, map[string]bool{}) ||
+			len(p.ExpMembers(file, exp.PrefixExp, key.Str)) > 0 {
+			return true
+		}
+		list = append(list, Diagnostic{
+			Loc:      key.Loc,
+			Severity: SeverityWarning,
+			Code:     DiagUndefinedField,
+			Message:  fmt.Sprintf("undefined field '%s' in class '%s'", key.Str, class.NameAndLoc.Name),
+		})
+		return true
+	})
+	return list
+}
+
+// isClosedClass 类的所有定义以及父类都只继承 @class ，这时类的字段都能找到
+func (p *Project) isClosedClass(name string, visited map[string]bool) bool {
+	if visited[name] {
+		return true
+	}
+	visited[name] = true
+	var classes = p.GetClass(name)
+	if len(classes) == 0 {
+		return false
+	}
+	for _, class := range classes {
+		for _, parent := range class.ParentTypeList {
+			var super = parentName(parent)
+			if super == "" || !p.isClosedClass(super, visited) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,147 @@
+package check
+
+import (
+	"fmt"
+	"mylua-lsp/lsp/ast"
+	"slices"
+)
+
+// isDefinedGlobal 全局变量是否有定义，包括工程里赋值过的，标准库的和宿主程序注入的
+func (p *Project) isDefinedGlobal(name string, opts *DiagnosticOptions) bool {
+	return p.GetGlobal(name) != nil || isStdlibGlobal(name, opts.Version) || slices.Contains(opts.Globals, name)
+}
+
+// checkUndefinedGlobals 读取整个工程都没有定义的全局变量，有相近的名字时提示可能的拼写
+func checkUndefinedGlobals(p *Project, file *ast.FileInfo, opts *DiagnosticOptions) []Diagnostic {
+	var list []Diagnostic
+	for exp, varInfo := range file.NameVarMap {
+		if varInfo.IsLocal() || isTarget(exp.GetParent(), exp) || p.isDefinedGlobal(exp.Name, opts) {
+			continue
+		}
+		var message = fmt.Sprintf("undefined global '%s'", exp.Name)
+		if similar := p.similarName(file, exp, opts); similar != "" {
+			message += fmt.Sprintf(", did you mean '%s'?", similar)
+		}
+		list = append(list, Diagnostic{
+			Loc:      exp.Loc,
+			Severity: SeverityWarning,
+			Code:     DiagUndefinedGlobal,
+			Message:  message,
+		})
+	}
+	return list
+}
+
+// similarName 和没有定义的名字最接近的变量名，候选的有可见的局部变量和所有有定义的全局变量。
+// 名字越长允许的编辑距离越大，最多 3 ，太短的名字不提示，没有足够接近的返回空
+func (p *Project) similarName(file *ast.FileInfo, exp *ast.NameExp, opts *DiagnosticOptions) string {
+	var limit = min(len(exp.Name)/3, 3)
+	if limit == 0 {
+		return ""
+	}
+	var best string
+	var bestDist = limit + 1
+	var try = func(name string) {
+		if name == exp.Name {
+			return
+		}
+		var dist = editDistance(exp.Name, name)
+		if dist < bestDist || dist == bestDist && name < best {
+			best, bestDist = name, dist
+		}
+	}
+	for scope := file.MainFunc.Scope.FindScope(exp.Loc.Start); scope != nil; scope = scope.Parent {
+		for _, varInfo := range scope.VarInfoList {
+			if !exp.Loc.Start.Before(varInfo.VisiblePos) {
+				try(varInfo.Name)
+			}
+		}
+	}
+	for name := range p.globalMap {
+		if p.GetGlobal(name) != nil {
+			try(name)
+		}
+	}
+	for _, list := range [][]string{stdlibGlobals, versionGlobals[opts.Version], opts.Globals} {
+		for _, name := range list {
+			try(name)
+		}
+	}
+	return best
+}
+
+// editDistance 两个名字的编辑距离，插入、删除、替换和交换相邻的两个字符都算一次
+func editDistance(a, b string) int {
+	var prev2 = make([]int, len(b)+1)
+	var prev = make([]int, len(b)+1)
+	var cur = make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			var cost = 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(b)]
+}
+
+// checkGlobalsInFunction 在函数里直接对名字赋值创建的全局变量，通常是漏写了 local 。
+// 工程里有在主函数里赋值的，或者用 _G.x 赋值的不算
+func checkGlobalsInFunction(p *Project, file *ast.FileInfo, opts *DiagnosticOptions) []Diagnostic {
+	var list []Diagnostic
+	for name, varInfo := range file.GlobalMaps {
+		if !isTarget(varInfo.DefStat, nameTarget(varInfo)) || inMainFunc(file, varInfo.Loc.Start) ||
+			isStdlibGlobal(name, opts.Version) || slices.Contains(opts.Globals, name) || p.assignedInMain(name) {
+			continue
+		}
+		list = append(list, Diagnostic{
+			Loc:      varInfo.Loc,
+			Severity: SeverityWarning,
+			Code:     DiagGlobalInFunction,
+			Message:  fmt.Sprintf("assignment creates global '%s' inside a function, did you forget 'local'?", name),
+		})
+	}
+	return list
+}
+
+// nameTarget 全局变量第一次赋值时左边的名字，用 _G.x 赋值时返回 nil
+func nameTarget(varInfo *ast.VarInfo) ast.Exp {
+	if assign, ok := varInfo.DefStat.(*ast.AssignStat); ok {
+		for _, target := range assign.VarList {
+			if name, ok := target.(*ast.NameExp); ok && name.Loc == varInfo.Loc {
+				return name
+			}
+		}
+	}
+	return nil
+}
+
+// inMainFunc pos 是否直接在文件的主函数里，不在任何函数定义里面
+func inMainFunc(file *ast.FileInfo, pos Position) bool {
+	return file.MainFunc.Scope.FindScope(pos).Func == file.MainFunc
+}
+
+// assignedInMain 全局变量在工程里是否有在主函数里的赋值
+func (p *Project) assignedInMain(name string) bool {
+	for _, varInfo := range p.globalMap[name] {
+		if varInfo.DefStat != nil && inMainFunc(varInfo.File, varInfo.Loc.Start) {
+			return true
+		}
+		for _, ref := range varInfo.RefList {
+			if ref.IsWrite && inMainFunc(varInfo.File, ref.Exp.Loc.Start) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,173 @@
+package check
+
+import (
+	"fmt"
+	"mylua-lsp/lsp/ast"
+	"mylua-lsp/lsp/common"
+)
+
+/*
+解析器不检查的 goto 和 break 的规则，错误信息和对应版本的 luac 一致：
+goto 只能跳到同一个函数里可见的标签，也就是所在的 block 以及外层 block 里的标签；
+向前跳的时候不能跳进中间定义的局部变量的作用域，标签是 block 最后的语句时例外，repeat 的 block 除外；
+同一个 block 里的标签不能重名，5.4 里外层 block 前面的标签也不能重名；
+break 只能在循环里面。
+*/
+
+// compileError 编译时的错误，不能用注释关闭
+func compileError(loc Location, format string, a ...any) Diagnostic {
+	return Diagnostic{Loc: loc, Severity: SeverityError, Message: fmt.Sprintf(format, a...)}
+}
+
+// checkGotoLabels 检查 goto 的目标，重复的标签和循环外的 break
+func checkGotoLabels(p *Project, file *ast.FileInfo, opts *DiagnosticOptions) []Diagnostic {
+	var version = opts.Version
+	var list []Diagnostic
+	ast.Inspect(file.Block, func(node ast.Stat) bool {
+		switch stat := node.(type) {
+		case *ast.BreakStat:
+			if !inLoop(stat) {
+				list = append(list, compileError(stat.Loc, "%s", breakMessage(version, stat.Loc.Start.GetLine()+1)))
+			}
+		case *ast.GotoStat:
+			if version.SupportGoto() {
+				list = append(list, checkGoto(file, stat, version)...)
+			}
+		}
+		return node != nil
+	})
+	if version.SupportGoto() {
+		list = append(list, checkDuplicateLabels(file.MainFunc.Scope, version)...)
+	}
+	return list
+}
+
+// inLoop break 是否在循环里面，函数定义会隔开外面的循环
+func inLoop(stat ast.Stat) bool {
+	for node := stat.GetParent(); node != nil; node = node.GetParent() {
+		switch node.(type) {
+		case *ast.WhileStat, *ast.RepeatStat, *ast.ForNumStat, *ast.ForInStat:
+			return true
+		case *ast.FuncDefExp:
+			return false
+		}
+	}
+	return false
+}
+
+func breakMessage(version common.LuaVersion, line int) string {
+	switch version {
+	case common.LuaVersion51, common.LuaVersionJIT:
+		return "no loop to break"
+	case common.LuaVersion52, common.LuaVersion53:
+		return fmt.Sprintf("<break> at line %d not inside a loop", line)
+	}
+	return fmt.Sprintf("break outside a loop at line %d", line)
+}
+
+// checkGoto goto 的标签要可见，向前跳时不能跳进局部变量的作用域
+func checkGoto(file *ast.FileInfo, stat *ast.GotoStat, version common.LuaVersion) []Diagnostic {
+	var name = stat.Name.TokenStr
+	var line = stat.Loc.Start.GetLine() + 1
+	var label = FindLabel(file, stat)
+	if label == nil {
+		if version.IsJIT() {
+			return []Diagnostic{compileError(stat.Loc, "undefined label '%s'", name)}
+		}
+		return []Diagnostic{compileError(stat.Loc, "no visible label '%s' for <goto> at line %d", name, line)}
+	}
+	if label.Loc.Start.Before(stat.Loc.Start) || labelAtBlockEnd(file, label) {
+		return nil
+	}
+	// 标签所在的作用域是 goto 所在作用域往外的第 ScopeLv 层
+	var scope = file.MainFunc.Scope.FindScope(stat.Loc.Start)
+	for depth := scopeDepth(scope); depth > label.ScopeLv; depth-- {
+		scope = scope.Parent
+	}
+	for _, varInfo := range scope.VarInfoList {
+		if !stat.Loc.End.Before(varInfo.VisiblePos) || label.Loc.Start.Before(varInfo.VisiblePos) {
+			continue
+		}
+		if version.IsJIT() {
+			return []Diagnostic{compileError(stat.Loc, "<goto %s> jumps into the scope of local '%s'", name, varInfo.Name)}
+		}
+		return []Diagnostic{compileError(stat.Loc, "<goto %s> at line %d jumps into the scope of local '%s'", name, line, varInfo.Name)}
+	}
+	return nil
+}
+
+// scopeDepth 作用域在函数里的嵌套层数，函数体是 0 层，和 LabelInfo.ScopeLv 对应
+func scopeDepth(scope *ast.ScopeInfo) int {
+	var depth = 0
+	for ; scope.Parent != nil && scope.Parent.Func == scope.Func; scope = scope.Parent {
+		depth++
+	}
+	return depth
+}
+
+// labelAtBlockEnd 标签后面是否只有其他标签，这时局部变量的作用域已经结束。
+// repeat 的局部变量在 until 的条件里还能用，不算结束
+func labelAtBlockEnd(file *ast.FileInfo, label *ast.LabelInfo) bool {
+	var result = false
+	ast.Inspect(file.Block, func(node ast.Stat) bool {
+		var block, ok = node.(*ast.Block)
+		if !ok {
+			return node != nil
+		}
+		if !inLoc(block.Loc, label.Loc.Start) {
+			return false
+		}
+		for i, stat := range block.Stats {
+			if labelStat, ok := stat.(*ast.LabelStat); ok && labelStat.Name.Loc == label.Loc {
+				if _, isRepeat := block.GetParent().(*ast.RepeatStat); isRepeat {
+					return false
+				}
+				result = true
+				for _, next := range block.Stats[i+1:] {
+					if _, ok := next.(*ast.LabelStat); !ok {
+						result = false
+					}
+				}
+				return false
+			}
+		}
+		return true
+	})
+	return result
+}
+
+// checkDuplicateLabels 同一个 block 里重名的标签，5.4 还要检查外层 block 里前面的标签
+func checkDuplicateLabels(scope *ast.ScopeInfo, version common.LuaVersion) []Diagnostic {
+	var list []Diagnostic
+	for i, label := range scope.LabelInfoList {
+		var prev *ast.LabelInfo
+		for _, other := range scope.LabelInfoList[:i] {
+			if other.Name == label.Name {
+				prev = other
+				break
+			}
+		}
+		if version == common.LuaVersion54 {
+			for outer := scope; prev == nil && outer.Parent != nil && outer.Parent.Func == outer.Func; outer = outer.Parent {
+				for _, other := range outer.Parent.LabelInfoList {
+					if other.Name == label.Name && other.Loc.Start.Before(label.Loc.Start) {
+						prev = other
+						break
+					}
+				}
+			}
+		}
+		if prev == nil {
+			continue
+		}
+		if version.IsJIT() {
+			list = append(list, compileError(label.Loc, "duplicate label '%s'", label.Name))
+		} else {
+			list = append(list, compileError(label.Loc, "label '%s' already defined on line %d", label.Name, prev.Loc.Start.GetLine()+1))
+		}
+	}
+	for _, sub := range scope.SubScopes {
+		list = append(list, checkDuplicateLabels(sub, version)...)
+	}
+	return list
+}
@@ -0,0 +1,99 @@
+package check
+
+import (
+	"fmt"
+	"mylua-lsp/lsp/ast"
+	"strings"
+)
+
+// unnecessary 没有用到的代码的诊断，编辑器显示成淡色
+func unnecessary(loc Location, code string, message string) Diagnostic {
+	return Diagnostic{Loc: loc, Severity: SeverityHint, Code: code, Message: message, Unnecessary: true}
+}
+
+// checkUnusedVars 没有读取的局部变量、参数和局部函数。_ 开头的名字表示有意不使用，<close> 变量的作用在于关闭，都不报告
+func checkUnusedVars(p *Project, file *ast.FileInfo, opts *DiagnosticOptions) []Diagnostic {
+	var list []Diagnostic
+	var walk func(scope *ast.ScopeInfo)
+	walk = func(scope *ast.ScopeInfo) {
+		for _, varInfo := range scope.VarInfoList {
+			if varInfo.Kind == ast.VarKindSelf || varInfo.Attr == ast.RDKTOCLOSE || strings.HasPrefix(varInfo.Name, "_") || hasRead(varInfo) {
+				continue
+			}
+			var message string
+			switch {
+			case varInfo.Kind == ast.VarKindParam:
+				if varInfo.Name == "self" {
+					continue
+				}
+				message = fmt.Sprintf("unused parameter '%s'", varInfo.Name)
+			case varInfo.Kind == ast.VarKindLocalFunc:
+				message = fmt.Sprintf("unused local function '%s'", varInfo.Name)
+			case len(varInfo.RefList) > 0:
+				message = fmt.Sprintf("local '%s' is assigned but never read", varInfo.Name)
+			default:
+				message = fmt.Sprintf("unused local '%s'", varInfo.Name)
+			}
+			list = append(list, unnecessary(varInfo.Loc, unusedCode(varInfo), message))
+		}
+		for _, sub := range scope.SubScopes {
+			walk(sub)
+		}
+	}
+	walk(file.MainFunc.Scope)
+	return list
+}
+
+// checkUnusedLabels 没有 goto 跳转的标签
+func checkUnusedLabels(p *Project, file *ast.FileInfo, opts *DiagnosticOptions) []Diagnostic {
+	var used = map[*ast.LabelInfo]bool{}
+	ast.Inspect(file.Block, func(node ast.Stat) bool {
+		if stat, ok := node.(*ast.GotoStat); ok {
+			if label := FindLabel(file, stat); label != nil {
+				used[label] = true
+			}
+		}
+		return node != nil
+	})
+	var list []Diagnostic
+	for _, funcInfo := range file.FuncList {
+		for _, label := range funcInfo.LabelInfoList {
+			if !used[label] {
+				list = append(list, unnecessary(label.Loc, DiagUnusedLabel, fmt.Sprintf("unused label '%s'", label.Name)))
+			}
+		}
+	}
+	return list
+}
+
+// checkUnreachable return break goto 后面的语句执行不到，直到下一个标签，goto 可以跳到标签
+func checkUnreachable(p *Project, file *ast.FileInfo, opts *DiagnosticOptions) []Diagnostic {
+	var list []Diagnostic
+	ast.Inspect(file.Block, func(node ast.Stat) bool {
+		var block, ok = node.(*ast.Block)
+		if !ok {
+			return node != nil
+		}
+		var start = -1 // 第一个执行不到的语句
+		var flush = func(end int) {
+			if start >= 0 && start < end {
+				var loc = Location{Start: block.Stats[start].GetLoc().Start, End: block.Stats[end-1].GetLoc().End}
+				list = append(list, unnecessary(loc, DiagUnreachableCode, "unreachable code"))
+			}
+			start = -1
+		}
+		for i, stat := range block.Stats {
+			switch stat.(type) {
+			case *ast.LabelStat:
+				flush(i)
+			case *ast.RetStat, *ast.BreakStat, *ast.GotoStat:
+				if start < 0 {
+					start = i + 1
+				}
+			}
+		}
+		flush(len(block.Stats))
+		return true
+	})
+	return list
+}
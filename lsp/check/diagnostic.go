@@ -0,0 +1,83 @@
+package check
+
+import (
+	"mylua-lsp/lsp/ast"
+	"mylua-lsp/lsp/common"
+	"sort"
+)
+
+// 诊断的编号，可以用 ---@diagnostic 注释关闭，代码操作也通过编号关联到它修复的诊断
+const (
+	DiagUndefinedGlobal   = "undefined-global"   // 读取没有定义的全局变量
+	DiagGlobalInFunction  = "global-in-function" // 在函数里赋值创建全局变量
+	DiagUnusedLocal       = "unused-local"       // 没有使用的局部变量
+	DiagUnusedParam       = "unused-param"       // 没有使用的参数
+	DiagUnusedFunction    = "unused-function"    // 没有使用的局部函数
+	DiagUndefinedField    = "undefined-field"    // 类里没有定义的字段
+	DiagUnusedLabel       = "unused-label"       // 没有 goto 跳转的标签
+	DiagUnreachableCode   = "unreachable-code"   // return break goto 后面执行不到的代码
+	DiagUnusedSuppression = "unused-suppression" // 没有关闭任何诊断的 ---@diagnostic 注释
+)
+
+// diagCodes 可以用注释关闭的诊断编号
+var diagCodes = []string{
+	DiagUndefinedGlobal, DiagGlobalInFunction, DiagUnusedLocal, DiagUnusedParam, DiagUnusedFunction,
+	DiagUndefinedField, DiagUnusedLabel, DiagUnreachableCode,
+}
+
+// DiagSeverity 诊断的严重程度
+type DiagSeverity uint8
+
+const (
+	SeverityError DiagSeverity = iota + 1
+	SeverityWarning
+	SeverityInformation
+	SeverityHint
+)
+
+// Diagnostic 一条诊断
+type Diagnostic struct {
+	Loc         Location
+	Severity    DiagSeverity
+	Code        string // 诊断编号，语法错误没有编号，不能关闭
+	Message     string
+	Unnecessary bool // 没有用到的代码，编辑器会显示成淡色
+}
+
+// DiagnosticOptions 诊断的配置
+type DiagnosticOptions struct {
+	Version common.LuaVersion // 决定有哪些标准库的全局变量
+	Globals []string          // 宿主程序注入的全局变量，不算没有定义
+}
+
+// diagChecker 一种语义检查
+type diagChecker func(p *Project, file *ast.FileInfo, opts *DiagnosticOptions) []Diagnostic
+
+// diagCheckers 依次执行的语义检查
+var diagCheckers = []diagChecker{
+	checkUndefinedGlobals,
+	checkGlobalsInFunction,
+	checkUnusedVars,
+	checkUndefinedFields,
+	checkUnusedLabels,
+	checkUnreachable,
+	checkGotoLabels,
+}
+
+// Diagnostics 文件的诊断，包括语法错误和语义检查的结果。
+// 语义检查的结果经过 ---@diagnostic 注释的过滤，没有用到的注释也会报告。按位置排序
+func (p *Project) Diagnostics(file *ast.FileInfo, opts *DiagnosticOptions) []Diagnostic {
+	var list []Diagnostic
+	for _, err := range file.ParseErrors {
+		list = append(list, Diagnostic{Loc: err.Loc, Severity: SeverityError, Message: err.ErrStr})
+	}
+	var checked []Diagnostic
+	for _, checker := range diagCheckers {
+		checked = append(checked, checker(p, file, opts)...)
+	}
+	list = append(list, newSuppressions(file).filter(checked)...)
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Loc.Start.Before(list[j].Loc.Start)
+	})
+	return list
+}
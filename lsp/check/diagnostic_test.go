@@ -0,0 +1,140 @@
+package check
+
+import (
+	"fmt"
+	"mylua-lsp/lsp/common"
+	"strings"
+	"testing"
+)
+
+// diagnostics 文件的诊断，每条是 "行:列 编号 信息"，行号从 1 开始
+func diagnostics(text string, version common.LuaVersion) []string {
+	var p = NewProject()
+	var file = analyzeText("a.lua", text)
+	p.UpdateFile(file)
+	var list []string
+	for _, diag := range p.Diagnostics(file, &DiagnosticOptions{Version: version, Globals: []string{"HostApi"}}) {
+		list = append(list, fmt.Sprintf("%d:%d %s %s", diag.Loc.Start.Line+1, diag.Loc.Start.Column, diag.Code, diag.Message))
+	}
+	return list
+}
+
+func checkDiagnostics(t *testing.T, got []string, want []string) {
+	t.Helper()
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	var tests = []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"undefined global", "pirnt(1)\nprint(HostApi, Unknown)\n", []string{
+			"1:0 undefined-global undefined global 'pirnt', did you mean 'print'?",
+			"2:15 undefined-global undefined global 'Unknown'",
+		}},
+		{"global in function", "local function f()\n  counter = 1\nend\nf()\n", []string{
+			"2:2 global-in-function assignment creates global 'counter' inside a function, did you forget 'local'?",
+		}},
+		{"unused", "local a = 1\nlocal b\nb = 2\nlocal function g(x) end\ndo ::l:: end\n", []string{
+			"1:6 unused-local unused local 'a'",
+			"2:6 unused-local local 'b' is assigned but never read",
+			"4:15 unused-function unused local function 'g'",
+			"4:17 unused-param unused parameter 'x'",
+			"5:5 unused-label unused label 'l'",
+		}},
+		{"used", "local a, _b = 1, 2\nlocal function f(x, _y) return x + a end\nreturn f\n", nil},
+		{"unreachable after return", "local function f()\n  return 1\n  print(2)\nend\nreturn f\n", []string{
+			"3:2 unreachable-code unreachable code",
+		}},
+		{"unreachable after break", "while true do\n  break\n  print(1)\n  print(2)\nend\n", []string{
+			"3:2 unreachable-code unreachable code",
+		}},
+		{"syntax error", "local = 1\n", []string{
+			"1:6  expected identifier, found '='",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkDiagnostics(t, diagnostics(tt.text, common.LuaVersion54), tt.want)
+		})
+	}
+}
+
+// 只检查读取 @class 类型对象的字段，字段可以来自 @field、类的表、self 的赋值和父类
+func TestDiagnosticsUndefinedField(t *testing.T) {
+	const point = "---@class Point\n---@field x number\nlocal Point = {}\nfunction Point:init() self.y = 1 end\n"
+	var tests = []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"read", point + "---@param p Point\nlocal function f(p) return p.x + p.y + p.z + p.init end\nreturn f\n", []string{
+			"6:41 undefined-field undefined field 'z' in class 'Point'",
+		}},
+		{"write", point + "---@param p Point\nlocal function f(p) p.z = 1 end\nreturn f\n", nil},
+		{"union", point + "---@param p Point|string\nlocal function f(p) return p.z end\nreturn f\n", nil},
+		{"parent", "---@class Base\n---@field id integer\n---@class Item : Base\n---@param i Item\nlocal function f(i) return i.id, i.nope end\nreturn f\n", []string{
+			"5:35 undefined-field undefined field 'nope' in class 'Item'",
+		}},
+		{"parent not a class", "---@class Box : table\n---@param b Box\nlocal function f(b) return b.z end\nreturn f\n", nil},
+		{"cycle", "---@class A : B\n---@class B : A\n---@param a A\nlocal function f(a) return a.z end\nreturn f\n", []string{
+			"4:29 undefined-field undefined field 'z' in class 'A'",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkDiagnostics(t, diagnostics(tt.text, common.LuaVersion54), tt.want)
+		})
+	}
+}
+
+// break 和 goto 的错误信息和对应版本的 luac 一致，5.1 不检查 goto
+func TestDiagnosticsGoto(t *testing.T) {
+	var text = "break\ngoto nowhere\ndo\n  local x = 1\n  goto skip\n  local y = 2\n  ::skip::\n  print(x, y)\nend\n::a:: ::a::\n"
+	var tests = []struct {
+		version common.LuaVersion
+		want    []string
+	}{
+		{common.LuaVersion51, []string{"1:0  no loop to break"}},
+		{common.LuaVersion53, []string{
+			"1:0  <break> at line 1 not inside a loop",
+			"2:0  no visible label 'nowhere' for <goto> at line 2",
+			"5:2  <goto skip> at line 5 jumps into the scope of local 'y'",
+			"10:8  label 'a' already defined on line 10",
+		}},
+		{common.LuaVersion54, []string{
+			"1:0  break outside a loop at line 1",
+			"2:0  no visible label 'nowhere' for <goto> at line 2",
+			"5:2  <goto skip> at line 5 jumps into the scope of local 'y'",
+			"10:8  label 'a' already defined on line 10",
+		}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, diag := range diagnostics(text, tt.version) {
+			if !strings.Contains(diag, "unreachable-code") && !strings.Contains(diag, "unused-label") {
+				got = append(got, diag)
+			}
+		}
+		t.Run(tt.version.String(), func(t *testing.T) {
+			checkDiagnostics(t, got, tt.want)
+		})
+	}
+}
+
+func TestDiagnosticsSuppress(t *testing.T) {
+	var text = "---@diagnostic disable-next-line: undefined-global\nprint(foo)\n" +
+		"print(bar) ---@diagnostic disable-line: undefined-global\n" +
+		"---@diagnostic disable-next-line: unused-local\nprint(1)\n" +
+		"---@diagnostic disable-next-line: no-such\nprint(2)\n" +
+		"---@diagnostic disable: unused-local\nlocal a\n---@diagnostic enable: unused-local\nlocal b\n"
+	checkDiagnostics(t, diagnostics(text, common.LuaVersion54), []string{
+		"4:34 unused-suppression unused suppression, no 'unused-local' diagnostic is reported here",
+		"6:34 unused-suppression unknown diagnostic code 'no-such'",
+		"11:6 unused-local unused local 'b'",
+	})
+}
@@ -0,0 +1,861 @@
+package check
+
+import (
+	"mylua-lsp/lsp/ast"
+	"mylua-lsp/lsp/compiler"
+	"sort"
+	"strings"
+)
+
+/*
+单个文件的语义分析，不依赖其他文件：
+  - 作用域和变量，每个名字引用的变量
+  - 函数和表的字段
+  - 注释标记以及注释关联的语句
+*/
+
+// funcName 函数定义的名字信息，分析函数体之前先确定
+type funcName struct {
+	name      string
+	loc       Location
+	comment   *ast.AnnotateBlock
+	selfTable *ast.TableInfo
+}
+
+type fileAnalyzer struct {
+	file     *ast.FileInfo
+	scope    *ast.ScopeInfo
+	funcInfo *ast.FuncInfo
+	scopeLv  int // 当前函数内 block 的嵌套层数
+
+	headBlocks map[int]*ast.AnnotateBlock // 行首开始的注释块，key 是最后一行
+	tailBlocks map[int]*ast.AnnotateBlock // 语句后面的尾注释，key 是所在的行
+	funcNames  map[*ast.FuncDefExp]funcName
+	blockTypes map[*ast.AnnotateBlock][]any // 注释块里定义的 *Type_Class 和 *Type_Enum
+}
+
+// AnalyzeFile 单个文件的语义分析
+func AnalyzeFile(result *compiler.ParseResult) *ast.FileInfo {
+	var file = &ast.FileInfo{
+		Source:      result.Source,
+		Block:       result.Block,
+		ParseErrors: result.ErrList,
+		GlobalMaps:  map[string]*ast.VarInfo{},
+		CommentMap:  result.CommentMap,
+		Annotate:    &ast.AnnotateFile{},
+		NameVarMap:  map[*ast.NameExp]*ast.VarInfo{},
+		TableMap:    map[*ast.TableConstructorExp]*ast.TableInfo{},
+		FuncMap:     map[*ast.FuncDefExp]*ast.FuncInfo{},
+		StatComment: map[ast.Stat]*ast.AnnotateBlock{},
+		FieldRefMap: map[string][]ast.FieldRef{},
+		TypeRefMap:  map[string][]ast.NameAndLoc{},
+	}
+	var a = &fileAnalyzer{
+		file:       file,
+		headBlocks: map[int]*ast.AnnotateBlock{},
+		tailBlocks: map[int]*ast.AnnotateBlock{},
+		funcNames:  map[*ast.FuncDefExp]funcName{},
+		blockTypes: map[*ast.AnnotateBlock][]any{},
+	}
+	a.parseAnnotates()
+	a.analyzeMain()
+	return file
+}
+
+// parseAnnotates 解析所有的注释块，收集 @class @alias @enum 定义
+func (a *fileAnalyzer) parseAnnotates() {
+	var keys = make([]int, 0, len(a.file.CommentMap))
+	for key := range a.file.CommentMap {
+		keys = append(keys, key)
+	}
+	sort.Ints(keys)
+
+	var annotate = a.file.Annotate
+	for _, key := range keys {
+		var commentBlock = a.file.CommentMap[key]
+		if len(commentBlock.List) == 0 {
+			continue
+		}
+		block, errList := compiler.ParseAnnotateBlock(commentBlock)
+		annotate.ErrList = append(annotate.ErrList, errList...)
+		annotate.BlockList = append(annotate.BlockList, block)
+		if commentBlock.List[0].HeadFlag {
+			a.headBlocks[block.Loc.End.GetLine()] = block
+		} else {
+			a.tailBlocks[block.Loc.Start.GetLine()] = block
+		}
+		a.collectTypes(block)
+		a.collectTypeRefs(block)
+	}
+}
+
+// collectTypes 收集注释块里定义的类型，@field 属于前面最近的 @class
+func (a *fileAnalyzer) collectTypes(block *ast.AnnotateBlock) {
+	var annotate = a.file.Annotate
+	var class *ast.Type_Class
+	for _, line := range block.LineList {
+		switch state := line.State.(type) {
+		case *ast.AnnotateClassState:
+			class = &ast.Type_Class{
+				NameAndLoc:       state.NameAndLoc,
+				ParentTypeList:   state.ParentTypeList,
+				GenericParamList: state.GenericParamList,
+				Comment:          joinDoc(block.Doc, state.Comment),
+				File:             a.file,
+			}
+			annotate.ClassList = append(annotate.ClassList, class)
+			a.blockTypes[block] = append(a.blockTypes[block], class)
+		case *ast.AnnotateFieldState:
+			if class == nil {
+				continue
+			}
+			class.FieldList = append(class.FieldList, ast.Type_ClassField{
+				NameAndLoc: state.NameAndLoc,
+				Type:       state.FieldType,
+				Comment:    state.Comment,
+				IsOptional: state.IsOptional,
+			})
+		case *ast.AnnotateAliasState:
+			annotate.AliasList = append(annotate.AliasList, &ast.Type_Alias{
+				NameAndLoc: state.NameAndLoc,
+				Type:       state.Type,
+				Comment:    joinDoc(block.Doc, state.Comment),
+				File:       a.file,
+			})
+		case *ast.AnnotateEnumState:
+			var enum = &ast.Type_Enum{
+				NameAndLoc: state.NameAndLoc,
+				Comment:    joinDoc(block.Doc, state.Comment),
+				File:       a.file,
+			}
+			annotate.EnumList = append(annotate.EnumList, enum)
+			a.blockTypes[block] = append(a.blockTypes[block], enum)
+		}
+	}
+}
+
+// collectTypeRefs 收集注释块里引用的类型名
+func (a *fileAnalyzer) collectTypeRefs(block *ast.AnnotateBlock) {
+	var add = func(list ...ast.TypeBase) {
+		for _, t := range list {
+			walkTypeNames(t, func(name ast.NameAndLoc) bool {
+				a.file.TypeRefMap[name.Name] = append(a.file.TypeRefMap[name.Name], name)
+				return true
+			})
+		}
+	}
+	for _, line := range block.LineList {
+		switch state := line.State.(type) {
+		case *ast.AnnotateGenericState:
+			for _, param := range state.ParamList {
+				add(param.Type)
+			}
+		case *ast.AnnotateTypeState:
+			add(state.TypeList...)
+		case *ast.AnnotateParamState:
+			add(state.ParamType)
+		case *ast.AnnotateReturnState:
+			add(state.ReturnTypeList...)
+		case *ast.AnnotateClassState:
+			for _, param := range state.GenericParamList {
+				add(param.Type)
+			}
+			add(state.ParentTypeList...)
+		case *ast.AnnotateFieldState:
+			add(state.FieldType)
+		case *ast.AnnotateAliasState:
+			add(state.Type)
+		case *ast.AnnotateOverloadState:
+			add(state.Fun)
+		}
+	}
+}
+
+// joinDoc 合并注释块的说明和注释标记后面的说明
+func joinDoc(doc, comment string) string {
+	if doc == "" {
+		return comment
+	}
+	if comment == "" {
+		return doc
+	}
+	return doc + "\n\n" + comment
+}
+
+// findComment 查找 loc 前面紧挨着的注释，没有时使用同一行后面的尾注释
+func (a *fileAnalyzer) findComment(loc Location, owner ast.Stat) *ast.AnnotateBlock {
+	if block := a.headBlocks[loc.Start.GetLine()-1]; block != nil && block.Stat == nil {
+		block.Stat = owner
+		return block
+	}
+	if block := a.tailBlocks[loc.End.GetLine()]; block != nil && block.Stat == nil && !block.Loc.Start.Before(loc.End) {
+		block.Stat = owner
+		block.TailFlag = true
+		return block
+	}
+	return nil
+}
+
+func (a *fileAnalyzer) openScope(loc Location) *ast.ScopeInfo {
+	var scope = &ast.ScopeInfo{
+		Parent: a.scope,
+		Loc:    loc,
+		Func:   a.funcInfo,
+	}
+	if a.scope != nil {
+		a.scope.SubScopes = append(a.scope.SubScopes, scope)
+	}
+	a.scope = scope
+	return scope
+}
+
+func (a *fileAnalyzer) closeScope() {
+	a.scope = a.scope.Parent
+}
+
+func (a *fileAnalyzer) analyzeMain() {
+	var file = a.file
+	var mainFunc = &ast.FuncInfo{
+		Name: "main",
+		File: file,
+	}
+	file.MainFunc = mainFunc
+	file.FuncList = append(file.FuncList, mainFunc)
+	a.funcInfo = mainFunc
+
+	var end = Position{Line: int32(max(file.Source.GetLineNum(), 1))}
+	mainFunc.Scope = a.openScope(Location{End: end})
+	a.analyzeStats(file.Block.Stats)
+	a.closeScope()
+}
+
+func (a *fileAnalyzer) analyzeStats(stats []ast.Stat) {
+	for _, stat := range stats {
+		a.analyzeStat(stat)
+	}
+}
+
+// analyzeBlock 分析一个新作用域的 block
+func (a *fileAnalyzer) analyzeBlock(block *ast.Block) {
+	a.openScope(block.Loc)
+	a.scopeLv++
+	a.analyzeStats(block.Stats)
+	a.scopeLv--
+	a.closeScope()
+}
+
+func (a *fileAnalyzer) analyzeStat(stat ast.Stat) {
+	switch s := stat.(type) {
+	case *ast.LocalVarDeclStat:
+		a.analyzeLocalVarDecl(s, a.statComment(s))
+	case *ast.LocalFuncDefStat:
+		var comment = a.statComment(s)
+		var varInfo = a.addLocalVar(s.Name, ast.VarKindLocalFunc, s, comment)
+		varInfo.VisiblePos = s.Name.Loc.End
+		varInfo.ValueExp = s.FuncDef
+		a.funcNames[s.FuncDef] = funcName{name: s.Name.TokenStr, loc: s.Name.Loc, comment: comment}
+		a.analyzeExp(s.FuncDef)
+	case *ast.AssignStat:
+		a.analyzeAssign(s, a.statComment(s))
+	case *ast.FuncCallExp:
+		a.statComment(s)
+		a.analyzeExp(s)
+	case *ast.DoStat:
+		a.analyzeBlock(s.Block)
+	case *ast.WhileStat:
+		a.analyzeExp(s.Exp)
+		a.analyzeBlock(s.Block)
+	case *ast.RepeatStat:
+		// until 的条件可以使用 block 里的局部变量
+		a.openScope(Location{Start: s.Block.Loc.Start, End: s.Loc.End})
+		a.scopeLv++
+		a.analyzeStats(s.Block.Stats)
+		a.analyzeExp(s.Exp)
+		a.scopeLv--
+		a.closeScope()
+	case *ast.IfStat:
+		for i, block := range s.Blocks {
+			if i < len(s.Exps) {
+				a.analyzeExp(s.Exps[i])
+			}
+			a.analyzeBlock(block)
+		}
+	case *ast.ForNumStat:
+		a.analyzeExp(s.InitExp)
+		a.analyzeExp(s.LimitExp)
+		a.analyzeExp(s.StepExp)
+		a.openScope(s.Block.Loc)
+		a.scopeLv++
+		var varInfo = a.addLocalVar(s.VarName, ast.VarKindFor, s, nil)
+		varInfo.VisiblePos = s.Block.Loc.Start
+		a.analyzeStats(s.Block.Stats)
+		a.scopeLv--
+		a.closeScope()
+	case *ast.ForInStat:
+		for _, exp := range s.ExpList {
+			a.analyzeExp(exp)
+		}
+		a.openScope(s.Block.Loc)
+		a.scopeLv++
+		for i, name := range s.NameList {
+			var varInfo = a.addLocalVar(name, ast.VarKindFor, s, nil)
+			varInfo.VisiblePos = s.Block.Loc.Start
+			// 值是迭代函数的第 i 个返回值
+			if len(s.ExpList) > 0 {
+				varInfo.ValueExp, varInfo.ValueIdx = s.ExpList[0], i
+			}
+		}
+		a.analyzeStats(s.Block.Stats)
+		a.scopeLv--
+		a.closeScope()
+	case *ast.RetStat:
+		for _, exp := range s.ExpList {
+			a.analyzeExp(exp)
+		}
+		a.funcInfo.ReturnList = append(a.funcInfo.ReturnList, &ast.ReturnInfo{Stat: s})
+		if a.funcInfo == a.file.MainFunc && a.file.ReturnExp == nil && len(s.ExpList) > 0 {
+			a.file.ReturnExp = s.ExpList[0]
+		}
+	case *ast.LabelStat:
+		var label = &ast.LabelInfo{
+			Name:    s.Name.TokenStr,
+			ScopeLv: a.scopeLv,
+			Loc:     s.Name.Loc,
+		}
+		a.funcInfo.LabelInfoList = append(a.funcInfo.LabelInfoList, label)
+		a.scope.LabelInfoList = append(a.scope.LabelInfoList, label)
+	case *ast.BadStat:
+		if s.Exp != nil {
+			a.analyzeExp(s.Exp)
+		}
+	}
+}
+
+// statComment 语句关联的注释
+func (a *fileAnalyzer) statComment(stat ast.Stat) *ast.AnnotateBlock {
+	var comment = a.findComment(stat.GetLoc(), stat)
+	if comment != nil {
+		a.file.StatComment[stat] = comment
+	}
+	return comment
+}
+
+// valueOf 第 i 个变量赋的值，超出值的个数时取最后一个函数调用的其他返回值
+func valueOf(expList []ast.Exp, i int) (ast.Exp, int) {
+	if i < len(expList) {
+		return expList[i], 0
+	}
+	if len(expList) == 0 {
+		return nil, 0
+	}
+	switch last := expList[len(expList)-1].(type) {
+	case *ast.FuncCallExp, *ast.VarargExp:
+		return last, i - len(expList) + 1
+	}
+	return nil, 0
+}
+
+// addLocalVar 在当前作用域添加局部变量
+func (a *fileAnalyzer) addLocalVar(name ast.Token, kind ast.VarKind, stat ast.Stat, comment *ast.AnnotateBlock) *ast.VarInfo {
+	var varInfo = &ast.VarInfo{
+		Name:    name.TokenStr,
+		Kind:    kind,
+		Loc:     name.Loc,
+		Attr:    name.LocalAttr,
+		File:    a.file,
+		Scope:   a.scope,
+		Func:    a.funcInfo,
+		DefStat: stat,
+		Comment: comment,
+	}
+	// 错误恢复插入的名字是空的
+	if varInfo.Name != "" {
+		a.scope.VarInfoList = append(a.scope.VarInfoList, varInfo)
+	}
+	return varInfo
+}
+
+func (a *fileAnalyzer) analyzeLocalVarDecl(s *ast.LocalVarDeclStat, comment *ast.AnnotateBlock) {
+	for i, exp := range s.ExpList {
+		if funcDef, ok := exp.(*ast.FuncDefExp); ok && i < len(s.NameList) {
+			var name = s.NameList[i]
+			a.funcNames[funcDef] = funcName{name: name.TokenStr, loc: name.Loc, comment: comment}
+		}
+	}
+	for _, exp := range s.ExpList {
+		a.analyzeExp(exp)
+	}
+
+	var typeState, _ = ast.FindState[*ast.AnnotateTypeState](comment)
+	var firstTable *ast.TableInfo
+	for i, name := range s.NameList {
+		var varInfo = a.addLocalVar(name, ast.VarKindLocal, s, comment)
+		varInfo.VisiblePos = s.Loc.End
+		varInfo.ValueExp, varInfo.ValueIdx = valueOf(s.ExpList, i)
+		if typeState != nil && i < len(typeState.TypeList) {
+			varInfo.AnnType = typeState.TypeList[i]
+		}
+		if constructor, ok := varInfo.ValueExp.(*ast.TableConstructorExp); ok {
+			varInfo.Table = a.file.TableMap[constructor]
+		}
+		if i == 0 && len(a.blockTypes[comment]) > 0 {
+			if varInfo.Table == nil {
+				varInfo.Table = &ast.TableInfo{Loc: varInfo.Loc, File: a.file}
+			}
+			firstTable = varInfo.Table
+		}
+	}
+	a.bindTypes(comment, firstTable)
+}
+
+// bindTypes 注释里的 @class @enum 关联后面定义的表
+func (a *fileAnalyzer) bindTypes(comment *ast.AnnotateBlock, table *ast.TableInfo) {
+	if table == nil {
+		return
+	}
+	for _, t := range a.blockTypes[comment] {
+		switch t := t.(type) {
+		case *ast.Type_Class:
+			t.Table = table
+			table.ClassName = t.NameAndLoc.Name
+		case *ast.Type_Enum:
+			t.Table = table
+			table.ClassName = t.NameAndLoc.Name
+		}
+	}
+}
+
+func (a *fileAnalyzer) analyzeAssign(s *ast.AssignStat, comment *ast.AnnotateBlock) {
+	// 先分析左边的前缀，确定字段所属的表，函数定义里的 self 需要用到
+	var tables = make([]*ast.TableInfo, len(s.VarList))
+	for i, target := range s.VarList {
+		switch target := target.(type) {
+		case *ast.TableAccessExp:
+			a.analyzeExp(target.PrefixExp)
+			a.analyzeExp(target.KeyExp)
+			a.addFieldRef(target.KeyExp, target.PrefixExp, nil, target.IsWriteExp)
+			tables[i] = a.tableOf(target.PrefixExp, true)
+		case *ast.NameExp:
+			a.resolveName(target)
+		default:
+			a.analyzeExp(target)
+		}
+		if i >= len(s.ExpList) {
+			continue
+		}
+		funcDef, ok := s.ExpList[i].(*ast.FuncDefExp)
+		if !ok {
+			continue
+		}
+		var name = funcName{comment: comment}
+		name.name, name.loc = exprPath(target, funcDef.IsColon)
+		if funcDef.IsColon {
+			name.selfTable = tables[i]
+		}
+		a.funcNames[funcDef] = name
+	}
+
+	for _, exp := range s.ExpList {
+		a.analyzeExp(exp)
+	}
+
+	var typeState, _ = ast.FindState[*ast.AnnotateTypeState](comment)
+	var firstTable *ast.TableInfo
+	for i, target := range s.VarList {
+		var value, valueIdx = valueOf(s.ExpList, i)
+		var annType ast.TypeBase
+		if typeState != nil && i < len(typeState.TypeList) {
+			annType = typeState.TypeList[i]
+		}
+		var table *ast.TableInfo
+		switch target := target.(type) {
+		case *ast.NameExp:
+			table = a.writeName(target, s, value, valueIdx, comment, annType)
+		case *ast.TableAccessExp:
+			if name := a.globalAccessName(target); name != "" {
+				table = a.writeGlobal(a.globalVar(name, target.KeyExp.GetLoc()), s, target.KeyExp.GetLoc(), value, valueIdx, comment, annType)
+				break
+			}
+			table = a.writeField(tables[i], target, value, valueIdx, comment, annType)
+		}
+		if i == 0 {
+			firstTable = table
+		}
+	}
+	if len(a.blockTypes[comment]) > 0 && firstTable == nil && len(s.VarList) > 0 {
+		firstTable = a.tableOf(s.VarList[0], true)
+	}
+	a.bindTypes(comment, firstTable)
+}
+
+// exprPath 赋值目标显示用的名字，例如 M.sub:foo ，以及最后一个名字的位置
+func exprPath(exp ast.Exp, isColon bool) (string, Location) {
+	switch exp := exp.(type) {
+	case *ast.NameExp:
+		return exp.Name, exp.Loc
+	case *ast.TableAccessExp:
+		var key, ok = exp.KeyExp.(*ast.StringExp)
+		if !ok {
+			return "", exp.Loc
+		}
+		var prefix, _ = exprPath(exp.PrefixExp, false)
+		var sep = "."
+		if isColon {
+			sep = ":"
+		}
+		if prefix == "" {
+			return key.Str, key.Loc
+		}
+		return prefix + sep + key.Str, key.Loc
+	case *ast.ParensExp:
+		return exprPath(exp.Exp, isColon)
+	}
+	return "", exp.GetLoc()
+}
+
+// resolveName 查找名字引用的变量，找不到局部变量时是全局变量
+func (a *fileAnalyzer) resolveName(exp *ast.NameExp) *ast.VarInfo {
+	var varInfo = a.scope.FindVar(exp.Name, exp.Loc.Start)
+	if varInfo == nil {
+		varInfo = a.globalVar(exp.Name, exp.Loc)
+	}
+	a.file.NameVarMap[exp] = varInfo
+	return varInfo
+}
+
+// globalVar 文件里的全局变量，不存在时创建
+func (a *fileAnalyzer) globalVar(name string, loc Location) *ast.VarInfo {
+	var varInfo = a.file.GlobalMaps[name]
+	if varInfo == nil {
+		varInfo = &ast.VarInfo{
+			Name: name,
+			Kind: ast.VarKindGlobal,
+			Loc:  loc,
+			File: a.file,
+		}
+		a.file.GlobalMaps[name] = varInfo
+	}
+	return varInfo
+}
+
+// globalAccessName _G.name 这样的访问返回 name
+func (a *fileAnalyzer) globalAccessName(exp *ast.TableAccessExp) string {
+	var prefix, ok = exp.PrefixExp.(*ast.NameExp)
+	if !ok || prefix.Name != "_G" {
+		return ""
+	}
+	if varInfo := a.file.NameVarMap[prefix]; varInfo == nil || varInfo.IsLocal() {
+		return ""
+	}
+	if key, ok := exp.KeyExp.(*ast.StringExp); ok {
+		return key.Str
+	}
+	return ""
+}
+
+// writeName 对名字赋值，返回变量关联的表
+func (a *fileAnalyzer) writeName(exp *ast.NameExp, stat ast.Stat, value ast.Exp, valueIdx int,
+	comment *ast.AnnotateBlock, annType ast.TypeBase) *ast.TableInfo {
+	var varInfo = a.file.NameVarMap[exp]
+	// 全局变量第一次赋值是定义，不算引用
+	if varInfo.IsLocal() || varInfo.DefStat != nil {
+		varInfo.RefList = append(varInfo.RefList, ast.VarRef{Exp: exp, IsWrite: true})
+	}
+	if varInfo.IsLocal() {
+		return varInfo.Table
+	}
+	return a.writeGlobal(varInfo, stat, exp.Loc, value, valueIdx, comment, annType)
+}
+
+// writeGlobal 对全局变量赋值，第一次赋值是定义，后面的是引用
+func (a *fileAnalyzer) writeGlobal(varInfo *ast.VarInfo, stat ast.Stat, loc Location, value ast.Exp, valueIdx int,
+	comment *ast.AnnotateBlock, annType ast.TypeBase) *ast.TableInfo {
+	if varInfo.DefStat != nil {
+		return varInfo.Table
+	}
+	varInfo.Loc = loc
+	varInfo.DefStat = stat
+	varInfo.ValueExp = value
+	varInfo.ValueIdx = valueIdx
+	varInfo.Comment = comment
+	varInfo.AnnType = annType
+	if constructor, ok := value.(*ast.TableConstructorExp); ok && varInfo.Table == nil {
+		varInfo.Table = a.file.TableMap[constructor]
+		setGlobalPath(varInfo.Table, varInfo.Name)
+	}
+	return varInfo.Table
+}
+
+// setGlobalPath 设置全局表以及嵌套的子表的路径
+func setGlobalPath(table *ast.TableInfo, path string) {
+	if table == nil || table.GlobalPath != "" {
+		return
+	}
+	table.GlobalPath = path
+	for _, field := range table.FieldList {
+		setGlobalPath(field.SubTable, path+"."+field.Name)
+	}
+}
+
+// writeField 对表的字段赋值，第一次有值的赋值是定义。返回字段关联的表
+func (a *fileAnalyzer) writeField(table *ast.TableInfo, exp *ast.TableAccessExp, value ast.Exp, valueIdx int,
+	comment *ast.AnnotateBlock, annType ast.TypeBase) *ast.TableInfo {
+	var key, ok = exp.KeyExp.(*ast.StringExp)
+	if table == nil || !ok {
+		return nil
+	}
+	var field = table.GetField(key.Str)
+	if field == nil {
+		field = table.AddField(&ast.FieldInfo{Name: key.Str})
+	} else if field.ValueExp != nil {
+		return field.SubTable
+	}
+	field.Loc = key.Loc
+	field.DefExp = exp
+	field.ValueExp = value
+	field.ValueIdx = valueIdx
+	field.Comment = comment
+	field.AnnType = annType
+	if funcDef, ok := value.(*ast.FuncDefExp); ok {
+		field.IsMethod = funcDef.IsColon
+	}
+	if constructor, ok := value.(*ast.TableConstructorExp); ok && field.SubTable == nil {
+		field.SubTable = a.file.TableMap[constructor]
+		if table.GlobalPath != "" {
+			setGlobalPath(field.SubTable, table.GlobalPath+"."+field.Name)
+		}
+	}
+	return field.SubTable
+}
+
+// tableOf 表达式静态对应的表，例如变量 M 或者 M.sub 。create 为 true 时不存在就创建
+func (a *fileAnalyzer) tableOf(exp ast.Exp, create bool) *ast.TableInfo {
+	switch exp := exp.(type) {
+	case *ast.NameExp:
+		var varInfo = a.file.NameVarMap[exp]
+		if varInfo == nil {
+			return nil
+		}
+		if varInfo.Kind == ast.VarKindSelf {
+			return varInfo.Func.SelfTable
+		}
+		if varInfo.Table == nil && create {
+			varInfo.Table = &ast.TableInfo{Loc: varInfo.Loc, File: a.file}
+			if !varInfo.IsLocal() {
+				varInfo.Table.GlobalPath = varInfo.Name
+			}
+		}
+		return varInfo.Table
+	case *ast.ParensExp:
+		return a.tableOf(exp.Exp, create)
+	case *ast.TableAccessExp:
+		var key, ok = exp.KeyExp.(*ast.StringExp)
+		if !ok {
+			return nil
+		}
+		if name := a.globalAccessName(exp); name != "" {
+			var varInfo = a.globalVar(name, key.Loc)
+			if varInfo.Table == nil && create {
+				varInfo.Table = &ast.TableInfo{Loc: key.Loc, File: a.file, GlobalPath: name}
+			}
+			return varInfo.Table
+		}
+		var table = a.tableOf(exp.PrefixExp, create)
+		if table == nil {
+			return nil
+		}
+		var field = table.GetField(key.Str)
+		if field == nil {
+			if !create {
+				return nil
+			}
+			field = table.AddField(&ast.FieldInfo{Name: key.Str, Loc: key.Loc, DefExp: exp})
+		}
+		if field.SubTable == nil && create {
+			field.SubTable = &ast.TableInfo{Loc: field.Loc, File: a.file}
+			if table.GlobalPath != "" {
+				field.SubTable.GlobalPath = table.GlobalPath + "." + field.Name
+			}
+		}
+		return field.SubTable
+	}
+	return nil
+}
+
+func (a *fileAnalyzer) analyzeExp(exp ast.Exp) {
+	switch e := exp.(type) {
+	case nil:
+	case *ast.NameExp:
+		var varInfo = a.resolveName(e)
+		varInfo.RefList = append(varInfo.RefList, ast.VarRef{Exp: e})
+	case *ast.ParensExp:
+		a.analyzeExp(e.Exp)
+	case *ast.UnopExp:
+		a.analyzeExp(e.Exp)
+	case *ast.BinopExp:
+		a.analyzeExp(e.Exp1)
+		a.analyzeExp(e.Exp2)
+	case *ast.TableAccessExp:
+		a.analyzeExp(e.PrefixExp)
+		a.analyzeExp(e.KeyExp)
+		a.addFieldRef(e.KeyExp, e.PrefixExp, nil, e.IsWriteExp)
+	case *ast.FuncCallExp:
+		a.analyzeExp(e.PrefixExp)
+		if e.NameExp != nil {
+			a.addFieldRef(e.NameExp, e.PrefixExp, nil, false)
+		}
+		for _, arg := range e.Args {
+			a.analyzeExp(arg)
+		}
+	case *ast.TableConstructorExp:
+		a.analyzeConstructor(e)
+	case *ast.FuncDefExp:
+		a.analyzeFuncDef(e)
+	}
+}
+
+// addFieldRef 记录 t.k 这样的字段引用，t["k"] 记录引号里面的内容
+func (a *fileAnalyzer) addFieldRef(keyExp ast.Exp, prefix ast.Exp, table *ast.TableInfo, isWrite bool) {
+	var key, ok = keyExp.(*ast.StringExp)
+	if !ok {
+		return
+	}
+	var loc = key.Loc
+	if !isNameKey(key) {
+		loc, _ = stringContentLoc(key)
+	}
+	a.file.FieldRefMap[key.Str] = append(a.file.FieldRefMap[key.Str], ast.FieldRef{
+		Loc:     loc,
+		Prefix:  prefix,
+		Table:   table,
+		IsWrite: isWrite,
+	})
+}
+
+// stringContentLoc 字符串引号里面的内容的位置。内容和字符串的值不一样时，例如有转义或者跨行，返回整个字符串的位置和 false
+func stringContentLoc(str *ast.StringExp) (Location, bool) {
+	var raw = str.RawStr
+	var open = 1
+	if strings.HasPrefix(raw, "[") {
+		open = strings.IndexByte(raw[1:], '[') + 2
+	}
+	if str.Loc.Start.Line != str.Loc.End.Line || len(raw) < open*2 || raw[open:len(raw)-open] != str.Str {
+		return str.Loc, false
+	}
+	return Location{
+		Start: Position{Line: str.Loc.Start.Line, Column: str.Loc.Start.Column + int32(open)},
+		End:   Position{Line: str.Loc.End.Line, Column: str.Loc.End.Column - int32(open)},
+	}, true
+}
+
+func (a *fileAnalyzer) analyzeConstructor(exp *ast.TableConstructorExp) {
+	var table = &ast.TableInfo{
+		Loc:  exp.Loc,
+		File: a.file,
+	}
+	a.file.TableMap[exp] = table
+	for i, value := range exp.ValExps {
+		var keyExp ast.Exp
+		if i < len(exp.KeyExps) {
+			keyExp = exp.KeyExps[i]
+		}
+		var field *ast.FieldInfo
+		if key, ok := keyExp.(*ast.StringExp); ok {
+			a.addFieldRef(key, nil, table, true)
+			var comment = a.findComment(Location{Start: key.Loc.Start, End: value.GetLoc().End}, key)
+			field = table.AddField(&ast.FieldInfo{
+				Name:     key.Str,
+				Loc:      key.Loc,
+				DefExp:   key,
+				ValueExp: value,
+				Comment:  comment,
+			})
+			if typeState, ok := ast.FindState[*ast.AnnotateTypeState](comment); ok && len(typeState.TypeList) > 0 {
+				field.AnnType = typeState.TypeList[0]
+			}
+			if funcDef, ok := value.(*ast.FuncDefExp); ok {
+				a.funcNames[funcDef] = funcName{name: key.Str, loc: key.Loc, comment: comment}
+			}
+		} else {
+			a.analyzeExp(keyExp)
+		}
+		a.analyzeExp(value)
+		if constructor, ok := value.(*ast.TableConstructorExp); ok && field != nil && field.SubTable == nil {
+			field.SubTable = a.file.TableMap[constructor]
+		}
+	}
+}
+
+func (a *fileAnalyzer) analyzeFuncDef(funcDef *ast.FuncDefExp) {
+	var name = a.funcNames[funcDef]
+	var funcInfo = &ast.FuncInfo{
+		Parent:    a.funcInfo,
+		FuncDef:   funcDef,
+		Name:      name.name,
+		NameLoc:   name.loc,
+		File:      a.file,
+		Comment:   name.comment,
+		SelfTable: name.selfTable,
+	}
+	if name.name == "" {
+		// 匿名函数使用 function 关键字的位置
+		var start = funcDef.Loc.Start
+		funcInfo.NameLoc = Location{Start: start, End: Position{Line: start.Line, Column: start.Column + int32(len("function"))}}
+	}
+	a.file.FuncMap[funcDef] = funcInfo
+	a.file.FuncList = append(a.file.FuncList, funcInfo)
+	a.funcInfo.SubFuncs = append(a.funcInfo.SubFuncs, funcInfo)
+	a.scope.FuncInfoList = append(a.scope.FuncInfoList, funcInfo)
+
+	var oldFunc, oldScopeLv = a.funcInfo, a.scopeLv
+	a.funcInfo, a.scopeLv = funcInfo, 0
+	funcInfo.Scope = a.openScope(funcDef.Loc)
+	if funcDef.IsColon {
+		funcInfo.SelfVar = &ast.VarInfo{
+			Name:       "self",
+			Kind:       ast.VarKindSelf,
+			Loc:        funcInfo.NameLoc,
+			File:       a.file,
+			Scope:      a.scope,
+			Func:       funcInfo,
+			DefStat:    funcDef,
+			Table:      funcInfo.SelfTable,
+			VisiblePos: funcDef.Loc.Start,
+		}
+		a.scope.VarInfoList = append(a.scope.VarInfoList, funcInfo.SelfVar)
+	}
+	for _, param := range funcDef.ParList {
+		var varInfo = a.addLocalVar(param, ast.VarKindParam, funcDef, nil)
+		varInfo.VisiblePos = funcDef.Loc.Start
+		varInfo.AnnType, varInfo.Comment = paramAnnotate(name.comment, param.TokenStr)
+		funcInfo.ParamList = append(funcInfo.ParamList, varInfo)
+	}
+	a.analyzeStats(funcDef.Block.Stats)
+	a.closeScope()
+	a.funcInfo, a.scopeLv = oldFunc, oldScopeLv
+}
+
+// paramAnnotate 函数注释里对参数的 @param 标记的类型，可选参数加上 nil
+func paramAnnotate(comment *ast.AnnotateBlock, name string) (ast.TypeBase, *ast.AnnotateBlock) {
+	var state = FindParamState(comment, name)
+	if state == nil {
+		return nil, nil
+	}
+	if state.IsOptional {
+		return &ast.Type_Union{TypeList: []ast.TypeBase{state.ParamType, &ast.Type_LiteralValue{Type: ast.LiteralValueNil}}}, comment
+	}
+	return state.ParamType, comment
+}
+
+// FindParamState 函数注释里参数的 @param 标记
+func FindParamState(comment *ast.AnnotateBlock, name string) *ast.AnnotateParamState {
+	if comment == nil {
+		return nil
+	}
+	for _, line := range comment.LineList {
+		if state, ok := line.State.(*ast.AnnotateParamState); ok && state.NameAndLoc.Name == name {
+			return state
+		}
+	}
+	return nil
+}
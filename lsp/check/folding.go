@@ -0,0 +1,179 @@
+package check
+
+import (
+	"mylua-lsp/lsp/ast"
+	"sort"
+	"strings"
+)
+
+// FoldingKind 折叠范围的种类
+type FoldingKind uint8
+
+const (
+	FoldingBlock   FoldingKind = iota // 语句块、表构造和长字符串
+	FoldingComment                    // 连续的注释
+	FoldingRegion                     // --region 和 --endregion 之间
+)
+
+// FoldingRange 可以折叠的行，StartLine 保持显示，折叠后面到 EndLine 的行
+type FoldingRange struct {
+	StartLine int
+	EndLine   int
+	Kind      FoldingKind
+}
+
+// foldingBuilder 收集文件的折叠范围
+type foldingBuilder struct {
+	file         *ast.FileInfo
+	commentLines map[int]bool // 只有注释的行
+	list         []FoldingRange
+}
+
+// FoldingRanges 文件里所有的折叠范围，按开始的行排序，同一行开始的只保留最大的
+func FoldingRanges(file *ast.FileInfo) []FoldingRange {
+	var b = &foldingBuilder{file: file, commentLines: map[int]bool{}}
+	b.comments()
+	ast.Inspect(file.Block, func(node ast.Stat) bool {
+		if node != nil {
+			b.node(node)
+		}
+		return node != nil
+	})
+	sort.SliceStable(b.list, func(i, j int) bool {
+		var a, c = b.list[i], b.list[j]
+		return a.StartLine < c.StartLine || a.StartLine == c.StartLine && a.EndLine > c.EndLine
+	})
+	var result = b.list[:0]
+	for _, r := range b.list {
+		if n := len(result); n > 0 && result[n-1].StartLine == r.StartLine {
+			continue
+		}
+		result = append(result, r)
+	}
+	return result
+}
+
+// add 添加折叠范围，只有一行的忽略
+func (b *foldingBuilder) add(start, end int, kind FoldingKind) {
+	if end > start {
+		b.list = append(b.list, FoldingRange{StartLine: start, EndLine: end, Kind: kind})
+	}
+}
+
+// addLoc 折叠到结束位置的前一行，结束的 end } ]] 保持显示
+func (b *foldingBuilder) addLoc(loc Location) {
+	b.add(loc.Start.GetLine(), loc.End.GetLine()-1, FoldingBlock)
+}
+
+// node 语法树节点的折叠范围
+func (b *foldingBuilder) node(node ast.Stat) {
+	switch n := node.(type) {
+	case *ast.DoStat, *ast.WhileStat, *ast.RepeatStat, *ast.ForNumStat, *ast.ForInStat,
+		*ast.FuncDefExp, *ast.TableConstructorExp:
+		b.addLoc(node.GetLoc())
+	case *ast.IfStat:
+		b.ifStat(n)
+	case *ast.StringExp:
+		if strings.HasPrefix(n.RawStr, "[") {
+			b.addLoc(n.Loc)
+		}
+	}
+}
+
+// ifStat 每个分支单独折叠，到下一个分支的前一行为止
+func (b *foldingBuilder) ifStat(stat *ast.IfStat) {
+	var headers = make([]int, len(stat.Blocks))
+	for i, block := range stat.Blocks {
+		switch {
+		case i == 0:
+			headers[i] = stat.Loc.Start.GetLine()
+		case i < len(stat.Exps):
+			headers[i] = stat.Exps[i].GetLoc().Start.GetLine()
+		default:
+			headers[i] = b.prevTokenLine(block.Loc.Start)
+		}
+	}
+	for i, start := range headers {
+		var end = stat.Loc.End.GetLine()
+		if i+1 < len(headers) {
+			end = headers[i+1]
+		}
+		b.add(start, end-1, FoldingBlock)
+	}
+}
+
+// prevTokenLine pos 前面最近的代码所在的行，跳过空行和注释
+func (b *foldingBuilder) prevTokenLine(pos Position) int {
+	var source = b.file.Source
+	var line = pos.GetLine()
+	if text := source.GetOneLine(line); strings.TrimSpace(text[:min(pos.GetColumn(), len(text))]) != "" {
+		return line
+	}
+	for line--; line > 0; line-- {
+		if !b.commentLines[line] && strings.TrimSpace(source.GetOneLine(line)) != "" {
+			return line
+		}
+	}
+	return 0
+}
+
+// comments 连续的注释块，和 --region --endregion 标记
+func (b *foldingBuilder) comments() {
+	var keys = make([]int, 0, len(b.file.CommentMap))
+	for key := range b.file.CommentMap {
+		keys = append(keys, key)
+	}
+	sort.Ints(keys)
+
+	var regions []int
+	for _, key := range keys {
+		var block = b.file.CommentMap[key]
+		var start = -1 // 当前一段注释的开始行，region 标记会把注释块分开
+		var end = -1
+		var flush = func() {
+			if start >= 0 {
+				b.add(start, end, FoldingComment)
+			}
+			start = -1
+		}
+		for _, line := range block.List {
+			if !line.HeadFlag {
+				continue
+			}
+			for l := line.StartPos.GetLine(); l <= line.EndPos.GetLine(); l++ {
+				b.commentLines[l] = true
+			}
+			if line.ShortFlag {
+				switch regionMarker(line.Str) {
+				case "region":
+					flush()
+					regions = append(regions, line.StartPos.GetLine())
+					continue
+				case "endregion":
+					flush()
+					if n := len(regions); n > 0 {
+						b.add(regions[n-1], line.StartPos.GetLine(), FoldingRegion)
+						regions = regions[:n-1]
+					}
+					continue
+				}
+			}
+			if start < 0 {
+				start = line.StartPos.GetLine()
+			}
+			end = line.EndPos.GetLine()
+		}
+		flush()
+	}
+}
+
+// regionMarker 注释是否为 --region 或者 --endregion ，返回标记的名字
+func regionMarker(comment string) string {
+	var text = strings.TrimLeft(strings.TrimLeft(comment, "-"), " \t")
+	for _, marker := range []string{"region", "endregion"} {
+		if rest, ok := strings.CutPrefix(text, marker); ok && (rest == "" || rest[0] == ' ' || rest[0] == '\t') {
+			return marker
+		}
+	}
+	return ""
+}
@@ -0,0 +1,48 @@
+package check
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// foldingKindNames 测试里显示的折叠种类
+var foldingKindNames = map[FoldingKind]string{FoldingBlock: "block", FoldingComment: "comment", FoldingRegion: "region"}
+
+// foldingRanges 文件的折叠范围，每个是 "开始行-结束行 种类"
+func foldingRanges(text string) []string {
+	var list []string
+	for _, r := range FoldingRanges(analyzeText("a.lua", text)) {
+		list = append(list, fmt.Sprintf("%d-%d %s", r.StartLine, r.EndLine, foldingKindNames[r.Kind]))
+	}
+	return list
+}
+
+func TestFoldingRanges(t *testing.T) {
+	var tests = []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"if arms", "if a then\n  f()\nelseif b then\n  g()\nelse\n  h()\nend\n",
+			[]string{"0-1 block", "2-3 block", "4-5 block"}},
+		{"comment before else", "if a then\n  f()\n-- c\nelse\n  h()\nend\n",
+			[]string{"0-2 block", "3-4 block"}},
+		{"nested regions", "--region one\nlocal a = 1\n--region two\nlocal b = 2\n--endregion\n--endregion\n",
+			[]string{"0-5 region", "2-4 region"}},
+		{"unbalanced regions", "--endregion\nlocal a = 1\n--region open\nlocal b = 2\n", nil},
+		{"comment blocks", "-- a\n-- b\nlocal x = 1\n-- c\n-- d\n-- e\n",
+			[]string{"0-1 comment", "3-5 comment"}},
+		{"region splits comments", "-- a\n--region r\n-- b\n-- c\n--endregion\n",
+			[]string{"1-4 region", "2-3 comment"}},
+		{"long string and comment", "local s = [[\nline\n]]\n--[[ long\ncomment\n]]\nlocal t = {\n  1,\n}\n",
+			[]string{"0-1 block", "3-5 comment", "6-7 block"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := foldingRanges(tt.text); !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,408 @@
+package check
+
+import (
+	"mylua-lsp/lsp/ast"
+)
+
+/*
+类型推导。按需推导，结果缓存在 Project.typeMap 里，工程修改后缓存过期。
+缓存不能放在语法树上，增量解析会复用旧的节点。
+类型用 ast.TypeBase 表示：
+  - ast.LuaType          lua 的基础类型
+  - *ast.Type_xxx        注释标记的类型
+  - *ast.TableInfo       lua 表，关联了 @class 时是对应的类
+  - *ast.FuncInfo        lua 定义的函数
+*/
+
+// TypeOfExp 表达式第一个值的类型，推导不出来时为 nil
+func (p *Project) TypeOfExp(file *ast.FileInfo, exp ast.Exp) ast.TypeBase {
+	return p.TypeOfExpIdx(file, exp, 0)
+}
+
+// TypeOfExpIdx 表达式第 idx 个值的类型，只有函数调用有多个值
+func (p *Project) TypeOfExpIdx(file *ast.FileInfo, exp ast.Exp, idx int) ast.TypeBase {
+	if exp == nil {
+		return nil
+	}
+	if idx > 0 {
+		if call, ok := exp.(*ast.FuncCallExp); ok {
+			return p.callReturn(file, call, idx)
+		}
+		return nil
+	}
+	if p.typeGen != p.gen {
+		p.typeMap = map[ast.Exp]ast.TypeBase{}
+		p.typeGen = p.gen
+	}
+	if t, ok := p.typeMap[exp]; ok {
+		return t
+	}
+	// 先放一个空的缓存，循环引用时得到 nil
+	p.typeMap[exp] = nil
+	var t = p.inferExp(file, exp)
+	p.typeMap[exp] = t
+	return t
+}
+
+func (p *Project) inferExp(file *ast.FileInfo, exp ast.Exp) ast.TypeBase {
+	switch e := exp.(type) {
+	case *ast.NilExp:
+		return ast.LuaTypeNil
+	case *ast.TrueExp, *ast.FalseExp:
+		return ast.LuaTypeBool
+	case *ast.IntegerExp:
+		return ast.LuaTypeInter
+	case *ast.FloatExp:
+		return ast.LuaTypeFloat
+	case *ast.StringExp:
+		return ast.LuaTypeString
+	case *ast.TableConstructorExp:
+		if table := file.TableMap[e]; table != nil {
+			return table
+		}
+	case *ast.FuncDefExp:
+		if funcInfo := file.FuncMap[e]; funcInfo != nil {
+			return funcInfo
+		}
+	case *ast.ParensExp:
+		return p.TypeOfExp(file, e.Exp)
+	case *ast.NameExp:
+		if varInfo := file.NameVarMap[e]; varInfo != nil {
+			return p.TypeOfVar(varInfo)
+		}
+	case *ast.UnopExp:
+		switch e.Op {
+		case ast.TkOpNot:
+			return ast.LuaTypeBool
+		case ast.TkOpNen, ast.TkOpBnot:
+			return ast.LuaTypeInter
+		case ast.TkOpUnm:
+			if t := p.TypeOfExp(file, e.Exp); t == ast.LuaTypeInter || t == ast.LuaTypeFloat {
+				return t
+			}
+			return ast.LuaTypeNumber
+		}
+	case *ast.BinopExp:
+		return p.inferBinop(file, e)
+	case *ast.TableAccessExp:
+		if key, ok := e.KeyExp.(*ast.StringExp); ok {
+			return p.firstMemberType(p.ExpMembers(file, e.PrefixExp, key.Str))
+		}
+		_, value := p.indexTypes(p.TypeOfExp(file, e.PrefixExp))
+		return value
+	case *ast.FuncCallExp:
+		return p.callReturn(file, e, 0)
+	}
+	return nil
+}
+
+func (p *Project) inferBinop(file *ast.FileInfo, e *ast.BinopExp) ast.TypeBase {
+	switch e.Op {
+	case ast.TkOpConcat:
+		return ast.LuaTypeString
+	case ast.TkOpLt, ast.TkOpLe, ast.TkOpGt, ast.TkOpGe, ast.TkOpEq, ast.TkOpNe:
+		return ast.LuaTypeBool
+	case ast.TkOpBand, ast.TkOpBor, ast.TkOpBxor, ast.TkOpShl, ast.TkOpShr, ast.TkOpIdiv:
+		return ast.LuaTypeInter
+	case ast.TkOpDiv, ast.TkOpPow:
+		return ast.LuaTypeFloat
+	case ast.TkOpAdd, ast.TkOpSub, ast.TkOpMul, ast.TkOpMod:
+		var t1, t2 = p.TypeOfExp(file, e.Exp1), p.TypeOfExp(file, e.Exp2)
+		if t1 == ast.LuaTypeInter && t2 == ast.LuaTypeInter {
+			return ast.LuaTypeInter
+		}
+		return ast.LuaTypeNumber
+	case ast.TkOpAnd:
+		return p.TypeOfExp(file, e.Exp2)
+	case ast.TkOpOr:
+		// a or b ，a 为 nil 时取 b
+		var t1, t2 = removeNil(p.TypeOfExp(file, e.Exp1)), p.TypeOfExp(file, e.Exp2)
+		if t1 == nil {
+			return t2
+		}
+		if t2 == nil || p.TypeString(t1) == p.TypeString(t2) {
+			return t1
+		}
+		return &ast.Type_Union{TypeList: []ast.TypeBase{t1, t2}}
+	}
+	return nil
+}
+
+// removeNil 去掉联合类型里的 nil
+func removeNil(t ast.TypeBase) ast.TypeBase {
+	var union, ok = t.(*ast.Type_Union)
+	if !ok {
+		if isNilType(t) {
+			return nil
+		}
+		return t
+	}
+	var list []ast.TypeBase
+	for _, sub := range union.TypeList {
+		if !isNilType(sub) {
+			list = append(list, sub)
+		}
+	}
+	switch len(list) {
+	case 0:
+		return nil
+	case 1:
+		return list[0]
+	}
+	return &ast.Type_Union{TypeList: list}
+}
+
+func isNilType(t ast.TypeBase) bool {
+	switch t := t.(type) {
+	case ast.LuaType:
+		return t == ast.LuaTypeNil
+	case *ast.Type_LiteralValue:
+		return t.Type == ast.LiteralValueNil
+	case *ast.Type_Identifier:
+		return t.NameAndLoc.Name == "nil"
+	}
+	return false
+}
+
+// TypeOfVar 变量的类型，注释标记的类型优先
+func (p *Project) TypeOfVar(varInfo *ast.VarInfo) ast.TypeBase {
+	if varInfo.AnnType != nil {
+		return varInfo.AnnType
+	}
+	switch varInfo.Kind {
+	case ast.VarKindSelf:
+		if varInfo.Func.SelfTable != nil {
+			return varInfo.Func.SelfTable
+		}
+		return nil
+	case ast.VarKindFor:
+		return p.forVarType(varInfo)
+	case ast.VarKindGlobal:
+		// 其他文件里定义的全局变量
+		if varInfo.DefStat == nil {
+			if def := p.GetGlobal(varInfo.Name); def != nil && def != varInfo {
+				return p.TypeOfVar(def)
+			}
+		}
+	}
+	if varInfo.ValueExp != nil {
+		if t := p.TypeOfExpIdx(varInfo.File, varInfo.ValueExp, varInfo.ValueIdx); t != nil {
+			return t
+		}
+	}
+	if varInfo.Table != nil {
+		return varInfo.Table
+	}
+	return nil
+}
+
+// forVarType for 循环变量的类型，支持 ipairs pairs
+func (p *Project) forVarType(varInfo *ast.VarInfo) ast.TypeBase {
+	var file = varInfo.File
+	switch stat := varInfo.DefStat.(type) {
+	case *ast.ForNumStat:
+		if p.TypeOfExp(file, stat.InitExp) == ast.LuaTypeInter && (stat.StepExp == nil || p.TypeOfExp(file, stat.StepExp) == ast.LuaTypeInter) {
+			return ast.LuaTypeInter
+		}
+		return ast.LuaTypeNumber
+	case *ast.ForInStat:
+		var call, ok = varInfo.ValueExp.(*ast.FuncCallExp)
+		if !ok {
+			return nil
+		}
+		switch p.globalFuncName(file, call) {
+		case "ipairs":
+			if varInfo.ValueIdx == 0 {
+				return ast.LuaTypeInter
+			}
+			if len(call.Args) > 0 {
+				_, value := p.indexTypes(p.TypeOfExp(file, call.Args[0]))
+				return value
+			}
+			return nil
+		case "pairs":
+			if len(call.Args) == 0 {
+				return nil
+			}
+			key, value := p.indexTypes(p.TypeOfExp(file, call.Args[0]))
+			if varInfo.ValueIdx == 0 {
+				return key
+			}
+			return value
+		}
+		// 其他的迭代函数，取迭代函数的返回值
+		var iter = p.callReturn(file, call, 0)
+		return p.funcReturn(iter, varInfo.ValueIdx, nil, nil)
+	}
+	return nil
+}
+
+// globalFuncName 调用的是全局函数时返回函数名，例如 require
+func (p *Project) globalFuncName(file *ast.FileInfo, call *ast.FuncCallExp) string {
+	if call.NameExp != nil {
+		return ""
+	}
+	var name, ok = call.PrefixExp.(*ast.NameExp)
+	if !ok {
+		return ""
+	}
+	if varInfo := file.NameVarMap[name]; varInfo == nil || varInfo.IsLocal() {
+		return ""
+	}
+	return name.Name
+}
+
+// indexTypes 用 [] 访问时 key 和 value 的类型
+func (p *Project) indexTypes(t ast.TypeBase) (key, value ast.TypeBase) {
+	switch t := p.resolveAlias(t).(type) {
+	case *ast.Type_Array:
+		return ast.LuaTypeInter, t.ElementType
+	case *ast.Type_GenericInstance:
+		if t.NameAndLoc.Name == "table" && len(t.ParamTypeList) == 2 {
+			return t.ParamTypeList[0], t.ParamTypeList[1]
+		}
+	case *ast.Type_Map:
+		if len(t.FieldList) > 0 {
+			return ast.LuaTypeString, t.FieldList[0].Type
+		}
+	case *ast.TableInfo:
+		return ast.LuaTypeString, nil
+	}
+	return nil, nil
+}
+
+// resolveAlias 展开 @alias 定义的类型
+func (p *Project) resolveAlias(t ast.TypeBase) ast.TypeBase {
+	for i := 0; i < 10; i++ {
+		var ident, ok = t.(*ast.Type_Identifier)
+		if !ok {
+			return t
+		}
+		var aliasList = p.GetAlias(ident.NameAndLoc.Name)
+		if len(aliasList) == 0 {
+			return t
+		}
+		t = aliasList[0].Type
+	}
+	return t
+}
+
+// CalleeType 被调用的函数的类型，方法调用时是对象的成员
+func (p *Project) CalleeType(file *ast.FileInfo, call *ast.FuncCallExp) ast.TypeBase {
+	if call.NameExp != nil {
+		return p.firstMemberType(p.ExpMembers(file, call.PrefixExp, call.NameExp.Str))
+	}
+	return p.TypeOfExp(file, call.PrefixExp)
+}
+
+// callReturn 函数调用第 idx 个返回值的类型
+func (p *Project) callReturn(file *ast.FileInfo, call *ast.FuncCallExp, idx int) ast.TypeBase {
+	switch p.globalFuncName(file, call) {
+	case "require":
+		var name, ok = requireName(p, file, call)
+		if idx > 0 || !ok {
+			return nil
+		}
+		if reqFile := p.FindRequire(name); reqFile != nil && reqFile.ReturnExp != nil {
+			return p.TypeOfExp(reqFile, reqFile.ReturnExp)
+		}
+		return nil
+	case "setmetatable":
+		if idx > 0 || len(call.Args) == 0 {
+			return nil
+		}
+		// setmetatable({}, Class) 得到的是类的对象
+		if len(call.Args) > 1 {
+			if mt, ok := p.TypeOfExp(file, call.Args[1]).(*ast.TableInfo); ok && mt.ClassName != "" {
+				return mt
+			}
+		}
+		return p.TypeOfExp(file, call.Args[0])
+	}
+	return p.funcReturn(p.CalleeType(file, call), idx, file, call)
+}
+
+// funcReturn 函数类型第 idx 个返回值的类型。知道调用的参数时，替换泛型参数
+func (p *Project) funcReturn(t ast.TypeBase, idx int, file *ast.FileInfo, call *ast.FuncCallExp) ast.TypeBase {
+	switch t := p.resolveAlias(t).(type) {
+	case *ast.Type_Fun:
+		if idx < len(t.ReturnList) {
+			return t.ReturnList[idx].Type
+		}
+	case *ast.Type_Union:
+		for _, sub := range t.TypeList {
+			if ret := p.funcReturn(sub, idx, file, call); ret != nil {
+				return ret
+			}
+		}
+	case *ast.FuncInfo:
+		var returnList = FuncReturnTypes(t)
+		if len(returnList) > 0 {
+			if idx >= len(returnList) {
+				return nil
+			}
+			return p.substGeneric(t, returnList[idx], file, call)
+		}
+		// 没有 @return 时推导 return 语句
+		for _, ret := range t.ReturnList {
+			var value, valueIdx = valueOf(ret.Stat.ExpList, idx)
+			if r := p.TypeOfExpIdx(t.File, value, valueIdx); r != nil {
+				return r
+			}
+		}
+	}
+	return nil
+}
+
+// FuncReturnTypes 函数注释里 @return 标记的返回值类型
+func FuncReturnTypes(funcInfo *ast.FuncInfo) (list []ast.TypeBase) {
+	if funcInfo.Comment == nil {
+		return nil
+	}
+	for _, line := range funcInfo.Comment.LineList {
+		if state, ok := line.State.(*ast.AnnotateReturnState); ok {
+			list = append(list, state.ReturnTypeList...)
+		}
+	}
+	return list
+}
+
+// substGeneric 返回值是 @generic 的泛型参数时，替换成调用时参数的类型
+func (p *Project) substGeneric(funcInfo *ast.FuncInfo, t ast.TypeBase, file *ast.FileInfo, call *ast.FuncCallExp) ast.TypeBase {
+	var ident, ok = t.(*ast.Type_Identifier)
+	if !ok || call == nil {
+		return t
+	}
+	var generic, _ = ast.FindState[*ast.AnnotateGenericState](funcInfo.Comment)
+	if generic == nil {
+		return t
+	}
+	var isGeneric = false
+	for _, param := range generic.ParamList {
+		isGeneric = isGeneric || param.NameAndLoc.Name == ident.NameAndLoc.Name
+	}
+	if !isGeneric {
+		return t
+	}
+	// 方法调用和定义的 : 不一致时，参数要错开一个
+	var offset = 0
+	if call.NameExp != nil && !funcInfo.IsColon() {
+		offset = -1
+	} else if call.NameExp == nil && funcInfo.IsColon() {
+		offset = 1
+	}
+	for i, param := range funcInfo.ParamList {
+		var state = FindParamState(funcInfo.Comment, param.Name)
+		if state == nil {
+			continue
+		}
+		if paramIdent, ok := state.ParamType.(*ast.Type_Identifier); !ok || paramIdent.NameAndLoc.Name != ident.NameAndLoc.Name {
+			continue
+		}
+		var argIdx = i + offset
+		if argIdx >= 0 && argIdx < len(call.Args) {
+			return p.TypeOfExp(file, call.Args[argIdx])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package check
+
+import (
+	"mylua-lsp/lsp/ast"
+	"sort"
+)
+
+// InlayHintKind 内嵌提示的种类
+type InlayHintKind uint8
+
+const (
+	InlayHintParamName InlayHintKind = iota // 调用时字面量实参对应的形参名
+	InlayHintVarType                        // 没有注释的局部变量推导出的类型
+	InlayHintSelf                           // : 定义的方法里隐含的 self
+)
+
+// InlayHint 一个内嵌提示，显示在 Pos 的位置
+type InlayHint struct {
+	Pos          Position
+	Label        string
+	Kind         InlayHintKind
+	PaddingRight bool // 提示和后面的文字之间留空
+}
+
+// InlayHints loc 范围里的所有内嵌提示，按位置排序
+func (p *Project) InlayHints(file *ast.FileInfo, loc Location) []InlayHint {
+	var list []InlayHint
+	var add = func(hint InlayHint) {
+		if inLoc(loc, hint.Pos) {
+			list = append(list, hint)
+		}
+	}
+	ast.Inspect(file.Block, func(node ast.Stat) bool {
+		if call, ok := node.(*ast.FuncCallExp); ok && overlaps(call.Loc, loc) {
+			for _, hint := range p.paramHints(file, call) {
+				add(hint)
+			}
+		}
+		return node != nil
+	})
+	p.varTypeHints(file.MainFunc.Scope, add)
+	for _, funcInfo := range file.FuncList {
+		if !funcInfo.IsColon() {
+			continue
+		}
+		var pos, c = skipSpace(file.Source, funcInfo.NameLoc.End)
+		if c != '(' {
+			continue
+		}
+		var label = "self"
+		if len(funcInfo.FuncDef.ParList) > 0 || funcInfo.FuncDef.IsVararg {
+			label += ","
+		}
+		pos.Column++
+		add(InlayHint{Pos: pos, Label: label, Kind: InlayHintSelf, PaddingRight: label != "self"})
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Pos.Before(list[j].Pos)
+	})
+	return list
+}
+
+// overlaps 两个范围是否有重叠
+func overlaps(a, b Location) bool {
+	return !a.End.Before(b.Start) && !b.End.Before(a.Start)
+}
+
+// paramHints 调用时字面量实参前面的形参名，f"str" 和 f{...} 这样的调用不提示
+func (p *Project) paramHints(file *ast.FileInfo, call *ast.FuncCallExp) []InlayHint {
+	var calleeEnd = call.PrefixExp.GetLoc().End
+	if call.NameExp != nil {
+		calleeEnd = call.NameExp.Loc.End
+	}
+	if _, c := skipSpace(file.Source, calleeEnd); c != '(' || len(call.Args) == 0 {
+		return nil
+	}
+	var nameList []string
+	var isColon bool
+	switch t := p.CalleeType(file, call).(type) {
+	case *ast.FuncInfo:
+		for _, param := range t.ParamList {
+			nameList = append(nameList, param.Name)
+		}
+		isColon = t.IsColon()
+	case *ast.Type_Fun:
+		if t == nil {
+			return nil
+		}
+		for _, param := range t.ParamList {
+			nameList = append(nameList, param.NameAndLoc.Name)
+		}
+	default:
+		return nil
+	}
+	// 和签名一样处理 self
+	var callColon = call.NameExp != nil
+	switch {
+	case isColon && !callColon:
+		nameList = append([]string{"self"}, nameList...)
+	case !isColon && callColon && len(nameList) > 0:
+		nameList = nameList[1:]
+	}
+	var list []InlayHint
+	for i, arg := range call.Args {
+		if i >= len(nameList) {
+			break
+		}
+		var name = nameList[i]
+		if name == "" || name == "..." || !isLiteral(arg) {
+			continue
+		}
+		list = append(list, InlayHint{
+			Pos:          arg.GetLoc().Start,
+			Label:        name + ":",
+			Kind:         InlayHintParamName,
+			PaddingRight: true,
+		})
+	}
+	return list
+}
+
+// isLiteral 是否为字面量，包括负数、表构造和匿名函数
+func isLiteral(exp ast.Exp) bool {
+	switch e := exp.(type) {
+	case *ast.NilExp, *ast.TrueExp, *ast.FalseExp, *ast.IntegerExp, *ast.FloatExp, *ast.StringExp,
+		*ast.TableConstructorExp, *ast.FuncDefExp:
+		return true
+	case *ast.UnopExp:
+		switch e.Exp.(type) {
+		case *ast.IntegerExp, *ast.FloatExp:
+			return e.Op == ast.TkOpUnm
+		}
+	}
+	return false
+}
+
+// varTypeHints local x = ... 定义的变量名后面推导出的类型，有类型注释或者推导不出时不提示
+func (p *Project) varTypeHints(scope *ast.ScopeInfo, add func(InlayHint)) {
+	for _, varInfo := range scope.VarInfoList {
+		if varInfo.Kind != ast.VarKindLocal || varInfo.ValueExp == nil || varInfo.AnnType != nil || hasTypeTag(varInfo.Comment) {
+			continue
+		}
+		if _, ok := varInfo.ValueExp.(*ast.FuncDefExp); ok {
+			continue
+		}
+		var t = p.TypeOfVar(varInfo)
+		if t == nil {
+			continue
+		}
+		add(InlayHint{Pos: varInfo.Loc.End, Label: ": " + p.TypeString(t), Kind: InlayHintVarType})
+	}
+	for _, sub := range scope.SubScopes {
+		p.varTypeHints(sub, add)
+	}
+}
+
+// hasTypeTag 注释里是否有 @type @class @enum 这样决定变量类型的标记
+func hasTypeTag(comment *ast.AnnotateBlock) bool {
+	for _, line := range commentLines(comment) {
+		switch line.State.(type) {
+		case *ast.AnnotateTypeState, *ast.AnnotateClassState, *ast.AnnotateEnumState:
+			return true
+		}
+	}
+	return false
+}
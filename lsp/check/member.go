@@ -0,0 +1,255 @@
+package check
+
+import (
+	"mylua-lsp/lsp/ast"
+)
+
+// Member 类型的一个成员，可能来自 lua 表的字段，@field 或者 {name: Type}
+type Member struct {
+	Name       string
+	Field      *ast.FieldInfo          // lua 表里定义的字段
+	ClassField *ast.Type_ClassField    // @field 定义的字段
+	Class      *ast.Type_Class         // ClassField 所属的类
+	MapField   *ast.Type_KeyValue      // {name: Type} 里的字段
+	Subst      map[string]ast.TypeBase // 泛型类实例化的参数类型
+}
+
+// GetFile 成员定义所在的文件，{name: Type} 里的字段为 nil
+func (m *Member) GetFile() *ast.FileInfo {
+	switch {
+	case m.Field != nil:
+		return m.Field.Table.File
+	case m.Class != nil:
+		return m.Class.File
+	}
+	return nil
+}
+
+// GetLoc 成员定义的名字的位置
+func (m *Member) GetLoc() Location {
+	switch {
+	case m.Field != nil:
+		return m.Field.Loc
+	case m.ClassField != nil:
+		return m.ClassField.NameAndLoc.Loc
+	case m.MapField != nil:
+		return m.MapField.NameAndLoc.Loc
+	}
+	return Location{}
+}
+
+// memberCollector 收集类型的成员，visited 防止循环的继承和重复的表
+type memberCollector struct {
+	p       *Project
+	name    string // 为空时收集所有的成员
+	visited map[any]bool
+	list    []*Member
+}
+
+// FindMembers 查找类型的成员，name 为空时返回所有的成员。同名的成员可能有多个定义
+func (p *Project) FindMembers(t ast.TypeBase, name string) []*Member {
+	var c = &memberCollector{p: p, name: name, visited: map[any]bool{}}
+	c.collect(t, nil)
+	return c.list
+}
+
+// ExpMembers 表达式的成员，包括表达式静态对应的表，例如 obj.x = 1 给 obj 添加的字段
+func (p *Project) ExpMembers(file *ast.FileInfo, exp ast.Exp, name string) []*Member {
+	var c = &memberCollector{p: p, name: name, visited: map[any]bool{}}
+	c.collect(staticTable(file, exp), nil)
+	c.collect(p.TypeOfExp(file, exp), nil)
+	return c.list
+}
+
+// staticTable 表达式静态对应的表，例如变量 M 或者 M.sub
+func staticTable(file *ast.FileInfo, exp ast.Exp) *ast.TableInfo {
+	switch exp := exp.(type) {
+	case *ast.NameExp:
+		var varInfo = file.NameVarMap[exp]
+		if varInfo == nil {
+			return nil
+		}
+		if varInfo.Kind == ast.VarKindSelf {
+			return varInfo.Func.SelfTable
+		}
+		return varInfo.Table
+	case *ast.ParensExp:
+		return staticTable(file, exp.Exp)
+	case *ast.TableAccessExp:
+		var key, ok = exp.KeyExp.(*ast.StringExp)
+		if !ok {
+			return nil
+		}
+		var table = staticTable(file, exp.PrefixExp)
+		if table == nil {
+			return nil
+		}
+		if field := table.GetField(key.Str); field != nil {
+			return field.SubTable
+		}
+	}
+	return nil
+}
+
+func (c *memberCollector) add(member *Member) {
+	if c.name == "" || c.name == member.Name {
+		c.list = append(c.list, member)
+	}
+}
+
+func (c *memberCollector) collect(t ast.TypeBase, subst map[string]ast.TypeBase) {
+	switch t := t.(type) {
+	case nil:
+	case *ast.TableInfo:
+		if t == nil || c.visited[t] {
+			return
+		}
+		c.visited[t] = true
+		// 关联的类的 @field 优先
+		if t.ClassName != "" {
+			c.collectClass(t.ClassName, subst, nil)
+		}
+		var tables = []*ast.TableInfo{t}
+		if t.GlobalPath != "" {
+			tables = append(tables, c.p.GetGlobalTables(t.GlobalPath)...)
+		}
+		for _, table := range tables {
+			if table != t && c.visited[table] {
+				continue
+			}
+			c.visited[table] = true
+			for _, field := range table.FieldList {
+				c.add(&Member{Name: field.Name, Field: field})
+			}
+		}
+	case *ast.Type_Identifier:
+		if sub, ok := subst[t.NameAndLoc.Name]; ok {
+			c.collect(sub, nil)
+			return
+		}
+		c.collectClass(t.NameAndLoc.Name, nil, nil)
+	case *ast.Type_GenericInstance:
+		c.collectClass(t.NameAndLoc.Name, subst, t.ParamTypeList)
+	case *ast.Type_Map:
+		for i := range t.FieldList {
+			var field = &t.FieldList[i]
+			c.add(&Member{Name: field.NameAndLoc.Name, MapField: field, Subst: subst})
+		}
+	case *ast.Type_Union:
+		for _, sub := range t.TypeList {
+			c.collect(sub, subst)
+		}
+	}
+}
+
+// collectClass 收集类，别名或者枚举的成员，包括父类的成员。paramList 是泛型类实例化的参数
+func (c *memberCollector) collectClass(name string, subst map[string]ast.TypeBase, paramList []ast.TypeBase) {
+	var p = c.p
+	for _, class := range p.GetClass(name) {
+		if c.visited[class] {
+			continue
+		}
+		c.visited[class] = true
+		var classSubst = subst
+		if len(paramList) > 0 {
+			classSubst = map[string]ast.TypeBase{}
+			for i, param := range class.GenericParamList {
+				if i < len(paramList) {
+					classSubst[param.NameAndLoc.Name] = applySubst(paramList[i], subst)
+				}
+			}
+		}
+		for i := range class.FieldList {
+			var field = &class.FieldList[i]
+			c.add(&Member{Name: field.NameAndLoc.Name, ClassField: field, Class: class, Subst: classSubst})
+		}
+		if class.Table != nil {
+			c.collect(class.Table, classSubst)
+		}
+		for _, parent := range class.ParentTypeList {
+			c.collect(parent, classSubst)
+		}
+	}
+	for _, alias := range p.GetAlias(name) {
+		if !c.visited[alias] {
+			c.visited[alias] = true
+			c.collect(alias.Type, subst)
+		}
+	}
+	for _, enum := range p.GetEnum(name) {
+		if enum.Table != nil {
+			c.collect(enum.Table, nil)
+		}
+	}
+}
+
+// applySubst 把泛型参数替换成实例化的类型
+func applySubst(t ast.TypeBase, subst map[string]ast.TypeBase) ast.TypeBase {
+	if len(subst) == 0 {
+		return t
+	}
+	switch t := t.(type) {
+	case *ast.Type_Identifier:
+		if sub, ok := subst[t.NameAndLoc.Name]; ok {
+			return sub
+		}
+	case *ast.Type_Array:
+		return &ast.Type_Array{ElementType: applySubst(t.ElementType, subst)}
+	case *ast.Type_Union:
+		var union = &ast.Type_Union{}
+		for _, sub := range t.TypeList {
+			union.TypeList = append(union.TypeList, applySubst(sub, subst))
+		}
+		return union
+	case *ast.Type_GenericInstance:
+		var instance = &ast.Type_GenericInstance{NameAndLoc: t.NameAndLoc}
+		for _, sub := range t.ParamTypeList {
+			instance.ParamTypeList = append(instance.ParamTypeList, applySubst(sub, subst))
+		}
+		return instance
+	}
+	return t
+}
+
+// TypeOfMember 成员的类型
+func (p *Project) TypeOfMember(member *Member) ast.TypeBase {
+	switch {
+	case member.ClassField != nil:
+		var t = applySubst(member.ClassField.Type, member.Subst)
+		if member.ClassField.IsOptional {
+			t = &ast.Type_Union{TypeList: []ast.TypeBase{t, &ast.Type_LiteralValue{Type: ast.LiteralValueNil}}}
+		}
+		return t
+	case member.MapField != nil:
+		return applySubst(member.MapField.Type, member.Subst)
+	case member.Field != nil:
+		return p.TypeOfField(member.Field)
+	}
+	return nil
+}
+
+// TypeOfField lua 表字段的类型
+func (p *Project) TypeOfField(field *ast.FieldInfo) ast.TypeBase {
+	if field.AnnType != nil {
+		return field.AnnType
+	}
+	if field.ValueExp != nil {
+		if t := p.TypeOfExpIdx(field.Table.File, field.ValueExp, field.ValueIdx); t != nil {
+			return t
+		}
+	}
+	if field.SubTable != nil {
+		return field.SubTable
+	}
+	return nil
+}
+
+// firstMemberType 多个定义时取第一个推导出类型的
+func (p *Project) firstMemberType(list []*Member) ast.TypeBase {
+	for _, member := range list {
+		if t := p.TypeOfMember(member); t != nil {
+			return t
+		}
+	}
+	return nil
+}
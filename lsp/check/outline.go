@@ -0,0 +1,484 @@
+package check
+
+import (
+	"mylua-lsp/lsp/ast"
+	"sort"
+	"strings"
+)
+
+/*
+文件大纲。函数按定义的位置嵌套，function M:foo() 这样的函数放在 M 的下面，
+M 不是这个文件里定义的表时，用它的名字创建一个容器。
+*/
+
+// OutlineKind 大纲里符号的种类
+type OutlineKind uint8
+
+const (
+	OutlineVariable   OutlineKind = iota // 文件级别的变量
+	OutlineConstant                      // <const> 局部变量
+	OutlineFunction                      // 函数
+	OutlineMethod                        // : 定义的函数
+	OutlineField                         // 表的字段和 @field
+	OutlineTable                         // 表
+	OutlineClass                         // @class
+	OutlineAlias                         // @alias
+	OutlineEnum                          // @enum
+	OutlineEnumMember                    // 枚举的成员
+	OutlineLabel                         // goto 的标签
+)
+
+// OutlineSymbol 大纲里的一个符号
+type OutlineSymbol struct {
+	Name     string
+	Detail   string
+	Kind     OutlineKind
+	Loc      Location // 整个定义的范围
+	NameLoc  Location // 名字的位置
+	Children []*OutlineSymbol
+}
+
+// outlineBuilder 生成一个文件的大纲
+type outlineBuilder struct {
+	file       *ast.FileInfo
+	root       []*OutlineSymbol
+	tableSyms  map[*ast.TableInfo]*OutlineSymbol // 表对应的符号，用来放后面定义的字段和方法
+	containers map[string]*OutlineSymbol         // 不是这个文件里定义的表，key 是表达式的路径
+	bindTypes  map[any]bool                      // 已经和表合并的 @class @enum
+}
+
+// DocumentOutline 文件里定义的符号，按位置排序，函数和表的内容是它们的子节点
+func (p *Project) DocumentOutline(file *ast.FileInfo) []*OutlineSymbol {
+	var b = &outlineBuilder{
+		file:       file,
+		tableSyms:  map[*ast.TableInfo]*OutlineSymbol{},
+		containers: map[string]*OutlineSymbol{},
+		bindTypes:  map[any]bool{},
+	}
+	b.block(file.Block, &b.root, true)
+	b.annotateTypes()
+	sortOutline(b.root)
+	return b.root
+}
+
+// sortOutline 按位置排序，容器的范围扩大到包含所有子节点
+func sortOutline(list []*OutlineSymbol) {
+	for _, sym := range list {
+		sortOutline(sym.Children)
+		for _, child := range sym.Children {
+			if child.Loc.Start.Before(sym.Loc.Start) {
+				sym.Loc.Start = child.Loc.Start
+			}
+			if sym.Loc.End.Before(child.Loc.End) {
+				sym.Loc.End = child.Loc.End
+			}
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Loc.Start.Before(list[j].Loc.Start)
+	})
+}
+
+func (b *outlineBuilder) block(block *ast.Block, list *[]*OutlineSymbol, topLevel bool) {
+	if block == nil {
+		return
+	}
+	for _, stat := range block.Stats {
+		b.stat(stat, list, topLevel)
+	}
+}
+
+func (b *outlineBuilder) stat(stat ast.Stat, list *[]*OutlineSymbol, topLevel bool) {
+	switch s := stat.(type) {
+	case *ast.LocalFuncDefStat:
+		var sym = &OutlineSymbol{Name: s.Name.TokenStr, Kind: OutlineFunction, Loc: s.Loc, NameLoc: s.Name.Loc}
+		b.funcBody(sym, s.FuncDef)
+		*list = append(*list, sym)
+	case *ast.LocalVarDeclStat:
+		for i, name := range s.NameList {
+			var value = expAt(s.ExpList, i)
+			var loc = s.Loc
+			if len(s.NameList) > 1 {
+				loc = spanLoc(name.Loc, value)
+			}
+			var kind = OutlineVariable
+			if name.LocalAttr == ast.RDKCONST {
+				kind = OutlineConstant
+			}
+			var sym = b.valueSym(name.TokenStr, name.Loc, loc, value, kind)
+			if sym == nil && topLevel {
+				sym = &OutlineSymbol{Name: name.TokenStr, Kind: kind, Loc: loc, NameLoc: name.Loc}
+			}
+			if sym != nil {
+				if def := varDefAt(b.file, b.file.MainFunc.Scope, name.Loc.Start); def != nil && def.Var.Table != nil {
+					b.bindTable(sym, def.Var.Table)
+				}
+				*list = append(*list, sym)
+			}
+		}
+	case *ast.AssignStat:
+		for i, exp := range s.VarList {
+			var value = expAt(s.ExpList, i)
+			var loc = s.Loc
+			if len(s.VarList) > 1 {
+				loc = spanLoc(exp.GetLoc(), value)
+			}
+			b.assign(exp, value, loc, list, topLevel)
+		}
+	case *ast.LabelStat:
+		*list = append(*list, &OutlineSymbol{Name: s.Name.TokenStr, Kind: OutlineLabel, Loc: s.Loc, NameLoc: s.Name.Loc})
+	case *ast.DoStat:
+		b.block(s.Block, list, false)
+	case *ast.WhileStat:
+		b.funcsInExp(s.Exp, list)
+		b.block(s.Block, list, false)
+	case *ast.RepeatStat:
+		b.block(s.Block, list, false)
+		b.funcsInExp(s.Exp, list)
+	case *ast.IfStat:
+		for _, exp := range s.Exps {
+			b.funcsInExp(exp, list)
+		}
+		for _, block := range s.Blocks {
+			b.block(block, list, false)
+		}
+	case *ast.ForNumStat:
+		b.block(s.Block, list, false)
+	case *ast.ForInStat:
+		for _, exp := range s.ExpList {
+			b.funcsInExp(exp, list)
+		}
+		b.block(s.Block, list, false)
+	case *ast.RetStat:
+		for _, exp := range s.ExpList {
+			b.funcsInExp(exp, list)
+		}
+	case ast.Exp:
+		b.funcsInExp(s, list)
+	}
+}
+
+// expAt 表达式列表里的第 i 个，没有时为 nil
+func expAt(list []ast.Exp, i int) ast.Exp {
+	if i < len(list) {
+		return list[i]
+	}
+	return nil
+}
+
+// spanLoc 从名字到值结束的范围
+func spanLoc(nameLoc Location, value ast.Exp) Location {
+	if value == nil {
+		return nameLoc
+	}
+	return Location{Start: nameLoc.Start, End: value.GetLoc().End}
+}
+
+// valueSym 值为函数或者表构造时生成的符号。其他值里有函数时生成 kind 种类的符号，否则返回 nil
+func (b *outlineBuilder) valueSym(name string, nameLoc, loc Location, value ast.Exp, kind OutlineKind) *OutlineSymbol {
+	switch v := value.(type) {
+	case *ast.FuncDefExp:
+		var kind = OutlineFunction
+		if v.IsColon {
+			kind = OutlineMethod
+		}
+		var sym = &OutlineSymbol{Name: name, Kind: kind, Loc: loc, NameLoc: nameLoc}
+		b.funcBody(sym, v)
+		return sym
+	case *ast.TableConstructorExp:
+		var sym = &OutlineSymbol{Name: name, Kind: OutlineTable, Loc: loc, NameLoc: nameLoc}
+		b.constructor(sym, v)
+		return sym
+	case *ast.ParensExp:
+		return b.valueSym(name, nameLoc, loc, v.Exp, kind)
+	}
+	if value != nil {
+		var list []*OutlineSymbol
+		b.funcsInExp(value, &list)
+		if len(list) > 0 {
+			return &OutlineSymbol{Name: name, Kind: kind, Loc: loc, NameLoc: nameLoc, Children: list}
+		}
+	}
+	return nil
+}
+
+// funcBody 函数的参数和函数里定义的符号
+func (b *outlineBuilder) funcBody(sym *OutlineSymbol, funcDef *ast.FuncDefExp) {
+	if funcDef == nil {
+		return
+	}
+	var params []string
+	for _, param := range funcDef.ParList {
+		params = append(params, param.TokenStr)
+	}
+	if funcDef.IsVararg {
+		params = append(params, "...")
+	}
+	sym.Detail = "(" + strings.Join(params, ", ") + ")"
+	b.block(funcDef.Block, &sym.Children, false)
+}
+
+// constructor 表构造里的字段，数组部分的值里定义的函数也放在表下面
+func (b *outlineBuilder) constructor(sym *OutlineSymbol, exp *ast.TableConstructorExp) {
+	var table = b.file.TableMap[exp]
+	var fieldKind = OutlineField
+	if table != nil && b.enumOf(table) != nil {
+		fieldKind = OutlineEnumMember
+	}
+	for i, value := range exp.ValExps {
+		var key, ok = expAt(exp.KeyExps, i).(*ast.StringExp)
+		if !ok || hasChild(sym, key.Str) {
+			b.funcsInExp(value, &sym.Children)
+			continue
+		}
+		var loc = spanLoc(key.Loc, value)
+		var child = b.valueSym(key.Str, key.Loc, loc, value, fieldKind)
+		if child == nil {
+			child = &OutlineSymbol{Name: key.Str, Kind: fieldKind, Loc: loc, NameLoc: key.Loc}
+		} else if child.Kind == OutlineTable && table != nil {
+			if field := table.GetField(key.Str); field != nil && field.SubTable != nil {
+				b.bindTable(child, field.SubTable)
+			}
+		}
+		sym.Children = append(sym.Children, child)
+	}
+	if table != nil {
+		b.bindTable(sym, table)
+	}
+}
+
+// funcsInExp 表达式里的匿名函数，函数里定义的符号直接放到 list 里
+func (b *outlineBuilder) funcsInExp(exp ast.Exp, list *[]*OutlineSymbol) {
+	if exp == nil {
+		return
+	}
+	ast.Inspect(exp, func(node ast.Stat) bool {
+		if funcDef, ok := node.(*ast.FuncDefExp); ok {
+			b.block(funcDef.Block, list, false)
+			return false
+		}
+		return node != nil
+	})
+}
+
+// hasChild 是否已经有同名的子节点
+func hasChild(sym *OutlineSymbol, name string) bool {
+	for _, child := range sym.Children {
+		if child.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// assign 赋值语句的一个变量。全局变量在第一次赋值的地方，字段放到所属的表下面
+func (b *outlineBuilder) assign(exp ast.Exp, value ast.Exp, loc Location, list *[]*OutlineSymbol, topLevel bool) {
+	switch e := exp.(type) {
+	case *ast.NameExp:
+		var varInfo = b.file.NameVarMap[e]
+		var sym = b.valueSym(e.Name, e.Loc, loc, value, OutlineVariable)
+		if sym == nil && topLevel && varInfo != nil && !varInfo.IsLocal() && varInfo.Loc == e.Loc {
+			sym = &OutlineSymbol{Name: e.Name, Kind: OutlineVariable, Loc: loc, NameLoc: e.Loc}
+		}
+		if sym == nil {
+			b.funcsInExp(value, list)
+			return
+		}
+		if varInfo != nil && varInfo.Table != nil {
+			b.bindTable(sym, varInfo.Table)
+		}
+		*list = append(*list, sym)
+	case *ast.TableAccessExp:
+		var key, ok = e.KeyExp.(*ast.StringExp)
+		if !ok {
+			b.funcsInExp(value, list)
+			return
+		}
+		var table = b.tableOf(e.PrefixExp)
+		var field *ast.FieldInfo
+		if table != nil {
+			field = table.GetField(key.Str)
+		}
+		var _, isFunc = value.(*ast.FuncDefExp)
+		var isDef = field != nil && field.Loc == key.Loc
+		var owner = b.tableSyms[table]
+		if owner == nil && isFunc {
+			owner = b.container(e.PrefixExp, table)
+		}
+		if owner == nil || !isFunc && !isDef {
+			b.funcsInExp(value, list)
+			return
+		}
+		var fieldKind = OutlineField
+		if owner.Kind == OutlineEnum {
+			fieldKind = OutlineEnumMember
+		}
+		var sym = b.valueSym(key.Str, key.Loc, loc, value, fieldKind)
+		if sym == nil {
+			sym = &OutlineSymbol{Name: key.Str, Kind: fieldKind, Loc: loc, NameLoc: key.Loc}
+		}
+		if field != nil && field.SubTable != nil && sym.Kind == OutlineTable {
+			b.bindTable(sym, field.SubTable)
+		}
+		owner.Children = append(owner.Children, sym)
+	default:
+		b.funcsInExp(value, list)
+	}
+}
+
+// tableOf 表达式静态对应的表，例如 M 和 M.sub ，以及方法里的 self
+func (b *outlineBuilder) tableOf(exp ast.Exp) *ast.TableInfo {
+	switch e := exp.(type) {
+	case *ast.NameExp:
+		var varInfo = b.file.NameVarMap[e]
+		switch {
+		case varInfo == nil:
+			return nil
+		case varInfo.Kind == ast.VarKindSelf:
+			return varInfo.Func.SelfTable
+		}
+		return varInfo.Table
+	case *ast.ParensExp:
+		return b.tableOf(e.Exp)
+	case *ast.TableAccessExp:
+		var key, ok = e.KeyExp.(*ast.StringExp)
+		if !ok {
+			return nil
+		}
+		if table := b.tableOf(e.PrefixExp); table != nil {
+			if field := table.GetField(key.Str); field != nil {
+				return field.SubTable
+			}
+		}
+	}
+	return nil
+}
+
+// container 函数所属的表不是这个文件里的表构造时，按名字创建一个容器放在最外层
+func (b *outlineBuilder) container(prefix ast.Exp, table *ast.TableInfo) *OutlineSymbol {
+	var path, _ = exprPath(prefix, false)
+	if path == "" {
+		return nil
+	}
+	var sym = b.containers[path]
+	if sym == nil {
+		var loc = prefix.GetLoc()
+		sym = &OutlineSymbol{Name: path, Kind: OutlineTable, Loc: loc, NameLoc: loc}
+		b.containers[path] = sym
+		b.root = append(b.root, sym)
+	}
+	if table != nil {
+		b.bindTable(sym, table)
+	}
+	return sym
+}
+
+// bindTable 记录表对应的符号，表关联了这个文件里的 @class 或者 @enum 时合并成一个符号
+func (b *outlineBuilder) bindTable(sym *OutlineSymbol, table *ast.TableInfo) {
+	if _, ok := b.tableSyms[table]; !ok {
+		b.tableSyms[table] = sym
+	}
+	if class := b.classOf(table); class != nil && !b.bindTypes[class] {
+		b.bindTypes[class] = true
+		sym.Kind = OutlineClass
+		b.extendToLine(sym, class.NameAndLoc.Loc)
+		b.classFields(sym, class)
+	} else if enum := b.enumOf(table); enum != nil && !b.bindTypes[enum] {
+		b.bindTypes[enum] = true
+		sym.Kind = OutlineEnum
+		b.extendToLine(sym, enum.NameAndLoc.Loc)
+		for _, child := range sym.Children {
+			if child.Kind == OutlineField {
+				child.Kind = OutlineEnumMember
+			}
+		}
+	}
+}
+
+// extendToLine 范围扩大到包含类型名所在的注释行
+func (b *outlineBuilder) extendToLine(sym *OutlineSymbol, nameLoc Location) {
+	for _, block := range b.file.Annotate.BlockList {
+		for _, line := range block.LineList {
+			if inLoc(line.Loc, nameLoc.Start) && line.Loc.Start.Before(sym.Loc.Start) {
+				sym.Loc.Start = line.Loc.Start
+			}
+		}
+	}
+}
+
+// classOf 这个文件里和表关联的 @class
+func (b *outlineBuilder) classOf(table *ast.TableInfo) *ast.Type_Class {
+	for _, class := range b.file.Annotate.ClassList {
+		if class.Table == table {
+			return class
+		}
+	}
+	return nil
+}
+
+// enumOf 这个文件里和表关联的 @enum
+func (b *outlineBuilder) enumOf(table *ast.TableInfo) *ast.Type_Enum {
+	for _, enum := range b.file.Annotate.EnumList {
+		if enum.Table == table {
+			return enum
+		}
+	}
+	return nil
+}
+
+// classFields @field 定义的字段，和表里的字段同名时不重复
+func (b *outlineBuilder) classFields(sym *OutlineSymbol, class *ast.Type_Class) {
+	for _, field := range class.FieldList {
+		if !hasChild(sym, field.NameAndLoc.Name) {
+			var loc = field.NameAndLoc.Loc
+			sym.Children = append(sym.Children, &OutlineSymbol{Name: field.NameAndLoc.Name, Kind: OutlineField, Loc: loc, NameLoc: loc})
+		}
+	}
+}
+
+// annotateTypes 没有和表合并的 @class @alias @enum ，范围是注释所在的行
+func (b *outlineBuilder) annotateTypes() {
+	for _, block := range b.file.Annotate.BlockList {
+		for _, line := range block.LineList {
+			var sym *OutlineSymbol
+			switch state := line.State.(type) {
+			case *ast.AnnotateClassState:
+				var class = b.findClass(state.NameAndLoc.Loc)
+				if class == nil || b.bindTypes[class] {
+					continue
+				}
+				sym = &OutlineSymbol{Name: state.NameAndLoc.Name, Kind: OutlineClass, NameLoc: state.NameAndLoc.Loc}
+				b.classFields(sym, class)
+			case *ast.AnnotateAliasState:
+				sym = &OutlineSymbol{Name: state.NameAndLoc.Name, Kind: OutlineAlias, NameLoc: state.NameAndLoc.Loc}
+			case *ast.AnnotateEnumState:
+				if enum := b.findEnum(state.NameAndLoc.Loc); enum == nil || b.bindTypes[enum] {
+					continue
+				}
+				sym = &OutlineSymbol{Name: state.NameAndLoc.Name, Kind: OutlineEnum, NameLoc: state.NameAndLoc.Loc}
+			default:
+				continue
+			}
+			sym.Loc = line.Loc
+			b.root = append(b.root, sym)
+		}
+	}
+}
+
+func (b *outlineBuilder) findClass(loc Location) *ast.Type_Class {
+	for _, class := range b.file.Annotate.ClassList {
+		if class.NameAndLoc.Loc == loc {
+			return class
+		}
+	}
+	return nil
+}
+
+func (b *outlineBuilder) findEnum(loc Location) *ast.Type_Enum {
+	for _, enum := range b.file.Annotate.EnumList {
+		if enum.NameAndLoc.Loc == loc {
+			return enum
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package check
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// dumpOutline 大纲的文字表示，每行是缩进、种类、名字、详情和名字的位置
+func dumpOutline(sb *strings.Builder, list []*OutlineSymbol, indent string) {
+	for _, sym := range list {
+		fmt.Fprintf(sb, "%s%d %s%s %s\n", indent, sym.Kind, sym.Name, sym.Detail, locString(sym.NameLoc))
+		if !inLoc(sym.Loc, sym.NameLoc.Start) {
+			fmt.Fprintf(sb, "%s  name of %s is outside %s\n", indent, sym.Name, locString(sym.Loc))
+		}
+		dumpOutline(sb, sym.Children, indent+"  ")
+	}
+}
+
+func TestDocumentOutline(t *testing.T) {
+	var text = "---@class Shape\n---@field area number\nlocal Shape = {}\n\nfunction Shape:draw(x) end\n\n" +
+		"local MAX <const> = 10\nM = { a = 1, sub = { b = 2 } }\nfunction M.f() end\nfunction Other.g() end\n" +
+		"---@alias Id integer\n---@enum Color\nlocal Color = { Red = 1 }\n::top::\n"
+	var want = fmt.Sprintf(`%[1]d Shape 2:6-2:11
+  %[2]d area 1:10-1:14
+  %[3]d draw(x) 4:15-4:19
+%[4]d MAX 6:6-6:9
+%[5]d M 7:0-7:1
+  %[2]d a 7:6-7:7
+  %[5]d sub 7:13-7:16
+    %[2]d b 7:21-7:22
+  %[6]d f() 8:11-8:12
+%[5]d Other 9:9-9:14
+  %[6]d g() 9:15-9:16
+%[7]d Id 10:10-10:12
+%[8]d Color 12:6-12:11
+  %[9]d Red 12:16-12:19
+%[10]d top 13:2-13:5
+`, OutlineClass, OutlineField, OutlineMethod, OutlineConstant, OutlineTable, OutlineFunction,
+		OutlineAlias, OutlineEnum, OutlineEnumMember, OutlineLabel)
+	var p = NewProject()
+	var file = analyzeText("a.lua", text)
+	p.UpdateFile(file)
+	var sb strings.Builder
+	dumpOutline(&sb, p.DocumentOutline(file), "")
+	if sb.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", sb.String(), want)
+	}
+}
@@ -0,0 +1,249 @@
+package check
+
+import (
+	"mylua-lsp/lsp/ast"
+	"mylua-lsp/lsp/common"
+	"slices"
+	"sort"
+	"strings"
+)
+
+type Position = common.Position
+type Location = common.Location
+
+// Project 整个工程的语义信息。每个文件独立分析，这里合并所有文件的全局变量和注释类型
+type Project struct {
+	fileMap  map[string]*ast.FileInfo // key 是文件的路径
+	fileKeys map[string]*fileKeys     // 每个文件添加的索引 key
+
+	globalMap map[string][]*ast.VarInfo    // 全局变量，每个文件一个
+	tableMap  map[string][]*ast.TableInfo  // 全局表，key 是 GlobalPath ，同一个路径在多个文件里定义字段
+	classMap  map[string][]*ast.Type_Class // 注释类型都是全局的，重复定义时合并
+	aliasMap  map[string][]*ast.Type_Alias
+	enumMap   map[string][]*ast.Type_Enum
+
+	// 反向索引，名字对应引用了它的文件
+	fieldRefMap map[string][]*ast.FileInfo
+	typeRefMap  map[string][]*ast.FileInfo
+	subclassMap map[string][]*ast.Type_Class // 父类的名字对应直接继承它的类
+
+	symbolIndex *symbolIndex // 工程符号索引
+	calls       *callGraph   // 调用关系，每个文件的调用使用时才收集
+
+	gen     int                      // 每次修改文件后增加，类型推导的缓存过期
+	typeMap map[ast.Exp]ast.TypeBase // 表达式推导的类型
+	typeGen int                      // typeMap 对应的 gen
+}
+
+// NewProject 创建空的工程
+func NewProject() *Project {
+	return &Project{
+		fileMap:   map[string]*ast.FileInfo{},
+		fileKeys:  map[string]*fileKeys{},
+		globalMap: map[string][]*ast.VarInfo{},
+		tableMap:  map[string][]*ast.TableInfo{},
+		classMap:  map[string][]*ast.Type_Class{},
+		aliasMap:  map[string][]*ast.Type_Alias{},
+		enumMap:   map[string][]*ast.Type_Enum{},
+
+		fieldRefMap: map[string][]*ast.FileInfo{},
+		typeRefMap:  map[string][]*ast.FileInfo{},
+		subclassMap: map[string][]*ast.Type_Class{},
+		symbolIndex: newSymbolIndex(),
+		calls:       newCallGraph(),
+		gen:         1,
+	}
+}
+
+// GetFile 获取分析过的文件
+func (p *Project) GetFile(path string) *ast.FileInfo {
+	return p.fileMap[path]
+}
+
+// FileList 所有的文件，按路径排序
+func (p *Project) FileList() []*ast.FileInfo {
+	var list = make([]*ast.FileInfo, 0, len(p.fileMap))
+	for _, file := range p.fileMap {
+		list = append(list, file)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].GetPath() < list[j].GetPath()
+	})
+	return list
+}
+
+// fileKeys 一个文件添加到工程索引里的 key ，删除文件时只处理这些 key
+type fileKeys struct {
+	globals    []string
+	tables     []string
+	classes    []string
+	aliases    []string
+	enums      []string
+	fieldRefs  []string
+	typeRefs   []string
+	subclasses []string
+}
+
+// UpdateFile 添加或者替换一个文件的分析结果
+func (p *Project) UpdateFile(file *ast.FileInfo) {
+	p.RemoveFile(file.GetPath())
+	p.fileMap[file.GetPath()] = file
+	var keys = &fileKeys{}
+	for name, varInfo := range file.GlobalMaps {
+		p.globalMap[name] = append(p.globalMap[name], varInfo)
+		keys.globals = append(keys.globals, name)
+		p.addTable(varInfo.Table, keys)
+	}
+	for _, class := range file.Annotate.ClassList {
+		var name = class.NameAndLoc.Name
+		p.classMap[name] = append(p.classMap[name], class)
+		keys.classes = append(keys.classes, name)
+		var supers []string
+		for _, parent := range class.ParentTypeList {
+			var super = parentName(parent)
+			if super == "" || super == name || slices.Contains(supers, super) {
+				continue
+			}
+			supers = append(supers, super)
+			p.subclassMap[super] = append(p.subclassMap[super], class)
+			keys.subclasses = append(keys.subclasses, super)
+		}
+	}
+	for _, alias := range file.Annotate.AliasList {
+		var name = alias.NameAndLoc.Name
+		p.aliasMap[name] = append(p.aliasMap[name], alias)
+		keys.aliases = append(keys.aliases, name)
+	}
+	for _, enum := range file.Annotate.EnumList {
+		var name = enum.NameAndLoc.Name
+		p.enumMap[name] = append(p.enumMap[name], enum)
+		keys.enums = append(keys.enums, name)
+	}
+	for name := range file.FieldRefMap {
+		p.fieldRefMap[name] = append(p.fieldRefMap[name], file)
+		keys.fieldRefs = append(keys.fieldRefs, name)
+	}
+	for name := range file.TypeRefMap {
+		p.typeRefMap[name] = append(p.typeRefMap[name], file)
+		keys.typeRefs = append(keys.typeRefs, name)
+	}
+	p.fileKeys[file.GetPath()] = keys
+	p.symbolIndex.update(file.GetPath(), indexFile(file))
+	p.gen++
+}
+
+// addTable 添加全局表以及子表
+func (p *Project) addTable(table *ast.TableInfo, keys *fileKeys) {
+	if table == nil || table.GlobalPath == "" {
+		return
+	}
+	for _, old := range p.tableMap[table.GlobalPath] {
+		if old == table {
+			return
+		}
+	}
+	p.tableMap[table.GlobalPath] = append(p.tableMap[table.GlobalPath], table)
+	keys.tables = append(keys.tables, table.GlobalPath)
+	for _, field := range table.FieldList {
+		p.addTable(field.SubTable, keys)
+	}
+}
+
+// RemoveFile 删除一个文件的分析结果
+func (p *Project) RemoveFile(path string) {
+	var file = p.fileMap[path]
+	if file == nil {
+		return
+	}
+	var keys = p.fileKeys[path]
+	delete(p.fileMap, path)
+	delete(p.fileKeys, path)
+	removeFrom(p.globalMap, keys.globals, func(v *ast.VarInfo) bool { return v.File == file })
+	removeFrom(p.tableMap, keys.tables, func(t *ast.TableInfo) bool { return t.File == file })
+	removeFrom(p.classMap, keys.classes, func(c *ast.Type_Class) bool { return c.File == file })
+	removeFrom(p.aliasMap, keys.aliases, func(a *ast.Type_Alias) bool { return a.File == file })
+	removeFrom(p.enumMap, keys.enums, func(e *ast.Type_Enum) bool { return e.File == file })
+	removeFrom(p.fieldRefMap, keys.fieldRefs, func(f *ast.FileInfo) bool { return f == file })
+	removeFrom(p.typeRefMap, keys.typeRefs, func(f *ast.FileInfo) bool { return f == file })
+	removeFrom(p.subclassMap, keys.subclasses, func(c *ast.Type_Class) bool { return c.File == file })
+	p.symbolIndex.remove(path)
+	p.calls.removeFile(file)
+	p.gen++
+}
+
+// removeFrom 删除 map 里 keys 对应的列表中满足条件的元素，列表为空时删除 key 。
+// 列表可能还被调用者持有，有删除时才生成新的列表
+func removeFrom[T any](m map[string][]T, keys []string, del func(T) bool) {
+	for _, key := range keys {
+		var list, ok = m[key]
+		if !ok || !slices.ContainsFunc(list, del) {
+			continue
+		}
+		var newList = make([]T, 0, len(list)-1)
+		for _, item := range list {
+			if !del(item) {
+				newList = append(newList, item)
+			}
+		}
+		if len(newList) == 0 {
+			delete(m, key)
+		} else {
+			m[key] = newList
+		}
+	}
+}
+
+// GetGlobal 全局变量的定义，多个文件都有赋值时取第一个有定义的
+func (p *Project) GetGlobal(name string) *ast.VarInfo {
+	var list = p.globalMap[name]
+	var result *ast.VarInfo
+	for _, varInfo := range list {
+		if varInfo.DefStat == nil {
+			continue
+		}
+		if result == nil || varInfo.File.GetPath() < result.File.GetPath() {
+			result = varInfo
+		}
+	}
+	return result
+}
+
+// GetGlobalTables 全局路径对应的所有表，例如 M.sub
+func (p *Project) GetGlobalTables(path string) []*ast.TableInfo {
+	return p.tableMap[path]
+}
+
+// GetClass 注释定义的类
+func (p *Project) GetClass(name string) []*ast.Type_Class {
+	return p.classMap[name]
+}
+
+// GetAlias 注释定义的别名
+func (p *Project) GetAlias(name string) []*ast.Type_Alias {
+	return p.aliasMap[name]
+}
+
+// GetEnum 注释定义的枚举
+func (p *Project) GetEnum(name string) []*ast.Type_Enum {
+	return p.enumMap[name]
+}
+
+// FindRequire 查找 require 的模块对应的文件，a.b 对应 a/b.lua 或者 a/b/init.lua
+func (p *Project) FindRequire(name string) *ast.FileInfo {
+	var modPath = strings.ReplaceAll(name, ".", "/")
+	var suffixList = []string{"/" + modPath + ".lua", "/" + modPath + "/init.lua"}
+	var result *ast.FileInfo
+	for path, file := range p.fileMap {
+		path = "/" + strings.ReplaceAll(path, "\\", "/")
+		for _, suffix := range suffixList {
+			if !strings.HasSuffix(path, suffix) {
+				continue
+			}
+			// 多个文件匹配时取路径最短的
+			if result == nil || len(file.GetPath()) < len(result.GetPath()) {
+				result = file
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,135 @@
+package check
+
+import (
+	"fmt"
+	"mylua-lsp/lsp/ast"
+	"mylua-lsp/lsp/common"
+	"mylua-lsp/lsp/compiler"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// cursorMark 测试文本里标记请求位置的字符
+const cursorMark = "|"
+
+// analyzeText 分析一个文件的文本，path 用来区分文件
+func analyzeText(path string, text string) *ast.FileInfo {
+	var source = common.NewLuaSource([]byte(text), path)
+	return AnalyzeFile(compiler.ParseLuaFile(source, common.LuaVersion54))
+}
+
+// newTestProject 用 files 创建工程，key 是文件路径
+func newTestProject(files map[string]string) *Project {
+	var p = NewProject()
+	var paths = make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		p.UpdateFile(analyzeText(path, files[path]))
+	}
+	return p
+}
+
+// cursor 去掉文本里的光标标记，返回标记的位置
+func cursor(t *testing.T, text string) (string, Position) {
+	t.Helper()
+	var idx = strings.Index(text, cursorMark)
+	if idx < 0 {
+		t.Fatalf("no cursor in %q", text)
+	}
+	var before = text[:idx]
+	var line = strings.Count(before, "\n")
+	var column = idx - strings.LastIndex(before, "\n") - 1
+	return before + text[idx+len(cursorMark):], Position{Line: int32(line), Column: int32(column)}
+}
+
+// symbolAt 工程里加上带光标标记的文件 path ，返回光标位置的符号
+func symbolAt(t *testing.T, p *Project, path string, text string) *Symbol {
+	t.Helper()
+	var content, pos = cursor(t, text)
+	var file = analyzeText(path, content)
+	p.UpdateFile(file)
+	var sym = p.SymbolAt(file, pos)
+	if sym == nil {
+		t.Fatalf("no symbol at %v in %q", pos, text)
+	}
+	return sym
+}
+
+// applyEdits 把 file 里的 locList 都替换成 text ，返回修改后的文本
+func applyEdits(file *ast.FileInfo, locList []Location, text string) string {
+	var lines = strings.SplitAfter(file.Source.GetRawText(Location{End: Position{Line: int32(file.Source.GetLineNum())}}), "\n")
+	var offset = func(pos Position) int {
+		var n = 0
+		for _, line := range lines[:pos.Line] {
+			n += len(line)
+		}
+		return n + int(pos.Column)
+	}
+	var content = strings.Join(lines, "")
+	sort.Slice(locList, func(i, j int) bool {
+		return locList[j].Start.Before(locList[i].Start)
+	})
+	for _, loc := range locList {
+		content = content[:offset(loc.Start)] + text + content[offset(loc.End):]
+	}
+	return content
+}
+
+// locString 范围的文字表示 "行:列-行:列"
+func locString(loc Location) string {
+	return fmt.Sprintf("%d:%d-%d:%d", loc.Start.Line, loc.Start.Column, loc.End.Line, loc.End.Column)
+}
+
+func TestProjectUpdateRemove(t *testing.T) {
+	var p = newTestProject(map[string]string{
+		"a.lua": "---@class Shape\n---@alias Id integer\nM = { sub = { x = 1 } }\nfunction M.f() end\n",
+		"b.lua": "---@class Other\n---@type Shape\nlocal s\nprint(M.sub.x, s)\nN = 1\n",
+	})
+	var other = p.GetClass("Other")
+	if len(p.GetClass("Shape")) != 1 || len(p.GetAlias("Id")) != 1 || p.GetGlobal("M") == nil {
+		t.Fatal("a.lua is not indexed")
+	}
+	if len(p.GetGlobalTables("M.sub")) != 1 {
+		t.Fatal("sub table of M is not indexed")
+	}
+
+	p.UpdateFile(analyzeText("a.lua", "---@class Shape\nM = {}\n"))
+	if len(p.GetClass("Shape")) != 1 {
+		t.Errorf("class Shape defined %d times after update, want 1", len(p.GetClass("Shape")))
+	}
+	if p.GetAlias("Id") != nil || p.GetGlobalTables("M.sub") != nil {
+		t.Error("stale entries of a.lua after update")
+	}
+
+	p.RemoveFile("a.lua")
+	if p.GetFile("a.lua") != nil || p.GetGlobal("M") != nil || p.GetClass("Shape") != nil {
+		t.Error("stale entries of a.lua after remove")
+	}
+	if len(p.fieldRefMap["sub"]) != 1 || len(p.typeRefMap["Shape"]) != 1 {
+		t.Error("references of b.lua are removed")
+	}
+	var now = p.GetClass("Other")
+	if len(now) != 1 || &now[0] != &other[0] {
+		t.Error("entries of b.lua are changed by removing a.lua")
+	}
+
+	p.RemoveFile("b.lua")
+	for name, size := range map[string]int{
+		"globalMap":   len(p.globalMap),
+		"tableMap":    len(p.tableMap),
+		"classMap":    len(p.classMap),
+		"aliasMap":    len(p.aliasMap),
+		"enumMap":     len(p.enumMap),
+		"fieldRefMap": len(p.fieldRefMap),
+		"typeRefMap":  len(p.typeRefMap),
+		"fileKeys":    len(p.fileKeys),
+	} {
+		if size != 0 {
+			t.Errorf("%s has %d keys after removing all files", name, size)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package check
+
+import (
+	"mylua-lsp/lsp/ast"
+	"sort"
+)
+
+// Reference 符号的一次引用或者定义
+type Reference struct {
+	File    *ast.FileInfo
+	Loc     Location
+	IsWrite bool // 赋值或者定义
+	IsDecl  bool // 定义或者声明，例如 local a 里的 a ，@field 和 @class 的名字
+}
+
+// refCollector 收集引用，file 不为 nil 时只收集这个文件里的
+type refCollector struct {
+	p       *Project
+	file    *ast.FileInfo
+	visited map[Reference]bool
+	list    []Reference
+}
+
+// References 符号在整个工程里的所有引用，包括定义，按文件和位置排序
+func (p *Project) References(sym *Symbol) []Reference {
+	return p.collectReferences(sym, nil)
+}
+
+// FileReferences 符号在一个文件里的所有引用，包括定义
+func (p *Project) FileReferences(sym *Symbol, file *ast.FileInfo) []Reference {
+	return p.collectReferences(sym, file)
+}
+
+func (p *Project) collectReferences(sym *Symbol, file *ast.FileInfo) []Reference {
+	var c = &refCollector{p: p, file: file, visited: map[Reference]bool{}}
+	switch sym.Kind {
+	case SymbolVar:
+		if sym.Var.IsLocal() {
+			c.localRefs(sym.Var)
+		} else {
+			c.globalRefs(sym.Var.Name)
+		}
+	case SymbolMember:
+		c.memberRefs(sym.Name, sym.Members)
+	case SymbolType:
+		c.typeRefs(sym.Name)
+	case SymbolLabel:
+		if sym.Label != nil {
+			c.labelRefs(sym.File, sym.Label)
+		}
+	}
+	sort.SliceStable(c.list, func(i, j int) bool {
+		var a, b = c.list[i], c.list[j]
+		if a.File != b.File {
+			return a.File.GetPath() < b.File.GetPath()
+		}
+		return a.Loc.Start.Before(b.Loc.Start)
+	})
+	return c.list
+}
+
+func (c *refCollector) add(ref Reference) {
+	if ref.File == nil || c.file != nil && ref.File != c.file {
+		return
+	}
+	var key = Reference{File: ref.File, Loc: ref.Loc}
+	if c.visited[key] {
+		return
+	}
+	c.visited[key] = true
+	c.list = append(c.list, ref)
+}
+
+// addVarRefs 变量在文件里的所有引用
+func (c *refCollector) addVarRefs(varInfo *ast.VarInfo) {
+	for _, ref := range varInfo.RefList {
+		c.add(Reference{File: varInfo.File, Loc: ref.Exp.Loc, IsWrite: ref.IsWrite})
+	}
+}
+
+// localRefs 局部变量只在定义的函数里引用，参数还包括 @param 的名字
+func (c *refCollector) localRefs(varInfo *ast.VarInfo) {
+	if varInfo.Kind != ast.VarKindSelf {
+		c.add(Reference{File: varInfo.File, Loc: varInfo.Loc, IsWrite: true, IsDecl: true})
+	}
+	if varInfo.Kind == ast.VarKindParam && varInfo.Func != nil {
+		if state := FindParamState(varInfo.Func.Comment, varInfo.Name); state != nil {
+			c.add(Reference{File: varInfo.File, Loc: state.NameAndLoc.Loc, IsDecl: true})
+		}
+	}
+	c.addVarRefs(varInfo)
+}
+
+// globalRefs 所有文件里同名的全局变量
+func (c *refCollector) globalRefs(name string) {
+	for _, varInfo := range c.p.globalMap[name] {
+		if varInfo.DefStat != nil {
+			c.add(Reference{File: varInfo.File, Loc: varInfo.Loc, IsWrite: true, IsDecl: true})
+		}
+		c.addVarRefs(varInfo)
+	}
+}
+
+// memberKey 成员定义的标识，同一个字段的不同 Member 有相同的标识
+func memberKey(member *Member) any {
+	switch {
+	case member.Field != nil:
+		return member.Field
+	case member.ClassField != nil:
+		return member.ClassField
+	}
+	return member.MapField
+}
+
+// memberRefs 引用了同名字段的地方，字段所属的类型和 members 有相同的定义时才算
+func (c *refCollector) memberRefs(name string, members []*Member) {
+	var keySet = map[any]bool{}
+	for _, member := range members {
+		keySet[memberKey(member)] = true
+		if member.ClassField != nil {
+			c.add(Reference{File: member.GetFile(), Loc: member.GetLoc(), IsWrite: true, IsDecl: true})
+		}
+	}
+	var fileList = c.p.fieldRefMap[name]
+	if c.file != nil {
+		fileList = []*ast.FileInfo{c.file}
+	}
+	for _, file := range fileList {
+		for _, ref := range file.FieldRefMap[name] {
+			var refMembers []*Member
+			if ref.Prefix != nil {
+				refMembers = c.p.ExpMembers(file, ref.Prefix, name)
+			} else {
+				refMembers = c.p.FindMembers(ref.Table, name)
+			}
+			for _, member := range refMembers {
+				if keySet[memberKey(member)] {
+					// 字符串写的 key 的引用只是引号里面的内容
+					var isDecl = member.Field != nil && inLoc(member.Field.Loc, ref.Loc.Start) && member.GetFile() == file
+					c.add(Reference{File: file, Loc: ref.Loc, IsWrite: ref.IsWrite, IsDecl: isDecl})
+					break
+				}
+			}
+		}
+	}
+}
+
+// typeRefs 类型的定义以及注释里的引用
+func (c *refCollector) typeRefs(name string) {
+	var addDecl = func(file *ast.FileInfo, loc Location) {
+		c.add(Reference{File: file, Loc: loc, IsWrite: true, IsDecl: true})
+	}
+	for _, class := range c.p.GetClass(name) {
+		addDecl(class.File, class.NameAndLoc.Loc)
+	}
+	for _, alias := range c.p.GetAlias(name) {
+		addDecl(alias.File, alias.NameAndLoc.Loc)
+	}
+	for _, enum := range c.p.GetEnum(name) {
+		addDecl(enum.File, enum.NameAndLoc.Loc)
+	}
+	for _, file := range c.p.typeRefMap[name] {
+		for _, ref := range file.TypeRefMap[name] {
+			c.add(Reference{File: file, Loc: ref.Loc})
+		}
+	}
+}
+
+// labelRefs 标签的定义以及跳转到它的 goto
+func (c *refCollector) labelRefs(file *ast.FileInfo, label *ast.LabelInfo) {
+	c.add(Reference{File: file, Loc: label.Loc, IsWrite: true, IsDecl: true})
+	ast.Inspect(file.Block, func(node ast.Stat) bool {
+		if stat, ok := node.(*ast.GotoStat); ok && FindLabel(file, stat) == label {
+			c.add(Reference{File: file, Loc: stat.Name.Loc})
+		}
+		return node != nil
+	})
+}
@@ -0,0 +1,41 @@
+package check
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestReferences(t *testing.T) {
+	var tests = []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"local", "local count = 1\ncount = co|unt + 1\nlocal function f() return count end\n",
+			[]string{"a.lua 0:6-0:11 decl", "a.lua 1:0-1:5 write", "a.lua 1:8-1:13", "a.lua 2:26-2:31"}},
+		{"global", "local s = Sha|pe\n", []string{"a.lua 0:10-0:15", "lib.lua 2:0-2:5 decl", "lib.lua 4:9-4:14"}},
+		{"method", "---@type Shape\nlocal s\ns:dr|aw()\n", []string{"a.lua 2:2-2:6", "lib.lua 4:15-4:19 decl"}},
+		{"field", "print(Point.|x)\n", []string{"a.lua 0:12-0:13", "lib.lua 8:10-8:11 decl", "lib.lua 10:6-10:7 write"}},
+		{"label", "do\n  goto done\n  ::do|ne::\nend\n", []string{"a.lua 1:7-1:11", "a.lua 2:4-2:8 decl"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p = newTestProject(libFiles)
+			var sym = symbolAt(t, p, "a.lua", tt.text)
+			var got []string
+			for _, ref := range p.References(sym) {
+				var line = fmt.Sprintf("%s %s", ref.File.GetPath(), locString(ref.Loc))
+				if ref.IsDecl {
+					line += " decl"
+				} else if ref.IsWrite {
+					line += " write"
+				}
+				got = append(got, line)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package check
+
+import (
+	"errors"
+	"fmt"
+	"mylua-lsp/lsp/ast"
+	"slices"
+)
+
+// PrepareRename 检查符号能否重命名，返回不能重命名的原因
+func (p *Project) PrepareRename(sym *Symbol) error {
+	switch sym.Kind {
+	case SymbolVar:
+		if sym.Var.Kind == ast.VarKindSelf {
+			return errors.New("cannot rename 'self'")
+		}
+		if !sym.Var.IsLocal() && p.GetGlobal(sym.Var.Name) == nil {
+			return fmt.Errorf("cannot rename '%s', it is not defined in the workspace", sym.Name)
+		}
+	case SymbolMember:
+		if len(sym.Members) == 0 {
+			return fmt.Errorf("cannot rename '%s', it is not defined in the workspace", sym.Name)
+		}
+		for _, member := range sym.Members {
+			if member.MapField != nil {
+				return fmt.Errorf("cannot rename '%s', it is defined in a table type", sym.Name)
+			}
+		}
+	case SymbolType:
+		if !p.isUserType(sym.Name) {
+			return fmt.Errorf("cannot rename builtin type '%s'", sym.Name)
+		}
+	case SymbolLabel:
+		if sym.Label == nil {
+			return fmt.Errorf("no visible label '%s'", sym.Name)
+		}
+	default:
+		return errors.New("this element cannot be renamed")
+	}
+	return nil
+}
+
+// isUserType 是否为 @class @alias @enum 定义的类型
+func (p *Project) isUserType(name string) bool {
+	return len(p.GetClass(name)) > 0 || len(p.GetAlias(name)) > 0 || len(p.GetEnum(name)) > 0
+}
+
+// isValidName 是否为合法的名字，不能是关键字
+func isValidName(name string) bool {
+	if name == "" || name[0] >= '0' && name[0] <= '9' {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !isIdentByte(name[i]) {
+			return false
+		}
+	}
+	_, isKeyword := ast.Keywords[name]
+	return !isKeyword
+}
+
+// Rename 重命名符号，返回需要修改的所有位置。新名字会和其他名字冲突时返回错误，
+// opts 用来判断全局变量的新名字是否和标准库或者宿主程序注入的全局变量冲突
+func (p *Project) Rename(sym *Symbol, newName string, opts *DiagnosticOptions) ([]Reference, error) {
+	if err := p.PrepareRename(sym); err != nil {
+		return nil, err
+	}
+	if _, isKeyword := ast.Keywords[newName]; isKeyword {
+		return nil, fmt.Errorf("'%s' is a Lua keyword", newName)
+	}
+	if !isValidName(newName) {
+		return nil, fmt.Errorf("'%s' is not a valid name", newName)
+	}
+	if newName == sym.Name {
+		return nil, nil
+	}
+	var refList = p.References(sym)
+	var err error
+	switch sym.Kind {
+	case SymbolVar:
+		if sym.Var.IsLocal() {
+			err = p.checkLocalRename(sym.Var, newName)
+		} else {
+			err = p.checkGlobalRename(refList, newName, opts)
+		}
+	case SymbolMember:
+		err = p.checkMemberRename(sym.Members, newName)
+		if err == nil {
+			err = checkStringKeys(refList, sym.Name)
+		}
+	case SymbolType:
+		if p.isUserType(newName) || slices.Contains(builtinTypes, newName) {
+			err = fmt.Errorf("type '%s' already exists", newName)
+		}
+	case SymbolLabel:
+		err = checkLabelRename(sym.File, sym.Label, newName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return refList, nil
+}
+
+// isInner v 是否定义在 target 的作用域里面，并且在 target 的后面，这时 v 会遮住 target
+func isInner(v, target *ast.VarInfo) bool {
+	if !v.IsLocal() || !target.IsLocal() || v.File != target.File {
+		return false
+	}
+	for scope := v.Scope; scope != nil; scope = scope.Parent {
+		if scope == target.Scope {
+			return scope != v.Scope || target.VisiblePos.Before(v.VisiblePos)
+		}
+	}
+	return false
+}
+
+// checkLocalRename 局部变量改名后，引用不能被里面同名的变量遮住，也不能遮住外面同名变量的引用
+func (p *Project) checkLocalRename(target *ast.VarInfo, newName string) error {
+	var file = target.File
+	var mainScope = file.MainFunc.Scope
+	var refPosList = []Position{target.Loc.Start}
+	for _, ref := range target.RefList {
+		refPosList = append(refPosList, ref.Exp.Loc.Start)
+	}
+	for _, pos := range refPosList {
+		if other := mainScope.FindScope(pos).FindVar(newName, pos); other != nil && other != target && isInner(other, target) {
+			return fmt.Errorf("'%s' would be shadowed by the local '%s' defined at line %d", target.Name, newName, other.Loc.Start.Line+1)
+		}
+	}
+	for exp, other := range file.NameVarMap {
+		if exp.Name != newName || other == target {
+			continue
+		}
+		var pos = exp.Loc.Start
+		if inLoc(target.Scope.Loc, pos) && !pos.Before(target.VisiblePos) && !isInner(other, target) {
+			return fmt.Errorf("'%s' would capture the reference to '%s' at line %d", newName, newName, pos.Line+1)
+		}
+	}
+	return nil
+}
+
+// checkGlobalRename 全局变量改名后不能和已有的全局变量、标准库以及宿主程序注入的全局变量重名，引用的地方也不能有同名的局部变量
+func (p *Project) checkGlobalRename(refList []Reference, newName string, opts *DiagnosticOptions) error {
+	if len(p.globalMap[newName]) > 0 {
+		return fmt.Errorf("global '%s' already exists", newName)
+	}
+	if isStdlibGlobal(newName, opts.Version) {
+		return fmt.Errorf("'%s' is a standard library global", newName)
+	}
+	if slices.Contains(opts.Globals, newName) {
+		return fmt.Errorf("'%s' is a global provided by the host", newName)
+	}
+	for _, ref := range refList {
+		var pos = ref.Loc.Start
+		if local := ref.File.MainFunc.Scope.FindScope(pos).FindVar(newName, pos); local != nil {
+			return fmt.Errorf("the reference at %s:%d would be captured by the local '%s'", ref.File.GetPath(), pos.Line+1, newName)
+		}
+	}
+	return nil
+}
+
+// checkMemberRename 字段改名后不能和所属的表或者类里已有的字段重名
+func (p *Project) checkMemberRename(members []*Member, newName string) error {
+	for _, member := range members {
+		var owner ast.TypeBase
+		switch {
+		case member.Field != nil:
+			owner = member.Field.Table
+		case member.Class != nil:
+			owner = &ast.Type_Identifier{NameAndLoc: member.Class.NameAndLoc}
+		}
+		if len(p.FindMembers(owner, newName)) > 0 {
+			return fmt.Errorf("field '%s' already exists", newName)
+		}
+	}
+	return nil
+}
+
+// checkStringKeys t["k"] 这样的引用只修改引号里面的内容，有转义或者跨行的字符串不能修改，这时整个重命名都不做
+func checkStringKeys(refList []Reference, name string) error {
+	for _, ref := range refList {
+		if ref.File.Source.GetText(ref.Loc) != name {
+			return fmt.Errorf("cannot rename '%s', the string key at %s:%d uses escapes or spans lines", name, ref.File.GetPath(), ref.Loc.Start.Line+1)
+		}
+	}
+	return nil
+}
+
+// checkLabelRename 同一个函数里不能有同名的标签
+func checkLabelRename(file *ast.FileInfo, label *ast.LabelInfo, newName string) error {
+	for _, funcInfo := range file.FuncList {
+		if !slices.Contains(funcInfo.LabelInfoList, label) {
+			continue
+		}
+		for _, other := range funcInfo.LabelInfoList {
+			if other.Name == newName {
+				return fmt.Errorf("label '%s' already exists at line %d", newName, other.Loc.Start.Line+1)
+			}
+		}
+	}
+	return nil
+}
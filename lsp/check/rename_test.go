@@ -0,0 +1,86 @@
+package check
+
+import (
+	"mylua-lsp/lsp/common"
+	"strings"
+	"testing"
+)
+
+// renameText 重命名光标位置的符号，返回修改后的文件内容或者错误
+func renameText(t *testing.T, text string, newName string) (string, error) {
+	t.Helper()
+	var p = NewProject()
+	var sym = symbolAt(t, p, "a.lua", text)
+	var opts = &DiagnosticOptions{Version: common.DefaultLuaVersion, Globals: []string{"game"}}
+	var refList, err = p.Rename(sym, newName, opts)
+	if err != nil {
+		return "", err
+	}
+	var file = p.GetFile("a.lua")
+	var locList []Location
+	for _, ref := range refList {
+		if ref.File != file {
+			t.Fatalf("reference in other file %s", ref.File.GetPath())
+		}
+		locList = append(locList, ref.Loc)
+	}
+	return applyEdits(file, locList, newName), nil
+}
+
+func TestRename(t *testing.T) {
+	var tests = []struct {
+		name    string
+		text    string
+		newName string
+		want    string
+	}{
+		{"local", "local a|b = 1\nprint(ab, ab + 1)\n", "cd", "local cd = 1\nprint(cd, cd + 1)\n"},
+		{"param with @param", "---@param x number\nlocal function f(|x) return x end\n", "y",
+			"---@param y number\nlocal function f(y) return y end\n"},
+		{"field", "local t = { name = 1 }\nt.na|me = t.name + 1\n", "title",
+			"local t = { title = 1 }\nt.title = t.title + 1\n"},
+		{"string keys", "local t = { [\"name\"] = 1 }\nt.na|me = t['name'] + t[ [[name]] ]\n", "title",
+			"local t = { [\"title\"] = 1 }\nt.title = t['title'] + t[ [[title]] ]\n"},
+		{"string key of other table", "local t = { name = 1 }\nlocal u = { name = 2 }\nprint(t.na|me, u[\"name\"])\n", "title",
+			"local t = { title = 1 }\nlocal u = { name = 2 }\nprint(t.title, u[\"name\"])\n"},
+		{"label", "do\n  goto top\n  ::to|p::\nend\n", "again", "do\n  goto again\n  ::again::\nend\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got, err = renameText(t, tt.text, tt.newName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenameError(t *testing.T) {
+	var tests = []struct {
+		name    string
+		text    string
+		newName string
+		want    string
+	}{
+		{"keyword", "local a|b = 1\n", "end", "'end' is a Lua keyword"},
+		{"invalid name", "local a|b = 1\n", "1x", "'1x' is not a valid name"},
+		{"shadowed", "local a|b = 1\ndo\n  local cd = 2\n  print(ab, cd)\nend\n", "cd", "would be shadowed"},
+		{"existing field", "local t = { a = 1, b = 2 }\nprint(t.|a)\n", "b", "field 'b' already exists"},
+		{"escaped string key", "local t = { name = 1 }\nprint(t.na|me, t[\"n\\97me\"])\n", "title", "uses escapes or spans lines"},
+		{"self", "local t = {}\nfunction t:f() return se|lf end\n", "this", "cannot rename 'self'"},
+		{"stdlib function", "cou|nt = 1\nreturn count\n", "print", "'print' is a standard library global"},
+		{"stdlib table", "cou|nt = 1\nreturn count\n", "table", "'table' is a standard library global"},
+		{"host global", "cou|nt = 1\nreturn count\n", "game", "'game' is a global provided by the host"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var _, err = renameText(t, tt.text, tt.newName)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
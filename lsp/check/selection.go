@@ -0,0 +1,69 @@
+package check
+
+import (
+	"mylua-lsp/lsp/ast"
+)
+
+// SelectionRanges 光标处从内到外的选择范围，从名字开始，沿着父节点扩展到表达式、语句、语句块和函数。
+// 每个范围都包含前一个范围，相同的范围只保留一个
+func SelectionRanges(file *ast.FileInfo, pos Position) []Location {
+	var inner ast.Stat
+	ast.Inspect(file.Block, func(node ast.Stat) bool {
+		if node == nil || !inLoc(node.GetLoc(), pos) {
+			return false
+		}
+		inner = node
+		return true
+	})
+	if inner == nil {
+		return nil
+	}
+	var list []Location
+	var add = func(loc Location) {
+		if n := len(list); n > 0 {
+			var last = list[n-1]
+			// 父节点的范围可能不包含子节点的名字，例如 function M.f() 的 FuncDefExp 从括号开始
+			if last.Start.Before(loc.Start) {
+				loc.Start = last.Start
+			}
+			if loc.End.Before(last.End) {
+				loc.End = last.End
+			}
+			if loc == last {
+				return
+			}
+		}
+		list = append(list, loc)
+	}
+	for _, token := range nodeTokens(inner) {
+		if inLoc(token.Loc, pos) {
+			add(token.Loc)
+			break
+		}
+	}
+	for node := inner; node != nil; node = node.GetParent() {
+		add(node.GetLoc())
+	}
+	return list
+}
+
+// nodeTokens 节点里不是子节点的名字，例如 local 定义的变量名和函数的参数
+func nodeTokens(node ast.Stat) []ast.Token {
+	switch n := node.(type) {
+	case *ast.LocalVarDeclStat:
+		return n.NameList
+	case *ast.ForInStat:
+		return n.NameList
+	case *ast.ForNumStat:
+		return []ast.Token{n.VarName}
+	case *ast.LocalFuncDefStat:
+		return []ast.Token{n.Name}
+	case *ast.FuncDefExp:
+		return n.ParList
+	case *ast.LabelStat:
+		return []ast.Token{n.Name}
+	case *ast.GotoStat:
+		return []ast.Token{n.Name}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package check
+
+import (
+	"slices"
+	"testing"
+)
+
+// selectionRanges 光标处从内到外的选择范围的文字
+func selectionRanges(t *testing.T, text string) []string {
+	t.Helper()
+	var content, pos = cursor(t, text)
+	var file = analyzeText("a.lua", content)
+	var list []string
+	for _, loc := range SelectionRanges(file, pos) {
+		list = append(list, file.Source.GetRawText(loc))
+	}
+	return list
+}
+
+func TestSelectionRanges(t *testing.T) {
+	var tests = []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"identifier to function", "local function f(a)\n  local x = |a + 1\n  return x\nend\nf(1)\n", []string{
+			"a",
+			"a + 1",
+			"local x = a + 1",
+			"local x = a + 1\n  return x",
+			"(a)\n  local x = a + 1\n  return x\nend",
+			"local function f(a)\n  local x = a + 1\n  return x\nend",
+			"local function f(a)\n  local x = a + 1\n  return x\nend\nf(1)",
+		}},
+		{"parameter", "local function f(|a)\n  return a\nend\n", []string{
+			"a",
+			"(a)\n  return a\nend",
+			"local function f(a)\n  return a\nend",
+		}},
+		{"for variable", "for |i = 1, 2 do end\n", []string{"i", "for i = 1, 2 do end"}},
+		{"table field", "local t = { k = f(|x) }\n", []string{"x", "f(x)", "{ k = f(x) }", "local t = { k = f(x) }"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := selectionRanges(t, tt.text); !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,329 @@
+package check
+
+import (
+	"mylua-lsp/lsp/ast"
+	"slices"
+	"sort"
+)
+
+/*
+语义高亮。代码里的名字根据解析出的变量和字段分类，注释里的标记、类型名和参数名也有高亮。
+*/
+
+// SemanticType 语义高亮的种类
+type SemanticType uint8
+
+const (
+	SemanticVariable      SemanticType = iota // 变量
+	SemanticParameter                         // 参数和 self
+	SemanticProperty                          // 字段
+	SemanticMethod                            // 调用的字段或者值为函数的字段
+	SemanticFunction                          // 值为函数的变量
+	SemanticClass                             // @class
+	SemanticEnum                              // @enum
+	SemanticEnumMember                        // 枚举的成员
+	SemanticTypeName                          // @alias 和基础类型
+	SemanticTypeParameter                     // @generic 的泛型参数
+	SemanticKeyword                           // 注释标记 ---@
+)
+
+// SemanticModifier 语义高亮的修饰，可以组合
+type SemanticModifier uint16
+
+const (
+	SemanticDeclaration    SemanticModifier = 1 << iota // 定义的地方
+	SemanticReadonly                                    // <const> <close> 局部变量
+	SemanticDeprecated                                  // ---@deprecated
+	SemanticDocumentation                               // 注释里的
+	SemanticDefaultLibrary                              // 基础类型
+	SemanticGlobal                                      // 全局变量
+	SemanticUpvalue                                     // 在里面的函数里引用的局部变量
+	SemanticSelf                                        // self
+)
+
+// SemanticToken 一个高亮的名字，只在一行内
+type SemanticToken struct {
+	Loc       Location
+	Type      SemanticType
+	Modifiers SemanticModifier
+}
+
+// tokenCollector 遍历语法树收集高亮
+type tokenCollector struct {
+	p         *Project
+	file      *ast.FileInfo
+	nodes     []ast.Stat      // 正在访问的节点
+	funcStack []*ast.FuncInfo // 所在的函数，第一个是主函数
+	called    map[ast.Exp]bool
+	list      []SemanticToken
+}
+
+// SemanticTokens 文件里所有需要高亮的名字，按位置排序，不会重叠
+func (p *Project) SemanticTokens(file *ast.FileInfo) []SemanticToken {
+	var c = &tokenCollector{
+		p:         p,
+		file:      file,
+		funcStack: []*ast.FuncInfo{file.MainFunc},
+		called:    map[ast.Exp]bool{},
+	}
+	c.varDecls(file.MainFunc.Scope)
+	ast.Walk(c, file.Block)
+	for _, block := range file.Annotate.BlockList {
+		c.annotate(block)
+	}
+	sort.SliceStable(c.list, func(i, j int) bool {
+		return c.list[i].Loc.Start.Before(c.list[j].Loc.Start)
+	})
+	var result = c.list[:0]
+	for _, token := range c.list {
+		var loc = token.Loc
+		if loc.Start.Line != loc.End.Line || !loc.Start.Before(loc.End) {
+			continue
+		}
+		if n := len(result); n > 0 && loc.Start.Before(result[n-1].Loc.End) {
+			continue
+		}
+		result = append(result, token)
+	}
+	return result
+}
+
+func (c *tokenCollector) add(loc Location, t SemanticType, mods SemanticModifier) {
+	c.list = append(c.list, SemanticToken{Loc: loc, Type: t, Modifiers: mods})
+}
+
+// isDeprecated 注释里是否有 ---@deprecated
+func isDeprecated(comment *ast.AnnotateBlock) bool {
+	for _, line := range commentLines(comment) {
+		if line.Tag.Name == "deprecated" {
+			return true
+		}
+	}
+	return false
+}
+
+// varDecls 所有局部变量定义的名字，包括参数和 for 的变量
+func (c *tokenCollector) varDecls(scope *ast.ScopeInfo) {
+	for _, varInfo := range scope.VarInfoList {
+		if varInfo.Kind != ast.VarKindSelf {
+			var t, mods = c.varType(varInfo, varInfo.Func)
+			c.add(varInfo.Loc, t, mods|SemanticDeclaration)
+		}
+	}
+	for _, sub := range scope.SubScopes {
+		c.varDecls(sub)
+	}
+}
+
+// varType 变量的高亮种类，curFunc 是引用所在的函数
+func (c *tokenCollector) varType(varInfo *ast.VarInfo, curFunc *ast.FuncInfo) (SemanticType, SemanticModifier) {
+	var mods SemanticModifier
+	if varInfo.IsLocal() && varInfo.Func != curFunc {
+		mods |= SemanticUpvalue
+	}
+	switch varInfo.Kind {
+	case ast.VarKindSelf:
+		return SemanticParameter, mods | SemanticSelf
+	case ast.VarKindParam:
+		if isDeprecated(varInfo.Comment) {
+			mods |= SemanticDeprecated
+		}
+		return SemanticParameter, mods
+	case ast.VarKindGlobal:
+		mods |= SemanticGlobal
+		if def := c.p.GetGlobal(varInfo.Name); def != nil {
+			varInfo = def
+		}
+	}
+	if varInfo.Attr != ast.VDKREG {
+		mods |= SemanticReadonly
+	}
+	if isDeprecated(varInfo.Comment) {
+		mods |= SemanticDeprecated
+	}
+	if _, ok := varInfo.ValueExp.(*ast.FuncDefExp); ok || varInfo.Kind == ast.VarKindLocalFunc {
+		return SemanticFunction, mods
+	}
+	return SemanticVariable, mods
+}
+
+// Visit 访问语法树的节点，node 为 nil 时表示上一个节点的子节点访问完了
+func (c *tokenCollector) Visit(node ast.Stat) ast.Visitor {
+	if node == nil {
+		var last = c.nodes[len(c.nodes)-1]
+		c.nodes = c.nodes[:len(c.nodes)-1]
+		if _, ok := last.(*ast.FuncDefExp); ok {
+			c.funcStack = c.funcStack[:len(c.funcStack)-1]
+		}
+		return nil
+	}
+	c.nodes = append(c.nodes, node)
+	switch n := node.(type) {
+	case *ast.FuncDefExp:
+		var funcInfo = c.file.FuncMap[n]
+		if funcInfo == nil {
+			funcInfo = c.funcStack[len(c.funcStack)-1]
+		}
+		c.funcStack = append(c.funcStack, funcInfo)
+	case *ast.AssignStat:
+		for i, exp := range n.VarList {
+			if _, ok := expAt(n.ExpList, i).(*ast.FuncDefExp); ok {
+				c.called[exp] = true
+			}
+		}
+	case *ast.NameExp:
+		if varInfo := c.file.NameVarMap[n]; varInfo != nil {
+			var t, mods = c.varType(varInfo, c.funcStack[len(c.funcStack)-1])
+			if !varInfo.IsLocal() && varInfo.Loc == n.Loc && varInfo.DefStat != nil {
+				mods |= SemanticDeclaration
+			}
+			c.add(n.Loc, t, mods)
+		}
+	case *ast.TableAccessExp:
+		if key, ok := n.KeyExp.(*ast.StringExp); ok && isNameKey(key) {
+			c.field(n.PrefixExp, key, c.called[n], n.IsWriteExp)
+		}
+	case *ast.FuncCallExp:
+		c.called[n.PrefixExp] = true
+		if n.NameExp != nil {
+			c.field(n.PrefixExp, n.NameExp, true, false)
+		}
+	case *ast.TableConstructorExp:
+		var table = c.file.TableMap[n]
+		for i, value := range n.ValExps {
+			var key, ok = expAt(n.KeyExps, i).(*ast.StringExp)
+			if !ok || !isNameKey(key) {
+				continue
+			}
+			var t, mods = SemanticProperty, SemanticDeclaration
+			if _, isFunc := value.(*ast.FuncDefExp); isFunc {
+				t = SemanticMethod
+			}
+			if table != nil {
+				if field := table.GetField(key.Str); field != nil {
+					t, mods = c.fieldType(field, t, mods)
+				}
+			}
+			c.add(key.Loc, t, mods)
+		}
+	}
+	return c
+}
+
+// field 字段的名字，isCall 是否为调用的函数或者赋值为函数
+func (c *tokenCollector) field(prefix ast.Exp, key *ast.StringExp, isCall bool, isWrite bool) {
+	var t, mods = SemanticProperty, SemanticModifier(0)
+	if isCall {
+		t = SemanticMethod
+	}
+	for _, member := range c.p.ExpMembers(c.file, prefix, key.Str) {
+		if member.Field == nil {
+			continue
+		}
+		if isWrite && member.Field.Loc == key.Loc && member.GetFile() == c.file {
+			mods |= SemanticDeclaration
+		}
+		t, mods = c.fieldType(member.Field, t, mods)
+		break
+	}
+	c.add(key.Loc, t, mods)
+}
+
+// fieldType 根据字段的定义调整高亮的种类
+func (c *tokenCollector) fieldType(field *ast.FieldInfo, t SemanticType, mods SemanticModifier) (SemanticType, SemanticModifier) {
+	if isDeprecated(field.Comment) {
+		mods |= SemanticDeprecated
+	}
+	if _, ok := field.ValueExp.(*ast.FuncDefExp); ok || field.IsMethod {
+		t = SemanticMethod
+	}
+	if name := field.Table.ClassName; name != "" && len(c.p.GetEnum(name)) > 0 {
+		t = SemanticEnumMember
+	}
+	return t, mods
+}
+
+// annotate 注释块里的标记，类型名和参数名
+func (c *tokenCollector) annotate(block *ast.AnnotateBlock) {
+	var generics []string
+	for _, line := range block.LineList {
+		switch state := line.State.(type) {
+		case *ast.AnnotateGenericState:
+			for _, param := range state.ParamList {
+				generics = append(generics, param.NameAndLoc.Name)
+			}
+		case *ast.AnnotateClassState:
+			for _, param := range state.GenericParamList {
+				generics = append(generics, param.NameAndLoc.Name)
+			}
+		}
+	}
+	const doc = SemanticDocumentation
+	var types = func(list ...ast.TypeBase) {
+		for _, t := range list {
+			walkTypeNames(t, func(name ast.NameAndLoc) bool {
+				c.add(name.Loc, c.typeNameType(name.Name, generics), doc|c.typeNameMods(name.Name))
+				return true
+			})
+		}
+	}
+	for _, line := range block.LineList {
+		if line.Tag.Name != "" {
+			// 标记包括前面的 @
+			var loc = line.Tag.Loc
+			loc.Start.Column--
+			c.add(loc, SemanticKeyword, doc)
+		}
+		switch state := line.State.(type) {
+		case *ast.AnnotateClassState:
+			c.add(state.NameAndLoc.Loc, SemanticClass, doc|SemanticDeclaration)
+			for _, param := range state.GenericParamList {
+				c.add(param.NameAndLoc.Loc, SemanticTypeParameter, doc|SemanticDeclaration)
+				types(param.Type)
+			}
+			types(state.ParentTypeList...)
+		case *ast.AnnotateAliasState:
+			c.add(state.NameAndLoc.Loc, SemanticTypeName, doc|SemanticDeclaration)
+			types(state.Type)
+		case *ast.AnnotateEnumState:
+			c.add(state.NameAndLoc.Loc, SemanticEnum, doc|SemanticDeclaration)
+		case *ast.AnnotateFieldState:
+			c.add(state.NameAndLoc.Loc, SemanticProperty, doc|SemanticDeclaration)
+			types(state.FieldType)
+		case *ast.AnnotateParamState:
+			c.add(state.NameAndLoc.Loc, SemanticParameter, doc)
+			types(state.ParamType)
+		case *ast.AnnotateGenericState:
+			for _, param := range state.ParamList {
+				c.add(param.NameAndLoc.Loc, SemanticTypeParameter, doc|SemanticDeclaration)
+				types(param.Type)
+			}
+		case *ast.AnnotateTypeState:
+			types(state.TypeList...)
+		case *ast.AnnotateReturnState:
+			types(state.ReturnTypeList...)
+		case *ast.AnnotateOverloadState:
+			types(state.Fun)
+		}
+	}
+}
+
+// typeNameType 注释里引用的类型名的高亮种类
+func (c *tokenCollector) typeNameType(name string, generics []string) SemanticType {
+	switch {
+	case slices.Contains(generics, name):
+		return SemanticTypeParameter
+	case len(c.p.GetClass(name)) > 0:
+		return SemanticClass
+	case len(c.p.GetEnum(name)) > 0:
+		return SemanticEnum
+	}
+	return SemanticTypeName
+}
+
+func (c *tokenCollector) typeNameMods(name string) SemanticModifier {
+	if slices.Contains(builtinTypes, name) {
+		return SemanticDefaultLibrary
+	}
+	return 0
+}
@@ -0,0 +1,91 @@
+package check
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// semanticTokens 文件的语义高亮，每个是 "行:列 文字 种类 修饰"
+func semanticTokens(text string) []string {
+	var p = NewProject()
+	var file = analyzeText("a.lua", text)
+	p.UpdateFile(file)
+	var list []string
+	for _, token := range p.SemanticTokens(file) {
+		list = append(list, tokenString(token.Loc.Start.Line, token.Loc.Start.Column, file.Source.GetRawText(token.Loc), token.Type, token.Modifiers))
+	}
+	return list
+}
+
+func tokenString(line, column int32, text string, t SemanticType, mods SemanticModifier) string {
+	return fmt.Sprintf("%d:%d %s %d %d", line, column, text, t, mods)
+}
+
+func TestSemanticTokens(t *testing.T) {
+	const doc = SemanticDocumentation
+	var text = "---@class Point\n---@field x number\nlocal Point = {}\nlocal count <const> = 1\n---@deprecated\nlocal function old() end\n" +
+		"function Point:move(dx)\n  self.x = self.x + dx + count\n  old()\nend\nprint(Point)\n"
+	var want = []string{
+		tokenString(0, 3, "@class", SemanticKeyword, doc),
+		tokenString(0, 10, "Point", SemanticClass, doc|SemanticDeclaration),
+		tokenString(1, 3, "@field", SemanticKeyword, doc),
+		tokenString(1, 10, "x", SemanticProperty, doc|SemanticDeclaration),
+		tokenString(1, 12, "number", SemanticTypeName, doc|SemanticDefaultLibrary),
+		tokenString(2, 6, "Point", SemanticVariable, SemanticDeclaration),
+		tokenString(3, 6, "count", SemanticVariable, SemanticDeclaration|SemanticReadonly),
+		tokenString(4, 3, "@deprecated", SemanticKeyword, doc),
+		tokenString(5, 15, "old", SemanticFunction, SemanticDeclaration|SemanticDeprecated),
+		tokenString(6, 9, "Point", SemanticVariable, 0),
+		tokenString(6, 15, "move", SemanticMethod, SemanticDeclaration),
+		tokenString(6, 20, "dx", SemanticParameter, SemanticDeclaration),
+		tokenString(7, 2, "self", SemanticParameter, SemanticSelf),
+		tokenString(7, 7, "x", SemanticProperty, SemanticDeclaration),
+		tokenString(7, 11, "self", SemanticParameter, SemanticSelf),
+		tokenString(7, 16, "x", SemanticProperty, 0),
+		tokenString(7, 20, "dx", SemanticParameter, 0),
+		tokenString(7, 25, "count", SemanticVariable, SemanticReadonly|SemanticUpvalue),
+		tokenString(8, 2, "old", SemanticFunction, SemanticDeprecated|SemanticUpvalue),
+		tokenString(10, 0, "print", SemanticVariable, SemanticGlobal),
+		tokenString(10, 6, "Point", SemanticVariable, 0),
+	}
+	var got = semanticTokens(text)
+	if !slices.Equal(got, want) {
+		t.Errorf("got\n%q\nwant\n%q", got, want)
+	}
+}
+
+// 注释里的标记、类型名、泛型参数和参数名
+func TestSemanticTokensAnnotate(t *testing.T) {
+	const doc = SemanticDocumentation
+	var text = "---@alias ID integer\n---@enum Color\nlocal Color = { Red = 1 }\n---@generic T\n---@param v T\n---@param id ID\n---@return Color\n" +
+		"local function pick(v, id) return Color.Red end\n"
+	var want = []string{
+		tokenString(0, 3, "@alias", SemanticKeyword, doc),
+		tokenString(0, 10, "ID", SemanticTypeName, doc|SemanticDeclaration),
+		tokenString(0, 13, "integer", SemanticTypeName, doc|SemanticDefaultLibrary),
+		tokenString(1, 3, "@enum", SemanticKeyword, doc),
+		tokenString(1, 9, "Color", SemanticEnum, doc|SemanticDeclaration),
+		tokenString(2, 6, "Color", SemanticVariable, SemanticDeclaration),
+		tokenString(2, 16, "Red", SemanticEnumMember, SemanticDeclaration),
+		tokenString(3, 3, "@generic", SemanticKeyword, doc),
+		tokenString(3, 12, "T", SemanticTypeParameter, doc|SemanticDeclaration),
+		tokenString(4, 3, "@param", SemanticKeyword, doc),
+		tokenString(4, 10, "v", SemanticParameter, doc),
+		tokenString(4, 12, "T", SemanticTypeParameter, doc),
+		tokenString(5, 3, "@param", SemanticKeyword, doc),
+		tokenString(5, 10, "id", SemanticParameter, doc),
+		tokenString(5, 13, "ID", SemanticTypeName, doc),
+		tokenString(6, 3, "@return", SemanticKeyword, doc),
+		tokenString(6, 11, "Color", SemanticEnum, doc),
+		tokenString(7, 15, "pick", SemanticFunction, SemanticDeclaration),
+		tokenString(7, 20, "v", SemanticParameter, SemanticDeclaration),
+		tokenString(7, 23, "id", SemanticParameter, SemanticDeclaration),
+		tokenString(7, 34, "Color", SemanticVariable, SemanticUpvalue),
+		tokenString(7, 40, "Red", SemanticEnumMember, 0),
+	}
+	var got = semanticTokens(text)
+	if !slices.Equal(got, want) {
+		t.Errorf("got\n%q\nwant\n%q", got, want)
+	}
+}
@@ -0,0 +1,243 @@
+package check
+
+import (
+	"mylua-lsp/lsp/ast"
+	"mylua-lsp/lsp/common"
+	"strings"
+)
+
+// SignatureParam 签名里的一个参数，Start 和 End 是在 Label 里的字节偏移
+type SignatureParam struct {
+	Name  string
+	Start int
+	End   int
+	Doc   string
+}
+
+// Signature 函数的一个签名
+type Signature struct {
+	Label       string
+	Doc         string
+	ParamList   []SignatureParam
+	ActiveParam int // 光标所在的参数，可变参数时多余的实参都对应最后一个
+}
+
+// SignatureHelp 光标所在的函数调用的所有签名，第一个是函数本身，后面是 @overload
+func (p *Project) SignatureHelp(file *ast.FileInfo, pos Position) []*Signature {
+	var call = callAt(file, pos)
+	if call == nil {
+		return nil
+	}
+	var argIdx = argIndexAt(file.Source, call, pos)
+	var name = callName(call)
+	var callColon = call.NameExp != nil
+
+	var list []*Signature
+	switch t := p.CalleeType(file, call).(type) {
+	case *ast.FuncInfo:
+		var printer = &typePrinter{p: p}
+		var paramList []sigParam
+		for _, param := range t.ParamList {
+			var item = sigParam{name: param.Name}
+			if state := FindParamState(t.Comment, param.Name); state != nil {
+				item.isOptional, item.t, item.doc = state.IsOptional, state.ParamType, state.Comment
+			}
+			paramList = append(paramList, item)
+		}
+		if t.IsVararg() && t.FuncDef != nil {
+			var item = sigParam{name: "..."}
+			if state := FindParamState(t.Comment, "..."); state != nil {
+				item.t, item.doc = state.ParamType, state.Comment
+			}
+			paramList = append(paramList, item)
+		}
+		var sig = printer.signature(name, paramList, p.funcReturnList(t), t.IsColon(), callColon)
+		if t.Comment != nil {
+			sig.Doc = t.Comment.Doc
+		}
+		list = append(list, sig)
+		for _, line := range commentLines(t.Comment) {
+			if state, ok := line.State.(*ast.AnnotateOverloadState); ok && state.Fun != nil {
+				// 方法的 @overload 不写 self
+				list = append(list, p.funSignature(name, state.Fun, t.IsColon(), callColon))
+			}
+		}
+	case *ast.Type_Fun:
+		if t != nil {
+			list = append(list, p.funSignature(name, t, false, callColon))
+		}
+	}
+	for _, sig := range list {
+		sig.ActiveParam = activeParam(sig, argIdx)
+	}
+	return list
+}
+
+// commentLines 注释块的所有行，注释块可能为 nil
+func commentLines(comment *ast.AnnotateBlock) []*ast.AnnotateLine {
+	if comment == nil {
+		return nil
+	}
+	return comment.LineList
+}
+
+// activeParam 实参对应的形参，超出时对应最后的可变参数，没有可变参数时为 -1
+func activeParam(sig *Signature, argIdx int) int {
+	var count = len(sig.ParamList)
+	if argIdx < count {
+		return argIdx
+	}
+	if count > 0 && sig.ParamList[count-1].Name == "..." {
+		return count - 1
+	}
+	return -1
+}
+
+// sigParam 生成签名时的参数信息
+type sigParam struct {
+	name       string
+	isOptional bool
+	t          ast.TypeBase
+	doc        string
+}
+
+// funSignature fun(...) 类型的签名
+func (p *Project) funSignature(name string, fun *ast.Type_Fun, isColon bool, callColon bool) *Signature {
+	var paramList []sigParam
+	for _, param := range fun.ParamList {
+		paramList = append(paramList, sigParam{name: param.NameAndLoc.Name, isOptional: param.IsOptional, t: param.Type})
+	}
+	var returnList []ast.TypeBase
+	for _, ret := range fun.ReturnList {
+		returnList = append(returnList, ret.Type)
+	}
+	var printer = &typePrinter{p: p}
+	return printer.signature(name, paramList, returnList, isColon, callColon)
+}
+
+// signature 生成签名。obj.m(obj) 调用 : 定义的方法时加上 self ，obj:m() 调用 . 定义的函数时去掉第一个参数
+func (w *typePrinter) signature(name string, paramList []sigParam, returnList []ast.TypeBase, isColon bool, callColon bool) *Signature {
+	switch {
+	case isColon && !callColon:
+		paramList = append([]sigParam{{name: "self"}}, paramList...)
+	case !isColon && callColon && len(paramList) > 0:
+		paramList = paramList[1:]
+	}
+	var sig = &Signature{}
+	w.depth++
+	w.write(name)
+	w.write("(")
+	for i, param := range paramList {
+		if i > 0 {
+			w.write(", ")
+		}
+		var start = w.builder.Len()
+		w.printParam(param.name, param.isOptional, param.t)
+		sig.ParamList = append(sig.ParamList, SignatureParam{
+			Name:  param.name,
+			Start: start,
+			End:   w.builder.Len(),
+			Doc:   param.doc,
+		})
+	}
+	w.write(")")
+	if len(returnList) > 0 {
+		w.write(": ")
+		w.printList(returnList)
+	}
+	w.depth--
+	sig.Label = w.builder.String()
+	return sig
+}
+
+// callName 调用时使用的名字，例如 obj:m 或者 M.sub.f
+func callName(call *ast.FuncCallExp) string {
+	var name, _ = exprPath(call.PrefixExp, false)
+	if name == "" {
+		name = "function"
+	}
+	if call.NameExp != nil {
+		name += ":" + call.NameExp.Str
+	}
+	return name
+}
+
+// callAt 光标在括号里的最内层的函数调用，没有写完的调用到后面的空白为止
+func callAt(file *ast.FileInfo, pos Position) *ast.FuncCallExp {
+	var result *ast.FuncCallExp
+	ast.Inspect(file.Block, func(node ast.Stat) bool {
+		if node == nil {
+			return false
+		}
+		if call, ok := node.(*ast.FuncCallExp); ok && inCallArgs(file.Source, call, pos) {
+			result = call
+		}
+		return true
+	})
+	return result
+}
+
+// inCallArgs pos 是否在调用的括号里
+func inCallArgs(source *common.LuaSource, call *ast.FuncCallExp, pos Position) bool {
+	var calleeEnd = call.PrefixExp.GetLoc().End
+	if call.NameExp != nil {
+		calleeEnd = call.NameExp.Loc.End
+	}
+	var parenPos, c = skipSpace(source, calleeEnd)
+	if c != '(' || !parenPos.Before(pos) {
+		return false
+	}
+	// 最后一个单词是右括号并且不属于最后的参数时，调用已经结束
+	var end = call.Loc.End
+	var closed = end.Column > 0 && charAt(source, Position{Line: end.Line, Column: end.Column - 1}) == ')' &&
+		(len(call.Args) == 0 || call.Args[len(call.Args)-1].GetLoc().End.Before(end))
+	if closed {
+		return pos.Before(end)
+	}
+	var next, _ = skipSpace(source, end)
+	return !next.Before(pos)
+}
+
+// argIndexAt 光标所在的是第几个实参，根据参数的位置和中间的逗号计算
+func argIndexAt(source *common.LuaSource, call *ast.FuncCallExp, pos Position) int {
+	var idx = -1
+	for i, arg := range call.Args {
+		if !pos.Before(arg.GetLoc().Start) {
+			idx = i
+		}
+	}
+	if idx < 0 {
+		return 0
+	}
+	var argEnd = call.Args[idx].GetLoc().End
+	if argEnd.Before(pos) && strings.Contains(source.GetText(Location{Start: argEnd, End: pos}), ",") {
+		idx++
+	}
+	return idx
+}
+
+// charAt 位置上的字符，超出范围时为 0
+func charAt(source *common.LuaSource, pos Position) byte {
+	var line = source.GetOneLine(pos.GetLine())
+	if pos.GetColumn() < 0 || pos.GetColumn() >= len(line) {
+		return 0
+	}
+	return line[pos.GetColumn()]
+}
+
+// skipSpace 跳过空白，返回第一个不是空白的字符和它的位置，到文件末尾时字符为 0
+func skipSpace(source *common.LuaSource, pos Position) (Position, byte) {
+	for line := pos.GetLine(); line < source.GetLineNum(); line++ {
+		var text = source.GetOneLine(line)
+		var col = 0
+		if line == pos.GetLine() {
+			col = pos.GetColumn()
+		}
+		for ; col < len(text); col++ {
+			if c := text[col]; c != ' ' && c != '\t' && c != '\r' {
+				return Position{Line: int32(line), Column: int32(col)}, c
+			}
+		}
+	}
+	return Position{Line: int32(source.GetLineNum()), Column: 0}, 0
+}
@@ -0,0 +1,52 @@
+package check
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// signatures 光标位置的签名，每个是 "标签 当前参数 参数名:文档..."
+func signatures(t *testing.T, text string) []string {
+	t.Helper()
+	var content, pos = cursor(t, text)
+	var p = NewProject()
+	var file = analyzeText("a.lua", content)
+	p.UpdateFile(file)
+	var list []string
+	for _, sig := range p.SignatureHelp(file, pos) {
+		var line = fmt.Sprintf("%s %d", sig.Label, sig.ActiveParam)
+		for _, param := range sig.ParamList {
+			if !strings.HasPrefix(sig.Label[param.Start:param.End], param.Name) {
+				t.Errorf("parameter %s at %d-%d of %q", param.Name, param.Start, param.End, sig.Label)
+			}
+			line += fmt.Sprintf(" %s:%s", param.Name, param.Doc)
+		}
+		list = append(list, line)
+	}
+	return list
+}
+
+func TestSignatureHelp(t *testing.T) {
+	var overload = "---@param a number first\n---@param b string\n---@overload fun(a: number)\nlocal function f(a, b) end\n"
+	var tests = []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"first param", overload + "f(|)\n", []string{"f(a: number, b: string) 0 a:first b:", "f(a: number) 0 a:"}},
+		{"second param", overload + "f(1, |)\n", []string{"f(a: number, b: string) 1 a:first b:", "f(a: number) -1 a:"}},
+		{"vararg", "local function g(fmt, ...) end\ng(1, 2, 3|)\n", []string{"g(fmt, ...) 1 fmt: ...:"}},
+		{"method", "local t = {}\nfunction t:m(x) end\nt:m(|)\n", []string{"t:m(x) 0 x:"}},
+		{"nested call", "local function g(x) end\nlocal function h(y, z) end\nh(1, g(|))\n", []string{"g(x) 0 x:"}},
+		{"outside call", "local function g(x) end\ng(1)|\n", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := signatures(t, tt.text); !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package check
+
+import (
+	"mylua-lsp/lsp/common"
+	"slices"
+)
+
+// stdlibGlobals 所有版本的标准库都有的全局变量
+var stdlibGlobals = []string{
+	"_G", "_VERSION", "assert", "collectgarbage", "dofile", "error", "getmetatable", "ipairs", "load", "loadfile",
+	"next", "pairs", "pcall", "print", "rawequal", "rawget", "rawset", "require", "select", "setmetatable",
+	"tonumber", "tostring", "type", "xpcall",
+	"coroutine", "debug", "io", "math", "os", "package", "string", "table",
+}
+
+// versionGlobals 只有部分版本才有的全局变量
+var versionGlobals = map[common.LuaVersion][]string{
+	common.LuaVersion51:  {"getfenv", "setfenv", "loadstring", "module", "unpack"},
+	common.LuaVersion52:  {"_ENV", "rawlen", "bit32", "unpack", "loadstring", "module"},
+	common.LuaVersion53:  {"_ENV", "rawlen", "utf8"},
+	common.LuaVersion54:  {"_ENV", "rawlen", "utf8", "warn"},
+	common.LuaVersionJIT: {"getfenv", "setfenv", "loadstring", "module", "unpack", "rawlen", "bit", "jit"},
+}
+
+// isStdlibGlobal 是否为标准库的全局变量
+func isStdlibGlobal(name string, version common.LuaVersion) bool {
+	return slices.Contains(stdlibGlobals, name) || slices.Contains(versionGlobals[version], name)
+}
@@ -0,0 +1,221 @@
+package check
+
+import (
+	"fmt"
+	"math"
+	"mylua-lsp/lsp/ast"
+	"slices"
+	"sort"
+	"strings"
+)
+
+/*
+---@diagnostic 注释关闭诊断，冒号后面是逗号分隔的编号，没有编号时关闭所有的诊断：
+	---@diagnostic disable-next-line: undefined-global  关闭下一行
+	x = 1 ---@diagnostic disable-line: global-in-function  关闭所在的行
+	---@diagnostic disable: unused-local  从这一行开始关闭，直到对应的 enable 或者文件结束
+	---@diagnostic enable: unused-local
+文件开头的 disable 就是关闭整个文件。enable 只结束编号相同的 disable ，没有编号时结束所有的。
+没有关闭任何诊断的注释和编号会报告为 unused-suppression ，这个诊断本身不能关闭，没有编号的编译错误也不能关闭。
+*/
+
+const diagnosticPrefix = "---@diagnostic"
+
+// suppressAction ---@diagnostic 后面的动作
+type suppressAction string
+
+const (
+	suppressNextLine suppressAction = "disable-next-line"
+	suppressLine     suppressAction = "disable-line"
+	suppressDisable  suppressAction = "disable"
+	suppressEnable   suppressAction = "enable"
+)
+
+// suppressCode 注释里的一个诊断编号
+type suppressCode struct {
+	name string
+	loc  Location
+	used bool
+}
+
+// suppression 一条 ---@diagnostic 注释
+type suppression struct {
+	action suppressAction
+	loc    Location
+	codes  []*suppressCode // 为空时针对所有的诊断
+	used   bool
+}
+
+// suppressRegion 注释关闭诊断的行范围，包括两端
+type suppressRegion struct {
+	owner      *suppression
+	code       *suppressCode // 为 nil 时关闭所有的诊断
+	start, end int
+}
+
+// suppressions 文件里所有的 ---@diagnostic 注释
+type suppressions struct {
+	list    []*suppression
+	regions []*suppressRegion
+	errList []Diagnostic // 写错的注释
+}
+
+// newSuppressions 从词法分析收集的注释块里解析 ---@diagnostic 注释，计算关闭的范围
+func newSuppressions(file *ast.FileInfo) *suppressions {
+	var keys = make([]int, 0, len(file.CommentMap))
+	for key := range file.CommentMap {
+		keys = append(keys, key)
+	}
+	sort.Ints(keys)
+
+	var s = &suppressions{}
+	var open []*suppressRegion // 还没有 enable 的 disable
+	for _, key := range keys {
+		for i := range file.CommentMap[key].List {
+			var comment = &file.CommentMap[key].List[i]
+			var sup = s.parse(comment)
+			if sup == nil {
+				continue
+			}
+			var line = comment.StartPos.GetLine()
+			if sup.action == suppressEnable {
+				open = slices.DeleteFunc(open, func(region *suppressRegion) bool {
+					if len(sup.codes) > 0 && (region.code == nil || !slices.ContainsFunc(sup.codes, func(code *suppressCode) bool {
+						return code.name == region.code.name
+					})) {
+						return false
+					}
+					region.end = line
+					return true
+				})
+				continue
+			}
+			s.list = append(s.list, sup)
+			var start, end = line, line
+			switch sup.action {
+			case suppressNextLine:
+				start, end = comment.EndPos.GetLine()+1, comment.EndPos.GetLine()+1
+			case suppressDisable:
+				end = math.MaxInt
+			}
+			var codes = sup.codes
+			if len(codes) == 0 {
+				codes = []*suppressCode{nil}
+			}
+			for _, code := range codes {
+				var region = &suppressRegion{owner: sup, code: code, start: start, end: end}
+				s.regions = append(s.regions, region)
+				if sup.action == suppressDisable {
+					open = append(open, region)
+				}
+			}
+		}
+	}
+	return s
+}
+
+// parse 解析一行 ---@diagnostic 注释，不是这样的注释时返回 nil
+func (s *suppressions) parse(comment *ast.CommentLine) *suppression {
+	var rest, ok = strings.CutPrefix(comment.Str, diagnosticPrefix)
+	if !comment.ShortFlag || !ok || rest != "" && rest[0] != ' ' && rest[0] != '\t' {
+		return nil
+	}
+	var line = comment.StartPos.Line
+	var column = func(offset int) int32 {
+		return comment.StartPos.Column + int32(len(comment.Str)-len(rest)+offset)
+	}
+	var sup = &suppression{
+		loc: Location{Start: comment.StartPos, End: Position{Line: line, Column: column(len(rest))}},
+	}
+	rest = strings.TrimLeft(rest, " \t")
+	var name = rest
+	if idx := strings.IndexAny(rest, ": \t"); idx >= 0 {
+		name = rest[:idx]
+	}
+	switch action := suppressAction(name); action {
+	case suppressNextLine, suppressLine, suppressDisable, suppressEnable:
+		sup.action = action
+	default:
+		s.errList = append(s.errList, Diagnostic{
+			Loc:      sup.loc,
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("unknown diagnostic action '%s', expected disable-next-line, disable-line, disable or enable", name),
+		})
+		return nil
+	}
+
+	rest = strings.TrimLeft(rest[len(name):], " \t")
+	codeList, ok := strings.CutPrefix(rest, ":")
+	if !ok {
+		return sup
+	}
+	var offset = len(comment.Str) - len(codeList)
+	for _, item := range strings.Split(codeList, ",") {
+		var code = strings.TrimSpace(item)
+		if code != "" {
+			var start = comment.StartPos.Column + int32(offset+strings.Index(item, code))
+			sup.codes = append(sup.codes, &suppressCode{
+				name: code,
+				loc:  Location{Start: Position{Line: line, Column: start}, End: Position{Line: line, Column: start + int32(len(code))}},
+			})
+		}
+		offset += len(item) + 1
+	}
+	return sup
+}
+
+// filter 去掉被注释关闭的诊断，加上没有用到的注释和写错的注释
+func (s *suppressions) filter(list []Diagnostic) []Diagnostic {
+	var result []Diagnostic
+	for _, diag := range list {
+		if diag.Code == "" {
+			result = append(result, diag)
+			continue
+		}
+		var suppressed = false
+		var line = diag.Loc.Start.GetLine()
+		for _, region := range s.regions {
+			if line < region.start || line > region.end || region.code != nil && region.code.name != diag.Code {
+				continue
+			}
+			suppressed = true
+			region.owner.used = true
+			if region.code != nil {
+				region.code.used = true
+			}
+		}
+		if !suppressed {
+			result = append(result, diag)
+		}
+	}
+	result = append(result, s.errList...)
+	return append(result, s.unused()...)
+}
+
+// unused 没有关闭任何诊断的注释和编号
+func (s *suppressions) unused() []Diagnostic {
+	var list []Diagnostic
+	var add = func(loc Location, message string) {
+		list = append(list, Diagnostic{
+			Loc:         loc,
+			Severity:    SeverityHint,
+			Code:        DiagUnusedSuppression,
+			Message:     message,
+			Unnecessary: true,
+		})
+	}
+	for _, sup := range s.list {
+		if len(sup.codes) == 0 && !sup.used {
+			add(sup.loc, "unused suppression, no diagnostic is reported here")
+		}
+		for _, code := range sup.codes {
+			switch {
+			case !slices.Contains(diagCodes, code.name):
+				add(code.loc, fmt.Sprintf("unknown diagnostic code '%s'", code.name))
+			case !code.used:
+				add(code.loc, fmt.Sprintf("unused suppression, no '%s' diagnostic is reported here", code.name))
+			}
+		}
+	}
+	return list
+}
@@ -0,0 +1,332 @@
+package check
+
+import (
+	"mylua-lsp/lsp/ast"
+)
+
+// SymbolKind 符号的种类
+type SymbolKind uint8
+
+const (
+	SymbolNone   SymbolKind = iota
+	SymbolVar               // 变量，包括全局变量
+	SymbolMember            // 表的字段或者类的成员
+	SymbolLabel             // goto 的标签
+	SymbolType              // 注释里的类型名
+	SymbolModule            // require 的模块名
+)
+
+// Symbol 某个位置上的名字对应的符号
+type Symbol struct {
+	Kind SymbolKind
+	Name string
+	Loc  Location // 名字的位置
+	File *ast.FileInfo
+
+	Var     *ast.VarInfo   // SymbolVar
+	Members []*Member      // SymbolMember 同名的所有定义
+	Prefix  ast.Exp        // SymbolMember 所属的表达式，@field 和表构造里的字段为 nil
+	Table   *ast.TableInfo // SymbolMember 表构造里的字段所属的表
+	Label   *ast.LabelInfo // SymbolLabel
+	Module  *ast.FileInfo  // SymbolModule 模块对应的文件，找不到时为 nil
+}
+
+// inLoc pos 是否在 loc 内，包括结束的位置，光标在名字后面时也能找到
+func inLoc(loc Location, pos Position) bool {
+	return !pos.Before(loc.Start) && !loc.End.Before(pos)
+}
+
+// SymbolAt 查找位置上的符号，没有时返回 nil
+func (p *Project) SymbolAt(file *ast.FileInfo, pos Position) *Symbol {
+	if sym := p.annotateSymbolAt(file, pos); sym != nil {
+		return sym
+	}
+	if sym := varDefAt(file, file.MainFunc.Scope, pos); sym != nil {
+		return sym
+	}
+	if sym := labelAt(file, pos); sym != nil {
+		return sym
+	}
+	return p.expSymbolAt(file, pos)
+}
+
+// varDefAt 局部变量定义的名字
+func varDefAt(file *ast.FileInfo, scope *ast.ScopeInfo, pos Position) *Symbol {
+	for _, varInfo := range scope.VarInfoList {
+		if varInfo.Kind != ast.VarKindSelf && inLoc(varInfo.Loc, pos) {
+			return &Symbol{Kind: SymbolVar, Name: varInfo.Name, Loc: varInfo.Loc, File: file, Var: varInfo}
+		}
+	}
+	for _, sub := range scope.SubScopes {
+		if sym := varDefAt(file, sub, pos); sym != nil {
+			return sym
+		}
+	}
+	return nil
+}
+
+// labelAt 标签的定义或者 goto 的名字
+func labelAt(file *ast.FileInfo, pos Position) *Symbol {
+	for _, funcInfo := range file.FuncList {
+		for _, label := range funcInfo.LabelInfoList {
+			if inLoc(label.Loc, pos) {
+				return &Symbol{Kind: SymbolLabel, Name: label.Name, Loc: label.Loc, File: file, Label: label}
+			}
+		}
+	}
+	var result *Symbol
+	ast.Inspect(file.Block, func(node ast.Stat) bool {
+		if node == nil || result != nil || !inLoc(node.GetLoc(), pos) {
+			return false
+		}
+		if stat, ok := node.(*ast.GotoStat); ok && inLoc(stat.Name.Loc, pos) {
+			result = &Symbol{Kind: SymbolLabel, Name: stat.Name.TokenStr, Loc: stat.Name.Loc, File: file}
+			result.Label = FindLabel(file, stat)
+			return false
+		}
+		return true
+	})
+	return result
+}
+
+// FindLabel goto 跳转的标签，在所在的 block 以及外层的 block 里查找，不能跳出函数
+func FindLabel(file *ast.FileInfo, stat *ast.GotoStat) *ast.LabelInfo {
+	var scope = file.MainFunc.Scope.FindScope(stat.Loc.Start)
+	for ; scope != nil; scope = scope.Parent {
+		for _, label := range scope.LabelInfoList {
+			if label.Name == stat.Name.TokenStr {
+				return label
+			}
+		}
+		if scope.Parent != nil && scope.Parent.Func != scope.Func {
+			break
+		}
+	}
+	return nil
+}
+
+// expSymbolAt 表达式里的名字，变量引用，字段和方法名
+func (p *Project) expSymbolAt(file *ast.FileInfo, pos Position) *Symbol {
+	var result *Symbol
+	ast.Inspect(file.Block, func(node ast.Stat) bool {
+		if node == nil || !inLoc(node.GetLoc(), pos) {
+			return false
+		}
+		switch n := node.(type) {
+		case *ast.NameExp:
+			if varInfo := file.NameVarMap[n]; varInfo != nil {
+				result = &Symbol{Kind: SymbolVar, Name: n.Name, Loc: n.Loc, File: file, Var: varInfo}
+			}
+		case *ast.TableAccessExp:
+			if key, ok := n.KeyExp.(*ast.StringExp); ok && isNameKey(key) && inLoc(key.Loc, pos) {
+				result = &Symbol{Kind: SymbolMember, Name: key.Str, Loc: key.Loc, File: file, Prefix: n.PrefixExp}
+				result.Members = p.ExpMembers(file, n.PrefixExp, key.Str)
+				return false
+			}
+		case *ast.FuncCallExp:
+			if name, ok := requireName(p, file, n); ok && inLoc(n.Args[0].GetLoc(), pos) {
+				result = &Symbol{Kind: SymbolModule, Name: name, Loc: n.Args[0].GetLoc(), File: file}
+				result.Module = p.FindRequire(name)
+				return false
+			}
+			if n.NameExp != nil && inLoc(n.NameExp.Loc, pos) {
+				result = &Symbol{Kind: SymbolMember, Name: n.NameExp.Str, Loc: n.NameExp.Loc, File: file, Prefix: n.PrefixExp}
+				result.Members = p.ExpMembers(file, n.PrefixExp, n.NameExp.Str)
+				return false
+			}
+		case *ast.TableConstructorExp:
+			for _, keyExp := range n.KeyExps {
+				var key, ok = keyExp.(*ast.StringExp)
+				if !ok || !isNameKey(key) || !inLoc(key.Loc, pos) {
+					continue
+				}
+				var table = file.TableMap[n]
+				result = &Symbol{Kind: SymbolMember, Name: key.Str, Loc: key.Loc, File: file, Table: table}
+				result.Members = p.FindMembers(table, key.Str)
+				return false
+			}
+		}
+		return true
+	})
+	return result
+}
+
+// requireName require("a.b") 调用的模块名
+func requireName(p *Project, file *ast.FileInfo, call *ast.FuncCallExp) (string, bool) {
+	if p.globalFuncName(file, call) != "require" || len(call.Args) == 0 {
+		return "", false
+	}
+	var str, ok = call.Args[0].(*ast.StringExp)
+	if !ok {
+		return "", false
+	}
+	return str.Str, true
+}
+
+// isNameKey 字段的 key 是否为名字，t.a 和 {a = 1} 里的 a ，不包括 t["a"]
+func isNameKey(key *ast.StringExp) bool {
+	return key.RawStr == key.Str
+}
+
+// annotateSymbolAt 注释里的名字，类型名，@param 的参数名，@field 的字段名
+func (p *Project) annotateSymbolAt(file *ast.FileInfo, pos Position) *Symbol {
+	for _, block := range file.Annotate.BlockList {
+		if !inLoc(block.Loc, pos) {
+			continue
+		}
+		for _, line := range block.LineList {
+			if !inLoc(line.Loc, pos) {
+				continue
+			}
+			return p.annotateLineSymbol(file, block, line, pos)
+		}
+	}
+	return nil
+}
+
+func (p *Project) annotateLineSymbol(file *ast.FileInfo, block *ast.AnnotateBlock, line *ast.AnnotateLine, pos Position) *Symbol {
+	var typeSymbol = func(nameAndLoc ast.NameAndLoc) *Symbol {
+		return &Symbol{Kind: SymbolType, Name: nameAndLoc.Name, Loc: nameAndLoc.Loc, File: file}
+	}
+	var findInTypes = func(list ...ast.TypeBase) *Symbol {
+		for _, t := range list {
+			if name, ok := typeNameAt(t, pos); ok {
+				return typeSymbol(name)
+			}
+		}
+		return nil
+	}
+
+	switch state := line.State.(type) {
+	case *ast.AnnotateTypeState:
+		return findInTypes(state.TypeList...)
+	case *ast.AnnotateParamState:
+		if inLoc(state.NameAndLoc.Loc, pos) {
+			var funcInfo = commentFunc(file, block)
+			if funcInfo == nil {
+				return nil
+			}
+			for _, param := range funcInfo.ParamList {
+				if param.Name == state.NameAndLoc.Name {
+					return &Symbol{Kind: SymbolVar, Name: param.Name, Loc: state.NameAndLoc.Loc, File: file, Var: param}
+				}
+			}
+			return nil
+		}
+		return findInTypes(state.ParamType)
+	case *ast.AnnotateReturnState:
+		return findInTypes(state.ReturnTypeList...)
+	case *ast.AnnotateClassState:
+		if inLoc(state.NameAndLoc.Loc, pos) {
+			return typeSymbol(state.NameAndLoc)
+		}
+		return findInTypes(state.ParentTypeList...)
+	case *ast.AnnotateFieldState:
+		if inLoc(state.NameAndLoc.Loc, pos) {
+			return p.classFieldSymbol(file, block, state)
+		}
+		return findInTypes(state.FieldType)
+	case *ast.AnnotateAliasState:
+		if inLoc(state.NameAndLoc.Loc, pos) {
+			return typeSymbol(state.NameAndLoc)
+		}
+		return findInTypes(state.Type)
+	case *ast.AnnotateEnumState:
+		if inLoc(state.NameAndLoc.Loc, pos) {
+			return typeSymbol(state.NameAndLoc)
+		}
+	case *ast.AnnotateOverloadState:
+		return findInTypes(state.Fun)
+	}
+	return nil
+}
+
+// classFieldSymbol @field 定义的字段
+func (p *Project) classFieldSymbol(file *ast.FileInfo, block *ast.AnnotateBlock, state *ast.AnnotateFieldState) *Symbol {
+	for _, class := range file.Annotate.ClassList {
+		if !inLoc(block.Loc, class.NameAndLoc.Loc.Start) {
+			continue
+		}
+		for i := range class.FieldList {
+			var field = &class.FieldList[i]
+			if field.NameAndLoc.Loc == state.NameAndLoc.Loc {
+				return &Symbol{
+					Kind:    SymbolMember,
+					Name:    field.NameAndLoc.Name,
+					Loc:     field.NameAndLoc.Loc,
+					File:    file,
+					Members: []*Member{{Name: field.NameAndLoc.Name, ClassField: field, Class: class}},
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// commentFunc 注释关联的函数
+func commentFunc(file *ast.FileInfo, block *ast.AnnotateBlock) *ast.FuncInfo {
+	for _, funcInfo := range file.FuncList {
+		if funcInfo.Comment == block {
+			return funcInfo
+		}
+	}
+	return nil
+}
+
+// typeNameAt 类型里 pos 位置的类型名
+func typeNameAt(t ast.TypeBase, pos Position) (result ast.NameAndLoc, found bool) {
+	walkTypeNames(t, func(name ast.NameAndLoc) bool {
+		if inLoc(name.Loc, pos) {
+			result, found = name, true
+			return false
+		}
+		return true
+	})
+	return result, found
+}
+
+// walkTypeNames 遍历类型里所有的类型名，fn 返回 false 时停止
+func walkTypeNames(t ast.TypeBase, fn func(name ast.NameAndLoc) bool) bool {
+	switch t := t.(type) {
+	case *ast.Type_Identifier:
+		return fn(t.NameAndLoc)
+	case *ast.Type_GenericInstance:
+		if !fn(t.NameAndLoc) {
+			return false
+		}
+		for _, sub := range t.ParamTypeList {
+			if !walkTypeNames(sub, fn) {
+				return false
+			}
+		}
+	case *ast.Type_Union:
+		for _, sub := range t.TypeList {
+			if !walkTypeNames(sub, fn) {
+				return false
+			}
+		}
+	case *ast.Type_Array:
+		return walkTypeNames(t.ElementType, fn)
+	case *ast.Type_Map:
+		for _, field := range t.FieldList {
+			if !walkTypeNames(field.Type, fn) {
+				return false
+			}
+		}
+	case *ast.Type_Fun:
+		if t == nil {
+			return true
+		}
+		for _, param := range t.ParamList {
+			if !walkTypeNames(param.Type, fn) {
+				return false
+			}
+		}
+		for _, ret := range t.ReturnList {
+			if !walkTypeNames(ret.Type, fn) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,86 @@
+package check
+
+import (
+	"mylua-lsp/lsp/ast"
+	"sort"
+)
+
+// TypeHierarchyClass 位置上的类，可以是注释里的类名，也可以是类对应的 lua 表的变量
+func (p *Project) TypeHierarchyClass(file *ast.FileInfo, pos Position) *ast.Type_Class {
+	var sym = p.SymbolAt(file, pos)
+	if sym == nil {
+		return nil
+	}
+	var name string
+	switch sym.Kind {
+	case SymbolType:
+		name = sym.Name
+	case SymbolVar:
+		if table, ok := p.TypeOfVar(sym.Var).(*ast.TableInfo); ok {
+			name = table.ClassName
+		}
+	}
+	return p.classDef(name, file)
+}
+
+// classDef 类的定义，多处定义时优先使用 file 里的
+func (p *Project) classDef(name string, file *ast.FileInfo) *ast.Type_Class {
+	var list = p.GetClass(name)
+	for _, class := range list {
+		if class.File == file {
+			return class
+		}
+	}
+	if len(list) > 0 {
+		return list[0]
+	}
+	return nil
+}
+
+// parentName 父类的名字，泛型类去掉参数
+func parentName(t ast.TypeBase) string {
+	switch t := t.(type) {
+	case *ast.Type_Identifier:
+		return t.NameAndLoc.Name
+	case *ast.Type_GenericInstance:
+		return t.NameAndLoc.Name
+	}
+	return ""
+}
+
+// Supertypes 类的所有定义里写的父类，不是 @class 的父类型忽略
+func (p *Project) Supertypes(name string) []*ast.Type_Class {
+	var list []*ast.Type_Class
+	var visited = map[string]bool{name: true}
+	for _, class := range p.GetClass(name) {
+		for _, parent := range class.ParentTypeList {
+			var super = parentName(parent)
+			if visited[super] {
+				continue
+			}
+			visited[super] = true
+			if def := p.classDef(super, class.File); def != nil {
+				list = append(list, def)
+			}
+		}
+	}
+	return list
+}
+
+// Subtypes 直接继承了这个类的所有类，按名字排序。同一个类多处定义时只取第一个
+func (p *Project) Subtypes(name string) []*ast.Type_Class {
+	var list []*ast.Type_Class
+	var visited = map[string]bool{}
+	for _, class := range p.subclassMap[name] {
+		var subName = class.NameAndLoc.Name
+		if visited[subName] {
+			continue
+		}
+		visited[subName] = true
+		list = append(list, class)
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].NameAndLoc.Name < list[j].NameAndLoc.Name
+	})
+	return list
+}
@@ -0,0 +1,91 @@
+package check
+
+import (
+	"mylua-lsp/lsp/ast"
+	"slices"
+	"testing"
+)
+
+// classNames 类的名字和定义所在的文件，每个是 "文件 名字"
+func classNames(list []*ast.Type_Class) []string {
+	var names []string
+	for _, class := range list {
+		names = append(names, class.File.GetPath()+" "+class.NameAndLoc.Name)
+	}
+	return names
+}
+
+func TestTypeHierarchy(t *testing.T) {
+	var p = newTestProject(map[string]string{
+		"a.lua": "---@class B\n---@class C\n---@class A : B, C, B\n",
+		"b.lua": "---@class D : B\n",
+	})
+	var tests = []struct {
+		name   string
+		supers []string
+		subs   []string
+	}{
+		{"A", []string{"a.lua B", "a.lua C"}, nil},
+		{"B", nil, []string{"a.lua A", "b.lua D"}},
+		{"C", nil, []string{"a.lua A"}},
+		{"D", []string{"a.lua B"}, nil},
+	}
+	for _, tt := range tests {
+		if got := classNames(p.Supertypes(tt.name)); !slices.Equal(got, tt.supers) {
+			t.Errorf("supertypes of %s: got %q, want %q", tt.name, got, tt.supers)
+		}
+		if got := classNames(p.Subtypes(tt.name)); !slices.Equal(got, tt.subs) {
+			t.Errorf("subtypes of %s: got %q, want %q", tt.name, got, tt.subs)
+		}
+	}
+}
+
+// 修改和删除文件后反向索引跟着更新
+func TestSubtypesUpdate(t *testing.T) {
+	var p = newTestProject(map[string]string{
+		"a.lua": "---@class B\n---@class C\n---@class A : B\n",
+		"b.lua": "---@class D : B\n",
+	})
+	p.UpdateFile(analyzeText("b.lua", "---@class D : C\n"))
+	if got, want := classNames(p.Subtypes("B")), []string{"a.lua A"}; !slices.Equal(got, want) {
+		t.Errorf("subtypes of B: got %q, want %q", got, want)
+	}
+	if got, want := classNames(p.Subtypes("C")), []string{"b.lua D"}; !slices.Equal(got, want) {
+		t.Errorf("subtypes of C: got %q, want %q", got, want)
+	}
+	p.RemoveFile("b.lua")
+	if got := classNames(p.Subtypes("C")); got != nil {
+		t.Errorf("subtypes of C after remove: got %q", got)
+	}
+}
+
+// 循环继承时逐层展开也会回到已经访问过的类，不会无限循环
+func TestTypeHierarchyCycle(t *testing.T) {
+	var p = newTestProject(map[string]string{
+		"a.lua": "---@class A : B\n---@class B : A\n---@class S : S\n",
+	})
+	for _, next := range []func(string) []*ast.Type_Class{p.Supertypes, p.Subtypes} {
+		var visited = map[string]bool{}
+		var queue = []string{"A"}
+		for len(queue) > 0 && len(visited) < 10 {
+			var name = queue[0]
+			queue = queue[1:]
+			if visited[name] {
+				continue
+			}
+			visited[name] = true
+			for _, class := range next(name) {
+				queue = append(queue, class.NameAndLoc.Name)
+			}
+		}
+		if len(visited) != 2 || !visited["A"] || !visited["B"] {
+			t.Errorf("visited %v", visited)
+		}
+	}
+	if got := p.Supertypes("S"); got != nil {
+		t.Errorf("supertypes of S: got %q", classNames(got))
+	}
+	if got := p.Subtypes("S"); got != nil {
+		t.Errorf("subtypes of S: got %q", classNames(got))
+	}
+}
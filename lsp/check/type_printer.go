@@ -0,0 +1,250 @@
+package check
+
+import (
+	"mylua-lsp/lsp/ast"
+	"strconv"
+	"strings"
+)
+
+// maxPrintDepth 函数的返回值可能是自己，嵌套太深时不再展开
+const maxPrintDepth = 3
+
+// typePrinter 把类型转换成注释标记的语法
+type typePrinter struct {
+	p       *Project
+	builder strings.Builder
+	depth   int
+}
+
+// TypeString 类型显示用的字符串，推导不出来的是 any
+func (p *Project) TypeString(t ast.TypeBase) string {
+	var printer = &typePrinter{p: p}
+	printer.print(t)
+	return printer.builder.String()
+}
+
+// FuncSignature 函数的签名，例如 function M:foo(a: number, b?: string): boolean 。name 为空时使用函数定义的名字
+func (p *Project) FuncSignature(name string, funcInfo *ast.FuncInfo) string {
+	var printer = &typePrinter{p: p}
+	if name == "" {
+		name = funcInfo.Name
+	}
+	printer.builder.WriteString("function ")
+	printer.builder.WriteString(name)
+	printer.printFuncInfo(funcInfo)
+	return printer.builder.String()
+}
+
+// FuncOverloads 函数注释里 @overload 标记的其他签名
+func (p *Project) FuncOverloads(funcInfo *ast.FuncInfo) (list []string) {
+	if funcInfo.Comment == nil {
+		return nil
+	}
+	for _, line := range funcInfo.Comment.LineList {
+		if state, ok := line.State.(*ast.AnnotateOverloadState); ok {
+			list = append(list, p.TypeString(state.Fun))
+		}
+	}
+	return list
+}
+
+func (w *typePrinter) write(str string) {
+	w.builder.WriteString(str)
+}
+
+func (w *typePrinter) print(t ast.TypeBase) {
+	switch t := t.(type) {
+	case nil:
+		w.write("any")
+	case ast.LuaType:
+		w.write(luaTypeName(t))
+	case *ast.Type_LiteralValue:
+		switch t.Type {
+		case ast.LiteralValueNil:
+			w.write("nil")
+		case ast.LiteralValueTrue:
+			w.write("true")
+		case ast.LiteralValueFalse:
+			w.write("false")
+		case ast.LiteralValueNumber:
+			w.write(t.Str)
+		case ast.LiteralValueString:
+			w.write(strconv.Quote(t.Str))
+		}
+	case *ast.Type_Identifier:
+		w.write(t.NameAndLoc.Name)
+	case *ast.Type_Union:
+		// 两个类型并且其中一个是 nil 时写成 T?
+		if len(t.TypeList) == 2 && isNilType(t.TypeList[1]) && !isNilType(t.TypeList[0]) {
+			w.printElem(t.TypeList[0])
+			w.write("?")
+			return
+		}
+		for i, sub := range t.TypeList {
+			if i > 0 {
+				w.write(" | ")
+			}
+			w.printElem(sub)
+		}
+	case *ast.Type_Array:
+		w.printElem(t.ElementType)
+		w.write("[]")
+	case *ast.Type_Map:
+		w.write("{")
+		for i, field := range t.FieldList {
+			if i > 0 {
+				w.write(", ")
+			}
+			w.write(field.NameAndLoc.Name)
+			w.write(": ")
+			w.print(field.Type)
+		}
+		w.write("}")
+	case *ast.Type_GenericInstance:
+		w.write(t.NameAndLoc.Name)
+		w.write("<")
+		w.printList(t.ParamTypeList)
+		w.write(">")
+	case *ast.Type_Fun:
+		w.write("fun(")
+		for i, param := range t.ParamList {
+			if i > 0 {
+				w.write(", ")
+			}
+			w.printParam(param.NameAndLoc.Name, param.IsOptional, param.Type)
+		}
+		w.write(")")
+		if len(t.ReturnList) > 0 {
+			w.write(": ")
+			for i, ret := range t.ReturnList {
+				if i > 0 {
+					w.write(", ")
+				}
+				w.print(ret.Type)
+			}
+		}
+	case *ast.TableInfo:
+		switch {
+		case t.ClassName != "":
+			w.write(t.ClassName)
+		default:
+			w.write("table")
+		}
+	case *ast.FuncInfo:
+		if w.depth >= maxPrintDepth {
+			w.write("function")
+			return
+		}
+		w.write("fun")
+		w.printFuncInfo(t)
+	default:
+		w.write("any")
+	}
+}
+
+// printElem 数组元素和联合类型里的函数类型加上括号
+func (w *typePrinter) printElem(t ast.TypeBase) {
+	switch t.(type) {
+	case *ast.Type_Fun, *ast.FuncInfo, *ast.Type_Union:
+		w.write("(")
+		w.print(t)
+		w.write(")")
+	default:
+		w.print(t)
+	}
+}
+
+func (w *typePrinter) printList(list []ast.TypeBase) {
+	for i, t := range list {
+		if i > 0 {
+			w.write(", ")
+		}
+		w.print(t)
+	}
+}
+
+func (w *typePrinter) printParam(name string, isOptional bool, t ast.TypeBase) {
+	w.write(name)
+	if isOptional {
+		w.write("?")
+	}
+	if t != nil {
+		w.write(": ")
+		w.print(t)
+	}
+}
+
+// printFuncInfo lua 函数的参数和返回值，参数的类型来自 @param
+func (w *typePrinter) printFuncInfo(funcInfo *ast.FuncInfo) {
+	w.depth++
+	defer func() { w.depth-- }()
+
+	w.write("(")
+	var count = 0
+	var writeParam = func(name string, isOptional bool, t ast.TypeBase) {
+		if count > 0 {
+			w.write(", ")
+		}
+		count++
+		w.printParam(name, isOptional, t)
+	}
+	for _, param := range funcInfo.ParamList {
+		var state = FindParamState(funcInfo.Comment, param.Name)
+		if state == nil {
+			writeParam(param.Name, false, nil)
+			continue
+		}
+		writeParam(param.Name, state.IsOptional, state.ParamType)
+	}
+	if funcInfo.IsVararg() && funcInfo.FuncDef != nil {
+		var t ast.TypeBase
+		if state := FindParamState(funcInfo.Comment, "..."); state != nil {
+			t = state.ParamType
+		}
+		writeParam("...", false, t)
+	}
+	w.write(")")
+
+	var returnList = w.p.funcReturnList(funcInfo)
+	if len(returnList) > 0 {
+		w.write(": ")
+		w.printList(returnList)
+	}
+}
+
+// funcReturnList 函数所有返回值的类型，没有 @return 时推导 return 语句
+func (p *Project) funcReturnList(funcInfo *ast.FuncInfo) []ast.TypeBase {
+	if list := FuncReturnTypes(funcInfo); len(list) > 0 {
+		return list
+	}
+	var count = 0
+	for _, ret := range funcInfo.ReturnList {
+		count = max(count, len(ret.Stat.ExpList))
+	}
+	var list = make([]ast.TypeBase, count)
+	for i := range list {
+		list[i] = p.funcReturn(funcInfo, i, nil, nil)
+	}
+	return list
+}
+
+// luaTypeName lua 基础类型的名字
+func luaTypeName(t ast.LuaType) string {
+	switch t {
+	case ast.LuaTypeNil:
+		return "nil"
+	case ast.LuaTypeBool:
+		return "boolean"
+	case ast.LuaTypeNumber, ast.LuaTypeFloat:
+		return "number"
+	case ast.LuaTypeInter:
+		return "integer"
+	case ast.LuaTypeString:
+		return "string"
+	case ast.LuaTypeTable, ast.LuaTypeArray:
+		return "table"
+	case ast.LuaTypeFunc:
+		return "function"
+	}
+	return "any"
+}
@@ -0,0 +1,417 @@
+package check
+
+import (
+	"container/heap"
+	"mylua-lsp/lsp/ast"
+	"sort"
+	"strings"
+)
+
+/*
+工程符号搜索。每个文件分析后生成全局变量、全局表的字段和注释类型的索引，
+查询时先用名字里出现的字符过滤，再按子序列做模糊匹配打分。
+*/
+
+// IndexSymbol 工程符号索引里的一项
+type IndexSymbol struct {
+	Name      string
+	Container string // 所属的表或者类，例如 M.sub ，没有时为空
+	Kind      OutlineKind
+	File      *ast.FileInfo
+	Loc       Location
+
+	fullName string // Container.Name ，查询里有 . 或者 : 时匹配它
+}
+
+// symbolIndex 整个工程的符号索引。所有文件的符号放在连续的数组里，过滤时顺序访问。
+// 删除文件时只把位置标记为空，空位太多时再整理
+type symbolIndex struct {
+	syms      []*IndexSymbol // 删除的为 nil
+	names     []string       // 名字，和 syms 对应，匹配时不用访问符号本身
+	fullNames []string
+	nameMasks []uint64 // 名字里出现的字符，删除的为 0
+	fullMasks []uint64
+	nameHeads []byte // 名字的第一个字符的小写，用来估计最高分
+	fullHeads []byte
+	fileSlots map[string][]int // 文件的符号在数组里的位置
+	deleted   int
+}
+
+func newSymbolIndex() *symbolIndex {
+	return &symbolIndex{fileSlots: map[string][]int{}}
+}
+
+// update 替换一个文件的符号
+func (x *symbolIndex) update(path string, list []*IndexSymbol) {
+	x.remove(path)
+	var slots = make([]int, 0, len(list))
+	for _, sym := range list {
+		slots = append(slots, len(x.syms))
+		x.syms = append(x.syms, sym)
+		x.names = append(x.names, sym.Name)
+		x.fullNames = append(x.fullNames, sym.fullName)
+		x.nameMasks = append(x.nameMasks, charMask(sym.Name))
+		x.fullMasks = append(x.fullMasks, charMask(sym.fullName))
+		x.nameHeads = append(x.nameHeads, lowerByte(sym.Name[0]))
+		x.fullHeads = append(x.fullHeads, lowerByte(sym.fullName[0]))
+	}
+	x.fileSlots[path] = slots
+}
+
+// remove 删除一个文件的符号
+func (x *symbolIndex) remove(path string) {
+	var slots, ok = x.fileSlots[path]
+	if !ok {
+		return
+	}
+	delete(x.fileSlots, path)
+	for _, i := range slots {
+		x.syms[i] = nil
+		x.names[i], x.fullNames[i] = "", ""
+		x.nameMasks[i], x.fullMasks[i] = 0, 0
+	}
+	x.deleted += len(slots)
+	if x.deleted > 1024 && x.deleted > len(x.syms)/2 {
+		x.compact()
+	}
+}
+
+// compact 去掉删除后留下的空位
+func (x *symbolIndex) compact() {
+	var old = x.syms
+	x.syms, x.names, x.fullNames, x.nameMasks, x.fullMasks = nil, nil, nil, nil, nil
+	x.nameHeads, x.fullHeads = nil, nil
+	x.deleted = 0
+	var fileSlots = x.fileSlots
+	x.fileSlots = map[string][]int{}
+	for path, slots := range fileSlots {
+		var list = make([]*IndexSymbol, 0, len(slots))
+		for _, i := range slots {
+			list = append(list, old[i])
+		}
+		x.update(path, list)
+	}
+}
+
+// maxSymbolResults 一次查询最多返回的符号个数
+const maxSymbolResults = 256
+
+// charMask 字符对应的位，不区分大小写
+func charMask(s string) uint64 {
+	var mask uint64
+	for i := 0; i < len(s); i++ {
+		var c = s[i]
+		switch {
+		case c >= 'a' && c <= 'z':
+			mask |= 1 << (c - 'a')
+		case c >= 'A' && c <= 'Z':
+			mask |= 1 << (c - 'A')
+		case c >= '0' && c <= '9':
+			mask |= 1 << (26 + c - '0')
+		case c == '_':
+			mask |= 1 << 36
+		case c == '.' || c == ':':
+			mask |= 1 << 37
+		default:
+			mask |= 1 << 38
+		}
+	}
+	return mask
+}
+
+// symbolIndexer 生成一个文件的符号索引
+type symbolIndexer struct {
+	file    *ast.FileInfo
+	list    []*IndexSymbol
+	visited map[*ast.TableInfo]bool
+}
+
+func (x *symbolIndexer) add(name, container string, kind OutlineKind, loc Location) {
+	if name == "" {
+		return
+	}
+	var sym = &IndexSymbol{Name: name, Container: container, Kind: kind, File: x.file, Loc: loc, fullName: name}
+	if container != "" {
+		sym.fullName = container + "." + name
+	}
+	x.list = append(x.list, sym)
+}
+
+// indexFile 文件里定义的全局变量，全局表的字段，@class @alias @enum 以及类的字段
+func indexFile(file *ast.FileInfo) []*IndexSymbol {
+	var x = &symbolIndexer{file: file, visited: map[*ast.TableInfo]bool{}}
+	for _, name := range sortedKeys(file.GlobalMaps) {
+		var varInfo = file.GlobalMaps[name]
+		if varInfo.DefStat != nil {
+			x.add(name, "", valueKind(varInfo.ValueExp, varInfo.Table, OutlineVariable), varInfo.Loc)
+		}
+		if varInfo.Table != nil && varInfo.Table.File == file {
+			x.fields(varInfo.Table, name)
+		}
+	}
+	for _, class := range file.Annotate.ClassList {
+		var name = class.NameAndLoc.Name
+		x.add(name, "", OutlineClass, class.NameAndLoc.Loc)
+		for _, field := range class.FieldList {
+			x.add(field.NameAndLoc.Name, name, OutlineField, field.NameAndLoc.Loc)
+		}
+		if class.Table != nil && class.Table.File == file {
+			x.fields(class.Table, name)
+		}
+	}
+	for _, alias := range file.Annotate.AliasList {
+		x.add(alias.NameAndLoc.Name, "", OutlineAlias, alias.NameAndLoc.Loc)
+	}
+	for _, enum := range file.Annotate.EnumList {
+		var name = enum.NameAndLoc.Name
+		x.add(name, "", OutlineEnum, enum.NameAndLoc.Loc)
+		if enum.Table != nil && enum.Table.File == file && !x.visited[enum.Table] {
+			x.visited[enum.Table] = true
+			for _, field := range enum.Table.FieldList {
+				x.add(field.Name, name, OutlineEnumMember, field.Loc)
+			}
+		}
+	}
+	return x.list
+}
+
+// fields 表的字段，子表的字段用 container.name 作为所属的表
+func (x *symbolIndexer) fields(table *ast.TableInfo, container string) {
+	if x.visited[table] {
+		return
+	}
+	x.visited[table] = true
+	for _, field := range table.FieldList {
+		var kind = valueKind(field.ValueExp, field.SubTable, OutlineField)
+		if field.IsMethod {
+			kind = OutlineMethod
+		}
+		x.add(field.Name, container, kind, field.Loc)
+		if field.SubTable != nil && field.SubTable.File == x.file {
+			x.fields(field.SubTable, container+"."+field.Name)
+		}
+	}
+}
+
+// valueKind 根据赋的值判断符号的种类
+func valueKind(value ast.Exp, table *ast.TableInfo, kind OutlineKind) OutlineKind {
+	if _, ok := value.(*ast.FuncDefExp); ok {
+		return OutlineFunction
+	}
+	if table != nil && len(table.FieldList) > 0 {
+		return OutlineTable
+	}
+	return kind
+}
+
+// SearchSymbols 按名字模糊搜索工程里的符号，按匹配的程度排序
+func (p *Project) SearchSymbols(query string) []*IndexSymbol {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil
+	}
+	var matchFull = strings.ContainsAny(query, ".:")
+	if matchFull {
+		query = strings.ReplaceAll(query, ":", ".")
+	}
+	var m = newFuzzyMatcher(query)
+	var results = &symbolHeap{}
+	var index = p.symbolIndex
+	var masks, names, heads = index.nameMasks, index.names, index.nameHeads
+	if matchFull {
+		masks, names, heads = index.fullMasks, index.fullNames, index.fullHeads
+	}
+	for i, mask := range masks {
+		if mask&m.mask != m.mask {
+			continue
+		}
+		// 结果已满时，最高分也比不上已有结果的就不用再匹配
+		if results.Len() == maxSymbolResults && m.upperBound(heads[i], len(names[i])) < results.list[0].score {
+			continue
+		}
+		var score, ok = m.score(names[i])
+		if !ok {
+			continue
+		}
+		var item = symbolMatch{sym: index.syms[i], score: score}
+		if results.Len() < maxSymbolResults {
+			heap.Push(results, item)
+		} else if results.less(results.list[0], item) {
+			results.list[0] = item
+			heap.Fix(results, 0)
+		}
+	}
+	sort.Slice(results.list, func(i, j int) bool {
+		return results.less(results.list[j], results.list[i])
+	})
+	var list = make([]*IndexSymbol, len(results.list))
+	for i, item := range results.list {
+		list[i] = item.sym
+	}
+	return list
+}
+
+// symbolMatch 匹配的符号和分数
+type symbolMatch struct {
+	sym   *IndexSymbol
+	score int
+}
+
+// symbolHeap 分数最低的在堆顶，只保留分数最高的若干个
+type symbolHeap struct {
+	list []symbolMatch
+}
+
+// less a 是否排在 b 的后面：分数低，名字长，名字和路径的字典序大
+func (h *symbolHeap) less(a, b symbolMatch) bool {
+	if a.score != b.score {
+		return a.score < b.score
+	}
+	if len(a.sym.Name) != len(b.sym.Name) {
+		return len(a.sym.Name) > len(b.sym.Name)
+	}
+	if a.sym.Name != b.sym.Name {
+		return a.sym.Name > b.sym.Name
+	}
+	return a.sym.File.GetPath() > b.sym.File.GetPath()
+}
+
+func (h *symbolHeap) Len() int           { return len(h.list) }
+func (h *symbolHeap) Less(i, j int) bool { return h.less(h.list[i], h.list[j]) }
+func (h *symbolHeap) Swap(i, j int)      { h.list[i], h.list[j] = h.list[j], h.list[i] }
+func (h *symbolHeap) Push(x any)         { h.list = append(h.list, x.(symbolMatch)) }
+func (h *symbolHeap) Pop() any {
+	var item = h.list[len(h.list)-1]
+	h.list = h.list[:len(h.list)-1]
+	return item
+}
+
+// 模糊匹配的分数
+const (
+	scoreMatch       = 16 // 每个匹配的字符
+	scoreBoundary    = 8  // 匹配在单词的开头，例如 _ 后面，或者驼峰的大写字母
+	scoreStart       = 12 // 匹配在名字的开头
+	scoreConsecutive = 6  // 和上一个字符连续
+	scoreCase        = 1  // 大小写也相同
+	scoreExact       = 64 // 名字完全相同，不区分大小写
+	penaltyGap       = 1  // 两个匹配之间每跳过一个字符
+	maxLeadingGap    = 3  // 第一个匹配前面的字符最多扣分的个数
+)
+
+// fuzzyMatcher 一次查询的匹配器，复用打分用的缓存
+type fuzzyMatcher struct {
+	query string
+	lower string
+	mask  uint64
+	prev  []int
+	cur   []int
+}
+
+func newFuzzyMatcher(query string) *fuzzyMatcher {
+	var lower = []byte(query)
+	for i, c := range lower {
+		lower[i] = lowerByte(c)
+	}
+	return &fuzzyMatcher{query: query, lower: string(lower), mask: charMask(query)}
+}
+
+// lowerByte ASCII 字母转成小写
+func lowerByte(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+// isBoundary name[j] 是否为单词的开头
+func isBoundary(name string, j int) bool {
+	if j == 0 {
+		return true
+	}
+	var prev, c = name[j-1], name[j]
+	switch {
+	case prev == '_' || prev == '.':
+		return true
+	case prev >= 'a' && prev <= 'z' && c >= 'A' && c <= 'Z':
+		return true
+	case (prev < '0' || prev > '9') && c >= '0' && c <= '9':
+		return true
+	}
+	return false
+}
+
+// upperBound 名字能得到的最高分，只根据第一个字符和长度估计
+func (m *fuzzyMatcher) upperBound(head byte, n int) int {
+	var count = len(m.lower)
+	var bound = count*(scoreMatch+scoreBoundary+scoreCase) + (count-1)*scoreConsecutive
+	if head == m.lower[0] {
+		bound += scoreStart
+	}
+	if n == count {
+		bound += scoreExact
+	}
+	return bound
+}
+
+// score 查询是名字的子序列时返回最高的分数。动态规划，cur[j] 是当前字符匹配 name[j] 时的最高分
+func (m *fuzzyMatcher) score(name string) (int, bool) {
+	var n = len(name)
+	if n < len(m.lower) {
+		return 0, false
+	}
+	// 先用贪心确认是子序列
+	var qi = 0
+	for j := 0; j < n && qi < len(m.lower); j++ {
+		if lowerByte(name[j]) == m.lower[qi] {
+			qi++
+		}
+	}
+	if qi < len(m.lower) {
+		return 0, false
+	}
+	const none = -1 << 30
+	if cap(m.prev) < n {
+		m.prev, m.cur = make([]int, n), make([]int, n)
+	}
+	var prev, cur = m.prev[:n], m.cur[:n]
+	for i := 0; i < len(m.lower); i++ {
+		var gapBest = none // 跳过若干字符后接上的最高分
+		for j := 0; j < n; j++ {
+			var best = none
+			if i == 0 {
+				best = -penaltyGap * min(j, maxLeadingGap)
+			} else if j > 0 {
+				best = max(gapBest, prev[j-1]+scoreConsecutive)
+				gapBest = max(gapBest, prev[j-1]) - penaltyGap
+			}
+			// none 加减之后仍然远小于 none/2
+			if best <= none/2 || lowerByte(name[j]) != m.lower[i] {
+				cur[j] = none
+				continue
+			}
+			var s = best + scoreMatch
+			if j == 0 {
+				s += scoreStart
+			}
+			if isBoundary(name, j) {
+				s += scoreBoundary
+			}
+			if name[j] == m.query[i] {
+				s += scoreCase
+			}
+			cur[j] = s
+		}
+		prev, cur = cur, prev
+	}
+	var result = none
+	for _, s := range prev {
+		result = max(result, s)
+	}
+	if result <= none/2 {
+		return 0, false
+	}
+	if n == len(m.lower) {
+		result += scoreExact
+	}
+	return result, true
+}
@@ -0,0 +1,117 @@
+package check
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// searchNames 查询结果的名字，每个是 "所属.名字"
+func searchNames(p *Project, query string) []string {
+	var list []string
+	for _, sym := range p.SearchSymbols(query) {
+		var name = sym.Name
+		if sym.Container != "" {
+			name = sym.Container + "." + name
+		}
+		list = append(list, name)
+	}
+	return list
+}
+
+// 完全相同 > 前缀 > 驼峰和下划线分隔的单词开头 > 分散的字符
+func TestSearchSymbolsRank(t *testing.T) {
+	var p = newTestProject(map[string]string{
+		"a.lua": "ixtxexm = 1\nbag_item = 1\ngetItem = 1\nitemList = 1\nitem = 1\nother = 1\n",
+	})
+	var got = searchNames(p, "item")
+	var want = []string{"item", "itemList", "bag_item", "getItem", "ixtxexm"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestSearchSymbolsContainer(t *testing.T) {
+	var p = newTestProject(map[string]string{
+		"a.lua": "M = {}\nfunction M.load() end\nfunction M:save() end\nload2 = 1\n",
+	})
+	var got = searchNames(p, "M:sa")
+	if want := []string{"M.save"}; !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	got = searchNames(p, "load")
+	if want := []string{"M.load", "load2"}; !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestSearchSymbolsEmpty(t *testing.T) {
+	var p = newTestProject(map[string]string{"a.lua": "item = 1\n"})
+	for _, query := range []string{"", "  "} {
+		if got := p.SearchSymbols(query); got != nil {
+			t.Errorf("query %q: got %d symbols", query, len(got))
+		}
+	}
+}
+
+// 修改和删除文件后索引跟着更新，删除的符号多了整理之后也一样
+func TestSearchSymbolsUpdate(t *testing.T) {
+	var p = newTestProject(map[string]string{
+		"a.lua": "alpha = 1\n",
+		"b.lua": "beta = 1\n",
+	})
+	p.UpdateFile(analyzeText("a.lua", "alphaNew = 1\n"))
+	if got, want := searchNames(p, "alpha"), []string{"alphaNew"}; !slices.Equal(got, want) {
+		t.Errorf("after update: got %q, want %q", got, want)
+	}
+	p.RemoveFile("a.lua")
+	if got := searchNames(p, "alpha"); got != nil {
+		t.Errorf("after remove: got %q", got)
+	}
+
+	var big strings.Builder
+	for i := 0; i < 2000; i++ {
+		fmt.Fprintf(&big, "gamma%d = 1\n", i)
+	}
+	p.UpdateFile(analyzeText("c.lua", big.String()))
+	p.RemoveFile("c.lua")
+	if p.symbolIndex.deleted != 0 || len(p.symbolIndex.syms) != 1 {
+		t.Fatalf("not compacted: %d deleted of %d", p.symbolIndex.deleted, len(p.symbolIndex.syms))
+	}
+	if got := searchNames(p, "gamma"); got != nil {
+		t.Errorf("after compaction: got %d gamma symbols", len(got))
+	}
+	if got, want := searchNames(p, "beta"), []string{"beta"}; !slices.Equal(got, want) {
+		t.Errorf("after compaction: got %q, want %q", got, want)
+	}
+	p.UpdateFile(analyzeText("b.lua", "delta = 1\n"))
+	if got := searchNames(p, "beta"); got != nil {
+		t.Errorf("update after compaction: got %q", got)
+	}
+	if got, want := searchNames(p, "delta"), []string{"delta"}; !slices.Equal(got, want) {
+		t.Errorf("update after compaction: got %q, want %q", got, want)
+	}
+}
+
+// 大约 100 万个符号的工程，一次查询的目标是 50ms 以内
+func BenchmarkSearchSymbols(b *testing.B) {
+	var words = []string{"get", "set", "user", "name", "player", "item", "update", "draw", "load", "save", "config", "list", "map", "add", "remove"}
+	var p = NewProject()
+	for i := 0; i < 30000; i++ {
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "---@class Mod%d\nMod%d = {}\n", i, i)
+		for j := 0; j < 30; j++ {
+			var a, c = words[(i+j)%len(words)], words[(i*7+j*3)%len(words)]
+			fmt.Fprintf(&sb, "function Mod%d.%s%s%d() end\n", i, a, strings.ToUpper(c[:1])+c[1:], j)
+		}
+		p.UpdateFile(analyzeText(fmt.Sprintf("f%d.lua", i), sb.String()))
+	}
+	for _, query := range []string{"gun", "a", "Mod12.get", "xyzq"} {
+		b.Run(query, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				p.SearchSymbols(query)
+			}
+		})
+	}
+}
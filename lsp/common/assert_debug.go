@@ -3,7 +3,7 @@
 package common
 
 // go 里面没有 assert 费解
-func Assert(condition bool, msg string) {
+func Assert(condition bool) {
 	if !condition {
 		panic("Something Wrong, check stack.")
 	}
@@ -19,6 +19,11 @@ func (pos Position) GetColumn() int {
 	return int(pos.Column)
 }
 
+// Before pos 是否在 other 之前
+func (pos Position) Before(other Position) bool {
+	return pos.Line < other.Line || pos.Line == other.Line && pos.Column < other.Column
+}
+
 // GetRangeLoc 获取两个位置的范围，为[]
 func GetRangeLoc(beginLoc, endLoc Location) Location {
 	return Location{
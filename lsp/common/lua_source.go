@@ -8,6 +8,11 @@ import (
 type LuaSource struct {
 	LuaPath string // lua 全路径，唯一标识
 	lines   []string
+
+	// 以下是还原原始文本需要的信息，分析时用不到
+	crlfLines []bool // 以 \r\n 结尾的行，没有 \r\n 时为 nil
+	hasBOM    bool   // 开头是否有 utf-8 bom
+	shebang   string // 被清空的 # 开头的第一行
 }
 
 func (s *LuaSource) GetOneLine(line int) string {
@@ -17,6 +22,71 @@ func (s *LuaSource) GetOneLine(line int) string {
 	return ""
 }
 
+// GetText 获取 [Start, End) 范围内的源码，跨行的部分用 \n 连接
+func (s *LuaSource) GetText(loc Location) string {
+	return s.getText(loc, false)
+}
+
+// GetRawText 获取 [Start, End) 范围内的原始文本，保留原来的换行符和 # 开头的第一行
+func (s *LuaSource) GetRawText(loc Location) string {
+	return s.getText(loc, true)
+}
+
+func (s *LuaSource) getText(loc Location, raw bool) string {
+	var getLine = s.GetOneLine
+	if raw {
+		getLine = s.GetRawLine
+	}
+	var startLine, endLine = loc.Start.GetLine(), loc.End.GetLine()
+	if startLine == endLine {
+		var line = getLine(startLine)
+		return line[min(loc.Start.GetColumn(), len(line)):min(loc.End.GetColumn(), len(line))]
+	}
+	var builder strings.Builder
+	for i := startLine; i <= endLine; i++ {
+		var line = getLine(i)
+		switch i {
+		case startLine:
+			builder.WriteString(line[min(loc.Start.GetColumn(), len(line)):])
+		case endLine:
+			builder.WriteString(line[:min(loc.End.GetColumn(), len(line))])
+			continue
+		default:
+			builder.WriteString(line)
+		}
+		if raw {
+			builder.WriteString(s.GetLineEnding(i))
+		} else {
+			builder.WriteByte('\n')
+		}
+	}
+	return builder.String()
+}
+
+// GetRawLine 和 GetOneLine 一样，只是第一行会还原成 # 开头的原始内容
+func (s *LuaSource) GetRawLine(line int) string {
+	if line == 0 && s.shebang != "" {
+		return s.shebang
+	}
+	return s.GetOneLine(line)
+}
+
+// GetLineEnding 获取一行原始的换行符，最后一行为空
+func (s *LuaSource) GetLineEnding(line int) string {
+	if line < 0 || line >= len(s.lines)-1 {
+		return ""
+	}
+	if s.crlfLines != nil && s.crlfLines[line] {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// HasBOM 原始文本开头是否有 utf-8 bom
+func (s *LuaSource) HasBOM() bool {
+	return s.hasBOM
+}
+
 func (s *LuaSource) GetLineNum() int {
 	return len(s.lines)
 }
@@ -26,34 +96,100 @@ func NewLuaSource(chunk []byte, Path string) *LuaSource {
 		LuaPath: Path,
 	}
 	// try skip utf-8 bom
-	chunk = bytes.TrimPrefix(chunk, []byte{0xEF, 0xBB, 0xBF})
+	chunk, source.hasBOM = bytes.CutPrefix(chunk, []byte{0xEF, 0xBB, 0xBF})
+	if len(chunk) == 0 {
+		return source
+	}
 
-	// 分解成行，只支持utf-8
-	var last_idx = 0
-	var builder strings.Builder
-	for i := 0; i < len(chunk); i++ {
-		var ch = chunk[i]
-		if ch == '\r' { // 直接无视掉 \r
-			continue
+	// 分解成行，只支持utf-8。行尾的 \r 去掉，其他位置的 \r 当成空白符
+	source.lines = strings.Split(string(chunk), "\n")
+	for i := 0; i < len(source.lines)-1; i++ {
+		var line = source.lines[i]
+		if strings.HasSuffix(line, "\r") {
+			if source.crlfLines == nil {
+				source.crlfLines = make([]bool, len(source.lines))
+			}
+			source.crlfLines[i] = true
+			source.lines[i] = line[:len(line)-1]
 		}
-		if ch == '\n' {
-			source.lines = append(source.lines, builder.String())
-			builder.Reset()
-			last_idx = i
-		} else {
-			builder.WriteByte(ch)
+	}
+	// 剔除 # 开头的第一行
+	if strings.HasPrefix(source.lines[0], "#") {
+		source.shebang = source.lines[0]
+		source.lines[0] = ""
+	}
+	return source
+}
+
+// ApplyChange 把 loc 范围内的文本替换成 text，返回新的 LuaSource。没有修改的行会共用
+func (s *LuaSource) ApplyChange(loc Location, text string) *LuaSource {
+	var lines = s.lines
+	if len(lines) == 0 {
+		lines = []string{""}
+	}
+	var startLine = min(max(loc.Start.GetLine(), 0), len(lines)-1)
+	var endLine = min(max(loc.End.GetLine(), startLine), len(lines)-1)
+	var firstLine, lastLine = s.GetRawLine(startLine), s.GetRawLine(endLine)
+	var startCol = min(max(loc.Start.GetColumn(), 0), len(firstLine))
+	var endCol = min(max(loc.End.GetColumn(), 0), len(lastLine))
+	if startLine == endLine {
+		endCol = max(endCol, startCol)
+	}
+
+	var newSource = &LuaSource{
+		LuaPath: s.LuaPath,
+		hasBOM:  s.hasBOM,
+	}
+	var middle = strings.Split(firstLine[:startCol]+text+lastLine[endCol:], "\n")
+	var lastEnding = s.GetLineEnding(endLine)
+	newSource.lines = make([]string, 0, len(lines)-(endLine-startLine+1)+len(middle))
+	newSource.lines = append(newSource.lines, lines[:startLine]...)
+	newSource.lines = append(newSource.lines, middle...)
+	newSource.lines = append(newSource.lines, lines[endLine+1:]...)
+
+	var setCRLF = func(line int) {
+		if newSource.crlfLines == nil {
+			newSource.crlfLines = make([]bool, len(newSource.lines))
 		}
+		newSource.crlfLines[line] = true
 	}
-	if last_idx < len(chunk) {
-		source.lines = append(source.lines, builder.String())
+	for i := 0; i < startLine; i++ {
+		if s.crlfLines != nil && s.crlfLines[i] {
+			setCRLF(i)
+		}
 	}
-	if len(source.lines) > 0 {
-		// 剔除 # 开头的第一行
-		if strings.HasPrefix(source.lines[0], "#") {
-			source.lines[0] = ""
+	for i := 0; i < len(middle)-1; i++ {
+		var idx = startLine + i
+		if strings.HasSuffix(middle[i], "\r") {
+			newSource.lines[idx] = middle[i][:len(middle[i])-1]
+			setCRLF(idx)
 		}
 	}
-	return source
+	var lastIdx = startLine + len(middle) - 1
+	if lastEnding == "\n" && strings.HasSuffix(newSource.lines[lastIdx], "\r") {
+		// 和后面的 \n 组成了 \r\n
+		newSource.lines[lastIdx] = strings.TrimSuffix(newSource.lines[lastIdx], "\r")
+		lastEnding = "\r\n"
+	}
+	if lastEnding == "\r\n" {
+		setCRLF(lastIdx)
+	}
+	for i := endLine + 1; i < len(lines); i++ {
+		if s.crlfLines != nil && s.crlfLines[i] {
+			setCRLF(i - endLine - 1 + startLine + len(middle))
+		}
+	}
+
+	// 剔除 # 开头的第一行
+	if startLine == 0 {
+		if strings.HasPrefix(newSource.lines[0], "#") {
+			newSource.shebang = newSource.lines[0]
+			newSource.lines[0] = ""
+		}
+	} else {
+		newSource.shebang = s.shebang
+	}
+	return newSource
 }
 
 func (s *LuaSource) GetOneLineLength(line int) int {
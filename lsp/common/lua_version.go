@@ -0,0 +1,90 @@
+package common
+
+import "strings"
+
+// LuaVersion 解析时使用的 lua 方言版本。不同版本支持的语法特性不同。
+type LuaVersion uint8
+
+const (
+	LuaVersion51  LuaVersion = iota // Lua 5.1
+	LuaVersion52                    // Lua 5.2
+	LuaVersion53                    // Lua 5.3
+	LuaVersion54                    // Lua 5.4
+	LuaVersionJIT                   // LuaJIT 2.x，语法上是 5.1 加上部分 5.2 的扩展
+
+	DefaultLuaVersion = LuaVersion54
+)
+
+var luaVersionNames = [...]string{
+	LuaVersion51:  "Lua 5.1",
+	LuaVersion52:  "Lua 5.2",
+	LuaVersion53:  "Lua 5.3",
+	LuaVersion54:  "Lua 5.4",
+	LuaVersionJIT: "LuaJIT",
+}
+
+func (v LuaVersion) String() string {
+	if int(v) < len(luaVersionNames) {
+		return luaVersionNames[v]
+	}
+	return "Lua ?"
+}
+
+// ParseLuaVersion 解析配置里的版本字符串，例如 "5.1", "Lua 5.4", "LuaJIT"。
+func ParseLuaVersion(str string) (LuaVersion, bool) {
+	str = strings.ToLower(strings.TrimSpace(str))
+	str = strings.TrimSpace(strings.TrimPrefix(str, "lua"))
+	switch str {
+	case "5.1":
+		return LuaVersion51, true
+	case "5.2":
+		return LuaVersion52, true
+	case "5.3":
+		return LuaVersion53, true
+	case "5.4":
+		return LuaVersion54, true
+	case "jit", "-jit", "_jit":
+		return LuaVersionJIT, true
+	}
+	return DefaultLuaVersion, false
+}
+
+// IsJIT 是否是 LuaJIT
+func (v LuaVersion) IsJIT() bool {
+	return v == LuaVersionJIT
+}
+
+// SupportGoto goto 和 ::label:: 。5.2 引入，LuaJIT 也支持
+func (v LuaVersion) SupportGoto() bool {
+	return v != LuaVersion51
+}
+
+// SupportIntDiv 整除运算符 //
+func (v LuaVersion) SupportIntDiv() bool {
+	return v == LuaVersion53 || v == LuaVersion54
+}
+
+// SupportBitwiseOp 位运算符 & | ~ << >>
+func (v LuaVersion) SupportBitwiseOp() bool {
+	return v == LuaVersion53 || v == LuaVersion54
+}
+
+// SupportAttrib local 变量的 <const> <close> 属性
+func (v LuaVersion) SupportAttrib() bool {
+	return v == LuaVersion54
+}
+
+// SupportHexFloat 16进制的小数和 p 指数
+func (v LuaVersion) SupportHexFloat() bool {
+	return v != LuaVersion51
+}
+
+// SupportHexEscape 字符串里的 \xXX 和 \z 转义
+func (v LuaVersion) SupportHexEscape() bool {
+	return v != LuaVersion51
+}
+
+// SupportUtf8Escape 字符串里的 \u{XXX} 转义
+func (v LuaVersion) SupportUtf8Escape() bool {
+	return v != LuaVersion51 && v != LuaVersion52
+}
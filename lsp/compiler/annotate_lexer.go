@@ -1,2 +1,178 @@
 package compiler
 
+import (
+	"mylua-lsp/lsp/ast"
+	"mylua-lsp/lsp/common"
+	"strings"
+)
+
+// AnnotateToken 注释里的单词
+type AnnotateToken struct {
+	Kind ast.ATokenType
+	Str  string // 单词的内容，字符串字面值为去掉引号后的内容
+	Loc  Location
+}
+
+// AnnotateLexer 注释的词法分析，每次分析一行注释
+type AnnotateLexer struct {
+	line string   // 一行注释的内容
+	base Position // line 第一个字节在源码中的位置
+	pos  int      // 下一个字符在 line 中的下标
+
+	aheadToken AnnotateToken
+	hasAhead   bool
+}
+
+// NewAnnotateLexer 创建注释的词法分析器，start 为 line 中开始分析的下标
+func NewAnnotateLexer(line string, base Position, start int) *AnnotateLexer {
+	return &AnnotateLexer{
+		line: line,
+		base: base,
+		pos:  start,
+	}
+}
+
+// getPos line 中的下标对应源码中的位置
+func (l *AnnotateLexer) getPos(idx int) Position {
+	return Position{Line: l.base.Line, Column: l.base.Column + int32(idx)}
+}
+
+// LookAhead 查看下一个单词，但不移动位置
+func (l *AnnotateLexer) LookAhead() AnnotateToken {
+	if !l.hasAhead {
+		l.aheadToken = l.scanToken()
+		l.hasAhead = true
+	}
+	return l.aheadToken
+}
+
+// NextToken 读取下一个单词
+func (l *AnnotateLexer) NextToken() AnnotateToken {
+	var token = l.LookAhead()
+	l.hasAhead = false
+	return token
+}
+
+// RestText 没有读取的剩余文本，用于获取注释后面的说明
+func (l *AnnotateLexer) RestText() (text string, pos Position) {
+	var idx = l.pos
+	if l.hasAhead {
+		idx = int(l.aheadToken.Loc.Start.Column - l.base.Column)
+	}
+	for idx < len(l.line) && isWhiteSpace(l.line[idx]) {
+		idx++
+	}
+	return l.line[idx:], l.getPos(idx)
+}
+
+// SkipToEnd 剩下的内容全部跳过
+func (l *AnnotateLexer) SkipToEnd() {
+	l.pos = len(l.line)
+	l.hasAhead = false
+}
+
+func (l *AnnotateLexer) scanToken() AnnotateToken {
+	for l.pos < len(l.line) && isWhiteSpace(l.line[l.pos]) {
+		l.pos++
+	}
+	var start = l.pos
+	var makeToken = func(kind ast.ATokenType, str string) AnnotateToken {
+		return AnnotateToken{
+			Kind: kind,
+			Str:  str,
+			Loc:  Location{Start: l.getPos(start), End: l.getPos(l.pos)},
+		}
+	}
+	if l.pos >= len(l.line) {
+		return makeToken(ast.ATokenEOF, "")
+	}
+
+	var c = l.line[l.pos]
+	switch c {
+	case ',':
+		l.pos++
+		return makeToken(ast.ATokenSepComma, ",")
+	case ':':
+		l.pos++
+		return makeToken(ast.ATokenSepColon, ":")
+	case '(':
+		l.pos++
+		return makeToken(ast.ATokenVSepLparen, "(")
+	case ')':
+		l.pos++
+		return makeToken(ast.ATokenVSepRparen, ")")
+	case '[':
+		l.pos++
+		return makeToken(ast.ATokenVSepLbrack, "[")
+	case ']':
+		l.pos++
+		return makeToken(ast.ATokenVSepRbrack, "]")
+	case '{':
+		l.pos++
+		return makeToken(ast.ATokenLcurly, "{")
+	case '}':
+		l.pos++
+		return makeToken(ast.ATokenRcurly, "}")
+	case '|':
+		l.pos++
+		return makeToken(ast.ATokenBor, "|")
+	case '<':
+		l.pos++
+		return makeToken(ast.ATokenLt, "<")
+	case '>':
+		l.pos++
+		return makeToken(ast.ATokenGt, ">")
+	case '@':
+		l.pos++
+		return makeToken(ast.ATokenAt, "@")
+	case '?':
+		l.pos++
+		return makeToken(ast.ATokenOption, "?")
+	case '.':
+		if strings.HasPrefix(l.line[l.pos:], "...") {
+			l.pos += 3
+			return makeToken(ast.ATokenVararg, "...")
+		}
+	case '"', '\'', '`':
+		l.pos++
+		for l.pos < len(l.line) && l.line[l.pos] != c {
+			if l.line[l.pos] == '\\' {
+				l.pos++
+			}
+			l.pos++
+		}
+		var str = l.line[start+1 : min(l.pos, len(l.line))]
+		l.pos = min(l.pos+1, len(l.line))
+		return makeToken(ast.ATokenLiteral, str)
+	}
+
+	if common.IsDigit(c) || c == '-' && l.pos+1 < len(l.line) && common.IsDigit(l.line[l.pos+1]) {
+		l.pos++
+		for l.pos < len(l.line) && (common.IsNameChar(l.line[l.pos]) || l.line[l.pos] == '.') {
+			l.pos++
+		}
+		return makeToken(ast.ATokenNumber, l.line[start:l.pos])
+	}
+
+	if common.IsLetterChar(c) || c == '_' || c >= 0x80 {
+		// 名字里可以有 . 例如 mod.ClassName
+		for l.pos < len(l.line) {
+			var ch = l.line[l.pos]
+			if common.IsNameChar(ch) || ch >= 0x80 {
+				l.pos++
+			} else if ch == '.' && l.pos+1 < len(l.line) && (common.IsLetterChar(l.line[l.pos+1]) || l.line[l.pos+1] == '_') {
+				l.pos++
+			} else {
+				break
+			}
+		}
+		var str = l.line[start:l.pos]
+		if kind, ok := ast.Annotate_Keywords[str]; ok {
+			return makeToken(kind, str)
+		}
+		return makeToken(ast.ATokenString, str)
+	}
+
+	l.pos++
+	return makeToken(ast.ATokenKwOther, l.line[start:l.pos])
+}
@@ -0,0 +1,502 @@
+package compiler
+
+import (
+	"fmt"
+	"mylua-lsp/lsp/ast"
+	"strconv"
+	"strings"
+)
+
+/*
+注释标记的语法分析，语法见 ast/annotate_state.go 。
+每行 ---@ 开头的注释单独解析，出错时丢弃这一行，不影响其他行。
+*/
+
+// annotateBail 一行注释解析出错，中断这一行的解析
+type annotateBail struct{}
+
+type annotateParser struct {
+	l       *AnnotateLexer
+	errList []ParseError
+	depth   int // 括号的嵌套层数，嵌套里的 fun 只有一个返回值
+}
+
+// ParseAnnotateBlock 解析一个注释块里的注释标记和说明
+func ParseAnnotateBlock(block *ast.CommentBlock) (result *ast.AnnotateBlock, errList []ParseError) {
+	result = &ast.AnnotateBlock{
+		Block: block,
+	}
+	var docList []string
+	var p = &annotateParser{}
+	for i := range block.List {
+		var comment = &block.List[i]
+		if !comment.ShortFlag {
+			docList = append(docList, comment.Str)
+			continue
+		}
+		switch {
+		case strings.HasPrefix(comment.Str, "---@"):
+			if line := p.parseLine(comment); line != nil {
+				result.LineList = append(result.LineList, line)
+			}
+		case strings.HasPrefix(comment.Str, "---|"):
+			p.parseAliasItem(comment, result.LineList)
+		default:
+			docList = append(docList, trimCommentPrefix(comment.Str))
+		}
+	}
+	result.Doc = strings.TrimSpace(strings.Join(docList, "\n"))
+
+	var first, last = block.List[0], block.List[len(block.List)-1]
+	result.Loc = Location{Start: first.StartPos, End: last.EndPos}
+	return result, p.errList
+}
+
+// trimCommentPrefix 去掉短注释的 -- 或者 --- 前缀以及后面的一个空格
+func trimCommentPrefix(str string) string {
+	str = strings.TrimPrefix(str, "--")
+	str = strings.TrimPrefix(str, "-")
+	str = strings.TrimPrefix(str, " ")
+	return strings.TrimRight(str, " \t\r")
+}
+
+func (p *annotateParser) error(token AnnotateToken, f string, a ...any) {
+	p.errList = append(p.errList, ParseError{
+		ErrStr: fmt.Sprintf(f, a...),
+		Loc:    token.Loc,
+	})
+	panic(annotateBail{})
+}
+
+// tokenDesc 错误信息里单词的描述
+func tokenDesc(token AnnotateToken) string {
+	if token.Kind == ast.ATokenEOF {
+		return "<eol>"
+	}
+	return token.Str
+}
+
+// parseLine 解析一行 ---@ 开头的注释
+func (p *annotateParser) parseLine(comment *ast.CommentLine) (line *ast.AnnotateLine) {
+	p.l = NewAnnotateLexer(comment.Str, comment.StartPos, len("---@"))
+	p.depth = 0
+	var tagToken = p.l.NextToken()
+	line = &ast.AnnotateLine{
+		Tag: ast.NameAndLoc{Name: tagToken.Str, Loc: tagToken.Loc},
+		Loc: Location{
+			Start: comment.StartPos,
+			End:   Position{Line: comment.StartPos.Line, Column: comment.StartPos.Column + int32(len(comment.Str))},
+		},
+	}
+	if tagToken.Kind == ast.ATokenEOF {
+		return nil
+	}
+
+	defer func() {
+		if err := recover(); err != nil {
+			if _, ok := err.(annotateBail); !ok {
+				panic(err)
+			}
+			line.State = nil
+		}
+	}()
+
+	switch tagToken.Kind {
+	case ast.ATokenKwType:
+		line.State = p.parseTypeState()
+	case ast.ATokenKwParam:
+		line.State = p.parseParamState()
+	case ast.ATokenKwVararg:
+		// 旧的写法 ---@vararg TypeName 当成可变参数
+		line.State = &ast.AnnotateParamState{
+			NameAndLoc: ast.NameAndLoc{Name: "...", Loc: tagToken.Loc},
+			ParamType:  p.parseType(),
+			Comment:    p.parseComment(),
+		}
+	case ast.ATokenKwReturn:
+		line.State = p.parseReturnState()
+	case ast.ATokenKwClass:
+		line.State = p.parseClassState()
+	case ast.ATokenKwField:
+		line.State = p.parseFieldState()
+	case ast.ATokenKwAlias:
+		line.State = p.parseAliasState()
+	case ast.ATokenKwEnum:
+		line.State = &ast.AnnotateEnumState{
+			NameAndLoc: p.expectName("enum name"),
+			Comment:    p.parseComment(),
+		}
+	case ast.ATokenKwGeneric:
+		line.State = &ast.AnnotateGenericState{
+			ParamList: p.parseGenericParamList(),
+		}
+	case ast.ATokenKwOverload:
+		var token = p.l.LookAhead()
+		fun, ok := p.parseType().(*ast.Type_Fun)
+		if !ok {
+			p.error(token, "expected fun type, found '%s'", tokenDesc(token))
+		}
+		line.State = &ast.AnnotateOverloadState{
+			Fun: fun,
+		}
+	default:
+		// 不认识的标签，例如 @deprecated @see ，保留标签
+		p.l.SkipToEnd()
+	}
+	return line
+}
+
+// parseComment 剩下的文本作为说明，去掉开头的 @ 或者 #
+func (p *annotateParser) parseComment() string {
+	var text, _ = p.l.RestText()
+	p.l.SkipToEnd()
+	text = strings.TrimPrefix(text, "@")
+	text = strings.TrimPrefix(text, "#")
+	return strings.TrimSpace(text)
+}
+
+// isNameToken 名字可以是任意的标识符，包括注释里的关键字
+func isNameToken(token AnnotateToken) bool {
+	return token.Kind == ast.ATokenString || (token.Kind >= ast.ATokenKwFun && token.Kind <= ast.ATokenKwEnumEnd && token.Kind != ast.ATokenKwOther)
+}
+
+func (p *annotateParser) expectName(what string) ast.NameAndLoc {
+	var token = p.l.NextToken()
+	if !isNameToken(token) {
+		p.error(token, "expected %s, found '%s'", what, tokenDesc(token))
+	}
+	return ast.NameAndLoc{Name: token.Str, Loc: token.Loc}
+}
+
+func (p *annotateParser) expect(kind ast.ATokenType, str string) AnnotateToken {
+	var token = p.l.NextToken()
+	if token.Kind != kind {
+		p.error(token, "expected '%s', found '%s'", str, tokenDesc(token))
+	}
+	return token
+}
+
+// ---@type TypeName{,TypeName}
+func (p *annotateParser) parseTypeState() *ast.AnnotateTypeState {
+	return &ast.AnnotateTypeState{
+		TypeList: p.parseTypeList(),
+		Comment:  p.parseComment(),
+	}
+}
+
+// ---@param param_name? TypeName
+func (p *annotateParser) parseParamState() *ast.AnnotateParamState {
+	var state = &ast.AnnotateParamState{}
+	if token := p.l.LookAhead(); token.Kind == ast.ATokenVararg {
+		p.l.NextToken()
+		state.NameAndLoc = ast.NameAndLoc{Name: token.Str, Loc: token.Loc}
+	} else {
+		state.NameAndLoc = p.expectName("param name")
+	}
+	if p.l.LookAhead().Kind == ast.ATokenOption {
+		p.l.NextToken()
+		state.IsOptional = true
+	}
+	state.ParamType = p.parseType()
+	state.Comment = p.parseComment()
+	return state
+}
+
+// ---@return TypeName {, TypeName}
+func (p *annotateParser) parseReturnState() *ast.AnnotateReturnState {
+	return &ast.AnnotateReturnState{
+		ReturnTypeList: p.parseTypeList(),
+		Comment:        p.parseComment(),
+	}
+}
+
+// ---@class TypeName : TypeName {, TypeName}
+func (p *annotateParser) parseClassState() *ast.AnnotateClassState {
+	var state = &ast.AnnotateClassState{
+		NameAndLoc: p.expectName("class name"),
+	}
+	if p.l.LookAhead().Kind == ast.ATokenLt {
+		p.l.NextToken()
+		state.GenericParamList = p.parseGenericParamList()
+		p.expect(ast.ATokenGt, ">")
+	}
+	if p.l.LookAhead().Kind == ast.ATokenSepColon {
+		p.l.NextToken()
+		state.ParentTypeList = p.parseTypeList()
+	}
+	state.Comment = p.parseComment()
+	return state
+}
+
+// ---@field [public|protected|private] field_name? TypeName
+func (p *annotateParser) parseFieldState() *ast.AnnotateFieldState {
+	var state = &ast.AnnotateFieldState{}
+	switch p.l.LookAhead().Kind {
+	case ast.ATokenKwPubic, ast.ATokenKwProtected, ast.ATokenKwPrivate:
+		var token = p.l.NextToken()
+		if !isNameToken(p.l.LookAhead()) && p.l.LookAhead().Kind != ast.ATokenVSepLbrack {
+			// 字段名字就是 public 之类的
+			state.NameAndLoc = ast.NameAndLoc{Name: token.Str, Loc: token.Loc}
+		}
+	}
+	if state.NameAndLoc.Name == "" {
+		if token := p.l.LookAhead(); token.Kind == ast.ATokenVSepLbrack {
+			// [TypeName] 索引字段，名字保留原样
+			p.l.NextToken()
+			p.depth++
+			p.parseType()
+			p.depth--
+			var end = p.expect(ast.ATokenVSepRbrack, "]")
+			state.NameAndLoc = ast.NameAndLoc{
+				Name: p.l.line[token.Loc.Start.Column-p.l.base.Column : end.Loc.End.Column-p.l.base.Column],
+				Loc:  Location{Start: token.Loc.Start, End: end.Loc.End},
+			}
+		} else {
+			state.NameAndLoc = p.expectName("field name")
+		}
+	}
+	if p.l.LookAhead().Kind == ast.ATokenOption {
+		p.l.NextToken()
+		state.IsOptional = true
+	}
+	state.FieldType = p.parseType()
+	state.Comment = p.parseComment()
+	return state
+}
+
+// ---@alias new_name TypeName
+func (p *annotateParser) parseAliasState() *ast.AnnotateAliasState {
+	var state = &ast.AnnotateAliasState{
+		NameAndLoc: p.expectName("alias name"),
+	}
+	if p.l.LookAhead().Kind != ast.ATokenEOF {
+		state.Type = p.parseType()
+	}
+	state.Comment = p.parseComment()
+	return state
+}
+
+// parseAliasItem 解析 ---| 开头的行，是前面 @alias 的一个可选值
+func (p *annotateParser) parseAliasItem(comment *ast.CommentLine, lineList []*ast.AnnotateLine) {
+	if len(lineList) == 0 {
+		return
+	}
+	alias, ok := lineList[len(lineList)-1].State.(*ast.AnnotateAliasState)
+	if !ok {
+		return
+	}
+	p.l = NewAnnotateLexer(comment.Str, comment.StartPos, len("---|"))
+	p.depth = 0
+	defer func() {
+		if err := recover(); err != nil {
+			if _, ok := err.(annotateBail); !ok {
+				panic(err)
+			}
+		}
+	}()
+	var itemType = p.parseType()
+	if alias.Type == nil {
+		alias.Type = itemType
+		return
+	}
+	union, ok := alias.Type.(*ast.Type_Union)
+	if !ok {
+		union = &ast.Type_Union{TypeList: []ast.TypeBase{alias.Type}}
+		alias.Type = union
+	}
+	union.TypeList = append(union.TypeList, itemType)
+}
+
+// T[: TypeName] {, T[: TypeName]}
+func (p *annotateParser) parseGenericParamList() (list []ast.Type_KeyValue) {
+	for {
+		var param = ast.Type_KeyValue{
+			NameAndLoc: p.expectName("generic name"),
+		}
+		if p.l.LookAhead().Kind == ast.ATokenSepColon {
+			p.l.NextToken()
+			param.Type = p.parseType()
+		}
+		list = append(list, param)
+		if p.l.LookAhead().Kind != ast.ATokenSepComma {
+			return list
+		}
+		p.l.NextToken()
+	}
+}
+
+// TypeName {, TypeName}
+func (p *annotateParser) parseTypeList() (list []ast.TypeBase) {
+	list = append(list, p.parseType())
+	for p.l.LookAhead().Kind == ast.ATokenSepComma {
+		p.l.NextToken()
+		list = append(list, p.parseType())
+	}
+	return list
+}
+
+// TypeName ::= TypeName|TypeName
+func (p *annotateParser) parseType() ast.TypeBase {
+	var first = p.parsePostfixType()
+	if p.l.LookAhead().Kind != ast.ATokenBor {
+		return first
+	}
+	var union = &ast.Type_Union{
+		TypeList: []ast.TypeBase{first},
+	}
+	for p.l.LookAhead().Kind == ast.ATokenBor {
+		p.l.NextToken()
+		union.TypeList = append(union.TypeList, p.parsePostfixType())
+	}
+	return union
+}
+
+// TypeName ::= TypeName[] | TypeName?
+func (p *annotateParser) parsePostfixType() ast.TypeBase {
+	var t = p.parsePrimaryType()
+	for {
+		switch p.l.LookAhead().Kind {
+		case ast.ATokenVSepLbrack:
+			p.l.NextToken()
+			p.expect(ast.ATokenVSepRbrack, "]")
+			t = &ast.Type_Array{ElementType: t}
+		case ast.ATokenOption:
+			p.l.NextToken()
+			t = &ast.Type_Union{
+				TypeList: []ast.TypeBase{t, &ast.Type_LiteralValue{Type: ast.LiteralValueNil}},
+			}
+		default:
+			return t
+		}
+	}
+}
+
+func (p *annotateParser) parsePrimaryType() ast.TypeBase {
+	var token = p.l.NextToken()
+	switch token.Kind {
+	case ast.ATokenKwFun:
+		return p.parseFunType()
+	case ast.ATokenLcurly:
+		return p.parseMapType()
+	case ast.ATokenVSepLparen:
+		p.depth++
+		var t = p.parseType()
+		p.depth--
+		p.expect(ast.ATokenVSepRparen, ")")
+		return t
+	case ast.ATokenLiteral:
+		return &ast.Type_LiteralValue{Type: ast.LiteralValueString, Str: token.Str}
+	case ast.ATokenNumber:
+		var num, err = strconv.ParseFloat(token.Str, 64)
+		if err != nil {
+			if i, ok := parseInteger(token.Str); ok {
+				num = float64(i)
+			} else {
+				p.error(token, "malformed number near '%s'", token.Str)
+			}
+		}
+		return &ast.Type_LiteralValue{Type: ast.LiteralValueNumber, Num: num, Str: token.Str}
+	case ast.ATokenVararg:
+		// fun(...): ... 里面的可变返回值
+		return &ast.Type_Identifier{NameAndLoc: ast.NameAndLoc{Name: "any", Loc: token.Loc}}
+	}
+	if !isNameToken(token) {
+		p.error(token, "expected type, found '%s'", tokenDesc(token))
+	}
+
+	switch token.Str {
+	case "nil":
+		return &ast.Type_LiteralValue{Type: ast.LiteralValueNil}
+	case "true":
+		return &ast.Type_LiteralValue{Type: ast.LiteralValueTrue, Bool: true}
+	case "false":
+		return &ast.Type_LiteralValue{Type: ast.LiteralValueFalse}
+	}
+	var nameAndLoc = ast.NameAndLoc{Name: token.Str, Loc: token.Loc}
+	if p.l.LookAhead().Kind == ast.ATokenLt {
+		// Name<TypeName{,TypeName}>
+		p.l.NextToken()
+		p.depth++
+		var instance = &ast.Type_GenericInstance{
+			NameAndLoc:    nameAndLoc,
+			ParamTypeList: p.parseTypeList(),
+		}
+		p.depth--
+		p.expect(ast.ATokenGt, ">")
+		return instance
+	}
+	return &ast.Type_Identifier{NameAndLoc: nameAndLoc}
+}
+
+// fun( {param_name?:TypeName} ) [:TypeName{,TypeName} ]
+func (p *annotateParser) parseFunType() *ast.Type_Fun {
+	var fun = &ast.Type_Fun{}
+	p.expect(ast.ATokenVSepLparen, "(")
+	p.depth++
+	for p.l.LookAhead().Kind != ast.ATokenVSepRparen {
+		var param ast.Type_FunParam
+		if token := p.l.LookAhead(); token.Kind == ast.ATokenVararg {
+			p.l.NextToken()
+			param.NameAndLoc = ast.NameAndLoc{Name: token.Str, Loc: token.Loc}
+		} else {
+			param.NameAndLoc = p.expectName("param name")
+		}
+		if p.l.LookAhead().Kind == ast.ATokenOption {
+			p.l.NextToken()
+			param.IsOptional = true
+		}
+		if p.l.LookAhead().Kind == ast.ATokenSepColon {
+			p.l.NextToken()
+			param.Type = p.parseType()
+		}
+		fun.ParamList = append(fun.ParamList, param)
+		if p.l.LookAhead().Kind != ast.ATokenSepComma {
+			break
+		}
+		p.l.NextToken()
+	}
+	p.depth--
+	p.expect(ast.ATokenVSepRparen, ")")
+
+	if p.l.LookAhead().Kind == ast.ATokenSepColon {
+		p.l.NextToken()
+		fun.ReturnList = append(fun.ReturnList, ast.Type_FunReturn{Type: p.parseType()})
+		// 嵌套在括号里的时候，逗号属于外层
+		for p.depth == 0 && p.l.LookAhead().Kind == ast.ATokenSepComma {
+			p.l.NextToken()
+			fun.ReturnList = append(fun.ReturnList, ast.Type_FunReturn{Type: p.parseType()})
+		}
+	}
+	return fun
+}
+
+// TypeName ::= '{' {file_name:TypeName ,} '}'
+func (p *annotateParser) parseMapType() *ast.Type_Map {
+	var m = &ast.Type_Map{}
+	p.depth++
+	for p.l.LookAhead().Kind != ast.ATokenRcurly {
+		var field ast.Type_KeyValue
+		if token := p.l.LookAhead(); token.Kind == ast.ATokenVSepLbrack {
+			// [TypeName]: TypeName
+			p.l.NextToken()
+			p.parseType()
+			var end = p.expect(ast.ATokenVSepRbrack, "]")
+			field.NameAndLoc = ast.NameAndLoc{
+				Name: p.l.line[token.Loc.Start.Column-p.l.base.Column : end.Loc.End.Column-p.l.base.Column],
+				Loc:  Location{Start: token.Loc.Start, End: end.Loc.End},
+			}
+		} else {
+			field.NameAndLoc = p.expectName("field name")
+		}
+		p.expect(ast.ATokenSepColon, ":")
+		field.Type = p.parseType()
+		m.FieldList = append(m.FieldList, field)
+		if p.l.LookAhead().Kind != ast.ATokenSepComma {
+			break
+		}
+		p.l.NextToken()
+	}
+	p.depth--
+	p.expect(ast.ATokenRcurly, "}")
+	return m
+}
@@ -0,0 +1,247 @@
+package compiler
+
+import (
+	"mylua-lsp/lsp/ast"
+	"mylua-lsp/lsp/common"
+	"sort"
+	"strings"
+)
+
+/*
+增量解析。文件修改后只重新解析受影响的顶层语句：
+1. 修改位置之前的顶层语句直接复用。词法分析从一个安全的行开始，这一行的开头不能在长字符串或者长注释里面。
+2. 重新解析修改的部分，直到遇到一个和旧的顶层语句开始位置相同的单词，之后的语句整体移动行号后复用。
+结果和完整的重新解析一致，debug 版本下会校验。
+*/
+
+// reuseInfo 增量解析时可以复用的旧语句
+type reuseInfo struct {
+	old       *ParseResult
+	firstIdx  int      // 从这个下标开始的旧语句在修改的位置之后，可以复用
+	editLine  int32    // 修改结束的行，新文件中的行号
+	lineDelta int32    // 修改后的行号变化
+	reusedIdx int      // 实际从这个下标开始复用，没有复用时为 -1
+	reusedPos Position // 复用的第一个语句在旧文件中的开始位置
+}
+
+// findStat 查找新文件中 pos 位置开始的可以复用的旧语句，没有返回 -1 。
+// errs 是新文件目前的错误，同一个位置的错误会去重，前面的最后一个错误在 pos 时不复用
+func (r *reuseInfo) findStat(pos Position, errs []ParseError) int {
+	if pos.Line <= r.editLine {
+		return -1
+	}
+	if n := len(errs); n > 0 && errs[n-1].Loc.Start == pos {
+		return -1
+	}
+	var oldPos = Position{Line: pos.Line - r.lineDelta, Column: pos.Column}
+	var stats = r.old.Block.Stats
+	var idx = sort.Search(len(stats), func(i int) bool {
+		return !stats[i].GetLoc().Start.Before(oldPos)
+	})
+	if idx < r.firstIdx || idx >= len(stats) || stats[idx].GetLoc().Start != oldPos {
+		return -1
+	}
+	if n := r.old.statErrCounts[idx]; n > 0 && r.old.ErrList[n-1].Loc.Start == oldPos {
+		return -1
+	}
+	return idx
+}
+
+// parseChunk 解析顶层的 block。增量解析时 prefix 是前面复用的语句，reuse 用来查找后面可以复用的语句
+func (p *Parser) parseChunk(prefix []ast.Stat, reuse *reuseInfo) *ast.Block {
+	var start_loc = p.LookAheadToken().Loc
+	var before_loc = p.nowToken.Loc
+	if len(prefix) > 0 {
+		start_loc = reuse.old.Block.Loc
+	}
+	var block = &ast.Block{
+		Stats: append(make([]ast.Stat, 0, len(prefix)+1), prefix...),
+	}
+	// 复用的语句只需要修改父节点，子节点的父节点不变
+	for _, stat := range prefix {
+		stat.SetParent(block)
+	}
+
+	var reuseIdx = -1
+	for p.LookAheadKind() != ast.TkEOF {
+		if reuse != nil {
+			if reuseIdx = reuse.findStat(p.aheadToken.Loc.Start, p.parseErrs); reuseIdx >= 0 {
+				break
+			}
+		}
+		var start_loc = p.aheadToken.Loc
+		var errCount = len(p.parseErrs)
+		var stat ast.Stat
+		if isBlockEnd(p.aheadToken.TokenKind) {
+			// 多余的 end 之类的，跳过
+			stat = p.parseStrayBlockEnd()
+		} else {
+			stat = p.parseStat()
+		}
+		if stat != nil {
+			var Loc = common.GetRangeLoc(start_loc, p.nowToken.Loc)
+			stat.SetLoc(Loc)
+			ast.SetParents(stat, block)
+			block.Stats = append(block.Stats, stat)
+			p.statErrCounts = append(p.statErrCounts, errCount)
+		}
+	}
+
+	if reuseIdx >= 0 {
+		// 后面的语句和错误整体复用
+		reuse.reusedIdx = reuseIdx
+		var old = reuse.old
+		reuse.reusedPos = old.Block.Stats[reuseIdx].GetLoc().Start
+		var errBase = len(p.parseErrs)
+		var oldErrBase = old.statErrCounts[reuseIdx]
+		for _, stat := range old.Block.Stats[reuseIdx:] {
+			ast.ShiftLines(stat, reuse.lineDelta)
+			stat.SetParent(block)
+			block.Stats = append(block.Stats, stat)
+		}
+		for _, count := range old.statErrCounts[reuseIdx:] {
+			p.statErrCounts = append(p.statErrCounts, count-oldErrBase+errBase)
+		}
+		for _, oneErr := range old.ErrList[oldErrBase:] {
+			oneErr.Loc.Start.Line += reuse.lineDelta
+			oneErr.Loc.End.Line += reuse.lineDelta
+			p.parseErrs = append(p.parseErrs, oneErr)
+		}
+		var end_loc = old.Block.Loc
+		end_loc.End.Line += reuse.lineDelta
+		block.Loc = common.GetRangeLoc(start_loc, end_loc)
+		return block
+	}
+
+	var end_loc = p.nowToken.Loc
+	if end_loc == before_loc && len(prefix) == 0 {
+		// 空的 block
+		block.Loc = common.Location{Start: before_loc.End, End: before_loc.End}
+	} else {
+		block.Loc = common.GetRangeLoc(start_loc, end_loc)
+	}
+	p.NextTokenKind(ast.TkEOF)
+	return block
+}
+
+// Reparse 增量解析，把 loc 范围内的文本替换成 text 之后重新解析。
+// 复用的旧语句和注释会被直接修改位置和父节点，调用之后旧的结果以及用它分析出的 FileInfo 都不能再使用，
+// 同一个结果只能增量解析一次，再次调用会 panic
+func (r *ParseResult) Reparse(loc Location, text string) *ParseResult {
+	if r.reparsed {
+		panic("compiler: Reparse called on a result that has already been reparsed")
+	}
+	r.reparsed = true
+	var source = r.Source.ApplyChange(loc, text)
+
+	// 和 ApplyChange 一样修正范围
+	var oldLineNum = max(r.Source.GetLineNum(), 1)
+	var startLine = min(max(loc.Start.Line, 0), int32(oldLineNum-1))
+	var endLine = min(max(loc.End.Line, startLine), int32(oldLineNum-1))
+	var reuse = &reuseInfo{
+		old:       r,
+		lineDelta: int32(source.GetLineNum() - oldLineNum),
+		reusedIdx: -1,
+	}
+	reuse.editLine = endLine + reuse.lineDelta
+
+	var stats = r.Block.Stats
+	reuse.firstIdx = sort.Search(len(stats), func(i int) bool {
+		return stats[i].GetLoc().Start.Line > endLine
+	})
+	var prefixNum = r.findSafePrefix(startLine)
+
+	parser := Parser{
+		version: r.Version,
+	}
+	lexer := NewLexer(source, r.Version, parser.insertErr)
+	var commentMap = lexer.commentMap
+	if prefixNum > 0 {
+		// 从最后一个复用的语句的下一行开始词法分析
+		var lastLoc = stats[prefixNum-1].GetLoc()
+		lexer.nextPos = Position{Line: lastLoc.End.Line + 1, Column: 0}
+		lexer.cur_line = source.GetOneLine(lexer.nextPos.GetLine())
+		lexer.nowToken = Token{
+			Valid: true,
+			Loc:   Location{Start: lastLoc.End, End: lastLoc.End},
+		}
+		parser.nowToken = lexer.nowToken
+		parser.parseErrs = append(parser.parseErrs, r.ErrList[:r.statErrCounts[prefixNum]]...)
+		parser.statErrCounts = append(parser.statErrCounts, r.statErrCounts[:prefixNum]...)
+		for line, block := range r.CommentMap {
+			if line <= lastLoc.End.GetLine() {
+				commentMap[line] = block
+			}
+		}
+	}
+	parser.l = lexer
+
+	parser.aheadToken = lexer.NextToken()
+	var block = parser.parseChunk(stats[:prefixNum], reuse)
+
+	if reuse.reusedIdx >= 0 {
+		// 复用的语句之后的注释也复用，注释块不会跨过单词
+		for line, block := range r.CommentMap {
+			if !block.List[0].StartPos.Before(reuse.reusedPos) {
+				for i := range block.List {
+					block.List[i].StartPos.Line += reuse.lineDelta
+					block.List[i].EndPos.Line += reuse.lineDelta
+				}
+				commentMap[line+int(reuse.lineDelta)] = block
+			}
+		}
+	}
+
+	var result = &ParseResult{
+		Source:        source,
+		Version:       r.Version,
+		Block:         block,
+		CommentMap:    commentMap,
+		ErrList:       parser.parseErrs,
+		statErrCounts: parser.statErrCounts,
+	}
+	checkReparse(result)
+	return result
+}
+
+// findSafePrefix 查找修改位置之前可以复用的顶层语句数量。要求：
+//  1. 最后一个复用语句后面的单词在修改的行之前，保证复用语句的解析不受影响
+//  2. 最后一个复用语句所在行的剩余部分只有空白或者短注释，下一行的开头是安全的
+//  3. 复用语句解析过程中报告的错误都在这之前
+func (r *ParseResult) findSafePrefix(editLine int32) int {
+	var stats = r.Block.Stats
+	var idx = sort.Search(len(stats), func(i int) bool {
+		return stats[i].GetLoc().Start.Line >= editLine
+	})
+	// stats[k] 是最后一个复用的语句，stats[k+1] 要在修改的行之前
+	for k := idx - 2; k >= 0; k-- {
+		var endPos = stats[k].GetLoc().End
+		if stats[k+1].GetLoc().Start.Line <= endPos.Line {
+			continue
+		}
+		var rest = strings.TrimLeft(r.Source.GetOneLine(endPos.GetLine())[endPos.Column:], " \t\v\f\r")
+		if rest != "" && (!strings.HasPrefix(rest, "--") || isLongBracketStart(rest[2:])) {
+			continue
+		}
+		var safe = true
+		for _, oneErr := range r.ErrList[:r.statErrCounts[k+1]] {
+			if oneErr.Loc.Start.Line > endPos.Line {
+				safe = false
+				break
+			}
+		}
+		if safe {
+			return k + 1
+		}
+	}
+	return 0
+}
+
+// isLongBracketStart 是否是 [=*[ 开头
+func isLongBracketStart(str string) bool {
+	if !strings.HasPrefix(str, "[") {
+		return false
+	}
+	str = strings.TrimLeft(str[1:], "=")
+	return strings.HasPrefix(str, "[")
+}
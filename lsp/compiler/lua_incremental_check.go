@@ -0,0 +1,7 @@
+//go:build !debug
+
+package compiler
+
+// checkReparse debug 版本下校验增量解析的结果
+func checkReparse(result *ParseResult) {
+}
@@ -0,0 +1,18 @@
+//go:build debug
+
+package compiler
+
+import (
+	"reflect"
+)
+
+// checkReparse 增量解析的结果必须和完整解析的一致
+func checkReparse(result *ParseResult) {
+	var full = ParseLuaFile(result.Source, result.Version)
+	if !reflect.DeepEqual(full.Block, result.Block) ||
+		!reflect.DeepEqual(full.ErrList, result.ErrList) ||
+		!reflect.DeepEqual(full.CommentMap, result.CommentMap) ||
+		!reflect.DeepEqual(full.statErrCounts, result.statErrCounts) {
+		panic("incremental reparse differs from full parse")
+	}
+}
@@ -0,0 +1,184 @@
+package compiler
+
+import (
+	"math/rand"
+	"mylua-lsp/lsp/ast"
+	"mylua-lsp/lsp/common"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// reparseSnippets 随机生成文件用的片段，包括跨行的长字符串、长注释和语法错误
+var reparseSnippets = []string{
+	"local a = 1\n",
+	"function f(x)\n  return x + 1\nend\n",
+	"-- comment\n",
+	"--[[ long\ncomment ]]\n",
+	"x = [[\nstr\n]]\n",
+	"if a then\n b()\nelseif c then\nelse\nend\n",
+	"t = {1, 2, k = 3}\n",
+	"f\n(g)\n",
+	"a.b.c = 'str'\n",
+	"for i = 1, 10 do print(i) end\n",
+	"while true do break end\n",
+	"\n",
+	"; ;\n",
+	"local s = \"x\\\ny\"\n",
+	"return\n",
+	"goto l\n::l::\n",
+	"repeat until x\n",
+	"do end --[==[ x\n]==] y = 1\n",
+	"---@class A\n---@field x number\nlocal A = {}\n",
+	"obj:method(1, 2)\n",
+}
+
+// reparseEdits 随机插入的文本，大多会破坏或者修复语法
+var reparseEdits = []string{
+	"", "\n", "end", "--[[", "]]", "\"", "'", "(", ")", "function", "local ", "x", " = ", "1", "--",
+	"\n\n", "[[", "{", "}", "\\", "do ", "if x then ", ";", "return", ".", ":", ",", "\r\n",
+}
+
+var reparseVersions = []common.LuaVersion{
+	common.LuaVersion51, common.LuaVersion52, common.LuaVersion53, common.LuaVersion54, common.LuaVersionJIT,
+}
+
+func randomSource(r *rand.Rand) string {
+	var sb strings.Builder
+	for n := r.Intn(30); n > 0; n-- {
+		sb.WriteString(reparseSnippets[r.Intn(len(reparseSnippets))])
+	}
+	return sb.String()
+}
+
+// randomEdit 随机的修改范围，大部分是插入，有时跨几行
+func randomEdit(r *rand.Rand, source *common.LuaSource) (Location, string) {
+	var lineNum = max(source.GetLineNum(), 1)
+	var startLine = r.Intn(lineNum)
+	var startColumn = r.Intn(source.GetOneLineLength(startLine) + 1)
+	var endLine, endColumn = startLine, startColumn
+	if r.Intn(3) == 0 {
+		endLine = min(startLine+r.Intn(3), lineNum-1)
+		endColumn = r.Intn(source.GetOneLineLength(endLine) + 1)
+		if endLine == startLine && endColumn < startColumn {
+			endColumn = startColumn
+		}
+	}
+	var text = reparseEdits[r.Intn(len(reparseEdits))]
+	if r.Intn(4) == 0 {
+		text = reparseSnippets[r.Intn(len(reparseSnippets))]
+	}
+	var loc = Location{
+		Start: Position{Line: int32(startLine), Column: int32(startColumn)},
+		End:   Position{Line: int32(endLine), Column: int32(endColumn)},
+	}
+	return loc, text
+}
+
+// checkSameParse 增量解析的结果要和完整解析的完全一致
+func checkSameParse(t *testing.T, full, inc *ParseResult) {
+	t.Helper()
+	if !reflect.DeepEqual(full.Block, inc.Block) {
+		t.Error("Block differs")
+	}
+	if !reflect.DeepEqual(full.ErrList, inc.ErrList) {
+		t.Errorf("ErrList differs\nfull: %v\ninc:  %v", full.ErrList, inc.ErrList)
+	}
+	if !reflect.DeepEqual(full.CommentMap, inc.CommentMap) {
+		t.Error("CommentMap differs")
+	}
+	if !reflect.DeepEqual(full.statErrCounts, inc.statErrCounts) {
+		t.Errorf("statErrCounts differs\nfull: %v\ninc:  %v", full.statErrCounts, inc.statErrCounts)
+	}
+}
+
+// parentChecker 检查每个节点的父节点
+type parentChecker struct {
+	t     *testing.T
+	stack []ast.Stat
+}
+
+func (c *parentChecker) Visit(node ast.Stat) ast.Visitor {
+	if node == nil {
+		c.stack = c.stack[:len(c.stack)-1]
+		return nil
+	}
+	if parent := c.stack[len(c.stack)-1]; node.GetParent() != parent {
+		c.t.Errorf("%T at %v has parent %T, want %T", node, node.GetLoc(), node.GetParent(), parent)
+	}
+	c.stack = append(c.stack, node)
+	return c
+}
+
+func checkParents(t *testing.T, block *ast.Block) {
+	t.Helper()
+	ast.Walk(&parentChecker{t: t, stack: []ast.Stat{nil}}, block)
+}
+
+// 随机的文件上连续做随机的修改，每次增量解析都和完整解析比较
+func TestReparseRandomEdits(t *testing.T) {
+	var rounds = 1000
+	if testing.Short() {
+		rounds = 100
+	}
+	var r = rand.New(rand.NewSource(1))
+	for round := 0; round < rounds; round++ {
+		var version = reparseVersions[r.Intn(len(reparseVersions))]
+		var code = randomSource(r)
+		var result = ParseLuaFile(common.NewLuaSource([]byte(code), "a.lua"), version)
+		for edit := 0; edit < 20; edit++ {
+			var source = result.Source
+			var loc, text = randomEdit(r, source)
+			var full = ParseLuaFile(source.ApplyChange(loc, text), version)
+			var inc = result.Reparse(loc, text)
+			checkSameParse(t, full, inc)
+			checkParents(t, inc.Block)
+			if t.Failed() {
+				t.Fatalf("%v round %d edit %d: replace %v with %q in\n%s", version, round, edit, loc, text, code)
+			}
+			result = inc
+			code = inc.Source.GetRawText(Location{End: Position{Line: int32(inc.Source.GetLineNum())}})
+		}
+	}
+}
+
+// 修改中间的语句时，前后没有受影响的语句直接复用
+func TestReparseReuse(t *testing.T) {
+	var code = "local a = 1\n\nfunction f()\n  return a\nend\n\nlocal b = 2\n"
+	var result = ParseLuaFile(common.NewLuaSource([]byte(code), "a.lua"), common.LuaVersion54)
+	var old = append([]ast.Stat(nil), result.Block.Stats...)
+	var loc = Location{Start: Position{Line: 3, Column: 9}, End: Position{Line: 3, Column: 10}}
+	var inc = result.Reparse(loc, "a + 1\n  -- more")
+	checkSameParse(t, ParseLuaFile(inc.Source, inc.Version), inc)
+	checkParents(t, inc.Block)
+
+	var stats = inc.Block.Stats
+	if len(stats) != 3 {
+		t.Fatalf("got %d statements, want 3", len(stats))
+	}
+	if stats[0] != old[0] {
+		t.Error("statement before the edit is not reused")
+	}
+	if stats[1] == old[1] {
+		t.Error("edited statement is reused")
+	}
+	if stats[2] != old[2] {
+		t.Error("statement after the edit is not reused")
+	}
+	if line := stats[2].GetLoc().Start.Line; line != 7 {
+		t.Errorf("statement after the edit starts at line %d, want 7", line)
+	}
+}
+
+// 增量解析会修改旧的语法树，同一个结果不能解析两次
+func TestReparseTwice(t *testing.T) {
+	var result = ParseLuaFile(common.NewLuaSource([]byte("local a = 1\nlocal b = 2\n"), "a.lua"), common.LuaVersion54)
+	var loc = Location{Start: Position{Line: 0, Column: 0}, End: Position{Line: 0, Column: 0}}
+	result.Reparse(loc, "\n")
+	defer func() {
+		if recover() == nil {
+			t.Error("second Reparse on the same result does not panic")
+		}
+	}()
+	result.Reparse(loc, "\n")
+}
@@ -37,13 +37,19 @@ type Lexer struct {
 
 	commentMap LuaCommentMap // 收集注释块信息，key时每块的最后一行。
 
+	version common.LuaVersion // 使用的 lua 版本，决定支持哪些语法
+
+	keepTokens bool    // 无损模式，记录所有的单词
+	tokens     []Token // keepTokens 时记录的所有单词
+
 	errHandler ErrorHandler // error reporting; or nil
 }
 
 // NewLexer 创建一个词法分析器
-func NewLexer(source *common.LuaSource, errHandler ErrorHandler) *Lexer {
+func NewLexer(source *common.LuaSource, version common.LuaVersion, errHandler ErrorHandler) *Lexer {
 	var lex = &Lexer{
 		source:   source,
+		version:  version,
 		cur_line: source.GetOneLine(0),
 		nextPos:  Position{Line: 0, Column: 0},
 		nowToken: Token{
@@ -132,11 +138,15 @@ func (l *Lexer) getFileEndLoc() Location {
 // NextToken 下一个单词
 func (l *Lexer) NextToken() Token {
 	l.nextTokenStruct()
+	if l.keepTokens {
+		l.tokens = append(l.tokens, l.nowToken)
+	}
 	return l.nowToken
 }
 
 // setNowToken 设置当前的单词
 func (l *Lexer) setNowToken(kind TkKind, tokenStr string) {
+	l.nowToken.Valid = true
 	l.nowToken.Loc.Start = l.tokenStartPos
 	l.nowToken.Loc.End = l.nextPos
 	l.nowToken.TokenKind = kind
@@ -193,9 +203,11 @@ func (l *Lexer) nextTokenStruct() {
 		return
 	case '&':
 		l.setNowToken(ast.TkOpBand, "&")
+		l.checkBitwiseOp()
 		return
 	case '|':
 		l.setNowToken(ast.TkOpBor, "|")
+		l.checkBitwiseOp()
 		return
 	case '#':
 		l.setNowToken(ast.TkOpNen, "#")
@@ -210,6 +222,9 @@ func (l *Lexer) nextTokenStruct() {
 	case '/':
 		if l.test_then_next('/') {
 			l.setNowToken(ast.TkOpIdiv, "//")
+			if !l.version.SupportIntDiv() {
+				l.errorPrint(l.nowToken.Loc, "integer division '//' is not supported in %s", l.version)
+			}
 		} else {
 			l.setNowToken(ast.TkOpDiv, "/")
 		}
@@ -219,6 +234,7 @@ func (l *Lexer) nextTokenStruct() {
 			l.setNowToken(ast.TkOpNe, "~=")
 		} else {
 			l.setNowToken(ast.TkOpWave, "~")
+			l.checkBitwiseOp()
 		}
 		return
 	case '=':
@@ -231,6 +247,7 @@ func (l *Lexer) nextTokenStruct() {
 	case '<':
 		if l.test_then_next('<') {
 			l.setNowToken(ast.TkOpShl, "<<")
+			l.checkBitwiseOp()
 		} else if l.test_then_next('=') {
 			l.setNowToken(ast.TkOpLe, "<=")
 		} else {
@@ -240,6 +257,7 @@ func (l *Lexer) nextTokenStruct() {
 	case '>':
 		if l.test_then_next('>') {
 			l.setNowToken(ast.TkOpShr, ">>")
+			l.checkBitwiseOp()
 		} else if l.test_then_next('=') {
 			l.setNowToken(ast.TkOpGe, ">=")
 		} else {
@@ -253,9 +271,12 @@ func (l *Lexer) nextTokenStruct() {
 			} else {
 				l.setNowToken(ast.TkOpConcat, "..")
 			}
-		} else {
+			return
+		} else if !common.IsDigit(l.lookChar()) {
 			l.setNowToken(ast.TkSepDot, ".")
+			return
 		}
+		// .5 这样的数字，下面处理
 	case '[':
 		if l.test2('[', '=') {
 			l.setNowToken(ast.TkString, l.scanLongString(0))
@@ -276,7 +297,7 @@ func (l *Lexer) nextTokenStruct() {
 
 	if c == '_' || common.IsLetterChar(c) {
 		token := l.scanIdentifier()
-		if kind, ok := ast.Keywords[token]; ok {
+		if kind, ok := ast.Keywords[token]; ok && l.isKeyword(kind) {
 			l.setNowToken(kind, token)
 		} else {
 			l.setNowToken(ast.TkIdentifier, token)
@@ -289,6 +310,21 @@ func (l *Lexer) nextTokenStruct() {
 	l.errorPrint(l.nowToken.Loc, "unexpected token:%s", illegalStr)
 }
 
+// checkBitwiseOp 位运算符只有 5.3 之后才有
+func (l *Lexer) checkBitwiseOp() {
+	if !l.version.SupportBitwiseOp() {
+		l.errorPrint(l.nowToken.Loc, "bitwise operator '%s' is not supported in %s", l.nowToken.TokenStr, l.version)
+	}
+}
+
+// isKeyword 5.1 里 goto 只是普通的名字
+func (l *Lexer) isKeyword(kind TkKind) bool {
+	if kind == ast.TkKwGoto {
+		return l.version.SupportGoto()
+	}
+	return true
+}
+
 func (l *Lexer) scanIllegalToken() string {
 	for !l.isEndOfLine() {
 		ch := l.lookChar()
@@ -354,7 +390,8 @@ func (l *Lexer) skipWhiteSpaces() {
 		}
 	}
 	for !l.isEndOfFile() {
-		if l.test_then_next('\n') {
+		if l.isEndOfLine() {
+			l.next_line()
 			continue
 		} else if isWhiteSpace(l.lookChar()) {
 			l.next()
@@ -375,6 +412,10 @@ func (l *Lexer) skipWhiteSpaces() {
 
 		startPos := l.nextPos
 		shortFlag, skipComment := l.skipComment()
+		endPos := l.nextPos
+		if shortFlag {
+			endPos = Position{Line: startPos.Line, Column: startPos.Column + int32(len(skipComment))}
+		}
 
 		if !shortFlag {
 			// 长注释，需要满足要类似 单行注释的要求。
@@ -405,6 +446,7 @@ func (l *Lexer) skipWhiteSpaces() {
 		commentInfo.List = append(commentInfo.List, ast.CommentLine{
 			Str:       skipComment,
 			StartPos:  startPos,
+			EndPos:    endPos,
 			ShortFlag: shortFlag,
 			HeadFlag:  headFlag,
 		})
@@ -435,9 +477,9 @@ func (l *Lexer) skipComment() (shortFlag bool, strComment string) {
 			return
 		}
 	}
-	l.next_line()
 	shortFlag = true
 	strComment = l.cur_line[start_pos.Column:]
+	l.next_line()
 	return
 }
 
@@ -455,46 +497,40 @@ func (l *Lexer) scanIdentifier() string {
 	return str
 }
 
-// scanNumber 扫描数字
+// scanNumber 扫描数字。参考 lua 的 read_numeral，尽量多的读取，后续解析时再检查格式。
 func (l *Lexer) scanNumber() string {
 	l.backOneChar()
 	start_idx := l.nextPos.Column
 	// l.test_then_next('-') // 这个可以优化来着，目前是把 - 当成了单目运算符了
 
-	if l.test_then_next('0') { // 特别的数字模式，现在只支持 0x
-		if l.test2('x', 'X') { // 0x
+	var expo1, expo2 byte = 'e', 'E'
+	var isHex = false
+	if l.test_then_next('0') && l.test2('x', 'X') { // 0x
+		l.next()
+		expo1, expo2 = 'p', 'P'
+		isHex = true
+	}
+	for {
+		if l.test2(expo1, expo2) { // 指数部分可以有符号
 			l.next()
-			l.next_until(common.IsHexChar, false)
-			if l.test_then_next('.') {
-				l.next_until(common.IsHexChar, false)
-			}
-			if l.test2('p', 'P') {
+			if l.test2('+', '-') {
 				l.next()
-				if l.test2('+', '-') {
-					l.next()
-					l.next_until(common.IsDigit, false)
-				}
 			}
-			goto finish_all
-		}
-	}
-	// 普通的数字。lua 没有对 011 这种做8进制支持。
-	l.next_until(common.IsDigit, false)
-	if l.test_then_next('.') {
-		l.next_until(common.IsDigit, false)
-	}
-	if l.test2('e', 'E') {
-		l.next()
-		if l.test2('+', '-') {
+		} else if common.IsHexChar(l.lookChar()) || l.test1('.') {
 			l.next()
-			l.next_until(common.IsDigit, false)
+		} else {
+			break
 		}
 	}
-
-finish_all:
+	// 紧跟着的字母也当成数字的一部分，后续报错。LuaJIT 的 ULL LL i 后缀也是这么进来的
+	l.next_until(common.IsNameChar, false)
 
 	// 切分出来的字符串
 	str := l.cur_line[start_idx:l.nextPos.Column]
+	if isHex && !l.version.SupportHexFloat() && strings.ContainsAny(str, ".pP") {
+		l.errorPrint(Location{Start: l.tokenStartPos, End: l.nextPos},
+			"hexadecimal float '%s' is not supported in %s", str, l.version)
+	}
 	return str
 }
 
@@ -510,6 +546,10 @@ func (l *Lexer) scanLongString(count int) string {
 		}, "invalid long string delimiter")
 		return ""
 	}
+	// 紧跟着 [=*[ 的第一个换行符不算在内容里
+	if l.isEndOfLine() && l.nextPos.GetLine()+1 < l.source.GetLineNum() {
+		l.next_line()
+	}
 	var start_idx = l.nextPos.Column
 	var builder strings.Builder
 	// 往后找 ]=*]
@@ -561,75 +601,197 @@ func (l *Lexer) matchLongStringBacket() int {
 	return -count
 }
 
-// 短字符串，词法分析时处理少量转义字符。主要是 行尾的 \ \z
+// scanShortString 扫描短字符串，返回转义之后的实际值。之前已经吃掉开头的引号
 func (l *Lexer) scanShortString(delimiter byte) string {
 	var builder strings.Builder
-
-	start_idx := l.nextPos.GetColumn()
 	for {
-		var end_idx = start_idx
-		var line_len = len(l.cur_line)
-		for ; end_idx < line_len; end_idx++ {
-			c := l.cur_line[end_idx]
-			if c == delimiter {
-				builder.WriteString(l.cur_line[start_idx:end_idx])
-				l.nextPos.Column = int32(end_idx + 1)
-				goto finish // 正确结束
+		if l.isEndOfLine() {
+			// 短字符串不能直接换行，停在行尾，后续的词法分析从下一行继续
+			l.errorPrint(Location{Start: l.tokenStartPos, End: l.nextPos}, "unfinished string, missing %c", delimiter)
+			return builder.String()
+		}
+		var c = l.lookChar()
+		if c == delimiter {
+			l.next()
+			return builder.String()
+		}
+		if c == '\\' {
+			l.scanEscape(&builder)
+			continue
+		}
+		// 普通字符，一次写入到下一个特殊字符为止
+		var start_idx = l.nextPos.GetColumn()
+		l.next_until(func(ch byte) bool { return ch == delimiter || ch == '\\' }, true)
+		builder.WriteString(l.cur_line[start_idx:l.nextPos.GetColumn()])
+	}
+}
+
+// scanEscape 解析一个转义序列，结果写入 builder。当前字符是 \
+func (l *Lexer) scanEscape(builder *strings.Builder) {
+	var escStart = l.nextPos
+	l.next() // skip \
+	if l.isEndOfLine() {
+		// 行尾的 \，值是换行符
+		builder.WriteByte('\n')
+		l.next_line()
+		return
+	}
+	var c = l.lookChar()
+	l.next()
+	switch c {
+	case 'a':
+		builder.WriteByte('\a')
+	case 'b':
+		builder.WriteByte('\b')
+	case 'f':
+		builder.WriteByte('\f')
+	case 'n':
+		builder.WriteByte('\n')
+	case 'r':
+		builder.WriteByte('\r')
+	case 't':
+		builder.WriteByte('\t')
+	case 'v':
+		builder.WriteByte('\v')
+	case '\\', '"', '\'':
+		builder.WriteByte(c)
+	case 'x': // \xXX
+		l.checkEscapeVersion(escStart, c, l.version.SupportHexEscape())
+		var val = 0
+		for i := 0; i < 2; i++ {
+			if !common.IsHexChar(l.lookChar()) {
+				l.escapeError(escStart, "hexadecimal digit expected", true)
+				return
 			}
-			if c == '\\' {
-				if end_idx == line_len-1 {
-					// 行尾的 \
-					builder.WriteString(l.cur_line[start_idx:end_idx])
-					l.next_line()
-					if l.isEndOfFile() {
-						l.errorPrint(l.getFileEndLoc(), "unfinished string, expect %c but got \\", delimiter)
-						goto finish
-					}
-					start_idx = 0
-					goto next_line
-				} else {
-					if (end_idx+1 == line_len-1) && l.cur_line[end_idx+1] == 'z' {
-						// 行尾的 \z。吃掉空白符直到遇到非空白符
-						builder.WriteString(l.cur_line[start_idx:end_idx])
-						var find_idx int
-						for {
-							l.next_line()
-							if l.isEndOfFile() {
-								l.errorPrint(l.getFileEndLoc(), "unfinished string, missing %c", delimiter)
-								goto finish
-							}
-							for find_idx = 0; find_idx < len(l.cur_line); find_idx++ {
-								c1 := l.cur_line[find_idx]
-								if isWhiteSpace(c1) == false {
-									goto has_find
-								}
-							}
-						}
-					has_find:
-						start_idx = find_idx
-						goto next_line
-					} else {
-						// 遇到其他转义了。之前往前多吃掉一个字符
-						end_idx++
-					}
-				}
+			val = val*16 + hexValue(l.lookChar())
+			l.next()
+		}
+		builder.WriteByte(byte(val))
+	case 'z': // \z 跳过之后的空白字符，包括换行
+		l.checkEscapeVersion(escStart, c, l.version.SupportHexEscape())
+		for !l.isEndOfFile() {
+			if l.isEndOfLine() {
+				l.next_line()
+			} else if isWhiteSpace(l.lookChar()) {
+				l.next()
+			} else {
+				break
 			}
 		}
-		builder.WriteString(l.cur_line[start_idx:])
-		l.errorPrint(l.getLineEndLoc(l.nextPos.GetLine()), "unfinished string, missing %c", delimiter)
-		l.next_line() // 不管如何进入下一行
-		break
-	next_line:
-		continue
-	finish:
-		break
+	case 'u': // \u{XXX}
+		l.checkEscapeVersion(escStart, c, l.version.SupportUtf8Escape())
+		l.scanUtf8Escape(escStart, builder)
+	default:
+		if !common.IsDigit(c) {
+			l.escapeError(escStart, "invalid escape sequence", false)
+			builder.WriteByte(c)
+			return
+		}
+		// \ddd 最多3个10进制数字
+		var val = int(c - '0')
+		for i := 0; i < 2 && common.IsDigit(l.lookChar()); i++ {
+			val = val*10 + int(l.lookChar()-'0')
+			l.next()
+		}
+		if val > 255 {
+			l.escapeError(escStart, "decimal escape too large", false)
+			return
+		}
+		builder.WriteByte(byte(val))
+	}
+}
+
+// scanUtf8Escape 解析 \u{XXX}，已经吃掉 \u
+func (l *Lexer) scanUtf8Escape(escStart Position, builder *strings.Builder) {
+	if !l.test_then_next('{') {
+		l.escapeError(escStart, "missing '{' in \\u{xxxx}", true)
+		return
+	}
+	if !common.IsHexChar(l.lookChar()) {
+		l.escapeError(escStart, "hexadecimal digit expected", true)
+		return
+	}
+	var maxVal uint64 = 0x7FFFFFFF
+	if l.version != common.LuaVersion54 {
+		maxVal = 0x10FFFF
+	}
+	var val uint64 = 0
+	var tooLarge = false
+	for common.IsHexChar(l.lookChar()) {
+		val = val*16 + uint64(hexValue(l.lookChar()))
+		if val > maxVal {
+			tooLarge = true
+			val = maxVal
+		}
+		l.next()
+	}
+	if tooLarge {
+		l.escapeError(escStart, "UTF-8 value too large", false)
+	}
+	if !l.test_then_next('}') {
+		l.escapeError(escStart, "missing '}' in \\u{xxxx}", true)
+		return
+	}
+	if !tooLarge {
+		builder.WriteString(utf8Escape(uint32(val)))
+	}
+}
+
+// checkEscapeVersion 部分转义字符是新版本才支持的
+func (l *Lexer) checkEscapeVersion(escStart Position, c byte, support bool) {
+	if !support {
+		l.errorPrint(Location{Start: escStart, End: l.nextPos}, "escape sequence '\\%c' is not supported in %s", c, l.version)
+	}
+}
+
+// escapeError 报告转义字符的错误，位置是从 \ 到已经读取的字符。withNext 表示把下一个出错的字符也包括进来
+func (l *Lexer) escapeError(escStart Position, msg string, withNext bool) {
+	var end = l.nextPos
+	if withNext && !l.isEndOfLine() && end.Line == escStart.Line {
+		end.Column++
+	}
+	var near = ""
+	if end.Line == escStart.Line {
+		near = l.source.GetOneLine(escStart.GetLine())[escStart.Column:end.Column]
+	}
+	l.errorPrint(Location{Start: escStart, End: end}, "%s near '%s'", msg, near)
+}
+
+// utf8Escape 按照 lua 的 luaO_utf8esc 编码，支持到 0x7FFFFFFF
+func utf8Escape(x uint32) string {
+	if x < 0x80 {
+		return string([]byte{byte(x)})
+	}
+	var buf [8]byte
+	var n = 7
+	var mfb uint32 = 0x3f // 首字节能容纳的最大值
+	for {
+		buf[n] = byte(0x80 | (x & 0x3f))
+		n--
+		x >>= 6
+		mfb >>= 1
+		if x <= mfb {
+			break
+		}
+	}
+	buf[n] = byte((^mfb << 1) | x)
+	return string(buf[n:])
+}
+
+func hexValue(c byte) int {
+	switch {
+	case common.IsDigit(c):
+		return int(c - '0')
+	case c >= 'a' && c <= 'f':
+		return int(c-'a') + 10
+	default:
+		return int(c-'A') + 10
 	}
-	return builder.String()
 }
 
 func isWhiteSpace(c byte) bool {
 	switch c {
-	case '\t', '\v', '\f', ' ':
+	case '\t', '\v', '\f', ' ', '\r':
 		return true
 	}
 	return false
@@ -0,0 +1,137 @@
+package compiler
+
+import (
+	"mylua-lsp/lsp/ast"
+	"mylua-lsp/lsp/common"
+	"testing"
+)
+
+// parseErrors 解析 code ，返回所有错误的文本
+func parseErrors(code string, version common.LuaVersion) []string {
+	var _, _, errList = ParseLuaSource(common.NewLuaSource([]byte(code), "a.lua"), version)
+	var list []string
+	for _, oneErr := range errList {
+		list = append(list, oneErr.ErrStr)
+	}
+	return list
+}
+
+// firstString 第一个语句 local s = "..." 里的字符串
+func firstString(t *testing.T, code string, version common.LuaVersion) (*ast.StringExp, []ParseError) {
+	t.Helper()
+	var block, _, errList = ParseLuaSource(common.NewLuaSource([]byte(code), "a.lua"), version)
+	if len(block.Stats) == 0 {
+		t.Fatalf("no statement in %q", code)
+	}
+	var stat, ok = block.Stats[0].(*ast.LocalVarDeclStat)
+	if !ok || len(stat.ExpList) == 0 {
+		t.Fatalf("%q is not a local declaration", code)
+	}
+	str, ok := stat.ExpList[0].(*ast.StringExp)
+	if !ok {
+		t.Fatalf("%q does not declare a string", code)
+	}
+	return str, errList
+}
+
+func TestVersionFeatures(t *testing.T) {
+	var tests = []struct {
+		code    string
+		version common.LuaVersion
+		want    []string
+	}{
+		{"local a = 1 // 2", common.LuaVersion51, []string{"integer division '//' is not supported in Lua 5.1"}},
+		{"local a = 1 // 2", common.LuaVersion52, []string{"integer division '//' is not supported in Lua 5.2"}},
+		{"local a = 1 // 2 & 3 | ~4 << 1 >> 2", common.LuaVersion53, nil},
+		{"local a = 1 & 2 | ~3", common.LuaVersion52, []string{
+			"bitwise operator '&' is not supported in Lua 5.2",
+			"bitwise operator '|' is not supported in Lua 5.2",
+			"bitwise operator '~' is not supported in Lua 5.2",
+		}},
+		{"local a = 1 << 2", common.LuaVersionJIT, []string{"bitwise operator '<<' is not supported in LuaJIT"}},
+		{"::top:: goto top", common.LuaVersion51, []string{"label is not supported in Lua 5.1", "expected =, found 'identifier'"}},
+		{"::top:: goto top", common.LuaVersion52, nil},
+		{"::top:: goto top", common.LuaVersionJIT, nil},
+		{"goto = 1", common.LuaVersion51, nil},
+		{"local x <const> = 1", common.LuaVersion53, []string{"local variable attribute is not supported in Lua 5.3"}},
+		{"local x <const>, y <close> = 1, nil", common.LuaVersion54, nil},
+		{"local x <foo> = nil", common.LuaVersion54, []string{"unknown local attribute 'foo'"}},
+		{"local x = 0x10ULL + 1LL + 2ull + 2i + 0x1p4 + 1e5", common.LuaVersionJIT, nil},
+		{"local x = 0x10ULL + 1LL", common.LuaVersion54, []string{"malformed number near '0x10ULL'", "malformed number near '1LL'"}},
+		{"local x = 2i", common.LuaVersion51, []string{"malformed number near '2i'"}},
+	}
+	for _, tt := range tests {
+		var got = parseErrors(tt.code, tt.version)
+		if len(got) != len(tt.want) {
+			t.Errorf("%v %q: got errors %q, want %q", tt.version, tt.code, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%v %q: got error %q, want %q", tt.version, tt.code, got[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestStringEscapes(t *testing.T) {
+	var tests = []struct {
+		code string
+		want string
+	}{
+		{`local s = "a\n\t\\\"\'b"`, "a\n\t\\\"'b"},
+		{`local s = 'bell\a\b\f\r\v'`, "bell\a\b\f\r\v"},
+		{`local s = "\65\066\0670"`, "ABC0"},
+		{`local s = "\x41\x6a"`, "Aj"},
+		{`local s = "\u{48}\u{4E2D}\u{7FFFFFFF}"`, "H中\xFD\xBF\xBF\xBF\xBF\xBF"},
+		{"local s = \"line\\\nnext\"", "line\nnext"},
+		{"local s = \"skip\\z  \n   spaces\"", "skipspaces"},
+		{"local s = [[\nlong \\n]]", "long \\n"},
+	}
+	for _, tt := range tests {
+		var str, errList = firstString(t, tt.code, common.LuaVersion54)
+		if len(errList) > 0 {
+			t.Errorf("%q: unexpected error %s", tt.code, errList[0].ErrStr)
+		}
+		if str.Str != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.code, str.Str, tt.want)
+		}
+		if want := tt.code[len("local s = "):]; str.RawStr != want {
+			t.Errorf("%q: raw text %q, want %q", tt.code, str.RawStr, want)
+		}
+	}
+}
+
+func TestStringEscapeErrors(t *testing.T) {
+	var tests = []struct {
+		code    string
+		version common.LuaVersion
+		want    string
+		start   int32 // 错误开始的列
+		end     int32
+	}{
+		{`local s = "\q"`, common.LuaVersion54, `invalid escape sequence near '\q'`, 11, 13},
+		{`local s = "a\400"`, common.LuaVersion54, `decimal escape too large near '\400'`, 12, 16},
+		{`local s = "\u{}"`, common.LuaVersion54, `hexadecimal digit expected near '\u{}'`, 11, 15},
+		{`local s = "\u{110000000}"`, common.LuaVersion54, `UTF-8 value too large near '\u{110000000'`, 11, 23},
+		{`local s = "\x4"`, common.LuaVersion54, `hexadecimal digit expected near '\x4"'`, 11, 15},
+		{`local s = "\x41"`, common.LuaVersion51, `escape sequence '\x' is not supported in Lua 5.1`, 11, 13},
+		{`local s = "\u{41}"`, common.LuaVersion52, `escape sequence '\u' is not supported in Lua 5.2`, 11, 13},
+		{`local s = 'abc`, common.LuaVersion54, `unfinished string, missing '`, 10, 14},
+	}
+	for _, tt := range tests {
+		var _, errList = firstString(t, tt.code, tt.version)
+		if len(errList) != 1 {
+			t.Errorf("%v %q: got %d errors, want 1", tt.version, tt.code, len(errList))
+			continue
+		}
+		var oneErr = errList[0]
+		if oneErr.ErrStr != tt.want {
+			t.Errorf("%v %q: got error %q, want %q", tt.version, tt.code, oneErr.ErrStr, tt.want)
+		}
+		if oneErr.Loc.Start.Column != tt.start || oneErr.Loc.End.Column != tt.end {
+			t.Errorf("%v %q: error at columns %d-%d, want %d-%d", tt.version, tt.code,
+				oneErr.Loc.Start.Column, oneErr.Loc.End.Column, tt.start, tt.end)
+		}
+	}
+}
@@ -73,7 +73,7 @@ func getPriority(tokenKind ast.TkKind) int {
 func (p *Parser) parseSubExp(limit int) ast.Exp {
 	var exp ast.Exp
 	tokenKind := p.LookAheadKind()
-	start_loc := p.aheadToken.Loc
+	start_loc := p.LookAheadToken().Loc
 	// 单目运算符： not | # | - | ~
 	if tokenKind == ast.TkOpNen || tokenKind == ast.TkOpNot || tokenKind == ast.TkOpSub || tokenKind == ast.TkOpBnot {
 		p.NextToken()
@@ -85,7 +85,9 @@ func (p *Parser) parseSubExp(limit int) ast.Exp {
 	} else {
 		exp = p.parseExp0()
 	}
-	exp.SetLoc(common.GetRangeLoc(start_loc, p.nowToken.Loc))
+	if start_loc.Start.Before(p.nowToken.Loc.End) {
+		exp.SetLoc(common.GetRangeLoc(start_loc, p.nowToken.Loc))
+	}
 
 	tokenKind = p.LookAheadKind()
 	nowPriority := getPriority(tokenKind)
@@ -129,10 +131,7 @@ func (p *Parser) parseExp0() ast.Exp {
 		p.NextToken()
 		return &ast.FalseExp{}
 	case ast.TkString: // LiteralString
-		p.NextToken()
-		return &ast.StringExp{
-			Str: p.nowToken.TokenStr,
-		}
+		return p.parseStringExp()
 	case ast.TkNumber: // Numeral
 		return p.parseNumberExp()
 	case ast.TkSepLcurly: // tableconstructor
@@ -149,6 +148,18 @@ func (p *Parser) parseExp0() ast.Exp {
 func (p *Parser) parseNumberExp() ast.Exp {
 	p.NextToken()
 	token := p.nowToken.TokenStr
+	if p.version.IsJIT() {
+		// LuaJIT 的 64 位整数 1LL 0x10ULL 和虚数 1i
+		if i, ok := parseLuajitInteger(token); ok {
+			return &ast.IntegerExp{
+				Val: i,
+			}
+		} else if f, ok := parseLuajitImaginary(token); ok {
+			return &ast.FloatExp{
+				Val: f,
+			}
+		}
+	}
 	if i, ok := parseInteger(token); ok {
 		return &ast.IntegerExp{
 			Val: i,
@@ -158,7 +169,7 @@ func (p *Parser) parseNumberExp() ast.Exp {
 			Val: f,
 		}
 	} else { // todo
-		p.insertParserErr(p.nowToken.Loc, "not a number: %v", token)
+		p.insertParserErr(p.nowToken.Loc, "malformed number near '%s'", token)
 		return &ast.FloatExp{
 			Val: 0,
 		}
@@ -177,7 +188,8 @@ func (p *Parser) parseNameExp() *ast.NameExp {
 func (p *Parser) parseNameExpAsStringExp() *ast.StringExp {
 	p.NextIdentifier()
 	var exp = &ast.StringExp{
-		Str: p.nowToken.TokenStr,
+		Str:    p.nowToken.TokenStr,
+		RawStr: p.nowToken.TokenStr,
 	}
 	exp.SetLoc(p.nowToken.Loc)
 	return exp
@@ -186,7 +198,8 @@ func (p *Parser) parseNameExpAsStringExp() *ast.StringExp {
 func (p *Parser) parseStringExp() *ast.StringExp {
 	p.NextTokenKind(ast.TkString)
 	var exp = &ast.StringExp{
-		Str: p.nowToken.TokenStr,
+		Str:    p.nowToken.TokenStr,
+		RawStr: p.l.source.GetText(p.nowToken.Loc),
 	}
 	exp.SetLoc(p.nowToken.Loc)
 	return exp
@@ -203,7 +216,7 @@ func (p *Parser) _createTableAccessExp(prefixExp ast.Exp, keyExp ast.Exp) *ast.T
 
 // funcbody ::= ‘(’ [parlist] ‘)’ block end
 func (p *Parser) parseFuncBodyExp(func_keyword_loc Location) *ast.FuncDefExp {
-	start_loc := p.nowToken.Loc
+	start_loc := p.LookAheadToken().Loc
 	p.NextTokenKind(ast.TkSepLparen)
 	parList, isVararg := p._parseParList()
 	p.NextTokenKind(ast.TkSepRparen)
@@ -214,7 +227,7 @@ func (p *Parser) parseFuncBodyExp(func_keyword_loc Location) *ast.FuncDefExp {
 		Block:    block,
 		IsVararg: isVararg,
 	}
-	exp.SetLoc(common.GetRangeLoc(start_loc, block.GetLoc()))
+	exp.SetLoc(common.GetRangeLoc(start_loc, p.nowToken.Loc))
 	return exp
 }
 
@@ -247,7 +260,7 @@ func (p *Parser) _parseParList() (parList []ast.Token, isVararg bool) {
 
 // tableconstructor ::= ‘{’ [fieldlist] ‘}’
 func (p *Parser) parseTableConstructorExp() *ast.TableConstructorExp {
-	var start_loc = p.nowToken.Loc
+	var start_loc = p.LookAheadToken().Loc
 	p.NextTokenKind(ast.TkSepLcurly)       // {
 	keyExps, valExps := p.parseFieldList() // [fieldlist]
 	p.NextTokenKind(ast.TkSepRcurly)       // }
@@ -305,7 +318,8 @@ func (p *Parser) parseField() (k, v ast.Exp) {
 			p.NextToken()
 
 			k = &ast.StringExp{
-				Str: nameExp.Name,
+				Str:    nameExp.Name,
+				RawStr: nameExp.Name,
 			}
 			k.SetLoc(nameExp.GetLoc())
 			v = p.parseExp()
@@ -329,7 +343,7 @@ func (p *Parser) parseField() (k, v ast.Exp) {
 //	| prefixexp [‘:’ Name] args
 func (p *Parser) parsePrefixExp() ast.Exp {
 	var exp ast.Exp
-	beginLoc := p.aheadToken.Loc
+	beginLoc := p.LookAheadToken().Loc
 	aheadKind := p.LookAheadKind()
 	switch aheadKind {
 	case ast.TkIdentifier:
@@ -337,30 +351,35 @@ func (p *Parser) parsePrefixExp() ast.Exp {
 	case ast.TkSepLparen: // ‘(’ exp ‘)’
 		exp = p.parseParensExp()
 	default:
-		p.NextToken()
+		// 不读取这个单词，留给外层的错误恢复
+		p.insertParserErr(beginLoc, "`%s` can not start prefixexp", aheadKind.String())
+		var pos = p.nowToken.Loc.End
 		exp = &ast.BadExpr{}
-		exp.SetLoc(p.nowToken.Loc)
-		p.insertParserErr(p.nowToken.Loc, "`%s` can not start prefixexp", aheadKind.String())
+		exp.SetLoc(Location{Start: pos, End: pos})
+		return exp
 	}
 	return p.finishPrefixExp(exp, beginLoc)
 }
 
 func (p *Parser) parseParensExp() ast.Exp {
-	start_loc := p.aheadToken.Loc
+	start_loc := p.LookAheadToken().Loc
 	p.NextTokenKind(ast.TkSepLparen) // (
 	exp := p.parseExp()              // exp
 	p.NextTokenKind(ast.TkSepRparen) // )
 
+	var keepParens = p.lossless // 无损模式保留所有的括号
 	switch exp.(type) {
 	case *ast.VarargExp, *ast.FuncCallExp, *ast.NameExp, *ast.TableAccessExp:
+		keepParens = true
+	default:
+		// no need to keep parens
+	}
+	if keepParens {
 		loc := common.GetRangeLoc(start_loc, p.nowToken.Loc)
 		exp = &ast.ParensExp{
 			Exp: exp,
 		}
 		exp.SetLoc(loc)
-	default:
-		// do nothing
-		// no need to keep parens
 	}
 
 	return exp
@@ -410,7 +429,8 @@ func (p *Parser) finishFuncCallExp(prefixExp ast.Exp) *ast.FuncCallExp {
 		if p.LookAheadKind() != ast.TkIdentifier {
 			p.insertParserErr(p.nowToken.Loc, "missing method name after ':'")
 			nameExp = &ast.StringExp{
-				Str: "",
+				Str:    "",
+				RawStr: "",
 			}
 			nameExp.SetLoc(p.nowToken.Loc)
 		} else {
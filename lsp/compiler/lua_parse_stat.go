@@ -7,6 +7,7 @@ import (
 func (p *Parser) parseStat() ast.Stat {
 	switch p.LookAheadKind() {
 	case ast.TkSepSemi:
+		p.NextToken()
 		return nil
 	case ast.TkKwBreak:
 		return p.parseBreakStat()
@@ -47,6 +48,9 @@ func (p *Parser) parseBreakStat() ast.Stat {
 // ‘::’ Name ‘::’
 func (p *Parser) parseLabelStat() ast.Stat {
 	p.NextTokenKind(ast.TkSepLabel)
+	if !p.version.SupportGoto() {
+		p.insertParserErr(p.nowToken.Loc, "label is not supported in %s", p.version)
+	}
 	p.NextIdentifier()
 	var token = p.nowToken
 	p.NextTokenKind(ast.TkSepLabel)
@@ -196,6 +200,7 @@ func (p *Parser) parseFuncDefStat() ast.Stat {
 	funcNameExp, isColon := p._parseFuncName()
 	funcDef := p.parseFuncBodyExp(func_keyword_loc)
 	funcDef.IsColon = isColon
+	markWriteExp(funcNameExp)
 	var stat = &ast.AssignStat{
 		VarList: []ast.Exp{funcNameExp},
 		ExpList: []ast.Exp{funcDef},
@@ -272,6 +277,9 @@ func (p *Parser) _finishLocalVarDeclStat() *ast.LocalVarDeclStat {
 func (p *Parser) _getLocalAttribute() ast.LocalAttr {
 	if p.LookAheadKind() == ast.TkOpLt {
 		p.NextToken()
+		if !p.version.SupportAttrib() {
+			p.insertParserErr(p.nowToken.Loc, "local variable attribute is not supported in %s", p.version)
+		}
 		p.NextIdentifier()
 		var attrName = p.nowToken.TokenStr
 		if attrName == "const" {
@@ -294,19 +302,47 @@ func (p *Parser) parseIKIllegalStat() ast.Stat {
 }
 
 func (p *Parser) parseAssignOrFuncCallStat() ast.Stat {
+	startPos := p.LookAheadToken().Loc.Start
 	prefixExp := p.parsePrefixExp()
 	if _, ok := prefixExp.(*ast.BadExpr); ok {
-		return nil // 直接忽略掉
+		return p.parseBadStat(startPos, nil)
 	}
 
 	if fc, ok := prefixExp.(*ast.FuncCallExp); ok {
 		return fc
 	}
 
+	if kind := p.LookAheadKind(); kind != ast.TkOpAssign && kind != ast.TkSepComma {
+		// 不完整的语句，例如正在输入的 a.b ，保留解析出来的表达式
+		p.insertParserErr(p.LookAheadToken().Loc, "expected %s, found '%s'", ast.TkOpAssign.String(), kind.String())
+		return p.parseBadStat(startPos, prefixExp)
+	}
+
 	assignStat := p.parseAssignStat(prefixExp)
 	return assignStat
 }
 
+// parseBadStat 错误恢复，跳过单词直到同步点或者新的一行，至少跳过一个单词。startPos 是语句开始的位置
+func (p *Parser) parseBadStat(startPos Position, exp ast.Exp) *ast.BadStat {
+	if !startPos.Before(p.nowToken.Loc.End) {
+		// 还没有读取任何单词
+		p.NextToken()
+	}
+	for !isSyncToken(p.LookAheadKind()) && p.LookAheadToken().Loc.Start.Line == p.nowToken.Loc.End.Line {
+		p.NextToken()
+	}
+	return &ast.BadStat{
+		Exp: exp,
+	}
+}
+
+// parseStrayBlockEnd 顶层多余的 end else elseif until ，记录错误后跳过
+func (p *Parser) parseStrayBlockEnd() *ast.BadStat {
+	p.NextToken()
+	p.insertParserErr(p.nowToken.Loc, "expected %s, found '%s'", ast.TkEOF.String(), p.nowToken.TokenKind.String())
+	return &ast.BadStat{}
+}
+
 // varlist ‘=’ explist
 func (p *Parser) parseAssignStat(var0 ast.Exp) *ast.AssignStat {
 	var varList = []ast.Exp{var0}
@@ -316,12 +352,22 @@ func (p *Parser) parseAssignStat(var0 ast.Exp) *ast.AssignStat {
 	}
 	p.NextTokenKind(ast.TkOpAssign)
 	var expList = p.parseExpList()
+	for _, exp := range varList {
+		markWriteExp(exp)
+	}
 	return &ast.AssignStat{
 		VarList: varList,
 		ExpList: expList,
 	}
 }
 
+// markWriteExp 赋值语句左边的 t.k 标记为写入
+func markWriteExp(exp ast.Exp) {
+	if access, ok := exp.(*ast.TableAccessExp); ok {
+		access.IsWriteExp = true
+	}
+}
+
 // retstat ::= return [explist]
 func (p *Parser) parseRetStat() ast.Stat {
 	p.NextTokenKind(ast.TkKwReturn)
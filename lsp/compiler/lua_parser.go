@@ -6,25 +6,76 @@ import (
 	"mylua-lsp/lsp/common"
 )
 
-func ParseLuaSource(source *common.LuaSource) (block *ast.Block, commentMap LuaCommentMap, errList []ParseError) {
-	parser := Parser{}
-	lexer := NewLexer(source, parser.insertErr)
+func ParseLuaSource(source *common.LuaSource, version common.LuaVersion) (block *ast.Block, commentMap LuaCommentMap, errList []ParseError) {
+	var result = ParseLuaFile(source, version)
+	return result.Block, result.CommentMap, result.ErrList
+}
+
+// ParseResult 一个文件的解析结果，增量解析时需要上一次的结果
+type ParseResult struct {
+	Source     *common.LuaSource
+	Version    common.LuaVersion
+	Block      *ast.Block // 有语法错误时是部分的语法树，错误的地方是 BadStat 和 BadExpr
+	CommentMap LuaCommentMap
+	ErrList    []ParseError
+
+	statErrCounts []int // 每个顶层语句开始解析时的错误数量，和 Block.Stats 一一对应
+	reparsed      bool  // 已经增量解析过，复用的语句和注释被新的结果修改了
+}
+
+// ParseLuaFile 完整解析一个文件，有语法错误时也会返回部分的语法树
+func ParseLuaFile(source *common.LuaSource, version common.LuaVersion) *ParseResult {
+	parser := Parser{
+		version: version,
+	}
+	lexer := NewLexer(source, version, parser.insertErr)
 	parser.l = lexer
 	parser.aheadToken = lexer.NextToken() // 确保 aheadToken 有效
 
-	defer func() {
-		if err1 := recover(); err1 != nil {
-			// 太多简单的语法错误，丢弃分析结果，只保留错误列表
-			errList = parser.parseErrs
-			return
-		}
-	}()
+	var block = parser.parseChunk(nil, nil)
+	return &ParseResult{
+		Source:        source,
+		Version:       version,
+		Block:         block,
+		CommentMap:    lexer.GetCommentMap(),
+		ErrList:       parser.parseErrs,
+		statErrCounts: parser.statErrCounts,
+	}
+}
 
-	block = parser.parseBlock() // block
+// ParseLuaSourceLossless 无损模式的解析，保留所有的单词和杂项，可以还原源码
+func ParseLuaSourceLossless(source *common.LuaSource, version common.LuaVersion) (tree *ast.ConcreteTree, commentMap LuaCommentMap, errList []ParseError) {
+	parser := Parser{
+		version:  version,
+		lossless: true,
+	}
+	lexer := NewLexer(source, version, parser.insertErr)
+	lexer.keepTokens = true
+	parser.l = lexer
+	parser.aheadToken = lexer.NextToken() // 确保 aheadToken 有效
 
-	parser.NextTokenKind(ast.TkEOF)
+	var block = parser.parseChunk(nil, nil)
+	var eof = lexer.tokens[len(lexer.tokens)-1]
+	if len(block.Stats) == 0 {
+		block.Loc = common.Location{Start: Position{}, End: eof.Loc.End}
+	}
 
-	return block, lexer.GetCommentMap(), parser.parseErrs
+	var tokens = make([]*ast.SyntaxToken, 0, len(lexer.tokens))
+	var prevEnd Position
+	for _, token := range lexer.tokens {
+		var syntaxToken = &ast.SyntaxToken{
+			Token:         token,
+			LeadingTrivia: splitTrivia(source, prevEnd, token.Loc.Start),
+		}
+		if token.TokenKind != ast.TkEOF {
+			syntaxToken.Text = source.GetRawText(token.Loc)
+		}
+		tokens = append(tokens, syntaxToken)
+		prevEnd = token.Loc.End
+	}
+
+	tree = ast.NewConcreteTree(block, tokens, source.HasBOM())
+	return tree, lexer.GetCommentMap(), parser.parseErrs
 }
 
 type Parser struct {
@@ -34,6 +85,12 @@ type Parser struct {
 	nowToken   Token
 	aheadToken Token
 
+	version common.LuaVersion // 使用的 lua 版本，不支持的语法会报错
+
+	lossless bool // 无损模式，保留所有的括号表达式
+
+	statErrCounts []int // 每个顶层语句开始解析时的错误数量
+
 	parseErrs []ParseError
 }
 
@@ -66,12 +123,12 @@ func (p *Parser) NextIdentifier() {
 	p.NextTokenKind(ast.TkIdentifier)
 }
 
-// NextTokenKind 读取下一个单词，并且检验类型，如果不满足条件，记录错误。【遇到特殊token，会卡住】
+// NextTokenKind 读取下一个单词，并且检验类型。如果不满足条件，记录错误，不读取单词，当成插入了一个虚拟的单词
 func (p *Parser) NextTokenKind(kind TkKind) {
 	look_kind := p.LookAheadKind()
 	if look_kind != kind {
-		p.insertParserErr(p.aheadToken.Loc, "expected %s, found '%s'", kind.String(), look_kind.String())
-		p.NextToken()
+		p.insertParserErr(p.LookAheadToken().Loc, "expected %s, found '%s'", kind.String(), look_kind.String())
+		p.insertVirtualToken(kind)
 	} else {
 		p.NextToken()
 	}
@@ -81,13 +138,23 @@ func (p *Parser) NextTokenKindExpectByLoc(kind TkKind, beginTokenLoc Location) {
 	look_kind := p.LookAheadKind()
 	if look_kind != kind {
 		p.insertParserErr(beginTokenLoc, "miss correspond %s", kind.String())
-		p.insertParserErr(p.aheadToken.Loc, "expected %s, found '%s'", kind.String(), look_kind.String())
-		p.NextToken()
+		p.insertParserErr(p.LookAheadToken().Loc, "expected %s, found '%s'", kind.String(), look_kind.String())
+		p.insertVirtualToken(kind)
 	} else {
 		p.NextToken()
 	}
 }
 
+// insertVirtualToken 缺少的单词当成虚拟插入在前一个单词的后面，长度为 0 。虚拟的 Name 内容为空
+func (p *Parser) insertVirtualToken(kind TkKind) {
+	var pos = p.nowToken.Loc.End
+	p.nowToken = Token{
+		Valid:     true,
+		TokenKind: kind,
+		Loc:       Location{Start: pos, End: pos},
+	}
+}
+
 // CheckNowTokenKind 检查当前单词类型是否符合要求，否则记录错误
 func (p *Parser) CheckNowTokenKind(kind TkKind) {
 	if p.nowToken.TokenKind != kind {
@@ -107,24 +174,27 @@ func (p *Parser) insertParserErr(loc Location, f string, a ...any) {
 }
 
 func (p *Parser) insertErr(oneErr ParseError) {
-	if len(p.parseErrs) < 30 {
-		p.parseErrs = append(p.parseErrs, oneErr)
-	} else {
-		oneErr.ErrStr = oneErr.ErrStr + "(too many err...)"
-		p.parseErrs = append(p.parseErrs, oneErr)
-
-		panic("too many parse errors")
+	if n := len(p.parseErrs); n > 0 && p.parseErrs[n-1].Loc.Start == oneErr.Loc.Start {
+		// 同一个位置只报告第一个错误，错误恢复时经常会连续出错
+		return
 	}
+	p.parseErrs = append(p.parseErrs, oneErr)
 }
 
 // block ::= {stat} [retstat]
 func (p *Parser) parseBlock() *ast.Block {
-	var start_loc = p.aheadToken.Loc
+	var start_loc = p.LookAheadToken().Loc
+	var before_loc = p.nowToken.Loc
 	var block = &ast.Block{
 		Stats: p.parseStats(),
 	}
 	var end_loc = p.nowToken.Loc
-	block.Loc = common.GetRangeLoc(start_loc, end_loc)
+	if end_loc == before_loc {
+		// 空的 block，位置放在前一个单词的后面
+		block.Loc = common.Location{Start: before_loc.End, End: before_loc.End}
+	} else {
+		block.Loc = common.GetRangeLoc(start_loc, end_loc)
+	}
 	return block
 }
 
@@ -137,10 +207,21 @@ func isBlockEnd(tokenKind TkKind) bool {
 	return false
 }
 
+// isSyncToken 错误恢复时的同步点，跳过单词直到遇到语句的关键字或者 block 的结束
+func isSyncToken(tokenKind TkKind) bool {
+	switch tokenKind {
+	case ast.TkKwLocal, ast.TkKwFunction, ast.TkKwIf, ast.TkKwReturn,
+		ast.TkKwFor, ast.TkKwWhile, ast.TkKwRepeat, ast.TkKwDo,
+		ast.TkKwBreak, ast.TkKwGoto, ast.TkSepLabel, ast.TkSepSemi:
+		return true
+	}
+	return isBlockEnd(tokenKind)
+}
+
 func (p *Parser) parseStats() []ast.Stat {
 	stats := make([]ast.Stat, 0, 1)
 	for !isBlockEnd(p.LookAheadKind()) {
-		var start_loc = p.aheadToken.Loc
+		var start_loc = p.LookAheadToken().Loc
 		stat := p.parseStat()
 		if stat != nil {
 			var Loc = common.GetRangeLoc(start_loc, p.nowToken.Loc)
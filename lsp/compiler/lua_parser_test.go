@@ -0,0 +1,133 @@
+package compiler
+
+import (
+	"fmt"
+	"mylua-lsp/lsp/ast"
+	"mylua-lsp/lsp/common"
+	"strings"
+	"testing"
+)
+
+// 无损模式逐字节还原源码，包括 BOM 、shebang 、\r\n 和语法错误的文件
+func TestLosslessRoundTrip(t *testing.T) {
+	var files = []string{
+		"",
+		"\n",
+		"x",
+		"  -- only comment",
+		"\xEF\xBB\xBF#!/usr/bin/lua\r\nlocal a = (1) -- c\r\n--[==[ long\n]] ]==] b = {1;2,} ; \r\n\tfunction x:y(a, ...) return (a) end\r\n",
+		"local s = [[\r\nab\r\n]] .. 'q\\\r\nw'\r",
+		"if a then elseif b then else end do end while x do end repeat until y",
+		"for i=1,2 do end for k,v in pairs(t) do goto x end ::x:: local function f() end return 1;",
+		"x = = end end 1 $$ + '",
+		"local t = { [\"k\"] = f 'x', g {}, (h)(1) }",
+	}
+	for _, code := range files {
+		var tree, _, _ = ParseLuaSourceLossless(common.NewLuaSource([]byte(code), "a.lua"), common.LuaVersion54)
+		if text := tree.Text(); text != code {
+			t.Errorf("got %q, want %q", text, code)
+		}
+		for _, tok := range tree.Tokens {
+			if tok.Owner == nil {
+				t.Errorf("%q: token %q has no owner", code, tok.Text)
+			}
+		}
+		if last := tree.Tokens[len(tree.Tokens)-1]; last.TokenKind != ast.TkEOF {
+			t.Errorf("%q: last token is %q, want EOF", code, last.Text)
+		}
+	}
+}
+
+// 单词挂在直接包含它的最内层节点上，括号和关键字的位置都能找到
+func TestLosslessOwner(t *testing.T) {
+	var code = "do local x = (f(1)) end"
+	var tree, _, _ = ParseLuaSourceLossless(common.NewLuaSource([]byte(code), "a.lua"), common.LuaVersion54)
+	var owners []string
+	for _, tok := range tree.Tokens {
+		owners = append(owners, fmt.Sprintf("%s:%T", tok.Text, tok.Owner))
+	}
+	var want = []string{
+		"do:*ast.DoStat", "local:*ast.LocalVarDeclStat", "x:*ast.LocalVarDeclStat", "=:*ast.LocalVarDeclStat",
+		"(:*ast.ParensExp", "f:*ast.NameExp", "(:*ast.FuncCallExp", "1:*ast.IntegerExp", "):*ast.FuncCallExp",
+		"):*ast.ParensExp", "end:*ast.DoStat", ":*ast.Block",
+	}
+	if strings.Join(owners, " ") != strings.Join(want, " ") {
+		t.Errorf("got owners\n%v\nwant\n%v", owners, want)
+	}
+	var end = tree.FindToken(tree.Block.Stats[0], ast.TkKwEnd)
+	if end == nil || end.Loc.Start.Column != 20 {
+		t.Errorf("end keyword of do block: got %v", end)
+	}
+}
+
+// statKinds 顶层语句的类型
+func statKinds(block *ast.Block) []string {
+	var list []string
+	for _, stat := range block.Stats {
+		list = append(list, fmt.Sprintf("%T", stat))
+	}
+	return list
+}
+
+// 有语法错误时返回部分的语法树，错误的地方是 BadStat 和 BadExpr ，后面的语句不受影响
+func TestErrorRecovery(t *testing.T) {
+	var tests = []struct {
+		code  string
+		stats []string
+		errs  []string
+	}{
+		{
+			"local a = \nlocal b = 2\nprint(b)\n",
+			[]string{"*ast.LocalVarDeclStat", "*ast.LocalVarDeclStat", "*ast.FuncCallExp"},
+			[]string{"`local` can not start prefixexp"},
+		},
+		{
+			"if a then\n  x = \nend\nprint(1)\n",
+			[]string{"*ast.IfStat", "*ast.FuncCallExp"},
+			[]string{"`end` can not start prefixexp"},
+		},
+		{
+			"f(1, 2\nlocal t = {1, 2\nreturn",
+			[]string{"*ast.FuncCallExp", "*ast.LocalVarDeclStat", "*ast.RetStat"},
+			[]string{"expected ), found 'local'", "expected }, found 'return'"},
+		},
+		{
+			"function f()\n  local x = 1\n\nlocal y = 2\n",
+			[]string{"*ast.AssignStat"},
+			[]string{"miss correspond end", "expected end, found 'EOF'"},
+		},
+		{
+			"x = = 1\nend\nlocal ok = true\n",
+			[]string{"*ast.AssignStat", "*ast.BadStat", "*ast.BadStat", "*ast.LocalVarDeclStat"},
+			[]string{"`=` can not start prefixexp", "expected EOF, found 'end'"},
+		},
+	}
+	for _, tt := range tests {
+		var block, _, errList = ParseLuaSource(common.NewLuaSource([]byte(tt.code), "a.lua"), common.LuaVersion54)
+		if got := statKinds(block); strings.Join(got, " ") != strings.Join(tt.stats, " ") {
+			t.Errorf("%q: got statements %v, want %v", tt.code, got, tt.stats)
+		}
+		var got []string
+		for _, oneErr := range errList {
+			got = append(got, oneErr.ErrStr)
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.errs, "\n") {
+			t.Errorf("%q: got errors %q, want %q", tt.code, got, tt.errs)
+		}
+	}
+}
+
+// 错误很多的文件也不会放弃，每一行都解析成赋值语句和 BadStat
+func TestManyErrors(t *testing.T) {
+	var code = strings.Repeat("x = = 1\n", 100) + "local last = 1\n"
+	var block, _, errList = ParseLuaSource(common.NewLuaSource([]byte(code), "a.lua"), common.LuaVersion54)
+	if len(errList) != 100 {
+		t.Errorf("got %d errors, want 100", len(errList))
+	}
+	if len(block.Stats) != 201 {
+		t.Fatalf("got %d statements, want 201", len(block.Stats))
+	}
+	if _, ok := block.Stats[200].(*ast.LocalVarDeclStat); !ok {
+		t.Errorf("last statement is %T", block.Stats[200])
+	}
+}
@@ -0,0 +1,86 @@
+package compiler
+
+import (
+	"mylua-lsp/lsp/ast"
+	"mylua-lsp/lsp/common"
+	"strings"
+)
+
+// splitTrivia 把两个单词之间 [start, end) 的文本切分成杂项：空白，换行，注释
+func splitTrivia(source *common.LuaSource, start, end Position) []ast.Trivia {
+	var list []ast.Trivia
+	var pos = start
+	var add = func(kind ast.TriviaKind, to Position) {
+		if end.Before(to) {
+			to = end
+		}
+		var loc = Location{Start: pos, End: to}
+		list = append(list, ast.Trivia{
+			Kind: kind,
+			Text: source.GetRawText(loc),
+			Loc:  loc,
+		})
+		pos = to
+	}
+
+	for pos.Before(end) {
+		var line = source.GetRawLine(pos.GetLine())
+		var col = pos.GetColumn()
+		if col >= len(line) {
+			add(ast.TriviaNewline, Position{Line: pos.Line + 1, Column: 0})
+			continue
+		}
+		if pos.Line == 0 && col == 0 && line != source.GetOneLine(0) {
+			add(ast.TriviaShebang, Position{Line: 0, Column: int32(len(line))})
+			continue
+		}
+		if isWhiteSpace(line[col]) {
+			var to = col
+			for to < len(line) && isWhiteSpace(line[to]) {
+				to++
+			}
+			add(ast.TriviaWhitespace, Position{Line: pos.Line, Column: int32(to)})
+			continue
+		}
+		if strings.HasPrefix(line[col:], "--") {
+			add(ast.TriviaComment, findCommentEnd(source, pos))
+			continue
+		}
+		// 单词之间不应该有其他内容，防御一下
+		add(ast.TriviaWhitespace, Position{Line: pos.Line, Column: int32(col + 1)})
+	}
+	return list
+}
+
+// findCommentEnd 查找 pos 开始的注释的结束位置。长注释找到匹配的 ]=*] ，短注释到行尾
+func findCommentEnd(source *common.LuaSource, pos Position) Position {
+	var line = source.GetOneLine(pos.GetLine())
+	var rest = line[pos.GetColumn()+2:]
+	var lineEnd = Position{Line: pos.Line, Column: int32(len(line))}
+	if !strings.HasPrefix(rest, "[") {
+		return lineEnd
+	}
+	var level = 0
+	for 1+level < len(rest) && rest[1+level] == '=' {
+		level++
+	}
+	if 1+level >= len(rest) || rest[1+level] != '[' {
+		return lineEnd
+	}
+
+	var closeStr = "]" + strings.Repeat("=", level) + "]"
+	var lineIdx = pos.GetLine()
+	var searchCol = pos.GetColumn() + 2 + level + 2
+	for lineIdx < source.GetLineNum() {
+		line = source.GetOneLine(lineIdx)
+		if searchCol <= len(line) {
+			if idx := strings.Index(line[searchCol:], closeStr); idx >= 0 {
+				return Position{Line: int32(lineIdx), Column: int32(searchCol + idx + len(closeStr))}
+			}
+		}
+		lineIdx++
+		searchCol = 0
+	}
+	// 没有结束的长注释一直到文件末尾
+	return Position{Line: int32(source.GetLineNum()), Column: 0}
+}
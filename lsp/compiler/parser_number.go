@@ -153,6 +153,44 @@ func parseInteger(str string) (int64, bool) {
 	return sign * int64(i), err == nil
 }
 
+// parseLuajitInteger 解析 LuaJIT 的 64 位整数，例如 1LL 0x10ULL
+func parseLuajitInteger(str string) (int64, bool) {
+	str = strings.ToLower(strings.TrimSpace(str))
+	var digits string
+	if strings.HasSuffix(str, "ull") {
+		digits = str[:len(str)-3]
+	} else if strings.HasSuffix(str, "ll") {
+		digits = str[:len(str)-2]
+	} else {
+		return 0, false
+	}
+	if len(digits) == 0 {
+		return 0, false
+	}
+	if isLuajitHexInteger(str) {
+		return parseInteger(digits)
+	}
+	if !isSimpleInteger(digits) {
+		return 0, false
+	}
+	// ULL 可能超出 int64 的范围，按照补码截断
+	i, err := strconv.ParseUint(digits, 10, 64)
+	return int64(i), err == nil
+}
+
+// parseLuajitImaginary 解析 LuaJIT 的虚数，例如 1i 0.5i 。返回虚部
+func parseLuajitImaginary(str string) (float64, bool) {
+	str = strings.ToLower(strings.TrimSpace(str))
+	if len(str) <= 1 || !strings.HasSuffix(str, "i") {
+		return 0, false
+	}
+	str = str[:len(str)-1]
+	if i, ok := parseInteger(str); ok {
+		return float64(i), true
+	}
+	return parseFloat(str)
+}
+
 // parseFloat 解析浮点数
 func parseFloat(str string) (float64, bool) {
 	str = strings.TrimSpace(str)
@@ -0,0 +1,179 @@
+package format
+
+import (
+	"errors"
+	"mylua-lsp/lsp/ast"
+	"mylua-lsp/lsp/common"
+	"mylua-lsp/lsp/compiler"
+	"strings"
+	"unicode/utf8"
+)
+
+/*
+lua 源码的格式化。基于无损语法树，按照语法树确定每个单词的缩进、换行和空格，注释原样保留。
+语句和语句块的换行由格式化决定，表达式里面的换行保留源码里的，连续的空行最多保留一行。
+格式化之后会重新解析，比较前后的语法树和注释，保证不改变语义。
+*/
+
+// TrailingStyle 表构造末尾的分隔符
+type TrailingStyle string
+
+const (
+	TrailingKeep   TrailingStyle = "keep"   // 保持原样
+	TrailingAlways TrailingStyle = "always" // 多行的表加上，单行的去掉
+	TrailingNever  TrailingStyle = "never"  // 都去掉
+)
+
+// CallParensStyle 参数只有一个字符串或者表构造时，函数调用的括号
+type CallParensStyle string
+
+const (
+	CallParensKeep   CallParensStyle = "keep"   // 保持原样
+	CallParensAlways CallParensStyle = "always" // 都加上括号，f "x" 改成 f("x")
+	CallParensOmit   CallParensStyle = "omit"   // 都去掉括号，f("x") 改成 f "x"
+)
+
+// QuoteStyle 短字符串的引号，内容里有引号的字符串不会修改
+type QuoteStyle string
+
+const (
+	QuoteKeep   QuoteStyle = "keep"   // 保持原样
+	QuoteDouble QuoteStyle = "double" // 使用双引号
+	QuoteSingle QuoteStyle = "single" // 使用单引号
+)
+
+// Options 格式化的配置
+type Options struct {
+	IndentWidth       int  // 每层缩进的空格数
+	UseTabs           bool // 使用制表符缩进
+	OperatorSpaces    bool // 二元运算符两边加空格，and or 总是有空格
+	TrailingSeparator TrailingStyle
+	CallParens        CallParensStyle
+	QuoteStyle        QuoteStyle
+}
+
+// DefaultOptions 默认的配置，缩进 4 个空格，其他保持原样
+func DefaultOptions() Options {
+	return Options{
+		IndentWidth:       4,
+		OperatorSpaces:    true,
+		TrailingSeparator: TrailingKeep,
+		CallParens:        CallParensKeep,
+		QuoteStyle:        QuoteKeep,
+	}
+}
+
+// Edit 对源码的一处修改，把 Loc 范围内的文本替换成 Text
+type Edit struct {
+	Loc  common.Location
+	Text string
+}
+
+// Result 格式化的结果
+type Result struct {
+	Text  string // 格式化之后完整的文本
+	Edits []Edit // 从源码到格式化结果的修改，按位置排序，互不重叠
+}
+
+// ErrSyntax 有语法错误的文件不能格式化
+var ErrSyntax = errors.New("cannot format a file with syntax errors")
+
+// Format 格式化源码，返回完整的结果和需要的修改
+func Format(source *common.LuaSource, version common.LuaVersion, opts Options) (*Result, error) {
+	tree, _, errList := compiler.ParseLuaSourceLossless(source, version)
+	if len(errList) > 0 {
+		return nil, ErrSyntax
+	}
+	if opts.IndentWidth <= 0 {
+		opts.IndentWidth = 4
+	}
+
+	var p = newPrinter(tree, opts)
+	p.print()
+	var text = p.out.String()
+	if err := verify(tree, text, version); err != nil {
+		return nil, err
+	}
+
+	var eol = source.GetLineEnding(0)
+	if eol == "" {
+		eol = "\n"
+	}
+	var result = &Result{
+		Edits: p.edits(source, eol),
+	}
+	if tree.HasBOM {
+		result.Text = "\xEF\xBB\xBF"
+	}
+	result.Text += strings.ReplaceAll(text, "\n", eol)
+	return result, nil
+}
+
+// edits 比较源码和格式化结果，没有改变的单词作为锚点，锚点之间不同的文本生成一处修改
+func (p *printer) edits(source *common.LuaSource, eol string) []Edit {
+	var list []Edit
+	var out = p.out.String()
+	var prevEnd common.Position // 上一个锚点在源码里的结束位置
+	var prevOut = 0             // 上一个锚点在结果里的结束位置
+	var add = func(end common.Position, outEnd int) {
+		var old = source.GetRawText(common.Location{Start: prevEnd, End: end})
+		var text = strings.ReplaceAll(out[prevOut:outEnd], "\n", eol)
+		if old != text {
+			list = append(list, diffEdit(prevEnd, old, text))
+		}
+	}
+	for i, tok := range p.tree.Tokens {
+		var span = p.spans[i]
+		if tok.TokenKind == ast.TkEOF || span.end < 0 {
+			continue
+		}
+		add(tok.Loc.Start, span.start)
+		prevEnd, prevOut = tok.Loc.End, span.end
+	}
+	var lastLine = max(source.GetLineNum()-1, 0)
+	add(common.Position{Line: int32(lastLine), Column: int32(len(source.GetRawLine(lastLine)))}, len(out))
+	return list
+}
+
+// diffEdit 去掉两段文本相同的开头和结尾，剩下的部分作为修改，start 是旧文本在源码里的开始位置
+func diffEdit(start common.Position, old, text string) Edit {
+	var prefix = 0
+	for prefix < len(old) && prefix < len(text) && old[prefix] == text[prefix] {
+		prefix++
+	}
+	// 不能从一个字符或者 \r\n 的中间切开
+	for prefix > 0 && (prefix < len(old) && !utf8.RuneStart(old[prefix]) || prefix < len(text) && !utf8.RuneStart(text[prefix]) ||
+		old[prefix-1] == '\r' && prefix < len(old) && old[prefix] == '\n') {
+		prefix--
+	}
+	var suffix = 0
+	for suffix < len(old)-prefix && suffix < len(text)-prefix && old[len(old)-1-suffix] == text[len(text)-1-suffix] {
+		suffix++
+	}
+	for suffix > 0 && (!utf8.RuneStart(old[len(old)-suffix]) || len(old)-suffix > 0 && old[len(old)-suffix-1] == '\r' && old[len(old)-suffix] == '\n') {
+		suffix--
+	}
+	return Edit{
+		Loc: common.Location{
+			Start: advance(start, old[:prefix]),
+			End:   advance(start, old[:len(old)-suffix]),
+		},
+		Text: text[prefix : len(text)-suffix],
+	}
+}
+
+// advance pos 后面跟着 text 时，text 结束的位置
+func advance(pos common.Position, text string) common.Position {
+	if idx := strings.LastIndexByte(text, '\n'); idx >= 0 {
+		pos.Line += int32(strings.Count(text, "\n"))
+		pos.Column = int32(len(text) - idx - 1)
+	} else {
+		pos.Column += int32(len(text))
+	}
+	return pos
+}
+
+// normalize 把 \r\n 换行统一成 \n
+func normalize(text string) string {
+	return strings.ReplaceAll(text, "\r\n", "\n")
+}
@@ -0,0 +1,460 @@
+package format
+
+import (
+	"math/rand"
+	"mylua-lsp/lsp/common"
+	"mylua-lsp/lsp/compiler"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const bom = "\xEF\xBB\xBF"
+
+// allOptions 所有配置取值的组合
+func allOptions() []Options {
+	var list []Options
+	for _, indent := range []Options{{IndentWidth: 4}, {IndentWidth: 2}, {IndentWidth: 4, UseTabs: true}} {
+		for _, spaces := range []bool{true, false} {
+			for _, trailing := range []TrailingStyle{TrailingKeep, TrailingAlways, TrailingNever} {
+				for _, parens := range []CallParensStyle{CallParensKeep, CallParensAlways, CallParensOmit} {
+					for _, quote := range []QuoteStyle{QuoteKeep, QuoteDouble, QuoteSingle} {
+						var opts = indent
+						opts.OperatorSpaces = spaces
+						opts.TrailingSeparator = trailing
+						opts.CallParens = parens
+						opts.QuoteStyle = quote
+						list = append(list, opts)
+					}
+				}
+			}
+		}
+	}
+	return list
+}
+
+func formatText(t *testing.T, text string, opts Options) *Result {
+	t.Helper()
+	var result, err = Format(common.NewLuaSource([]byte(text), "a.lua"), common.LuaVersion54, opts)
+	if err != nil {
+		t.Fatalf("%v with %+v:\n%s", err, opts, text)
+	}
+	return result
+}
+
+// checkFormat 格式化要幂等，语法树和注释不变，修改应用到源码上得到格式化的结果
+func checkFormat(t *testing.T, text string, opts Options) {
+	t.Helper()
+	var source = common.NewLuaSource([]byte(text), "a.lua")
+	var first = formatText(t, text, opts)
+	var second = formatText(t, first.Text, opts)
+	if second.Text != first.Text {
+		t.Fatalf("not idempotent with %+v:\n%s\n--- first\n%s\n--- second\n%s", opts, text, first.Text, second.Text)
+	}
+	if len(second.Edits) != 0 {
+		t.Errorf("formatting formatted code gives %d edits with %+v", len(second.Edits), opts)
+	}
+
+	var oldTree, _, _ = compiler.ParseLuaSourceLossless(source, common.LuaVersion54)
+	var newTree, _, errList = compiler.ParseLuaSourceLossless(common.NewLuaSource([]byte(first.Text), "a.lua"), common.LuaVersion54)
+	if len(errList) > 0 {
+		t.Fatalf("formatted code has syntax errors with %+v: %v\n%s", opts, errList, first.Text)
+	}
+	if dumpTree(oldTree.Block) != dumpTree(newTree.Block) {
+		t.Errorf("syntax tree changed with %+v:\n%s\n---\n%s", opts, text, first.Text)
+	}
+	if !slices.Equal(comments(oldTree), comments(newTree)) {
+		t.Errorf("comments changed with %+v:\n%s\n---\n%s", opts, text, first.Text)
+	}
+
+	var applied = source
+	for i := len(first.Edits) - 1; i >= 0; i-- {
+		applied = applied.ApplyChange(first.Edits[i].Loc, first.Edits[i].Text)
+	}
+	// 源码的文本不包括 BOM ，修改也不涉及 BOM
+	var all = applied.GetRawText(common.Location{End: common.Position{Line: int32(applied.GetLineNum())}})
+	if all != strings.TrimPrefix(first.Text, bom) {
+		t.Errorf("edits give a different text with %+v:\n%q\n%q", opts, all, first.Text)
+	}
+}
+
+// formatSamples 覆盖注释、连续空行、各种语句和配置相关的写法
+var formatSamples = []string{
+	"",
+	"local a=1",
+	"-- only a comment\n",
+	"#!/usr/bin/lua\nprint 'x'\n",
+	"local a=1 -- tail\n\n\n\n-- head\nlocal b = {1,2,3,}\n\n\n",
+	"--[[ long\n  comment ]] local x = --[==[ inside ]==] 1\n",
+	"function M.f(a,b,...)\nif a then return b elseif b then\n-- nothing\nelse return end\nend\n",
+	"local t = {\n  a = 1, -- one\n  ['b'] = \"two\";\n  3\n}\n",
+	"for i=1,10,2 do print(i) end for k,v in pairs(t) do end\n",
+	"while x<10 and not y do x=x+1 end repeat local z=-x until z>0\n",
+	"f'str' f\"str\" f[[long]] f{1} f({2}) obj:m{} obj:m('s') f(\"it's\")\n",
+	"local s = 'say \"hi\"' .. \"it's\" .. 'plain'\n",
+	"local x <const>, y <close> = 1, nil\n::top:: goto top\n",
+	"local v = a + b * c ^ d // e % f .. g & h | i ~ j << k >> l == m\n",
+	"do\n\n\n  local a\n\n\nend\n",
+	"local function f()\n  return function() return {x = {y = {}}} end\nend\n",
+	"call(a,\n  b,\n  c)\n",
+	"t.x.y[1]:m(2).z = nil;;;\n",
+}
+
+func TestFormatSamples(t *testing.T) {
+	for _, opts := range allOptions() {
+		for _, text := range formatSamples {
+			checkFormat(t, text, opts)
+		}
+	}
+}
+
+// 换行统一成源码第一行的换行符，BOM 保留
+func TestFormatLineEndingAndBOM(t *testing.T) {
+	var text = bom + "local a=1\r\n\r\n\r\n-- c\r\nif a then print(a) end\r\n"
+	for _, opts := range allOptions() {
+		checkFormat(t, text, opts)
+	}
+	var result = formatText(t, text, DefaultOptions())
+	var want = bom + "local a = 1\r\n\r\n-- c\r\nif a then\r\n    print(a)\r\nend\r\n"
+	if result.Text != want {
+		t.Errorf("got %q, want %q", result.Text, want)
+	}
+}
+
+func TestFormatOptions(t *testing.T) {
+	var tests = []struct {
+		text string
+		set  func(opts *Options)
+		want string
+	}{
+		{"if a then\nb()\nend\n", func(o *Options) { o.IndentWidth = 2 }, "if a then\n  b()\nend\n"},
+		{"if a then\nb()\nend\n", func(o *Options) { o.UseTabs = true }, "if a then\n\tb()\nend\n"},
+		{"x=a+b*2\n", func(o *Options) { o.OperatorSpaces = true }, "x = a + b * 2\n"},
+		{"x = a + b*2\n", func(o *Options) { o.OperatorSpaces = false }, "x = a+b*2\n"},
+		{"t = {\n  1,\n  2\n}\nu = {1, 2,}\n", func(o *Options) { o.TrailingSeparator = TrailingAlways },
+			"t = {\n    1,\n    2,\n}\nu = { 1, 2 }\n"},
+		{"t = {\n  1,\n  2,\n}\n", func(o *Options) { o.TrailingSeparator = TrailingNever }, "t = {\n    1,\n    2\n}\n"},
+		{"f 'x'\ng {}\n", func(o *Options) { o.CallParens = CallParensAlways }, "f('x')\ng({})\n"},
+		{"f('x')\ng({})\n", func(o *Options) { o.CallParens = CallParensOmit }, "f 'x'\ng {}\n"},
+		{"s = 'a' .. \"b\" .. 'it\"s'\n", func(o *Options) { o.QuoteStyle = QuoteDouble }, "s = \"a\" .. \"b\" .. 'it\"s'\n"},
+		{"s = \"a\" .. 'b' .. \"it's\"\n", func(o *Options) { o.QuoteStyle = QuoteSingle }, "s = 'a' .. 'b' .. \"it's\"\n"},
+	}
+	for _, tt := range tests {
+		var opts = DefaultOptions()
+		tt.set(&opts)
+		if got := formatText(t, tt.text, opts).Text; got != tt.want {
+			t.Errorf("format %q with %+v\ngot  %q\nwant %q", tt.text, opts, got, tt.want)
+		}
+	}
+}
+
+// 有语法错误的文件不格式化
+func TestFormatSyntaxError(t *testing.T) {
+	var _, err = Format(common.NewLuaSource([]byte("if a then\n"), "a.lua"), common.LuaVersion54, DefaultOptions())
+	if err != ErrSyntax {
+		t.Errorf("got %v, want ErrSyntax", err)
+	}
+}
+
+// codeGen 随机生成语法正确的代码，单词之间随机插入空白、换行和注释
+type codeGen struct {
+	r  *rand.Rand
+	sb strings.Builder
+}
+
+func (g *codeGen) trivia() {
+	switch g.r.Intn(14) {
+	case 0:
+		g.sb.WriteString("\n")
+	case 1:
+		g.sb.WriteString("\n\n\n")
+	case 2:
+		g.sb.WriteString(" -- c\n")
+	case 3:
+		g.sb.WriteString(" --[[ lc ]] ")
+	case 4:
+		g.sb.WriteString("--[==[ multi\n line ]==]")
+	case 5:
+		g.sb.WriteString("\n  ")
+	case 6, 7:
+	default:
+		g.sb.WriteString(" ")
+	}
+}
+
+func (g *codeGen) tok(s string) {
+	g.trivia()
+	g.sb.WriteString(" ") // 避免两个单词连在一起
+	g.sb.WriteString(s)
+}
+
+func (g *codeGen) pick(list ...string) string {
+	return list[g.r.Intn(len(list))]
+}
+
+func (g *codeGen) exp(depth int) {
+	if depth > 3 {
+		g.simple()
+		return
+	}
+	switch g.r.Intn(12) {
+	case 0:
+		g.exp(depth + 1)
+		g.tok(g.pick("+", "-", "*", "/", "//", "..", "==", "~=", "<=", "and", "or", "^", "%", "&", "|", "~", "<<", ">>", "<", ">"))
+		g.exp(depth + 1)
+	case 1:
+		g.tok(g.pick("-", "not", "#", "~"))
+		g.exp(depth + 1)
+	case 2:
+		g.table(depth)
+	case 3:
+		g.tok("function")
+		g.tok("(")
+		if g.r.Intn(2) == 0 {
+			g.tok("p")
+			g.tok(",")
+			g.tok("...")
+		}
+		g.tok(")")
+		g.block(depth + 1)
+		g.tok("end")
+	case 4:
+		g.prefix(depth + 1)
+	case 5:
+		g.tok("(")
+		g.exp(depth + 1)
+		g.tok(")")
+	default:
+		g.simple()
+	}
+}
+
+func (g *codeGen) simple() {
+	g.tok(g.pick("1", "2.5", "0x10", "'s'", "\"d\"", "[[ls]]", "nil", "true", "...", "a", "b", "1e3", "'it\"s'", ".5"))
+}
+
+func (g *codeGen) table(depth int) {
+	g.tok("{")
+	var n = g.r.Intn(4)
+	for i := 0; i < n; i++ {
+		switch g.r.Intn(3) {
+		case 0:
+			g.tok("k")
+			g.tok("=")
+		case 1:
+			g.tok("[")
+			g.exp(depth + 1)
+			g.tok("]")
+			g.tok("=")
+		}
+		g.exp(depth + 1)
+		if i < n-1 || g.r.Intn(2) == 0 {
+			g.tok(g.pick(",", ";"))
+		}
+	}
+	g.tok("}")
+}
+
+func (g *codeGen) prefix(depth int) {
+	if g.r.Intn(4) == 0 {
+		g.tok("(")
+		g.exp(depth + 1)
+		g.tok(")")
+	} else {
+		g.tok(g.pick("a", "b", "self", "x1", "_y"))
+	}
+	for n := g.r.Intn(3); n > 0; n-- {
+		switch g.r.Intn(5) {
+		case 0:
+			g.tok(".")
+			g.tok("f")
+		case 1:
+			g.tok("[")
+			g.exp(depth + 1)
+			g.tok("]")
+		default:
+			g.call(depth)
+		}
+	}
+}
+
+func (g *codeGen) call(depth int) {
+	if g.r.Intn(3) == 0 {
+		g.tok(":")
+		g.tok("m")
+	}
+	switch g.r.Intn(4) {
+	case 0:
+		g.tok("'str'")
+	case 1:
+		g.table(depth + 1)
+	default:
+		g.tok("(")
+		for i, n := 0, g.r.Intn(3); i < n; i++ {
+			if i > 0 {
+				g.tok(",")
+			}
+			g.exp(depth + 1)
+		}
+		g.tok(")")
+	}
+}
+
+func (g *codeGen) block(depth int) {
+	var n = g.r.Intn(4)
+	if depth > 3 {
+		n = g.r.Intn(2)
+	}
+	for i := 0; i < n; i++ {
+		g.stat(depth)
+		if g.r.Intn(6) == 0 {
+			g.tok(";")
+		}
+	}
+	if g.r.Intn(5) == 0 {
+		g.tok("return")
+		if g.r.Intn(2) == 0 {
+			g.exp(depth + 1)
+		}
+		if g.r.Intn(3) == 0 {
+			g.tok(";")
+		}
+	}
+}
+
+func (g *codeGen) stat(depth int) {
+	switch g.r.Intn(14) {
+	case 0:
+		g.tok("local")
+		g.tok("v")
+		if g.r.Intn(3) == 0 {
+			g.tok("<")
+			g.tok("const")
+			g.tok(">")
+		}
+		g.tok("=")
+		g.exp(depth + 1)
+	case 1:
+		g.tok("if")
+		g.exp(depth + 1)
+		g.tok("then")
+		g.block(depth + 1)
+		if g.r.Intn(2) == 0 {
+			g.tok("elseif")
+			g.exp(depth + 1)
+			g.tok("then")
+			g.block(depth + 1)
+		}
+		if g.r.Intn(2) == 0 {
+			g.tok("else")
+			g.block(depth + 1)
+		}
+		g.tok("end")
+	case 2:
+		g.tok("while")
+		g.exp(depth + 1)
+		g.tok("do")
+		g.block(depth + 1)
+		g.tok("end")
+	case 3:
+		g.tok("for")
+		g.tok("i")
+		g.tok("=")
+		g.exp(depth + 1)
+		g.tok(",")
+		g.exp(depth + 1)
+		g.tok("do")
+		g.block(depth + 1)
+		g.tok("end")
+	case 4:
+		g.tok("for")
+		g.tok("k")
+		g.tok(",")
+		g.tok("v")
+		g.tok("in")
+		g.exp(depth + 1)
+		g.tok("do")
+		g.block(depth + 1)
+		g.tok("end")
+	case 5:
+		g.tok("repeat")
+		g.block(depth + 1)
+		g.tok("until")
+		g.exp(depth + 1)
+	case 6:
+		g.tok("do")
+		g.block(depth + 1)
+		g.tok("end")
+	case 7:
+		g.tok("function")
+		g.tok("M")
+		g.tok(".")
+		g.tok("f")
+		if g.r.Intn(2) == 0 {
+			g.tok(":")
+			g.tok("g")
+		}
+		g.tok("(")
+		g.tok(")")
+		g.block(depth + 1)
+		g.tok("end")
+	case 8:
+		g.tok("local")
+		g.tok("function")
+		g.tok("lf")
+		g.tok("(")
+		g.tok("...")
+		g.tok(")")
+		g.block(depth + 1)
+		g.tok("end")
+	case 9:
+		g.tok("::")
+		g.tok("lbl")
+		g.tok("::")
+	case 10:
+		g.tok("goto")
+		g.tok("lbl")
+	default:
+		g.tok(g.pick("a", "b", "self", "x1", "_y"))
+		if g.r.Intn(2) == 0 {
+			g.call(depth)
+		} else {
+			g.tok("=")
+			g.exp(depth + 1)
+		}
+	}
+}
+
+// 随机的代码用随机的配置格式化，换行随机用 \r\n ，有时带 BOM
+func TestFormatRandom(t *testing.T) {
+	var rounds = 2000
+	if testing.Short() {
+		rounds = 200
+	}
+	var r = rand.New(rand.NewSource(1))
+	var optsList = allOptions()
+	for round := 0; round < rounds; round++ {
+		var g = &codeGen{r: r}
+		if r.Intn(5) == 0 {
+			g.sb.WriteString("#!shebang\n")
+		}
+		g.block(0)
+		g.trivia()
+		var code = g.sb.String()
+		if r.Intn(4) == 0 {
+			code = strings.ReplaceAll(code, "\n", "\r\n")
+		}
+		if r.Intn(8) == 0 {
+			code = bom + code
+		}
+		var opts = optsList[r.Intn(len(optsList))]
+		if _, err := Format(common.NewLuaSource([]byte(code), "a.lua"), common.LuaVersion54, opts); err == ErrSyntax {
+			// 生成的代码可能有 goto 找不到标签之类的错误，解析器报告的才跳过
+			continue
+		}
+		checkFormat(t, code, opts)
+		if t.Failed() {
+			t.Fatalf("round %d:\n%s", round, code)
+		}
+	}
+}
@@ -0,0 +1,546 @@
+package format
+
+import (
+	"mylua-lsp/lsp/ast"
+	"sort"
+	"strings"
+)
+
+// tokenInfo 语法树决定的单词的格式
+type tokenInfo struct {
+	stmtStart   bool       // 语句的第一个单词，总是新起一行
+	opener      *ast.Block // 打开语句块的 then do else repeat 和函数参数的 )
+	base        int        // opener 所属节点的第一个单词，语句块的缩进以它所在的行为准
+	closer      bool       // 结束语句块的 end else elseif until
+	unary       bool       // 单目运算符
+	binary      bool       // 二元运算符
+	attached    bool       // 紧跟前面的单词，例如调用和函数参数的 ( ，下标的 [
+	tightAfter  bool       // 后面不加空格，例如标签开头的 :: ，属性的 <
+	tightBefore bool       // 前面不加空格，例如标签结尾的 :: ，属性的 >
+	skip        bool       // 格式化时删掉，例如去掉的调用括号和末尾的分隔符
+	text        string     // 替换的文本，例如修改引号的字符串
+	before      []ast.TkKind
+	after       []ast.TkKind
+}
+
+// item 输出的一个单词，可能是源码里的，也可能是格式化插入的
+type item struct {
+	tokenInfo
+	idx    int // 源码里单词的下标，插入的单词为 -1
+	kind   ast.TkKind
+	text   string
+	trivia []ast.Trivia
+}
+
+// frameKind 缩进层次的种类
+type frameKind uint8
+
+const (
+	frameBlock   frameKind = iota // 语句块
+	frameStat                     // 语句，语句中间换行时多缩进一层
+	frameBracket                  // 括号，括号里面换行时多缩进一层
+)
+
+// frame 一层缩进，base 是开始的行的缩进
+type frame struct {
+	kind  frameKind
+	base  int
+	empty bool // 没有语句的语句块
+}
+
+// span 单词在结果里的位置，修改了的单词 end 为 -1
+type span struct {
+	start, end int
+}
+
+// printer 按照语法树输出格式化的文本
+type printer struct {
+	tree  *ast.ConcreteTree
+	opts  Options
+	info  []tokenInfo
+	spans []span
+
+	out         strings.Builder
+	frames      []frame
+	lineIndent  int   // 当前行的缩进
+	tokIndent   []int // 每个单词所在行的缩进
+	prev        *item // 上一个输出的单词
+	needNewline bool  // 前面是短注释，必须换行
+	afterOpener bool  // 刚输出了语句块或者括号的开始，不加空行
+}
+
+func newPrinter(tree *ast.ConcreteTree, opts Options) *printer {
+	var p = &printer{
+		tree:      tree,
+		opts:      opts,
+		info:      make([]tokenInfo, len(tree.Tokens)),
+		spans:     make([]span, len(tree.Tokens)),
+		tokIndent: make([]int, len(tree.Tokens)),
+		frames:    []frame{{kind: frameBlock, base: -1}},
+	}
+	for i := range p.spans {
+		p.spans[i].end = -1
+	}
+	p.annotate()
+	p.callParens()
+	p.trailingSeparators()
+	p.quotes()
+	return p
+}
+
+// tokenIndex 从 pos 开始的单词的下标
+func (p *printer) tokenIndex(pos ast.Position) int {
+	var tokens = p.tree.Tokens
+	return sort.Search(len(tokens), func(i int) bool {
+		return !tokens[i].Loc.Start.Before(pos)
+	})
+}
+
+// lastTokenIndex 在 pos 结束的单词的下标
+func (p *printer) lastTokenIndex(pos ast.Position) int {
+	var tokens = p.tree.Tokens
+	return sort.Search(len(tokens), func(i int) bool {
+		return !tokens[i].Loc.End.Before(pos)
+	})
+}
+
+// annotate 根据每个单词所属的节点确定格式
+func (p *printer) annotate() {
+	ast.Inspect(p.tree.Block, func(node ast.Stat) bool {
+		if block, ok := node.(*ast.Block); ok {
+			for _, stat := range block.Stats {
+				p.info[p.tokenIndex(stat.GetLoc().Start)].stmtStart = true
+			}
+		}
+		return node != nil
+	})
+
+	var ifBranch = map[*ast.IfStat]int{}
+	for i, tok := range p.tree.Tokens {
+		var info = &p.info[i]
+		var kind = tok.TokenKind
+		switch owner := tok.Owner.(type) {
+		case *ast.UnopExp:
+			info.unary = true
+		case *ast.BinopExp:
+			info.binary = true
+		case *ast.FuncCallExp:
+			info.attached = kind == ast.TkSepLparen
+		case *ast.TableAccessExp:
+			info.attached = kind == ast.TkSepLbrack
+		case *ast.FuncDefExp:
+			switch kind {
+			case ast.TkSepLparen:
+				info.attached = true
+			case ast.TkSepRparen:
+				var base ast.Stat = owner
+				if p.tree.Tokens[p.tokenIndex(owner.Loc.Start)].TokenKind == ast.TkSepLparen {
+					// function 语句里的函数从 ( 开始，function 和名字属于语句
+					base = owner.Parent
+				}
+				p.setOpener(info, owner.Block, base)
+			case ast.TkKwEnd:
+				info.closer = true
+			}
+		case *ast.DoStat:
+			p.loopTokens(info, kind, owner, owner.Block)
+		case *ast.WhileStat:
+			p.loopTokens(info, kind, owner, owner.Block)
+		case *ast.ForNumStat:
+			p.loopTokens(info, kind, owner, owner.Block)
+		case *ast.ForInStat:
+			p.loopTokens(info, kind, owner, owner.Block)
+		case *ast.RepeatStat:
+			switch kind {
+			case ast.TkKwRepeat:
+				p.setOpener(info, owner.Block, owner)
+			case ast.TkKwUntil:
+				info.closer = true
+			}
+		case *ast.IfStat:
+			switch kind {
+			case ast.TkKwThen, ast.TkKwElse:
+				var branch = ifBranch[owner]
+				ifBranch[owner]++
+				if branch < len(owner.Blocks) {
+					p.setOpener(info, owner.Blocks[branch], owner)
+				}
+			}
+			info.closer = kind == ast.TkKwElseIf || kind == ast.TkKwElse || kind == ast.TkKwEnd
+		case *ast.LabelStat:
+			if kind == ast.TkSepLabel {
+				// :: Name ::
+				info.tightAfter = tok.Loc.Start.Before(owner.Name.Loc.Start)
+				info.tightBefore = !info.tightAfter
+			}
+		case *ast.LocalVarDeclStat:
+			// local x <const>
+			info.tightAfter = kind == ast.TkOpLt
+			info.tightBefore = kind == ast.TkOpGt
+		}
+	}
+}
+
+// loopTokens do while for 语句的 do 和 end
+func (p *printer) loopTokens(info *tokenInfo, kind ast.TkKind, stat ast.Stat, block *ast.Block) {
+	switch kind {
+	case ast.TkKwDo:
+		p.setOpener(info, block, stat)
+	case ast.TkKwEnd:
+		info.closer = true
+	}
+}
+
+func (p *printer) setOpener(info *tokenInfo, block *ast.Block, base ast.Stat) {
+	info.opener = block
+	info.base = p.tokenIndex(base.GetLoc().Start)
+}
+
+// hasComment 单词前面是否有注释
+func (p *printer) hasComment(idx int) bool {
+	for _, trivia := range p.tree.Tokens[idx].LeadingTrivia {
+		if trivia.Kind == ast.TriviaComment {
+			return true
+		}
+	}
+	return false
+}
+
+// hasNewline 单词前面是否有换行
+func (p *printer) hasNewline(idx int) bool {
+	for _, trivia := range p.tree.Tokens[idx].LeadingTrivia {
+		if trivia.Kind == ast.TriviaNewline {
+			return true
+		}
+	}
+	return false
+}
+
+// callParens 参数只有一个字符串或者表构造的函数调用，按照配置加上或者去掉括号
+func (p *printer) callParens() {
+	if p.opts.CallParens != CallParensAlways && p.opts.CallParens != CallParensOmit {
+		return
+	}
+	ast.Inspect(p.tree.Block, func(node ast.Stat) bool {
+		var call, ok = node.(*ast.FuncCallExp)
+		if !ok || len(call.Args) != 1 {
+			return node != nil
+		}
+		switch call.Args[0].(type) {
+		case *ast.StringExp, *ast.TableConstructorExp:
+		default:
+			return true
+		}
+		var loc = call.Args[0].GetLoc()
+		var first, last = p.tokenIndex(loc.Start), p.lastTokenIndex(loc.End)
+		var lparen = p.tree.FindToken(call, ast.TkSepLparen)
+		if lparen == nil && p.opts.CallParens == CallParensAlways {
+			p.info[first].before = append(p.info[first].before, ast.TkSepLparen)
+			p.info[last].after = append(p.info[last].after, ast.TkSepRparen)
+		}
+		if lparen != nil && p.opts.CallParens == CallParensOmit {
+			var lidx, ridx = first - 1, last + 1
+			if !p.hasComment(lidx) && !p.hasComment(first) && !p.hasComment(ridx) {
+				p.info[lidx].skip = true
+				p.info[ridx].skip = true
+			}
+		}
+		return true
+	})
+}
+
+// trailingSeparators 按照配置加上或者去掉表构造末尾的分隔符，} 单独一行的是多行的表
+func (p *printer) trailingSeparators() {
+	if p.opts.TrailingSeparator != TrailingAlways && p.opts.TrailingSeparator != TrailingNever {
+		return
+	}
+	ast.Inspect(p.tree.Block, func(node ast.Stat) bool {
+		var table, ok = node.(*ast.TableConstructorExp)
+		if !ok || len(table.ValExps) == 0 {
+			return node != nil
+		}
+		var ridx = p.lastTokenIndex(table.Loc.End)
+		var last = p.tree.Tokens[ridx-1]
+		var hasSep = last.Owner == table && (last.TokenKind == ast.TkSepComma || last.TokenKind == ast.TkSepSemi)
+		var multiline = p.hasNewline(ridx)
+		switch {
+		case !hasSep && multiline && p.opts.TrailingSeparator == TrailingAlways:
+			p.info[ridx-1].after = append(p.info[ridx-1].after, ast.TkSepComma)
+		case hasSep && (!multiline || p.opts.TrailingSeparator == TrailingNever):
+			if !p.hasComment(ridx - 1) {
+				p.info[ridx-1].skip = true
+			}
+		}
+		return true
+	})
+}
+
+// quotes 按照配置修改短字符串的引号，内容里有引号的不修改
+func (p *printer) quotes() {
+	var quote string
+	switch p.opts.QuoteStyle {
+	case QuoteDouble:
+		quote = `"`
+	case QuoteSingle:
+		quote = `'`
+	default:
+		return
+	}
+	for i, tok := range p.tree.Tokens {
+		if tok.TokenKind != ast.TkString || !strings.HasPrefix(tok.Text, `"`) && !strings.HasPrefix(tok.Text, `'`) {
+			continue
+		}
+		var body = tok.Text[1 : len(tok.Text)-1]
+		if !strings.HasPrefix(tok.Text, quote) && !strings.ContainsAny(body, `"'`) {
+			p.info[i].text = quote + body + quote
+		}
+	}
+}
+
+// items 输出的所有单词，包括插入的单词，不包括删掉的
+func (p *printer) items() []*item {
+	var list []*item
+	var insert = func(kinds []ast.TkKind) {
+		for _, kind := range kinds {
+			var it = &item{idx: -1, kind: kind, text: kind.String()}
+			it.attached = kind == ast.TkSepLparen
+			list = append(list, it)
+		}
+	}
+	for i, tok := range p.tree.Tokens {
+		var info = p.info[i]
+		insert(info.before)
+		if !info.skip {
+			var it = &item{tokenInfo: info, idx: i, kind: tok.TokenKind, text: normalize(tok.Text), trivia: tok.LeadingTrivia}
+			if info.text != "" {
+				it.text = info.text
+			}
+			list = append(list, it)
+		}
+		insert(info.after)
+	}
+	return list
+}
+
+// print 输出格式化的文本
+func (p *printer) print() {
+	for _, it := range p.items() {
+		p.comments(it)
+		p.token(it)
+	}
+	if p.out.Len() > 0 {
+		p.out.WriteByte('\n')
+	}
+}
+
+// comments 输出单词前面的注释。和前一个单词在同一行的放在行尾，其他的单独一行
+func (p *printer) comments(it *item) {
+	var newlines = 0
+	var comment = false // 同一行前面有注释，后面的单词要加空格
+	for _, trivia := range it.trivia {
+		switch trivia.Kind {
+		case ast.TriviaNewline:
+			newlines++
+		case ast.TriviaShebang:
+			p.out.WriteString(trivia.Text)
+			p.needNewline = true
+		case ast.TriviaComment:
+			var text = commentText(trivia)
+			switch {
+			case p.out.Len() == 0:
+			case newlines == 0 && !p.needNewline:
+				p.out.WriteByte(' ')
+			default:
+				p.newline(p.indent(it, true), newlines > 1)
+			}
+			p.out.WriteString(text)
+			p.needNewline = !isLongComment(text)
+			p.afterOpener = false
+			newlines = 0
+			comment = true
+		}
+	}
+	if it.kind == ast.TkEOF {
+		return
+	}
+
+	var newline = p.needNewline || it.stmtStart || newlines > 0 && it.kind != ast.TkSepSemi
+	if it.closer {
+		newline = newline || newlines > 0 || !p.topBlock().empty
+	}
+	if newline && p.out.Len() > 0 {
+		p.newline(p.indent(it, false), newlines > 1 && !it.closer && !isBracketClose(it.kind))
+	} else if comment || p.prev != nil && p.needSpace(p.prev, it) {
+		p.out.WriteByte(' ')
+	}
+}
+
+// commentText 输出的注释文本，短注释去掉行尾的空白
+func commentText(trivia ast.Trivia) string {
+	var text = normalize(trivia.Text)
+	if !isLongComment(text) {
+		text = strings.TrimRight(text, " \t")
+	}
+	return text
+}
+
+// isLongComment 是否为 --[[ ]] 这样的长注释
+func isLongComment(text string) bool {
+	var rest = strings.TrimPrefix(text, "--")
+	if !strings.HasPrefix(rest, "[") {
+		return false
+	}
+	rest = strings.TrimLeft(rest[1:], "=")
+	return strings.HasPrefix(rest, "[")
+}
+
+// newline 换行并缩进，blank 表示源码里有空行，保留一个
+func (p *printer) newline(indent int, blank bool) {
+	p.out.WriteByte('\n')
+	if blank && !p.afterOpener {
+		p.out.WriteByte('\n')
+	}
+	if p.opts.UseTabs {
+		p.out.WriteString(strings.Repeat("\t", indent))
+	} else {
+		p.out.WriteString(strings.Repeat(" ", indent*p.opts.IndentWidth))
+	}
+	p.lineIndent = indent
+	p.needNewline = false
+}
+
+// token 输出单词，并且更新缩进的层次
+func (p *printer) token(it *item) {
+	if it.kind == ast.TkEOF {
+		return
+	}
+	if it.stmtStart {
+		p.popToBlock()
+		p.frames = append(p.frames, frame{kind: frameStat, base: p.lineIndent})
+	}
+	if it.closer {
+		p.popToBlock()
+		p.frames = p.frames[:len(p.frames)-1]
+	}
+	if isBracketClose(it.kind) {
+		for len(p.frames) > 1 {
+			var top = p.frames[len(p.frames)-1]
+			p.frames = p.frames[:len(p.frames)-1]
+			if top.kind == frameBracket {
+				break
+			}
+		}
+	}
+
+	var start = p.out.Len()
+	p.out.WriteString(it.text)
+	if it.idx >= 0 {
+		p.tokIndent[it.idx] = p.lineIndent
+		if it.text == normalize(p.tree.Tokens[it.idx].Text) {
+			p.spans[it.idx] = span{start: start, end: p.out.Len()}
+		}
+	}
+
+	p.afterOpener = false
+	switch it.kind {
+	case ast.TkSepLparen, ast.TkSepLbrack, ast.TkSepLcurly:
+		p.frames = append(p.frames, frame{kind: frameBracket, base: p.lineIndent})
+		p.afterOpener = true
+	}
+	if it.opener != nil {
+		p.frames = append(p.frames, frame{kind: frameBlock, base: p.tokIndent[it.base], empty: len(it.opener.Stats) == 0})
+		p.afterOpener = true
+	}
+	p.prev = it
+}
+
+// popToBlock 结束当前语句，回到所在的语句块
+func (p *printer) popToBlock() {
+	for len(p.frames) > 1 && p.frames[len(p.frames)-1].kind != frameBlock {
+		p.frames = p.frames[:len(p.frames)-1]
+	}
+}
+
+func (p *printer) topBlock() frame {
+	p.popToBlock()
+	return p.frames[len(p.frames)-1]
+}
+
+// indent 单词新起一行时的缩进，comment 表示是单词前面单独一行的注释。
+// 结束语句块和括号的单词和开始的行对齐，前面的注释和里面的内容对齐
+func (p *printer) indent(it *item, comment bool) int {
+	if it.stmtStart || it.closer || it.kind == ast.TkEOF {
+		var top = p.topBlock()
+		if it.closer && !comment {
+			return top.base
+		}
+		return top.base + 1
+	}
+	var top = p.frames[len(p.frames)-1]
+	if isBracketClose(it.kind) && top.kind == frameBracket && !comment {
+		return top.base
+	}
+	return top.base + 1
+}
+
+func isBracketClose(kind ast.TkKind) bool {
+	return kind == ast.TkSepRparen || kind == ast.TkSepRbrack || kind == ast.TkSepRcurly
+}
+
+// needSpace 同一行的两个单词之间是否要加空格
+func (p *printer) needSpace(prev, cur *item) bool {
+	var space = true
+	switch {
+	case cur.attached || prev.tightAfter || cur.tightBefore:
+		space = false
+	case cur.kind == ast.TkSepComma || cur.kind == ast.TkSepSemi:
+		space = false
+	case cur.kind == ast.TkSepDot || cur.kind == ast.TkSepColon || prev.kind == ast.TkSepDot || prev.kind == ast.TkSepColon:
+		space = false
+	case cur.kind == ast.TkSepRparen || cur.kind == ast.TkSepRbrack:
+		space = false
+	case prev.kind == ast.TkSepLparen || prev.kind == ast.TkSepLbrack:
+		space = false
+	case cur.kind == ast.TkSepRcurly:
+		space = prev.kind != ast.TkSepLcurly
+	case prev.kind == ast.TkSepLcurly:
+		space = true
+	case prev.unary:
+		space = prev.kind == ast.TkOpNot
+	case prev.binary || cur.binary:
+		space = p.opts.OperatorSpaces || prev.binary && isWord(prev.text) || cur.binary && isWord(cur.text)
+	}
+	return space || glued(prev.text, cur.text)
+}
+
+// isWord 是否为 and or 这样的单词
+func isWord(text string) bool {
+	return text != "" && isNameChar(text[0])
+}
+
+func isNameChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
+
+// glued 两个单词紧挨着时是否会被当成别的单词，例如 - - 变成注释，[ [ 变成长字符串
+func glued(prev, cur string) bool {
+	var a, b = prev[len(prev)-1], cur[0]
+	switch {
+	case isNameChar(a) && isNameChar(b):
+		return true
+	case isNumber(prev) && (b == '.' || isNameChar(b)):
+		return true
+	case a == '.' && (b == '.' || b >= '0' && b <= '9'):
+		return true
+	case a == '-' && b == '-', a == '[' && (b == '[' || b == '='), a == '/' && b == '/',
+		a == '<' && b == '<', a == '>' && b == '>', a == ':' && b == ':':
+		return true
+	case b == '=' && strings.IndexByte("=<>~", a) >= 0:
+		return true
+	}
+	return false
+}
+
+func isNumber(text string) bool {
+	return text[0] >= '0' && text[0] <= '9' || len(text) > 1 && text[0] == '.' && text[1] >= '0' && text[1] <= '9'
+}
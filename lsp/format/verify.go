@@ -0,0 +1,115 @@
+package format
+
+import (
+	"errors"
+	"fmt"
+	"mylua-lsp/lsp/ast"
+	"mylua-lsp/lsp/common"
+	"mylua-lsp/lsp/compiler"
+	"slices"
+	"strings"
+)
+
+// verify 重新解析格式化的结果，语法树和注释都要和源码的一样
+func verify(tree *ast.ConcreteTree, text string, version common.LuaVersion) error {
+	newTree, _, errList := compiler.ParseLuaSourceLossless(common.NewLuaSource([]byte(text), ""), version)
+	if len(errList) > 0 {
+		return fmt.Errorf("formatting produced invalid code: %s", errList[0].ErrStr)
+	}
+	if dumpTree(tree.Block) != dumpTree(newTree.Block) {
+		return errors.New("formatting changed the syntax tree")
+	}
+	if !slices.Equal(comments(tree), comments(newTree)) {
+		return errors.New("formatting changed the comments")
+	}
+	return nil
+}
+
+// treeDumper 把语法树输出成文本，只包含和语义相关的内容，不包括位置
+type treeDumper struct {
+	builder strings.Builder
+}
+
+func (d *treeDumper) Visit(node ast.Stat) ast.Visitor {
+	if node == nil {
+		d.builder.WriteByte(')')
+		return nil
+	}
+	fmt.Fprintf(&d.builder, "(%T", node)
+	switch n := node.(type) {
+	case *ast.LabelStat:
+		d.write(n.Name.TokenStr)
+	case *ast.GotoStat:
+		d.write(n.Name.TokenStr)
+	case *ast.IfStat:
+		d.write(len(n.Exps), len(n.Blocks))
+	case *ast.ForNumStat:
+		d.write(n.VarName.TokenStr, n.StepExp != nil)
+	case *ast.ForInStat:
+		d.writeTokens(n.NameList)
+	case *ast.AssignStat:
+		d.write(len(n.VarList))
+	case *ast.LocalVarDeclStat:
+		d.writeTokens(n.NameList)
+	case *ast.LocalFuncDefStat:
+		d.write(n.Name.TokenStr)
+	case *ast.RetStat:
+		d.write(len(n.ExpList))
+	case *ast.IntegerExp:
+		d.write(n.Val)
+	case *ast.FloatExp:
+		d.write(n.Val)
+	case *ast.StringExp:
+		d.write(n.Str)
+	case *ast.UnopExp:
+		d.write(n.Op)
+	case *ast.BinopExp:
+		d.write(n.Op)
+	case *ast.TableConstructorExp:
+		// 没有 key 的字段 KeyExps 里是 nil
+		for _, key := range n.KeyExps {
+			d.write(key != nil)
+		}
+	case *ast.FuncDefExp:
+		d.writeTokens(n.ParList)
+		d.write(n.IsVararg, n.IsColon)
+	case *ast.NameExp:
+		d.write(n.Name)
+	case *ast.TableAccessExp:
+		d.write(n.IsWriteExp)
+	case *ast.FuncCallExp:
+		d.write(n.NameExp != nil, len(n.Args))
+	}
+	return d
+}
+
+func (d *treeDumper) write(values ...any) {
+	for _, value := range values {
+		fmt.Fprintf(&d.builder, " %#v", value)
+	}
+}
+
+func (d *treeDumper) writeTokens(list []ast.Token) {
+	for _, token := range list {
+		d.write(token.TokenStr, token.LocalAttr)
+	}
+}
+
+func dumpTree(block *ast.Block) string {
+	var d = &treeDumper{}
+	ast.Walk(d, block)
+	return d.builder.String()
+}
+
+// comments 所有注释的文本
+func comments(tree *ast.ConcreteTree) []string {
+	var list []string
+	for _, tok := range tree.Tokens {
+		for _, trivia := range tok.LeadingTrivia {
+			if trivia.Kind == ast.TriviaComment {
+				list = append(list, commentText(trivia))
+			}
+		}
+	}
+	return list
+}
@@ -0,0 +1,141 @@
+package langserver
+
+import (
+	"context"
+	"mylua-lsp/lsp/ast"
+	"mylua-lsp/lsp/check"
+	"mylua-lsp/lsp/protocol"
+	"path"
+)
+
+// unresolvedData 解析不出来的调用的 data ，这样的节点没有下一层
+const unresolvedData = "unresolved"
+
+// TextDocumentPrepareCallHierarchy 光标所在的函数
+func (s *Server) TextDocumentPrepareCallHierarchy(ctx context.Context, params *protocol.CallHierarchyPrepareParams) ([]protocol.CallHierarchyItem, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var doc = s.getDocument(params.TextDocument.URI)
+	if doc == nil {
+		return nil, nil
+	}
+	var funcInfo = s.project.CallHierarchyFunc(doc.file, toPosition(doc.file.Source, params.Position))
+	if funcInfo == nil {
+		return nil, nil
+	}
+	return []protocol.CallHierarchyItem{callItem(funcInfo)}, nil
+}
+
+// CallHierarchyIncomingCalls 调用了这个函数的函数，文件顶层的调用显示为文件
+func (s *Server) CallHierarchyIncomingCalls(ctx context.Context, params *protocol.CallHierarchyIncomingCallsParams) ([]protocol.CallHierarchyIncomingCall, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var funcInfo = s.itemFunc(params.Item)
+	if funcInfo == nil {
+		return nil, nil
+	}
+	var result = []protocol.CallHierarchyIncomingCall{}
+	for _, edge := range s.project.IncomingCalls(funcInfo) {
+		result = append(result, protocol.CallHierarchyIncomingCall{
+			From:       callItem(edge.Func),
+			FromRanges: callRanges(edge),
+		})
+	}
+	return result, nil
+}
+
+// CallHierarchyOutgoingCalls 这个函数调用的函数，解析不出来的动态调用也列出来
+func (s *Server) CallHierarchyOutgoingCalls(ctx context.Context, params *protocol.CallHierarchyOutgoingCallsParams) ([]protocol.CallHierarchyOutgoingCall, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var funcInfo = s.itemFunc(params.Item)
+	if funcInfo == nil {
+		return nil, nil
+	}
+	var result = []protocol.CallHierarchyOutgoingCall{}
+	for _, edge := range s.project.OutgoingCalls(funcInfo) {
+		var to protocol.CallHierarchyItem
+		if edge.Func != nil {
+			to = callItem(edge.Func)
+		} else {
+			var r = toRange(edge.File.Source, check.CalleeLoc(edge.Calls[0]))
+			to = protocol.CallHierarchyItem{
+				Name:           edge.Name,
+				Kind:           protocol.Function,
+				Detail:         "unresolved",
+				URI:            pathToURI(edge.File.GetPath()),
+				Range:          r,
+				SelectionRange: r,
+				Data:           unresolvedData,
+			}
+		}
+		result = append(result, protocol.CallHierarchyOutgoingCall{To: to, FromRanges: callRanges(edge)})
+	}
+	return result, nil
+}
+
+// callItem 函数对应的节点，主函数显示为文件
+func callItem(funcInfo *ast.FuncInfo) protocol.CallHierarchyItem {
+	var file = funcInfo.File
+	var source = file.Source
+	var item = protocol.CallHierarchyItem{
+		Name:   funcInfo.Name,
+		Kind:   protocol.Function,
+		Detail: path.Base(file.GetPath()),
+		URI:    pathToURI(file.GetPath()),
+	}
+	switch {
+	case funcInfo == file.MainFunc:
+		item.Name = path.Base(file.GetPath())
+		item.Kind = protocol.File
+		item.Detail = ""
+		item.Range = toRange(source, funcInfo.Scope.Loc)
+		item.SelectionRange = toRange(source, funcInfo.NameLoc)
+		return item
+	case funcInfo.Name == "":
+		item.Name = "function"
+	case funcInfo.IsColon():
+		item.Kind = protocol.Method
+	}
+	// 范围要包含名字，名字可能在 function 关键字前面
+	var loc = funcInfo.FuncDef.Loc
+	if funcInfo.NameLoc.Start.Before(loc.Start) {
+		loc.Start = funcInfo.NameLoc.Start
+	}
+	item.Range = toRange(source, loc)
+	item.SelectionRange = toRange(source, funcInfo.NameLoc)
+	return item
+}
+
+// itemFunc 客户端传回的节点对应的函数，文件已经修改时可能找不到
+func (s *Server) itemFunc(item protocol.CallHierarchyItem) *ast.FuncInfo {
+	if item.Data == unresolvedData {
+		return nil
+	}
+	var doc = s.getDocument(item.URI)
+	if doc == nil {
+		return nil
+	}
+	if item.Kind == protocol.File {
+		return doc.file.MainFunc
+	}
+	var pos = toPosition(doc.file.Source, item.SelectionRange.Start)
+	for _, funcInfo := range doc.file.FuncList[1:] {
+		if funcInfo.NameLoc.Start == pos {
+			return funcInfo
+		}
+	}
+	return nil
+}
+
+// callRanges 调用的地方，都在调用者的文件里
+func callRanges(edge *check.CallEdge) []protocol.Range {
+	var result = []protocol.Range{}
+	for _, call := range edge.Calls {
+		result = append(result, toRange(edge.File.Source, check.CalleeLoc(call)))
+	}
+	return result
+}
@@ -0,0 +1,93 @@
+package langserver
+
+import (
+	"context"
+	"mylua-lsp/lsp/check"
+	"mylua-lsp/lsp/protocol"
+	"slices"
+	"strings"
+)
+
+// codeActionKinds 支持的代码操作种类
+var codeActionKinds = []protocol.CodeActionKind{protocol.QuickFix, protocol.RefactorRewrite}
+
+// TextDocumentCodeAction 范围内的代码操作，修复和诊断关联。客户端传来的每个诊断还可以用注释关闭
+func (s *Server) TextDocumentCodeAction(ctx context.Context, params *protocol.CodeActionParams) ([]protocol.CodeAction, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var doc = s.getDocument(params.TextDocument.URI)
+	if doc == nil {
+		return nil, nil
+	}
+	var source = doc.file.Source
+	var diagnostics = params.Context.Diagnostics
+	var actions = s.project.CodeActions(doc.file, toLocation(source, params.Range), s.diagnosticOptions())
+	// 同一行同一种诊断只关闭一次
+	type lineCode struct {
+		line int
+		code string
+	}
+	var suppressed = map[lineCode]bool{}
+	for _, diag := range diagnostics {
+		var code, ok = diag.Code.(string)
+		var line = int(diag.Range.Start.Line)
+		var key = lineCode{line, code}
+		if !ok || code == "" || suppressed[key] {
+			continue
+		}
+		suppressed[key] = true
+		actions = append(actions, check.SuppressAction(doc.file, line, code))
+	}
+
+	var result = []protocol.CodeAction{}
+	for _, action := range actions {
+		var kind = protocol.QuickFix
+		if action.Kind == check.CodeActionRewrite {
+			kind = protocol.RefactorRewrite
+		}
+		if !wantKind(params.Context.Only, kind) {
+			continue
+		}
+		var item = protocol.CodeAction{
+			Title: action.Title,
+			Kind:  kind,
+			Edit:  toWorkspaceEdit(action.Edits),
+		}
+		for _, diag := range diagnostics {
+			var code, _ = diag.Code.(string)
+			var loc = toLocation(source, diag.Range)
+			if slices.Contains(action.Codes, code) && !loc.End.Before(action.Loc.Start) && !action.Loc.End.Before(loc.Start) {
+				item.Diagnostics = append(item.Diagnostics, diag)
+			}
+		}
+		result = append(result, item)
+	}
+	return result, nil
+}
+
+// wantKind 客户端是否需要这种代码操作，only 为空时都需要，refactor 包括 refactor.rewrite
+func wantKind(only []protocol.CodeActionKind, kind protocol.CodeActionKind) bool {
+	if len(only) == 0 {
+		return true
+	}
+	for _, prefix := range only {
+		if kind == prefix || strings.HasPrefix(string(kind), string(prefix)+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// toWorkspaceEdit 按文件分组修改
+func toWorkspaceEdit(edits []check.TextEdit) protocol.WorkspaceEdit {
+	var result = protocol.WorkspaceEdit{Changes: map[string][]protocol.TextEdit{}}
+	for _, edit := range edits {
+		var uri = string(pathToURI(edit.File.GetPath()))
+		result.Changes[uri] = append(result.Changes[uri], protocol.TextEdit{
+			Range:   toRange(edit.File.Source, edit.Loc),
+			NewText: edit.Text,
+		})
+	}
+	return result
+}
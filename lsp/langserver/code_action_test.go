@@ -0,0 +1,81 @@
+package langserver
+
+import (
+	"context"
+	"mylua-lsp/lsp/common"
+	"mylua-lsp/lsp/protocol"
+	"slices"
+	"sort"
+	"testing"
+)
+
+// applyTextEdits 把协议里的修改应用到文本上
+func applyTextEdits(text string, edits []protocol.TextEdit) string {
+	var source = common.NewLuaSource([]byte(text), "")
+	edits = slices.Clone(edits)
+	sort.SliceStable(edits, func(i, j int) bool {
+		var a, b = edits[i].Range.Start, edits[j].Range.Start
+		return a.Line > b.Line || a.Line == b.Line && a.Character > b.Character
+	})
+	for _, edit := range edits {
+		source = source.ApplyChange(toLocation(source, edit.Range), edit.NewText)
+	}
+	return source.GetRawText(common.Location{End: common.Position{Line: int32(source.GetLineNum())}})
+}
+
+// codeActions 请求代码操作，key 是标题
+func codeActions(t *testing.T, s *Server, params *protocol.CodeActionParams) map[string]protocol.CodeAction {
+	t.Helper()
+	var list, err = s.TextDocumentCodeAction(context.Background(), params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actions = map[string]protocol.CodeAction{}
+	for _, action := range list {
+		actions[action.Title] = action
+	}
+	return actions
+}
+
+func TestCodeAction(t *testing.T) {
+	var s = newTestServer(t, nil)
+	var text = "local function f()\n  counter = '中'\nend\nf()\n"
+	s.open(t, "a.lua", text)
+	var params = &protocol.CodeActionParams{TextDocument: textDocument("a.lua"), Range: textRange(1, 3, 3)}
+	var diag = protocol.Diagnostic{Range: textRange(1, 2, 9), Code: "global-in-function", Message: "global"}
+	params.Context.Diagnostics = []protocol.Diagnostic{diag}
+
+	var actions = codeActions(t, s, params)
+	var declare, ok = actions["Declare 'counter' as local"]
+	if !ok {
+		t.Fatalf("no action to declare local in %v", actions)
+	}
+	if declare.Kind != protocol.QuickFix || len(declare.Diagnostics) != 1 {
+		t.Errorf("got kind %s and diagnostics %v", declare.Kind, declare.Diagnostics)
+	}
+	var want = "local function f()\n  local counter = '中'\nend\nf()\n"
+	if got := applyTextEdits(text, declare.Edit.Changes[string(testURI("a.lua"))]); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	var suppress = actions["Disable 'global-in-function' for this line"]
+	want = "local function f()\n  ---@diagnostic disable-next-line: global-in-function\n  counter = '中'\nend\nf()\n"
+	if got := applyTextEdits(text, suppress.Edit.Changes[string(testURI("a.lua"))]); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+// 客户端指定种类时只返回这种代码操作
+func TestCodeActionOnly(t *testing.T) {
+	var s = newTestServer(t, nil)
+	s.open(t, "a.lua", "local function f(x, y)\n  return x + y\nend\nreturn f\n")
+	var params = &protocol.CodeActionParams{TextDocument: textDocument("a.lua"), Range: textRange(0, 16, 16)}
+	params.Context.Only = []protocol.CodeActionKind{protocol.QuickFix}
+	if actions := codeActions(t, s, params); len(actions) != 0 {
+		t.Errorf("got %v with only quickfix", actions)
+	}
+	params.Context.Only = []protocol.CodeActionKind{protocol.Refactor}
+	if _, ok := codeActions(t, s, params)["Add @param for all parameters"]; !ok {
+		t.Error("no rewrite action with only refactor")
+	}
+}
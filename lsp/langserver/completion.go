@@ -0,0 +1,153 @@
+package langserver
+
+import (
+	"context"
+	"encoding/json"
+	"mylua-lsp/lsp/ast"
+	"mylua-lsp/lsp/check"
+	"mylua-lsp/lsp/protocol"
+	"strings"
+)
+
+// completionData 补全项带上的数据，resolve 时用它找到补全列表里的这一项
+type completionData struct {
+	ID    int `json:"id"`
+	Index int `json:"index"`
+}
+
+// completionCache 上一次补全的结果，resolve 时直接取里面的补全项。修改文件之后清空
+type completionCache struct {
+	id   int
+	list []*check.Completion
+}
+
+// TextDocumentCompletion 代码补全，说明在 resolve 的时候再生成
+func (s *Server) TextDocumentCompletion(ctx context.Context, params *protocol.CompletionParams) (*protocol.CompletionList, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var doc = s.getDocument(params.TextDocument.URI)
+	if doc == nil {
+		return nil, nil
+	}
+	var source = doc.file.Source
+	var list, wordLoc = s.project.Complete(doc.file, toPosition(source, params.Position), s.settings.LuaVersion)
+	var wordRange = toRange(source, wordLoc)
+	s.completionSeq++
+	s.completion = &completionCache{id: s.completionSeq, list: list}
+	var result = &protocol.CompletionList{Items: []protocol.CompletionItem{}}
+	for i, c := range list {
+		if c.Kind == check.CompletionSnippet && !s.clientCaps.snippet {
+			continue
+		}
+		var item = protocol.CompletionItem{
+			Label: c.Label,
+			Kind:  s.completionItemKind(c),
+			TextEdit: &protocol.TextEdit{
+				Range:   wordRange,
+				NewText: c.Label,
+			},
+			Data: completionData{ID: s.completionSeq, Index: i},
+		}
+		if c.Kind == check.CompletionSnippet {
+			item.FilterText = c.Filter
+			item.TextEdit.NewText = c.Snippet
+			item.InsertTextFormat = protocol.SnippetTextFormat
+		}
+		result.Items = append(result.Items, item)
+	}
+	return result, nil
+}
+
+// completionItemKind 补全项在协议里的种类，只根据定义的语法判断，不做类型推导
+func (s *Server) completionItemKind(c *check.Completion) protocol.CompletionItemKind {
+	var p = s.project
+	switch c.Kind {
+	case check.CompletionVar:
+		if _, ok := c.Var.ValueExp.(*ast.FuncDefExp); ok || c.Var.Kind == ast.VarKindLocalFunc || isFuncType(c.Var.AnnType) {
+			return protocol.FunctionCompletion
+		}
+		return protocol.VariableCompletion
+	case check.CompletionMember:
+		for _, member := range c.Members {
+			if kind := memberItemKind(member); kind != protocol.FieldCompletion {
+				return kind
+			}
+		}
+		return protocol.FieldCompletion
+	case check.CompletionKeyword, check.CompletionTag:
+		return protocol.KeywordCompletion
+	case check.CompletionSnippet:
+		return protocol.SnippetCompletion
+	case check.CompletionType:
+		switch {
+		case len(p.GetClass(c.Label)) > 0:
+			return protocol.ClassCompletion
+		case len(p.GetEnum(c.Label)) > 0:
+			return protocol.EnumCompletion
+		case len(p.GetAlias(c.Label)) > 0:
+			return protocol.InterfaceCompletion
+		}
+		return protocol.TypeParameterCompletion
+	}
+	return protocol.TextCompletion
+}
+
+// memberItemKind 一个字段定义的种类，: 定义的函数和第一个参数是 self 的函数类型是方法
+func memberItemKind(member *check.Member) protocol.CompletionItemKind {
+	if field := member.Field; field != nil {
+		if field.IsMethod {
+			return protocol.MethodCompletion
+		}
+		if _, ok := field.ValueExp.(*ast.FuncDefExp); ok || isFuncType(field.AnnType) {
+			return protocol.FunctionCompletion
+		}
+	}
+	if field := member.ClassField; field != nil {
+		if t, ok := field.Type.(*ast.Type_Fun); ok && t != nil {
+			if len(t.ParamList) > 0 && t.ParamList[0].NameAndLoc.Name == "self" {
+				return protocol.MethodCompletion
+			}
+			return protocol.FunctionCompletion
+		}
+	}
+	return protocol.FieldCompletion
+}
+
+// isFuncType 注释标记的类型是否为函数
+func isFuncType(t ast.TypeBase) bool {
+	var fun, ok = t.(*ast.Type_Fun)
+	return ok && fun != nil
+}
+
+// CompletionItemResolve 补全项的类型和说明
+func (s *Server) CompletionItemResolve(ctx context.Context, item *protocol.CompletionItem) (*protocol.CompletionItem, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// 经过 json 传输后 Data 是 map ，统一转换一次
+	var data completionData
+	if content, err := json.Marshal(item.Data); err != nil || json.Unmarshal(content, &data) != nil {
+		return item, nil
+	}
+	var cache = s.completion
+	if cache == nil || cache.id != data.ID || data.Index < 0 || data.Index >= len(cache.list) {
+		return item, nil
+	}
+	var c = cache.list[data.Index]
+	if c.Label != item.Label {
+		return item, nil
+	}
+	var w = &hoverWriter{s: s}
+	switch c.Kind {
+	case check.CompletionVar:
+		w.writeVar(c.Var)
+	case check.CompletionMember:
+		w.writeMember(&check.Symbol{Kind: check.SymbolMember, Name: c.Label, Members: c.Members})
+	case check.CompletionType:
+		w.writeType(c.Label)
+	}
+	item.Detail = strings.Join(w.code, "\n")
+	item.Documentation = markdown(strings.Join(w.docList, "\n\n"))
+	return item, nil
+}
@@ -0,0 +1,115 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"mylua-lsp/lsp/protocol"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// completion 补全项，每个是 "名字 种类 替换的范围"
+func completion(t *testing.T, s *Server, params protocol.TextDocumentPositionParams) []string {
+	t.Helper()
+	var result, err = s.TextDocumentCompletion(context.Background(), &protocol.CompletionParams{TextDocumentPositionParams: params})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list []string
+	for _, item := range result.Items {
+		list = append(list, fmt.Sprintf("%s %d %s", item.Label, item.Kind, rangeString(item.TextEdit.Range)))
+	}
+	return list
+}
+
+func TestCompletion(t *testing.T) {
+	var s = newTestServer(t, nil)
+	var text = "local point = { x = 1 }\nfunction point:len() end\npoint.f = function() end\nprint('中', point.|)\n"
+	var got = completion(t, s, openAt(t, s, "a.lua", text))
+	var want = []string{
+		fmt.Sprintf("x %d 3:17-3:17", protocol.FieldCompletion),
+		fmt.Sprintf("len %d 3:17-3:17", protocol.MethodCompletion),
+		fmt.Sprintf("f %d 3:17-3:17", protocol.FunctionCompletion),
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// 客户端不支持代码片段时不返回
+func TestCompletionSnippet(t *testing.T) {
+	var text = "local x = 1\nwhi|\n"
+	for _, snippet := range []bool{false, true} {
+		var s = newTestServer(t, nil)
+		s.clientCaps.snippet = snippet
+		var result, err = s.TextDocumentCompletion(context.Background(), &protocol.CompletionParams{TextDocumentPositionParams: openAt(t, s, "a.lua", text)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var found bool
+		for _, item := range result.Items {
+			if item.Kind != protocol.SnippetCompletion {
+				continue
+			}
+			found = true
+			if item.InsertTextFormat != protocol.SnippetTextFormat || item.FilterText == "" || item.TextEdit.NewText == item.Label {
+				t.Errorf("snippet %q: format %d filter %q text %q", item.Label, item.InsertTextFormat, item.FilterText, item.TextEdit.NewText)
+			}
+		}
+		if found != snippet {
+			t.Errorf("snippet support %v: got snippets %v", snippet, found)
+		}
+	}
+}
+
+// 注释标记的函数字段不做类型推导也能区分方法和函数
+func TestCompletionClassField(t *testing.T) {
+	var s = newTestServer(t, nil)
+	var text = "---@class Timer\n---@field delay number\n---@field stop fun(self: Timer)\n---@field create fun(): Timer\n---@type Timer\nlocal timer\ntimer.|\n"
+	var got = completion(t, s, openAt(t, s, "a.lua", text))
+	var want = []string{
+		fmt.Sprintf("create %d 6:6-6:6", protocol.FunctionCompletion),
+		fmt.Sprintf("delay %d 6:6-6:6", protocol.FieldCompletion),
+		fmt.Sprintf("stop %d 6:6-6:6", protocol.MethodCompletion),
+	}
+	slices.Sort(got)
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// resolve 直接取上一次补全的结果，修改文件之后不再生成说明
+func TestCompletionResolve(t *testing.T) {
+	var s = newTestServer(t, nil)
+	var params = openAt(t, s, "a.lua", "local point = { x = 1 }\n---返回长度\nfunction point:len() end\nprint(point.|)\n")
+	var result, err = s.TextDocumentCompletion(context.Background(), &protocol.CompletionParams{TextDocumentPositionParams: params})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var item *protocol.CompletionItem
+	for i := range result.Items {
+		if result.Items[i].Label == "len" {
+			item = &result.Items[i]
+		}
+	}
+	if item == nil {
+		t.Fatalf("no len in %v", result.Items)
+	}
+	var resolved = *item
+	if _, err := s.CompletionItemResolve(context.Background(), &resolved); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(resolved.Detail, "len") || !strings.Contains(resolved.Documentation.Value, "返回长度") {
+		t.Errorf("resolved detail %q documentation %v", resolved.Detail, resolved.Documentation)
+	}
+
+	s.change(t, "a.lua", textRange(0, 0, 0), "\n")
+	var stale = *item
+	if _, err := s.CompletionItemResolve(context.Background(), &stale); err != nil {
+		t.Fatal(err)
+	}
+	if stale.Detail != "" {
+		t.Errorf("stale item resolved: %q", stale.Detail)
+	}
+}
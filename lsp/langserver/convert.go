@@ -0,0 +1,102 @@
+package langserver
+
+import (
+	"mylua-lsp/lsp/common"
+	"mylua-lsp/lsp/protocol"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+/*
+协议和内部结构的转换。
+协议里的列是 utf-16 的编码单元，内部的列是 utf-8 的字节偏移。
+*/
+
+// utf16Column 字节偏移转换成 utf-16 的列
+func utf16Column(line string, column int) uint32 {
+	column = min(column, len(line))
+	var count uint32
+	for _, r := range line[:column] {
+		if r >= 0x10000 {
+			count += 2
+		} else {
+			count++
+		}
+	}
+	return count
+}
+
+// byteColumn utf-16 的列转换成字节偏移，超过行尾时为行尾
+func byteColumn(line string, character uint32) int {
+	var count uint32
+	for i, r := range line {
+		if count >= character {
+			return i
+		}
+		if r >= 0x10000 {
+			count += 2
+		} else {
+			count++
+		}
+	}
+	return len(line)
+}
+
+// toPosition 协议的位置转换成内部的位置
+func toPosition(source *common.LuaSource, pos protocol.Position) common.Position {
+	var line = source.GetOneLine(int(pos.Line))
+	return common.Position{
+		Line:   int32(pos.Line),
+		Column: int32(byteColumn(line, pos.Character)),
+	}
+}
+
+// toProtocolPosition 内部的位置转换成协议的位置
+func toProtocolPosition(source *common.LuaSource, pos common.Position) protocol.Position {
+	var line = source.GetOneLine(pos.GetLine())
+	return protocol.Position{
+		Line:      uint32(max(pos.Line, 0)),
+		Character: utf16Column(line, pos.GetColumn()),
+	}
+}
+
+// toRange 内部的范围转换成协议的范围
+func toRange(source *common.LuaSource, loc common.Location) protocol.Range {
+	return protocol.Range{
+		Start: toProtocolPosition(source, loc.Start),
+		End:   toProtocolPosition(source, loc.End),
+	}
+}
+
+// toLocation 协议的范围转换成内部的范围
+func toLocation(source *common.LuaSource, r protocol.Range) common.Location {
+	return common.Location{
+		Start: toPosition(source, r.Start),
+		End:   toPosition(source, r.End),
+	}
+}
+
+// uriToPath file:// 开头的 uri 转换成文件路径，统一使用 / 分隔
+func uriToPath(uri protocol.DocumentURI) string {
+	var u, err = url.Parse(string(uri))
+	if err != nil || u.Scheme != "file" {
+		return string(uri)
+	}
+	var path = u.Path
+	// windows 的 /c:/dir 去掉开头的 /
+	if len(path) >= 3 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+	return filepath.ToSlash(path)
+}
+
+// pathToURI 文件路径转换成 file:// 开头的 uri
+func pathToURI(path string) protocol.DocumentURI {
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	var u = url.URL{Scheme: "file", Path: path}
+	return protocol.DocumentURI(u.String())
+}
@@ -0,0 +1,61 @@
+package langserver
+
+import (
+	"context"
+	"mylua-lsp/lsp/check"
+	"mylua-lsp/lsp/protocol"
+)
+
+// TextDocumentDefinition 跳转到定义，成员优先跳到 lua 代码里的赋值
+func (s *Server) TextDocumentDefinition(ctx context.Context, params *protocol.DefinitionParams) (any, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.definitionResult(params.TextDocumentPositionParams, false, s.clientCaps.definitionLink), nil
+}
+
+// TextDocumentDeclaration 跳转到声明，成员优先跳到 @field
+func (s *Server) TextDocumentDeclaration(ctx context.Context, params *protocol.DeclarationParams) (any, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.definitionResult(params.TextDocumentPositionParams, true, s.clientCaps.declarationLink), nil
+}
+
+// definitionResult 客户端支持时返回 LocationLink ，带上光标所在名字的范围
+func (s *Server) definitionResult(params protocol.TextDocumentPositionParams, declaration bool, linkSupport bool) any {
+	var doc, sym = s.symbolAt(params)
+	if sym == nil {
+		return nil
+	}
+	var defList = s.project.Definitions(sym, declaration)
+	if len(defList) == 0 {
+		return nil
+	}
+	if linkSupport {
+		var originRange = toRange(doc.file.Source, sym.Loc)
+		var linkList = make([]protocol.LocationLink, 0, len(defList))
+		for _, def := range defList {
+			linkList = append(linkList, protocol.LocationLink{
+				OriginSelectionRange: originRange,
+				TargetURI:            pathToURI(def.File.GetPath()),
+				TargetRange:          toRange(def.File.Source, def.Range),
+				TargetSelectionRange: toRange(def.File.Source, def.Loc),
+			})
+		}
+		return linkList
+	}
+	var locList = make([]protocol.Location, 0, len(defList))
+	for _, def := range defList {
+		locList = append(locList, defLocation(def))
+	}
+	return locList
+}
+
+// defLocation 定义的名字在协议里的位置
+func defLocation(def check.DefLoc) protocol.Location {
+	return protocol.Location{
+		URI:   pathToURI(def.File.GetPath()),
+		Range: toRange(def.File.Source, def.Loc),
+	}
+}
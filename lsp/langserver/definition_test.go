@@ -0,0 +1,70 @@
+package langserver
+
+import (
+	"context"
+	"mylua-lsp/lsp/protocol"
+	"slices"
+	"testing"
+)
+
+// libFiles 被其他文件引用的文件
+var libFiles = map[string]string{
+	"lib.lua": "---@class Shape\n---@field area number\nShape = {}\n\nfunction Shape:draw() end\n",
+}
+
+func TestDefinition(t *testing.T) {
+	var tests = []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"local", "local count = 1\nprint(cou|nt)\n", []string{"a.lua 0:6-0:11"}},
+		{"other file", "local s = Sha|pe\n", []string{"lib.lua 2:0-2:5"}},
+		{"method", "---@type Shape\nlocal s\ns:dr|aw()\n", []string{"lib.lua 4:15-4:19"}},
+		{"utf-16 columns", "local s = '\U0001F600中' local cou|nt = s\nprint(count)\n", []string{"a.lua 0:22-0:27"}},
+		{"nothing", "local s = 1 |\n", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s = newTestServer(t, libFiles)
+			var params = openAt(t, s, "a.lua", tt.text)
+			var result, err = s.TextDocumentDefinition(context.Background(), &protocol.DefinitionParams{TextDocumentPositionParams: params})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			if locList, ok := result.([]protocol.Location); ok {
+				for _, loc := range locList {
+					got = append(got, locationString(loc))
+				}
+			} else if result != nil {
+				t.Fatalf("got %T, want []protocol.Location", result)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// 客户端支持 LocationLink 时带上光标所在名字的范围
+func TestDefinitionLink(t *testing.T) {
+	var s = newTestServer(t, libFiles)
+	s.clientCaps.definitionLink = true
+	var params = openAt(t, s, "a.lua", "local s = Sha|pe\n")
+	var result, err = s.TextDocumentDefinition(context.Background(), &protocol.DefinitionParams{TextDocumentPositionParams: params})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var linkList, ok = result.([]protocol.LocationLink)
+	if !ok || len(linkList) != 1 {
+		t.Fatalf("got %#v", result)
+	}
+	var link = linkList[0]
+	if got := rangeString(link.OriginSelectionRange); got != "0:10-0:15" {
+		t.Errorf("got origin %s", got)
+	}
+	if link.TargetURI != testURI("lib.lua") || rangeString(link.TargetSelectionRange) != "2:0-2:5" || rangeString(link.TargetRange) != "2:0-2:10" {
+		t.Errorf("got target %s %s %s", link.TargetURI, rangeString(link.TargetSelectionRange), rangeString(link.TargetRange))
+	}
+}
@@ -0,0 +1,87 @@
+package langserver
+
+import (
+	"context"
+	"mylua-lsp/lsp/check"
+	"mylua-lsp/lsp/protocol"
+	"slices"
+)
+
+// diagnosticSource 诊断里显示的来源
+const diagnosticSource = "mylua"
+
+// Client 服务主动发给客户端的通知
+type Client interface {
+	PublishDiagnostics(ctx context.Context, params *protocol.PublishDiagnosticsParams) error
+}
+
+// SetClient 设置发送通知的客户端，没有设置时不发送诊断
+func (s *Server) SetClient(client Client) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.client = client
+}
+
+// diagnosticOptions 诊断的配置
+func (s *Server) diagnosticOptions() *check.DiagnosticOptions {
+	return &check.DiagnosticOptions{
+		Version: s.settings.LuaVersion,
+		Globals: s.settings.Globals,
+	}
+}
+
+// publishDiagnostics 发送打开的文件的诊断。全局变量和类型会影响其他文件，每次修改后都重新计算所有打开的文件，
+// changed 是修改了的文件，总是发送，其他的文件诊断有变化时才发送
+func (s *Server) publishDiagnostics(ctx context.Context, changed *document) {
+	if s.client == nil {
+		return
+	}
+	for _, doc := range s.docMap {
+		if !doc.opened {
+			continue
+		}
+		var list = s.project.Diagnostics(doc.file, s.diagnosticOptions())
+		if doc != changed && slices.Equal(list, doc.diagnostics) {
+			continue
+		}
+		doc.diagnostics = list
+		s.client.PublishDiagnostics(ctx, &protocol.PublishDiagnosticsParams{
+			URI:         doc.uri,
+			Version:     doc.version,
+			Diagnostics: toDiagnostics(doc, list),
+		})
+	}
+}
+
+// clearDiagnostics 关闭文件后清除客户端显示的诊断
+func (s *Server) clearDiagnostics(ctx context.Context, doc *document) {
+	doc.diagnostics = nil
+	if s.client != nil {
+		s.client.PublishDiagnostics(ctx, &protocol.PublishDiagnosticsParams{
+			URI:         doc.uri,
+			Diagnostics: []protocol.Diagnostic{},
+		})
+	}
+}
+
+func toDiagnostics(doc *document, list []check.Diagnostic) []protocol.Diagnostic {
+	var source = doc.file.Source
+	var result = make([]protocol.Diagnostic, 0, len(list))
+	for _, diag := range list {
+		var item = protocol.Diagnostic{
+			Range:    toRange(source, diag.Loc),
+			Severity: protocol.DiagnosticSeverity(diag.Severity),
+			Source:   diagnosticSource,
+			Message:  diag.Message,
+		}
+		if diag.Code != "" {
+			item.Code = diag.Code
+		}
+		if diag.Unnecessary {
+			item.Tags = []protocol.DiagnosticTag{protocol.Unnecessary}
+		}
+		result = append(result, item)
+	}
+	return result
+}
@@ -0,0 +1,69 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"mylua-lsp/lsp/protocol"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// recordClient 记录发送的诊断，每次是 "文件名 版本: 范围 编号 信息; ..."
+type recordClient struct {
+	list []string
+}
+
+func (c *recordClient) PublishDiagnostics(ctx context.Context, params *protocol.PublishDiagnosticsParams) error {
+	var items []string
+	for _, diag := range params.Diagnostics {
+		items = append(items, fmt.Sprintf("%s %v %s", rangeString(diag.Range), diag.Code, diag.Message))
+	}
+	var name = strings.TrimPrefix(string(params.URI), string(testURI("")))
+	c.list = append(c.list, fmt.Sprintf("%s %d: %s", name, params.Version, strings.Join(items, "; ")))
+	return nil
+}
+
+// take 返回记录的诊断并清空
+func (c *recordClient) take() []string {
+	var list = c.list
+	c.list = nil
+	return list
+}
+
+// checkPublished 检查发送的诊断，打开的文件没有固定的顺序
+func checkPublished(t *testing.T, client *recordClient, want ...string) {
+	t.Helper()
+	var got = client.take()
+	slices.Sort(got)
+	slices.Sort(want)
+	if !slices.Equal(got, want) {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestPublishDiagnostics(t *testing.T) {
+	var s = newTestServer(t, nil)
+	var client = &recordClient{}
+	s.SetClient(client)
+
+	s.open(t, "a.lua", "print('中', Config)\n")
+	checkPublished(t, client, "a.lua 1: 0:11-0:17 undefined-global undefined global 'Config'")
+
+	// 其他文件定义的全局变量，a.lua 的诊断变化了也要发送
+	s.open(t, "b.lua", "Config = {}\nlocal x = \n")
+	checkPublished(t, client,
+		"a.lua 1: ",
+		"b.lua 1: 1:6-1:7 unused-local unused local 'x'; 3:0-3:0 <nil> `EOF` can not start prefixexp",
+	)
+
+	// 没有变化的文件不重新发送
+	s.change(t, "b.lua", textRange(1, 10, 10), "1")
+	checkPublished(t, client, "b.lua 2: 1:6-1:7 unused-local unused local 'x'")
+
+	var params = &protocol.DidCloseTextDocumentParams{TextDocument: textDocument("b.lua")}
+	if err := s.TextDocumentDidClose(context.Background(), params); err != nil {
+		t.Fatal(err)
+	}
+	checkPublished(t, client, "b.lua 0: ", "a.lua 1: 0:11-0:17 undefined-global undefined global 'Config'")
+}
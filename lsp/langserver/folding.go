@@ -0,0 +1,37 @@
+package langserver
+
+import (
+	"context"
+	"mylua-lsp/lsp/check"
+	"mylua-lsp/lsp/protocol"
+)
+
+// foldingKinds 折叠范围在协议里的种类，语句块不写种类
+var foldingKinds = map[check.FoldingKind]string{
+	check.FoldingComment: string(protocol.Comment),
+	check.FoldingRegion:  string(protocol.Region),
+}
+
+// TextDocumentFoldingRange 文件的折叠范围，超出客户端的数量限制时只返回前面的
+func (s *Server) TextDocumentFoldingRange(ctx context.Context, params *protocol.FoldingRangeParams) ([]protocol.FoldingRange, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var doc = s.getDocument(params.TextDocument.URI)
+	if doc == nil {
+		return nil, nil
+	}
+	var list = check.FoldingRanges(doc.file)
+	if limit := s.clientCaps.foldingRangeLimit; limit > 0 && len(list) > limit {
+		list = list[:limit]
+	}
+	var result = []protocol.FoldingRange{}
+	for _, r := range list {
+		result = append(result, protocol.FoldingRange{
+			StartLine: uint32(r.StartLine),
+			EndLine:   uint32(r.EndLine),
+			Kind:      foldingKinds[r.Kind],
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,42 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"mylua-lsp/lsp/protocol"
+	"slices"
+	"testing"
+)
+
+// 只支持按行折叠的客户端：不返回列，结束的 end 保持显示，数量超过限制时只返回前面的
+func TestFoldingRangeLineOnly(t *testing.T) {
+	var text = "--region r\nlocal function f()\n  return {\n    1,\n  }\nend\n--endregion\n"
+	for _, limit := range []uint32{0, 2} {
+		var s = NewServer()
+		var params = &protocol.ParamInitialize{}
+		params.Capabilities.TextDocument.FoldingRange.LineFoldingOnly = true
+		params.Capabilities.TextDocument.FoldingRange.RangeLimit = limit
+		if _, err := s.Initialize(context.Background(), params); err != nil {
+			t.Fatal(err)
+		}
+		s.open(t, "a.lua", text)
+		var result, err = s.TextDocumentFoldingRange(context.Background(), &protocol.FoldingRangeParams{TextDocument: textDocument("a.lua")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, r := range result {
+			if r.StartCharacter != 0 || r.EndCharacter != 0 {
+				t.Errorf("range %d-%d has columns", r.StartLine, r.EndLine)
+			}
+			got = append(got, fmt.Sprintf("%d-%d %s", r.StartLine, r.EndLine, r.Kind))
+		}
+		var want = []string{"0-6 region", "1-4 ", "2-3 "}
+		if limit > 0 {
+			want = want[:limit]
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("limit %d: got %q, want %q", limit, got, want)
+		}
+	}
+}
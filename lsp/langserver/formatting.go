@@ -0,0 +1,104 @@
+package langserver
+
+import (
+	"context"
+	"mylua-lsp/lsp/format"
+	"mylua-lsp/lsp/protocol"
+	"strings"
+)
+
+// onTypeTriggers 输入后格式化的字符，换行格式化上一行，其他的格式化当前行
+var onTypeTriggers = []string{"\n", "d", ")", "}"}
+
+// TextDocumentFormatting 格式化整个文件
+func (s *Server) TextDocumentFormatting(ctx context.Context, params *protocol.DocumentFormattingParams) ([]protocol.TextEdit, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var doc = s.getDocument(params.TextDocument.URI)
+	if doc == nil {
+		return nil, nil
+	}
+	return s.formatEdits(doc, params.Options, func(edit format.Edit) bool {
+		return true
+	})
+}
+
+// TextDocumentRangeFormatting 格式化整个文件，只返回和范围相交的修改
+func (s *Server) TextDocumentRangeFormatting(ctx context.Context, params *protocol.DocumentRangeFormattingParams) ([]protocol.TextEdit, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var doc = s.getDocument(params.TextDocument.URI)
+	if doc == nil {
+		return nil, nil
+	}
+	var loc = toLocation(doc.file.Source, params.Range)
+	return s.formatEdits(doc, params.Options, func(edit format.Edit) bool {
+		return !edit.Loc.End.Before(loc.Start) && !loc.End.Before(edit.Loc.Start)
+	})
+}
+
+// TextDocumentOnTypeFormatting 输入换行后格式化上一行，输入 end 和右括号后格式化当前行。
+// 只修改到这一行为止的内容，不会动光标后面的缩进。正在输入的代码常常有语法错误，这时不做修改
+func (s *Server) TextDocumentOnTypeFormatting(ctx context.Context, params *protocol.DocumentOnTypeFormattingParams) ([]protocol.TextEdit, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var doc = s.getDocument(params.TextDocument.URI)
+	if doc == nil {
+		return nil, nil
+	}
+	var source = doc.file.Source
+	var pos = toPosition(source, params.Position)
+	var line = pos.GetLine()
+	switch params.Ch {
+	case "\n":
+		line--
+	case "d":
+		// 只有输入了 end 才格式化
+		var text = source.GetOneLine(line)[:pos.GetColumn()]
+		var before = strings.TrimSuffix(text, "end")
+		if len(before) == len(text) || before != "" && isNameByte(before[len(before)-1]) {
+			return nil, nil
+		}
+	}
+	if line < 0 {
+		return nil, nil
+	}
+	var result, err = s.formatEdits(doc, params.Options, func(edit format.Edit) bool {
+		return edit.Loc.End.GetLine() == line
+	})
+	if err != nil {
+		return nil, nil
+	}
+	return result, nil
+}
+
+// formatEdits 按照客户端的缩进配置格式化文件，返回 keep 为 true 的修改
+func (s *Server) formatEdits(doc *document, options protocol.FormattingOptions, keep func(format.Edit) bool) ([]protocol.TextEdit, error) {
+	var opts = s.settings.Format
+	opts.IndentWidth = int(options.TabSize)
+	opts.UseTabs = !options.InsertSpaces
+
+	var source = doc.file.Source
+	var result, err = format.Format(source, s.settings.LuaVersion, opts)
+	if err != nil {
+		return nil, err
+	}
+	var edits = []protocol.TextEdit{}
+	for _, edit := range result.Edits {
+		if keep(edit) {
+			edits = append(edits, protocol.TextEdit{
+				Range:   toRange(source, edit.Loc),
+				NewText: edit.Text,
+			})
+		}
+	}
+	return edits, nil
+}
+
+// isNameByte 是否为名字里的字符，非 ascii 的也算
+func isNameByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
@@ -0,0 +1,298 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"mylua-lsp/lsp/ast"
+	"mylua-lsp/lsp/check"
+	"mylua-lsp/lsp/protocol"
+	"path/filepath"
+	"strings"
+)
+
+// maxHoverFields 表的字段太多时只显示前面的
+const maxHoverFields = 30
+
+// hoverWriter 生成悬停提示的 markdown
+type hoverWriter struct {
+	s         *Server
+	code      []string // lua 代码块里的内容
+	docList   []string
+	defFile   *ast.FileInfo
+	defLoc    check.Location
+	hasDefLoc bool
+}
+
+// TextDocumentHover 悬停提示，显示符号的类型，说明和定义的位置
+func (s *Server) TextDocumentHover(ctx context.Context, params *protocol.HoverParams) (*protocol.Hover, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var doc, sym = s.symbolAt(params.TextDocumentPositionParams)
+	if sym == nil {
+		return nil, nil
+	}
+	var w = &hoverWriter{s: s}
+	switch sym.Kind {
+	case check.SymbolVar:
+		w.writeVar(sym.Var)
+	case check.SymbolMember:
+		w.writeMember(sym)
+	case check.SymbolType:
+		w.writeType(sym.Name)
+	case check.SymbolLabel:
+		w.code = append(w.code, "::"+sym.Name+"::")
+		if sym.Label != nil {
+			w.setDef(sym.File, sym.Label.Loc)
+		}
+	}
+	var text = w.String()
+	if text == "" {
+		return nil, nil
+	}
+	return &protocol.Hover{
+		Contents: protocol.MarkupContent{
+			Kind:  protocol.Markdown,
+			Value: text,
+		},
+		Range: toRange(doc.file.Source, sym.Loc),
+	}, nil
+}
+
+func (w *hoverWriter) setDef(file *ast.FileInfo, loc check.Location) {
+	w.defFile, w.defLoc, w.hasDefLoc = file, loc, true
+}
+
+func (w *hoverWriter) addDoc(doc string) {
+	if doc = strings.TrimSpace(doc); doc != "" {
+		w.docList = append(w.docList, doc)
+	}
+}
+
+// String 代码块，说明，定义的位置，之间用分隔线隔开
+func (w *hoverWriter) String() string {
+	if len(w.code) == 0 {
+		return ""
+	}
+	var sectionList []string
+	sectionList = append(sectionList, "```lua\n"+strings.Join(w.code, "\n")+"\n```")
+	if len(w.docList) > 0 {
+		sectionList = append(sectionList, strings.Join(w.docList, "\n\n"))
+	}
+	if w.hasDefLoc && w.defFile != nil {
+		var path = w.defFile.GetPath()
+		if rel, err := filepath.Rel(w.s.rootPath, path); err == nil && w.s.rootPath != "" && !strings.HasPrefix(rel, "..") {
+			path = filepath.ToSlash(rel)
+		}
+		var line = w.defLoc.Start.GetLine() + 1
+		sectionList = append(sectionList, fmt.Sprintf("Defined in [%s:%d](%s#L%d)", path, line, pathToURI(w.defFile.GetPath()), line))
+	}
+	return strings.Join(sectionList, "\n\n---\n\n")
+}
+
+// varPrefix 变量在代码块里的前缀
+func varPrefix(varInfo *ast.VarInfo) string {
+	switch varInfo.Kind {
+	case ast.VarKindParam:
+		return "(parameter) "
+	case ast.VarKindSelf:
+		return "(self) "
+	case ast.VarKindGlobal:
+		return "(global) "
+	case ast.VarKindFor:
+		return "(for) "
+	}
+	return "local "
+}
+
+func (w *hoverWriter) writeVar(varInfo *ast.VarInfo) {
+	var p = w.s.project
+	// 其他文件里定义的全局变量
+	if !varInfo.IsLocal() && varInfo.DefStat == nil {
+		if def := p.GetGlobal(varInfo.Name); def != nil {
+			varInfo = def
+		}
+	}
+	var t = p.TypeOfVar(varInfo)
+	var prefix = varPrefix(varInfo)
+	if funcInfo, ok := t.(*ast.FuncInfo); ok {
+		if varInfo.Kind == ast.VarKindLocal || varInfo.Kind == ast.VarKindLocalFunc {
+			prefix = "local "
+		} else {
+			prefix = ""
+		}
+		w.code = append(w.code, prefix+p.FuncSignature(varInfo.Name, funcInfo))
+		w.writeFuncDoc(funcInfo)
+	} else {
+		w.code = append(w.code, prefix+varInfo.Name+": "+w.typeText(t, varInfo.Table))
+		w.writeVarDoc(varInfo)
+	}
+	// 没有定义的全局变量
+	if varInfo.IsLocal() || varInfo.DefStat != nil {
+		w.setDef(varInfo.File, varInfo.Loc)
+	}
+}
+
+// writeVarDoc 变量的说明，参数使用 @param 后面的说明
+func (w *hoverWriter) writeVarDoc(varInfo *ast.VarInfo) {
+	if varInfo.Kind == ast.VarKindParam {
+		if state := check.FindParamState(varInfo.Comment, varInfo.Name); state != nil {
+			w.addDoc(state.Comment)
+		}
+		return
+	}
+	w.writeCommentDoc(varInfo.Comment)
+}
+
+// writeCommentDoc 注释块的说明以及 @type 后面的说明
+func (w *hoverWriter) writeCommentDoc(comment *ast.AnnotateBlock) {
+	if comment == nil {
+		return
+	}
+	w.addDoc(comment.Doc)
+	if state, ok := ast.FindState[*ast.AnnotateTypeState](comment); ok {
+		w.addDoc(state.Comment)
+	}
+}
+
+// writeFuncDoc 函数的说明，参数和返回值的说明以及 @overload 的签名
+func (w *hoverWriter) writeFuncDoc(funcInfo *ast.FuncInfo) {
+	var p = w.s.project
+	for _, overload := range p.FuncOverloads(funcInfo) {
+		w.code = append(w.code, "---@overload "+overload)
+	}
+	var comment = funcInfo.Comment
+	if comment == nil {
+		return
+	}
+	w.addDoc(comment.Doc)
+	var lineList []string
+	for _, line := range comment.LineList {
+		switch state := line.State.(type) {
+		case *ast.AnnotateParamState:
+			if state.Comment != "" {
+				lineList = append(lineList, fmt.Sprintf("*@param* `%s` — %s", state.NameAndLoc.Name, state.Comment))
+			}
+		case *ast.AnnotateReturnState:
+			if state.Comment != "" {
+				lineList = append(lineList, "*@return* — "+state.Comment)
+			}
+		}
+	}
+	w.addDoc(strings.Join(lineList, "\n\n"))
+}
+
+// typeText 代码块里的类型，没有关联类的表显示所有的字段。static 是变量静态对应的表，例如 o.x = 1 添加的字段
+func (w *hoverWriter) typeText(t ast.TypeBase, static *ast.TableInfo) string {
+	var p = w.s.project
+	var table, ok = t.(*ast.TableInfo)
+	if !ok || table.ClassName != "" {
+		return p.TypeString(t)
+	}
+	var memberList = p.FindMembers(table, "")
+	if static != nil && static != table {
+		memberList = append(memberList, p.FindMembers(static, "")...)
+	}
+	if len(memberList) == 0 {
+		return "{}"
+	}
+	var builder strings.Builder
+	builder.WriteString("{\n")
+	var nameSet = map[string]bool{}
+	for _, member := range memberList {
+		if nameSet[member.Name] {
+			continue
+		}
+		nameSet[member.Name] = true
+		if len(nameSet) > maxHoverFields {
+			builder.WriteString("    ...\n")
+			break
+		}
+		var memberType = p.TypeOfMember(member)
+		var typeStr = p.TypeString(memberType)
+		if _, ok := memberType.(*ast.TableInfo); ok {
+			typeStr = "table"
+		}
+		fmt.Fprintf(&builder, "    %s: %s,\n", member.Name, typeStr)
+	}
+	builder.WriteString("}")
+	return builder.String()
+}
+
+func (w *hoverWriter) writeMember(sym *check.Symbol) {
+	var p = w.s.project
+	if len(sym.Members) == 0 {
+		return
+	}
+	// 多个定义时使用第一个推导出类型的
+	var member = sym.Members[0]
+	var t ast.TypeBase
+	for _, m := range sym.Members {
+		if t = p.TypeOfMember(m); t != nil {
+			member = m
+			break
+		}
+	}
+
+	if funcInfo, ok := t.(*ast.FuncInfo); ok {
+		var name = funcInfo.Name
+		if name == "" {
+			name = sym.Name
+		}
+		w.code = append(w.code, p.FuncSignature(name, funcInfo))
+		w.writeFuncDoc(funcInfo)
+	} else {
+		w.code = append(w.code, "(field) "+sym.Name+": "+w.typeText(t, nil))
+		switch {
+		case member.ClassField != nil:
+			w.addDoc(member.ClassField.Comment)
+		case member.Field != nil:
+			w.writeCommentDoc(member.Field.Comment)
+		}
+	}
+	if file := member.GetFile(); file != nil {
+		w.setDef(file, member.GetLoc())
+	}
+}
+
+// writeType 注释类型的定义，类，别名或者枚举
+func (w *hoverWriter) writeType(name string) {
+	var p = w.s.project
+	if classList := p.GetClass(name); len(classList) > 0 {
+		var class = classList[0]
+		var code = "(class) " + name
+		if len(class.GenericParamList) > 0 {
+			var paramList []string
+			for _, param := range class.GenericParamList {
+				paramList = append(paramList, param.NameAndLoc.Name)
+			}
+			code += "<" + strings.Join(paramList, ", ") + ">"
+		}
+		if len(class.ParentTypeList) > 0 {
+			var parentList []string
+			for _, parent := range class.ParentTypeList {
+				parentList = append(parentList, p.TypeString(parent))
+			}
+			code += " : " + strings.Join(parentList, ", ")
+		}
+		w.code = append(w.code, code)
+		for _, c := range classList {
+			w.addDoc(c.Comment)
+		}
+		w.setDef(class.File, class.NameAndLoc.Loc)
+		return
+	}
+	if aliasList := p.GetAlias(name); len(aliasList) > 0 {
+		var alias = aliasList[0]
+		w.code = append(w.code, "(alias) "+name+" = "+p.TypeString(alias.Type))
+		w.addDoc(alias.Comment)
+		w.setDef(alias.File, alias.NameAndLoc.Loc)
+		return
+	}
+	if enumList := p.GetEnum(name); len(enumList) > 0 {
+		var enum = enumList[0]
+		w.code = append(w.code, "(enum) "+name)
+		w.addDoc(enum.Comment)
+		w.setDef(enum.File, enum.NameAndLoc.Loc)
+	}
+}
@@ -0,0 +1,64 @@
+package langserver
+
+import (
+	"context"
+	"mylua-lsp/lsp/compiler"
+	"mylua-lsp/lsp/protocol"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func hover(t *testing.T, s *Server, params protocol.TextDocumentPositionParams) string {
+	t.Helper()
+	var result, err = s.TextDocumentHover(context.Background(), &protocol.HoverParams{TextDocumentPositionParams: params})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result == nil {
+		return ""
+	}
+	return result.Contents.Value
+}
+
+func TestHover(t *testing.T) {
+	var tests = []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"local", "local count = 1\nprint(co|unt)\n", []string{"local count: integer"}},
+		{"function", "---adds two numbers\n---@param a number\nlocal function add(a, b) return a + b end\nad|d(1, 2)\n",
+			[]string{"function add(a: number, b)", "adds two numbers"}},
+		{"class field", "---@class Point\n---@field x number\nlocal p = {}\n---@type Point\nlocal q\nprint(q.|x)\n",
+			[]string{"x: number"}},
+		{"label", "do\n  goto done\n  ::do|ne::\nend\n", []string{"::done::"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s = newTestServer(t, nil)
+			var text = hover(t, s, openAt(t, s, "a.lua", tt.text))
+			for _, want := range tt.want {
+				if !strings.Contains(text, want) {
+					t.Errorf("hover %q does not contain %q", text, want)
+				}
+			}
+		})
+	}
+}
+
+// 悬停推导的类型不能影响增量解析，复用的语句要和完整解析的一样
+func TestHoverThenReparse(t *testing.T) {
+	var s = newTestServer(t, nil)
+	var params = openAt(t, s, "a.lua", "local t = { n = 1 }\nlocal v = t.|n + 1\n\nprint(v)\n")
+	if hover(t, s, params) == "" {
+		t.Fatal("no hover")
+	}
+	s.change(t, "a.lua", textRange(3, 0, 0), "v = v * 2\n")
+
+	var result = s.getDocument(testURI("a.lua")).result
+	var full = compiler.ParseLuaFile(result.Source, result.Version)
+	if !reflect.DeepEqual(full.Block, result.Block) {
+		t.Error("incremental reparse differs from full parse after hover")
+	}
+}
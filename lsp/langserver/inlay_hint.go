@@ -0,0 +1,41 @@
+package langserver
+
+import (
+	"context"
+	"mylua-lsp/lsp/check"
+	"mylua-lsp/lsp/protocol"
+)
+
+// TextDocumentInlayHint 范围内的内嵌提示，每种提示可以在配置里单独关闭
+func (s *Server) TextDocumentInlayHint(ctx context.Context, params *protocol.InlayHintParams) ([]protocol.InlayHint, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var doc = s.getDocument(params.TextDocument.URI)
+	if doc == nil {
+		return nil, nil
+	}
+	var enabled = map[check.InlayHintKind]bool{
+		check.InlayHintParamName: s.settings.InlayParamName,
+		check.InlayHintVarType:   s.settings.InlayVarType,
+		check.InlayHintSelf:      s.settings.InlaySelf,
+	}
+	var source = doc.file.Source
+	var result = []protocol.InlayHint{}
+	for _, hint := range s.project.InlayHints(doc.file, toLocation(source, params.Range)) {
+		if !enabled[hint.Kind] {
+			continue
+		}
+		var kind = protocol.ParameterHint
+		if hint.Kind == check.InlayHintVarType {
+			kind = protocol.TypeHint
+		}
+		result = append(result, protocol.InlayHint{
+			Position:     toProtocolPosition(source, hint.Pos),
+			Label:        hint.Label,
+			Kind:         kind,
+			PaddingRight: hint.PaddingRight,
+		})
+	}
+	return result, nil
+}
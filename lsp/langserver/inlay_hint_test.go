@@ -0,0 +1,85 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"mylua-lsp/lsp/protocol"
+	"slices"
+	"testing"
+)
+
+// inlayHints 整个文件的内嵌提示，每个是 "行:列 文字 种类"
+func inlayHints(t *testing.T, s *Server, name string) []string {
+	t.Helper()
+	var params = &protocol.InlayHintParams{TextDocument: textDocument(name), Range: protocol.Range{End: protocol.Position{Line: 100}}}
+	var result, err = s.TextDocumentInlayHint(context.Background(), params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list []string
+	for _, hint := range result {
+		list = append(list, fmt.Sprintf("%d:%d %s %d", hint.Position.Line, hint.Position.Character, hint.Label, hint.Kind))
+	}
+	return list
+}
+
+// inlayText 每种提示各有一些，也有不提示的：实参就是同名的变量，有类型注释的变量
+const inlayText = "local function move(dx, dy) end\nlocal dx = 1\nmove(dx, 2)\nmove('中', 2)\n---@type number\nlocal speed = 3\n" +
+	"local p = { x = 1 }\nfunction p:len(n) end\nfunction p:get() end\n"
+
+func TestInlayHint(t *testing.T) {
+	var s = newTestServer(t, map[string]string{"a.lua": inlayText})
+	var got = inlayHints(t, s, "a.lua")
+	var want = []string{
+		fmt.Sprintf("1:8 : integer %d", protocol.TypeHint),
+		fmt.Sprintf("2:9 dy: %d", protocol.ParameterHint),
+		fmt.Sprintf("3:5 dx: %d", protocol.ParameterHint),
+		fmt.Sprintf("3:10 dy: %d", protocol.ParameterHint),
+		fmt.Sprintf("6:7 : table %d", protocol.TypeHint),
+		fmt.Sprintf("7:15 self, %d", protocol.ParameterHint),
+		fmt.Sprintf("8:15 self %d", protocol.ParameterHint),
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// 每种提示都可以在配置里单独关闭
+func TestInlayHintSettings(t *testing.T) {
+	var tests = []struct {
+		name    string
+		disable func(settings *Settings)
+		want    []string
+	}{
+		{"param name", func(settings *Settings) { settings.InlayParamName = false }, []string{
+			fmt.Sprintf("1:8 : integer %d", protocol.TypeHint),
+			fmt.Sprintf("6:7 : table %d", protocol.TypeHint),
+			fmt.Sprintf("7:15 self, %d", protocol.ParameterHint),
+			fmt.Sprintf("8:15 self %d", protocol.ParameterHint),
+		}},
+		{"var type", func(settings *Settings) { settings.InlayVarType = false }, []string{
+			fmt.Sprintf("2:9 dy: %d", protocol.ParameterHint),
+			fmt.Sprintf("3:5 dx: %d", protocol.ParameterHint),
+			fmt.Sprintf("3:10 dy: %d", protocol.ParameterHint),
+			fmt.Sprintf("7:15 self, %d", protocol.ParameterHint),
+			fmt.Sprintf("8:15 self %d", protocol.ParameterHint),
+		}},
+		{"self", func(settings *Settings) { settings.InlaySelf = false }, []string{
+			fmt.Sprintf("1:8 : integer %d", protocol.TypeHint),
+			fmt.Sprintf("2:9 dy: %d", protocol.ParameterHint),
+			fmt.Sprintf("3:5 dx: %d", protocol.ParameterHint),
+			fmt.Sprintf("3:10 dy: %d", protocol.ParameterHint),
+			fmt.Sprintf("6:7 : table %d", protocol.TypeHint),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s = newTestServer(t, map[string]string{"a.lua": inlayText})
+			tt.disable(&s.settings)
+			var got = inlayHints(t, s, "a.lua")
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
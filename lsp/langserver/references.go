@@ -0,0 +1,59 @@
+package langserver
+
+import (
+	"context"
+	"mylua-lsp/lsp/check"
+	"mylua-lsp/lsp/protocol"
+)
+
+// TextDocumentReferences 查找所有的引用，局部变量只在所在的函数里，全局变量和字段在整个工程里
+func (s *Server) TextDocumentReferences(ctx context.Context, params *protocol.ReferenceParams) ([]protocol.Location, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var _, sym = s.symbolAt(params.TextDocumentPositionParams)
+	if sym == nil {
+		return nil, nil
+	}
+	var locList []protocol.Location
+	for _, ref := range s.project.References(sym) {
+		if ref.IsDecl && !params.Context.IncludeDeclaration {
+			continue
+		}
+		locList = append(locList, protocol.Location{
+			URI:   pathToURI(ref.File.GetPath()),
+			Range: toRange(ref.File.Source, ref.Loc),
+		})
+	}
+	return locList, nil
+}
+
+// TextDocumentDocumentHighlight 高亮文件里符号的所有引用，区分读和写
+func (s *Server) TextDocumentDocumentHighlight(ctx context.Context, params *protocol.DocumentHighlightParams) ([]protocol.DocumentHighlight, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var doc, sym = s.symbolAt(params.TextDocumentPositionParams)
+	if sym == nil {
+		return nil, nil
+	}
+	var highlightList []protocol.DocumentHighlight
+	for _, ref := range s.project.FileReferences(sym, doc.file) {
+		highlightList = append(highlightList, protocol.DocumentHighlight{
+			Range: toRange(doc.file.Source, ref.Loc),
+			Kind:  highlightKind(ref),
+		})
+	}
+	return highlightList, nil
+}
+
+// highlightKind 赋值和定义是写，注释里的名字是文本
+func highlightKind(ref check.Reference) protocol.DocumentHighlightKind {
+	switch {
+	case ref.IsWrite:
+		return protocol.Write
+	case ref.IsDecl:
+		return protocol.Text
+	}
+	return protocol.Read
+}
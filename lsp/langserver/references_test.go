@@ -0,0 +1,54 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"mylua-lsp/lsp/protocol"
+	"slices"
+	"testing"
+)
+
+func TestReferences(t *testing.T) {
+	var text = "---@type Shape\nlocal s\ns:dr|aw()\ns:draw()\n"
+	for _, include := range []bool{false, true} {
+		var s = newTestServer(t, libFiles)
+		var params = &protocol.ReferenceParams{TextDocumentPositionParams: openAt(t, s, "a.lua", text)}
+		params.Context.IncludeDeclaration = include
+		var locList, err = s.TextDocumentReferences(context.Background(), params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, loc := range locList {
+			got = append(got, locationString(loc))
+		}
+		var want = []string{"a.lua 2:2-2:6", "a.lua 3:2-3:6"}
+		if include {
+			want = append(want, "lib.lua 4:15-4:19")
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("include declaration %v: got %q, want %q", include, got, want)
+		}
+	}
+}
+
+func TestDocumentHighlight(t *testing.T) {
+	var s = newTestServer(t, nil)
+	var params = openAt(t, s, "a.lua", "local count = 1\ncount = co|unt + 1\n")
+	var list, err = s.TextDocumentDocumentHighlight(context.Background(), &protocol.DocumentHighlightParams{TextDocumentPositionParams: params})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, highlight := range list {
+		got = append(got, fmt.Sprintf("%s %v", rangeString(highlight.Range), highlight.Kind))
+	}
+	var want = []string{
+		fmt.Sprintf("0:6-0:11 %v", protocol.Write),
+		fmt.Sprintf("1:0-1:5 %v", protocol.Write),
+		fmt.Sprintf("1:8-1:13 %v", protocol.Read),
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,51 @@
+package langserver
+
+import (
+	"context"
+	"mylua-lsp/lsp/protocol"
+)
+
+// TextDocumentPrepareRename 检查光标位置的符号能否重命名，返回名字的范围
+func (s *Server) TextDocumentPrepareRename(ctx context.Context, params *protocol.PrepareRenameParams) (*protocol.PrepareRenameResult, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var doc, sym = s.symbolAt(params.TextDocumentPositionParams)
+	if sym == nil {
+		return nil, nil
+	}
+	if err := s.project.PrepareRename(sym); err != nil {
+		return nil, err
+	}
+	return &protocol.PrepareRenameResult{
+		Range:       toRange(doc.file.Source, sym.Loc),
+		Placeholder: sym.Name,
+	}, nil
+}
+
+// TextDocumentRename 重命名，局部变量只修改所在的文件，全局变量，字段和类型修改整个工程
+func (s *Server) TextDocumentRename(ctx context.Context, params *protocol.RenameParams) (*protocol.WorkspaceEdit, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var _, sym = s.symbolAt(protocol.TextDocumentPositionParams{
+		TextDocument: params.TextDocument,
+		Position:     params.Position,
+	})
+	if sym == nil {
+		return nil, nil
+	}
+	refList, err := s.project.Rename(sym, params.NewName, s.diagnosticOptions())
+	if err != nil {
+		return nil, err
+	}
+	var edit = &protocol.WorkspaceEdit{Changes: map[string][]protocol.TextEdit{}}
+	for _, ref := range refList {
+		var uri = string(pathToURI(ref.File.GetPath()))
+		edit.Changes[uri] = append(edit.Changes[uri], protocol.TextEdit{
+			Range:   toRange(ref.File.Source, ref.Loc),
+			NewText: params.NewName,
+		})
+	}
+	return edit, nil
+}
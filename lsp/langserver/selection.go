@@ -0,0 +1,33 @@
+package langserver
+
+import (
+	"context"
+	"mylua-lsp/lsp/check"
+	"mylua-lsp/lsp/protocol"
+)
+
+// TextDocumentSelectionRange 每个位置从内到外的选择范围
+func (s *Server) TextDocumentSelectionRange(ctx context.Context, params *protocol.SelectionRangeParams) ([]protocol.SelectionRange, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var doc = s.getDocument(params.TextDocument.URI)
+	if doc == nil {
+		return nil, nil
+	}
+	var source = doc.file.Source
+	var result = []protocol.SelectionRange{}
+	for _, position := range params.Positions {
+		var list = check.SelectionRanges(doc.file, toPosition(source, position))
+		// 结果要和位置一一对应，找不到时只有位置本身
+		var sel = &protocol.SelectionRange{Range: protocol.Range{Start: position, End: position}}
+		if len(list) > 0 {
+			sel = nil
+			for i := len(list) - 1; i >= 0; i-- {
+				sel = &protocol.SelectionRange{Range: toRange(source, list[i]), Parent: sel}
+			}
+		}
+		result = append(result, *sel)
+	}
+	return result, nil
+}
@@ -0,0 +1,65 @@
+package langserver
+
+import (
+	"context"
+	"mylua-lsp/lsp/protocol"
+	"slices"
+	"testing"
+)
+
+// selectionRanges 每个位置从内到外的范围
+func selectionRanges(t *testing.T, s *Server, name string, positions ...protocol.Position) [][]string {
+	t.Helper()
+	var params = &protocol.SelectionRangeParams{TextDocument: textDocument(name), Positions: positions}
+	var result, err = s.TextDocumentSelectionRange(context.Background(), params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != len(positions) {
+		t.Fatalf("got %d results for %d positions", len(result), len(positions))
+	}
+	var chains [][]string
+	for _, sel := range result {
+		var chain []string
+		for r := &sel; r != nil; r = r.Parent {
+			chain = append(chain, rangeString(r.Range))
+		}
+		chains = append(chains, chain)
+	}
+	return chains
+}
+
+// 一次请求里的多个位置各自返回，列是 utf-16 的编码单元
+func TestSelectionRangeMultiple(t *testing.T) {
+	var s = newTestServer(t, map[string]string{"a.lua": "local s = '中' .. x\n\nprint(s)\n"})
+	var got = selectionRanges(t, s, "a.lua",
+		protocol.Position{Line: 0, Character: 17},
+		protocol.Position{Line: 2, Character: 6},
+		protocol.Position{Line: 1, Character: 0},
+	)
+	var want = [][]string{
+		{"0:17-0:18", "0:10-0:18", "0:0-0:18", "0:0-2:8"},
+		{"2:6-2:7", "2:0-2:8", "0:0-2:8"},
+		{"0:0-2:8"},
+	}
+	if !slices.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// 增量解析复用的语句换了父节点，选择范围和重新打开的文件一样
+func TestSelectionRangeAfterChange(t *testing.T) {
+	var s = newTestServer(t, map[string]string{"a.lua": "local a = 1\nlocal function f()\n  return a\nend\nlocal b = f()\n"})
+	s.change(t, "a.lua", textRange(0, 10, 11), "2\ndo\n  local c = 3\nend")
+	var text = "local a = 2\ndo\n  local c = 3\nend\nlocal function f()\n  return a\nend\nlocal b = f()\n"
+	var fresh = newTestServer(t, map[string]string{"a.lua": text})
+	var positions = []protocol.Position{{Line: 5, Character: 9}, {Line: 7, Character: 10}, {Line: 2, Character: 8}}
+	var got = selectionRanges(t, s, "a.lua", positions...)
+	var want = selectionRanges(t, fresh, "a.lua", positions...)
+	if !slices.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if len(got[0]) < 4 {
+		t.Errorf("chain in the reused function is too short: %q", got[0])
+	}
+}
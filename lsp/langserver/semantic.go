@@ -0,0 +1,126 @@
+package langserver
+
+import (
+	"context"
+	"mylua-lsp/lsp/check"
+	"mylua-lsp/lsp/common"
+	"mylua-lsp/lsp/protocol"
+	"strconv"
+)
+
+// semanticLegend 语义高亮的种类和修饰，顺序和 check.SemanticType check.SemanticModifier 一致
+var semanticLegend = protocol.SemanticTokensLegend{
+	TokenTypes: []string{
+		"variable", "parameter", "property", "method", "function", "class",
+		"enum", "enumMember", "type", "typeParameter", "keyword",
+	},
+	TokenModifiers: []string{
+		"declaration", "readonly", "deprecated", "documentation", "defaultLibrary",
+		"global", "upvalue", "self",
+	},
+}
+
+// semanticData 上一次返回给客户端的语义高亮，用来计算增量
+type semanticData struct {
+	resultID string
+	data     []uint32
+}
+
+// TextDocumentSemanticTokensFull 整个文件的语义高亮
+func (s *Server) TextDocumentSemanticTokensFull(ctx context.Context, params *protocol.SemanticTokensParams) (*protocol.SemanticTokens, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var doc = s.getDocument(params.TextDocument.URI)
+	if doc == nil {
+		return nil, nil
+	}
+	var data = encodeTokens(doc.file.Source, s.project.SemanticTokens(doc.file), nil)
+	return &protocol.SemanticTokens{ResultID: s.saveTokens(doc, data), Data: data}, nil
+}
+
+// TextDocumentSemanticTokensFullDelta 和上一次结果的差异，上一次的结果已经过期时返回完整的结果
+func (s *Server) TextDocumentSemanticTokensFullDelta(ctx context.Context, params *protocol.SemanticTokensDeltaParams) (any, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var doc = s.getDocument(params.TextDocument.URI)
+	if doc == nil {
+		return nil, nil
+	}
+	var data = encodeTokens(doc.file.Source, s.project.SemanticTokens(doc.file), nil)
+	var prev = doc.semantic
+	var resultID = s.saveTokens(doc, data)
+	if prev == nil || prev.resultID != params.PreviousResultID {
+		return &protocol.SemanticTokens{ResultID: resultID, Data: data}, nil
+	}
+	var delta = &protocol.SemanticTokensDelta{ResultID: resultID, Edits: []protocol.SemanticTokensEdit{}}
+	if edit, changed := diffTokens(prev.data, data); changed {
+		delta.Edits = append(delta.Edits, edit)
+	}
+	return delta, nil
+}
+
+// TextDocumentSemanticTokensRange 范围内的语义高亮
+func (s *Server) TextDocumentSemanticTokensRange(ctx context.Context, params *protocol.SemanticTokensRangeParams) (*protocol.SemanticTokens, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var doc = s.getDocument(params.TextDocument.URI)
+	if doc == nil {
+		return nil, nil
+	}
+	var loc = toLocation(doc.file.Source, params.Range)
+	return &protocol.SemanticTokens{Data: encodeTokens(doc.file.Source, s.project.SemanticTokens(doc.file), &loc)}, nil
+}
+
+// saveTokens 记录返回的结果，生成新的 resultId
+func (s *Server) saveTokens(doc *document, data []uint32) string {
+	s.semanticSeq++
+	doc.semantic = &semanticData{resultID: strconv.Itoa(s.semanticSeq), data: data}
+	return doc.semantic.resultID
+}
+
+// encodeTokens 按协议编码，每个高亮是 5 个数：和上一个的行差，列差（同一行时），长度，种类，修饰。
+// 列是 utf-16 的编码单元。loc 不为 nil 时只要和它有交集的
+func encodeTokens(source *common.LuaSource, tokens []check.SemanticToken, loc *common.Location) []uint32 {
+	var data = []uint32{}
+	var prevLine, prevChar uint32
+	for _, token := range tokens {
+		if loc != nil && (token.Loc.End.Before(loc.Start) || loc.End.Before(token.Loc.Start)) {
+			continue
+		}
+		var text = source.GetOneLine(token.Loc.Start.GetLine())
+		var line = uint32(token.Loc.Start.Line)
+		var start = utf16Column(text, token.Loc.Start.GetColumn())
+		var end = utf16Column(text, token.Loc.End.GetColumn())
+		var deltaChar = start
+		if line == prevLine {
+			deltaChar = start - prevChar
+		}
+		data = append(data, line-prevLine, deltaChar, end-start, uint32(token.Type), uint32(token.Modifiers))
+		prevLine, prevChar = line, start
+	}
+	return data
+}
+
+// diffTokens 两次结果的差异，去掉相同的开头和结尾，中间的替换成新的
+func diffTokens(old, data []uint32) (protocol.SemanticTokensEdit, bool) {
+	var start = 0
+	for start < len(old) && start < len(data) && old[start] == data[start] {
+		start++
+	}
+	if start == len(old) && start == len(data) {
+		return protocol.SemanticTokensEdit{}, false
+	}
+	var oldEnd, newEnd = len(old), len(data)
+	for oldEnd > start && newEnd > start && old[oldEnd-1] == data[newEnd-1] {
+		oldEnd--
+		newEnd--
+	}
+	return protocol.SemanticTokensEdit{
+		Start:       uint32(start),
+		DeleteCount: uint32(oldEnd - start),
+		Data:        data[start:newEnd],
+	}, true
+}
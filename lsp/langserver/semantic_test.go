@@ -0,0 +1,97 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"mylua-lsp/lsp/protocol"
+	"slices"
+	"testing"
+)
+
+// decodeTokens 解码协议里的语义高亮，每个是 "行:列 长度 种类 修饰"
+func decodeTokens(data []uint32) []string {
+	var list []string
+	var line, char uint32
+	for i := 0; i+5 <= len(data); i += 5 {
+		if data[i] > 0 {
+			char = 0
+		}
+		line += data[i]
+		char += data[i+1]
+		list = append(list, fmt.Sprintf("%d:%d %d %s %d", line, char, data[i+2], semanticLegend.TokenTypes[data[i+3]], data[i+4]))
+	}
+	return list
+}
+
+// semanticFull 整个文件的语义高亮
+func semanticFull(t *testing.T, s *Server, name string) *protocol.SemanticTokens {
+	t.Helper()
+	var result, err = s.TextDocumentSemanticTokensFull(context.Background(), &protocol.SemanticTokensParams{TextDocument: textDocument(name)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+// 列和长度是 utf-16 的编码单元，代理对占两个
+func TestSemanticTokensUTF16(t *testing.T) {
+	var s = newTestServer(t, map[string]string{"a.lua": "local s = '中😀' local n = s\n"})
+	var got = decodeTokens(semanticFull(t, s, "a.lua").Data)
+	var want = []string{"0:6 1 variable 1", "0:22 1 variable 1", "0:26 1 variable 0"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// 只返回和范围有交集的，第一个的行差从 0 行算起
+func TestSemanticTokensRange(t *testing.T) {
+	var s = newTestServer(t, map[string]string{"a.lua": "local a = 1\nlocal b = a\nlocal c = b\n"})
+	var params = &protocol.SemanticTokensRangeParams{TextDocument: textDocument("a.lua"), Range: textRange(1, 0, 11)}
+	var result, err = s.TextDocumentSemanticTokensRange(context.Background(), params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got = decodeTokens(result.Data)
+	var want = []string{"1:6 1 variable 1", "1:10 1 variable 0"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// 增量结果应用到上一次的结果上，和重新请求完整结果相同
+func TestSemanticTokensDelta(t *testing.T) {
+	var s = newTestServer(t, map[string]string{"a.lua": "local a = 1\nlocal b = a\nprint(b)\n"})
+	var full = semanticFull(t, s, "a.lua")
+	var delta = func(previous string) any {
+		t.Helper()
+		var params = &protocol.SemanticTokensDeltaParams{TextDocument: textDocument("a.lua"), PreviousResultID: previous}
+		var result, err = s.TextDocumentSemanticTokensFullDelta(context.Background(), params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	var unchanged, ok = delta(full.ResultID).(*protocol.SemanticTokensDelta)
+	if !ok || len(unchanged.Edits) != 0 || unchanged.ResultID == full.ResultID {
+		t.Fatalf("unchanged file: got %+v", unchanged)
+	}
+
+	s.change(t, "a.lua", textRange(1, 10, 11), "a + 1\nlocal c = b")
+	var changed, isDelta = delta(unchanged.ResultID).(*protocol.SemanticTokensDelta)
+	if !isDelta {
+		t.Fatalf("got %T, want a delta", changed)
+	}
+	var data = slices.Clone(full.Data)
+	for _, edit := range changed.Edits {
+		data = slices.Replace(data, int(edit.Start), int(edit.Start+edit.DeleteCount), edit.Data...)
+	}
+	if want := semanticFull(t, s, "a.lua").Data; !slices.Equal(data, want) {
+		t.Errorf("delta applied: got %q, want %q", decodeTokens(data), decodeTokens(want))
+	}
+
+	// 客户端的结果已经过期，返回完整的结果
+	if _, isFull := delta("stale").(*protocol.SemanticTokens); !isFull {
+		t.Error("stale result id: want full tokens")
+	}
+}
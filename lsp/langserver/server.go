@@ -0,0 +1,309 @@
+package langserver
+
+import (
+	"context"
+	"io/fs"
+	"mylua-lsp/lsp/ast"
+	"mylua-lsp/lsp/check"
+	"mylua-lsp/lsp/common"
+	"mylua-lsp/lsp/compiler"
+	"mylua-lsp/lsp/format"
+	"mylua-lsp/lsp/protocol"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Settings 客户端初始化时传入的配置
+type Settings struct {
+	LuaVersion common.LuaVersion // 使用的 lua 版本
+
+	InlayParamName bool // 内嵌提示调用时的参数名
+	InlayVarType   bool // 内嵌提示局部变量的类型
+	InlaySelf      bool // 内嵌提示方法隐含的 self
+
+	Format format.Options // 格式化的配置，缩进以每次请求里的为准
+
+	Globals []string // 宿主程序注入的全局变量，不报告没有定义
+}
+
+// document 工程里的一个文件，打开的文件内容以客户端的为准
+type document struct {
+	uri     protocol.DocumentURI
+	version int32
+	opened  bool // 是否在客户端打开
+	result  *compiler.ParseResult
+	file    *ast.FileInfo
+
+	semantic    *semanticData      // 上一次返回的语义高亮
+	diagnostics []check.Diagnostic // 上一次发送的诊断
+}
+
+// clientCaps 客户端支持的功能
+type clientCaps struct {
+	definitionLink     bool // 跳转到定义时支持 LocationLink
+	declarationLink    bool // 跳转到声明时支持 LocationLink
+	snippet            bool // 补全时支持代码片段
+	hierarchicalSymbol bool // 文件大纲支持层级
+	foldingRangeLimit  int  // 折叠范围的最大数量，0 表示不限制
+}
+
+// Server lua 语言服务，每个请求对应一个方法，请求之间互斥
+type Server struct {
+	mutex      sync.Mutex
+	project    *check.Project
+	docMap     map[string]*document // key 是文件路径
+	rootPath   string
+	settings   Settings
+	clientCaps clientCaps
+	client     Client // 发送通知，可能为 nil
+
+	semanticSeq   int              // 语义高亮的 resultId
+	completionSeq int              // 补全结果的编号
+	completion    *completionCache // 上一次补全的结果
+}
+
+// NewServer 创建语言服务
+func NewServer() *Server {
+	return &Server{
+		project: check.NewProject(),
+		docMap:  map[string]*document{},
+		settings: Settings{
+			LuaVersion:     common.DefaultLuaVersion,
+			InlayParamName: true,
+			InlayVarType:   true,
+			InlaySelf:      true,
+			Format:         format.DefaultOptions(),
+		},
+	}
+}
+
+// Initialize 初始化，加载工作区里所有的 lua 文件
+func (s *Server) Initialize(ctx context.Context, params *protocol.ParamInitialize) (*protocol.InitializeResult, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if options, ok := params.InitializationOptions.(map[string]any); ok {
+		if str, ok := options["luaVersion"].(string); ok {
+			if version, ok := common.ParseLuaVersion(str); ok {
+				s.settings.LuaVersion = version
+			}
+		}
+		if hints, ok := options["inlayHints"].(map[string]any); ok {
+			for key, setting := range map[string]*bool{
+				"parameterNames": &s.settings.InlayParamName,
+				"variableTypes":  &s.settings.InlayVarType,
+				"implicitSelf":   &s.settings.InlaySelf,
+			} {
+				if value, ok := hints[key].(bool); ok {
+					*setting = value
+				}
+			}
+		}
+		if formatOptions, ok := options["format"].(map[string]any); ok {
+			s.parseFormatOptions(formatOptions)
+		}
+		if diagnostics, ok := options["diagnostics"].(map[string]any); ok {
+			var globals, _ = diagnostics["globals"].([]any)
+			for _, name := range globals {
+				if name, ok := name.(string); ok {
+					s.settings.Globals = append(s.settings.Globals, name)
+				}
+			}
+		}
+	}
+	var textCaps = params.Capabilities.TextDocument
+	s.clientCaps.definitionLink = textCaps.Definition.LinkSupport
+	s.clientCaps.declarationLink = textCaps.Declaration.LinkSupport
+	s.clientCaps.snippet = textCaps.Completion.CompletionItem.SnippetSupport
+	s.clientCaps.hierarchicalSymbol = textCaps.DocumentSymbol.HierarchicalDocumentSymbolSupport
+	s.clientCaps.foldingRangeLimit = int(textCaps.FoldingRange.RangeLimit)
+	if params.RootURI != "" {
+		s.rootPath = uriToPath(params.RootURI)
+	} else {
+		s.rootPath = filepath.ToSlash(params.RootPath)
+	}
+	if s.rootPath != "" {
+		s.loadWorkspace(s.rootPath)
+	}
+
+	var result = &protocol.InitializeResult{}
+	result.Capabilities.TextDocumentSync = protocol.TextDocumentSyncOptions{
+		OpenClose: true,
+		Change:    protocol.Incremental,
+	}
+	result.Capabilities.HoverProvider = true
+	result.Capabilities.DefinitionProvider = true
+	result.Capabilities.DeclarationProvider = true
+	result.Capabilities.ReferencesProvider = true
+	result.Capabilities.DocumentHighlightProvider = true
+	result.Capabilities.CompletionProvider = protocol.CompletionOptions{
+		TriggerCharacters: []string{".", ":", "@"},
+		ResolveProvider:   true,
+	}
+	result.Capabilities.SignatureHelpProvider = protocol.SignatureHelpOptions{
+		TriggerCharacters: []string{"(", ","},
+	}
+	result.Capabilities.RenameProvider = protocol.RenameOptions{PrepareProvider: true}
+	result.Capabilities.DocumentSymbolProvider = true
+	result.Capabilities.WorkspaceSymbolProvider = true
+	result.Capabilities.SemanticTokensProvider = protocol.SemanticTokensOptions{
+		Legend: semanticLegend,
+		Range:  true,
+		Full:   protocol.SemanticTokensFullOptions{Delta: true},
+	}
+	result.Capabilities.InlayHintProvider = true
+	result.Capabilities.CallHierarchyProvider = true
+	result.Capabilities.TypeHierarchyProvider = true
+	result.Capabilities.FoldingRangeProvider = true
+	result.Capabilities.SelectionRangeProvider = true
+	result.Capabilities.CodeActionProvider = protocol.CodeActionOptions{CodeActionKinds: codeActionKinds}
+	result.Capabilities.DocumentFormattingProvider = true
+	result.Capabilities.DocumentRangeFormattingProvider = true
+	result.Capabilities.DocumentOnTypeFormattingProvider = protocol.DocumentOnTypeFormattingOptions{
+		FirstTriggerCharacter: onTypeTriggers[0],
+		MoreTriggerCharacter:  onTypeTriggers[1:],
+	}
+	return result, nil
+}
+
+// parseFormatOptions 初始化配置里的格式化选项，不认识的取值当成保持原样
+func (s *Server) parseFormatOptions(options map[string]any) {
+	var opts = &s.settings.Format
+	if value, ok := options["operatorSpaces"].(bool); ok {
+		opts.OperatorSpaces = value
+	}
+	if value, ok := options["trailingSeparator"].(string); ok {
+		opts.TrailingSeparator = format.TrailingStyle(value)
+	}
+	if value, ok := options["callParens"].(string); ok {
+		opts.CallParens = format.CallParensStyle(value)
+	}
+	if value, ok := options["quoteStyle"].(string); ok {
+		opts.QuoteStyle = format.QuoteStyle(value)
+	}
+}
+
+// loadWorkspace 加载目录下所有的 lua 文件
+func (s *Server) loadWorkspace(root string) {
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			// 跳过 .git 这样的隐藏目录
+			if path != root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(d.Name(), ".lua") {
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		path = filepath.ToSlash(path)
+		s.updateDocument(&document{uri: pathToURI(path)}, common.NewLuaSource(content, path))
+		return nil
+	})
+}
+
+// updateDocument 完整解析文件，更新工程里的分析结果
+func (s *Server) updateDocument(doc *document, source *common.LuaSource) {
+	doc.result = compiler.ParseLuaFile(source, s.settings.LuaVersion)
+	s.analyzeDocument(doc)
+}
+
+func (s *Server) analyzeDocument(doc *document) {
+	doc.file = check.AnalyzeFile(doc.result)
+	s.docMap[doc.file.GetPath()] = doc
+	s.project.UpdateFile(doc.file)
+	s.completion = nil
+}
+
+// getDocument 查找 uri 对应的文件，没有时返回 nil
+func (s *Server) getDocument(uri protocol.DocumentURI) *document {
+	return s.docMap[uriToPath(uri)]
+}
+
+// symbolAt 请求的位置上的符号，没有时返回 nil
+func (s *Server) symbolAt(params protocol.TextDocumentPositionParams) (*document, *check.Symbol) {
+	var doc = s.getDocument(params.TextDocument.URI)
+	if doc == nil {
+		return nil, nil
+	}
+	var sym = s.project.SymbolAt(doc.file, toPosition(doc.file.Source, params.Position))
+	return doc, sym
+}
+
+// TextDocumentDidOpen 客户端打开了文件，以客户端的内容为准
+func (s *Server) TextDocumentDidOpen(ctx context.Context, params *protocol.DidOpenTextDocumentParams) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var item = params.TextDocument
+	var path = uriToPath(item.URI)
+	var doc = &document{
+		uri:     item.URI,
+		version: item.Version,
+		opened:  true,
+	}
+	s.updateDocument(doc, common.NewLuaSource([]byte(item.Text), path))
+	s.publishDiagnostics(ctx, doc)
+	return nil
+}
+
+// TextDocumentDidChange 文件修改了，有范围时增量解析
+func (s *Server) TextDocumentDidChange(ctx context.Context, params *protocol.DidChangeTextDocumentParams) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var doc = s.getDocument(params.TextDocument.URI)
+	if doc == nil {
+		return nil
+	}
+	doc.version = params.TextDocument.Version
+	// 增量解析会修改旧的语法树，旧的结果和分析从文件上摘下来，之后只使用新的
+	var result = doc.result
+	doc.result, doc.file = nil, nil
+	for _, change := range params.ContentChanges {
+		if change.Range == nil {
+			result = compiler.ParseLuaFile(common.NewLuaSource([]byte(change.Text), result.Source.LuaPath), s.settings.LuaVersion)
+			continue
+		}
+		var loc = toLocation(result.Source, *change.Range)
+		result = result.Reparse(loc, change.Text)
+	}
+	doc.result = result
+	s.analyzeDocument(doc)
+	s.publishDiagnostics(ctx, doc)
+	return nil
+}
+
+// TextDocumentDidClose 客户端关闭了文件，重新读取磁盘上的内容
+func (s *Server) TextDocumentDidClose(ctx context.Context, params *protocol.DidCloseTextDocumentParams) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var doc = s.getDocument(params.TextDocument.URI)
+	if doc == nil {
+		return nil
+	}
+	var path = doc.file.GetPath()
+	s.clearDiagnostics(ctx, doc)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		// 文件已经不存在了
+		delete(s.docMap, path)
+		s.project.RemoveFile(path)
+		s.completion = nil
+	} else {
+		doc.opened = false
+		s.updateDocument(doc, common.NewLuaSource(content, path))
+	}
+	s.publishDiagnostics(ctx, nil)
+	return nil
+}
@@ -0,0 +1,123 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"mylua-lsp/lsp/common"
+	"mylua-lsp/lsp/protocol"
+	"strings"
+	"testing"
+)
+
+// cursorMark 测试文本里标记请求位置的字符
+const cursorMark = "|"
+
+// testURI 测试文件的 uri ，不对应磁盘上的文件
+func testURI(name string) protocol.DocumentURI {
+	return pathToURI("/mylua-test/" + name)
+}
+
+// newTestServer 创建语言服务并打开 files 里的文件，key 是文件名
+func newTestServer(t *testing.T, files map[string]string) *Server {
+	t.Helper()
+	var s = NewServer()
+	if _, err := s.Initialize(context.Background(), &protocol.ParamInitialize{}); err != nil {
+		t.Fatal(err)
+	}
+	for name, text := range files {
+		s.open(t, name, text)
+	}
+	return s
+}
+
+// open 打开文件，文本里不能有光标标记
+func (s *Server) open(t *testing.T, name string, text string) {
+	t.Helper()
+	var params = &protocol.DidOpenTextDocumentParams{}
+	params.TextDocument = protocol.TextDocumentItem{URI: testURI(name), LanguageID: "lua", Version: 1, Text: text}
+	if err := s.TextDocumentDidOpen(context.Background(), params); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// change 把 r 范围的文本替换成 text
+func (s *Server) change(t *testing.T, name string, r protocol.Range, text string) {
+	t.Helper()
+	var doc = s.getDocument(testURI(name))
+	var params = &protocol.DidChangeTextDocumentParams{}
+	params.TextDocument.URI = testURI(name)
+	params.TextDocument.Version = doc.version + 1
+	params.ContentChanges = []protocol.TextDocumentContentChangeEvent{{Range: &r, Text: text}}
+	if err := s.TextDocumentDidChange(context.Background(), params); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// cursor 去掉文本里的光标标记，返回标记的位置，列是 utf-16 的编码单元
+func cursor(t *testing.T, text string) (string, protocol.Position) {
+	t.Helper()
+	var idx = strings.Index(text, cursorMark)
+	if idx < 0 {
+		t.Fatalf("no cursor in %q", text)
+	}
+	var before = text[:idx]
+	var line = strings.Count(before, "\n")
+	var lineText = before[strings.LastIndex(before, "\n")+1:]
+	return before + text[idx+len(cursorMark):], protocol.Position{Line: uint32(line), Character: utf16Column(lineText, len(lineText))}
+}
+
+// openAt 打开带光标标记的文件，返回请求的参数
+func openAt(t *testing.T, s *Server, name string, text string) protocol.TextDocumentPositionParams {
+	t.Helper()
+	var content, pos = cursor(t, text)
+	s.open(t, name, content)
+	return protocol.TextDocumentPositionParams{
+		TextDocument: textDocument(name),
+		Position:     pos,
+	}
+}
+
+// textRange 单行的范围
+func textRange(line, start, end uint32) protocol.Range {
+	return protocol.Range{
+		Start: protocol.Position{Line: line, Character: start},
+		End:   protocol.Position{Line: line, Character: end},
+	}
+}
+
+// textDocument 文件的标识
+func textDocument(name string) protocol.TextDocumentIdentifier {
+	return protocol.TextDocumentIdentifier{URI: testURI(name)}
+}
+
+// rangeString 范围的文字表示 "行:列-行:列"
+func rangeString(r protocol.Range) string {
+	return fmt.Sprintf("%d:%d-%d:%d", r.Start.Line, r.Start.Character, r.End.Line, r.End.Character)
+}
+
+// locationString 位置的文字表示 "文件名 行:列-行:列"
+func locationString(loc protocol.Location) string {
+	return fmt.Sprintf("%s %s", strings.TrimPrefix(string(loc.URI), string(testURI(""))), rangeString(loc.Range))
+}
+
+// 修改后文件和工程里都只有新的分析结果，一次请求里的多个修改依次增量解析
+func TestDidChange(t *testing.T) {
+	var s = newTestServer(t, map[string]string{"a.lua": "local a = 1\nprint(a)\n"})
+	var old = s.getDocument(testURI("a.lua")).file
+	var params = &protocol.DidChangeTextDocumentParams{}
+	params.TextDocument.URI = testURI("a.lua")
+	params.TextDocument.Version = 2
+	var first, second = textRange(0, 0, 0), textRange(1, 0, 0)
+	params.ContentChanges = []protocol.TextDocumentContentChangeEvent{{Range: &first, Text: "\n"}, {Range: &second, Text: "-- x\n"}}
+	if err := s.TextDocumentDidChange(context.Background(), params); err != nil {
+		t.Fatal(err)
+	}
+	var doc = s.getDocument(testURI("a.lua"))
+	if doc.file == old || s.project.GetFile(doc.file.GetPath()) != doc.file {
+		t.Error("old analysis is still used after the change")
+	}
+	var text = doc.result.Source.GetRawText(common.Location{End: common.Position{Line: 4}})
+	if text != "\n-- x\nlocal a = 1\nprint(a)\n" {
+		t.Errorf("got %q", text)
+	}
+}
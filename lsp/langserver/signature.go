@@ -0,0 +1,57 @@
+package langserver
+
+import (
+	"context"
+	"mylua-lsp/lsp/protocol"
+	"strings"
+)
+
+// TextDocumentSignatureHelp 函数调用的签名，高亮光标所在的参数，@overload 作为其他的签名
+func (s *Server) TextDocumentSignatureHelp(ctx context.Context, params *protocol.SignatureHelpParams) (*protocol.SignatureHelp, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var doc = s.getDocument(params.TextDocument.URI)
+	if doc == nil {
+		return nil, nil
+	}
+	var sigList = s.project.SignatureHelp(doc.file, toPosition(doc.file.Source, params.Position))
+	if len(sigList) == 0 {
+		return nil, nil
+	}
+	var result = &protocol.SignatureHelp{}
+	for _, sig := range sigList {
+		var info = protocol.SignatureInformation{
+			Label:         sig.Label,
+			Documentation: markdown(sig.Doc),
+		}
+		for _, param := range sig.ParamList {
+			// 使用 utf-16 的偏移，参数名可能在函数名里出现
+			info.Parameters = append(info.Parameters, protocol.ParameterInformation{
+				Label:         []uint32{utf16Column(sig.Label, param.Start), utf16Column(sig.Label, param.End)},
+				Documentation: markdown(param.Doc),
+			})
+		}
+		// 没有对应的参数时超出范围，不高亮任何参数
+		info.ActiveParameter = uint32(len(sig.ParamList))
+		if sig.ActiveParam >= 0 {
+			info.ActiveParameter = uint32(sig.ActiveParam)
+		}
+		result.Signatures = append(result.Signatures, info)
+	}
+	result.ActiveParameter = result.Signatures[0].ActiveParameter
+	// 参数个数够用的第一个签名
+	for i, sig := range sigList {
+		if sig.ActiveParam >= 0 {
+			result.ActiveSignature = uint32(i)
+			result.ActiveParameter = result.Signatures[i].ActiveParameter
+			break
+		}
+	}
+	return result, nil
+}
+
+// markdown 说明文字，为空时客户端不显示
+func markdown(text string) protocol.MarkupContent {
+	return protocol.MarkupContent{Kind: protocol.Markdown, Value: strings.TrimSpace(text)}
+}
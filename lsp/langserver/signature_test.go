@@ -0,0 +1,34 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"mylua-lsp/lsp/protocol"
+	"slices"
+	"testing"
+)
+
+func TestSignatureHelp(t *testing.T) {
+	var s = newTestServer(t, nil)
+	var text = "---@param a number first\n---@overload fun(b: string)\nlocal function f(a, b) end\nf(1, |)\n"
+	var params = openAt(t, s, "a.lua", text)
+	var result, err = s.TextDocumentSignatureHelp(context.Background(), &protocol.SignatureHelpParams{TextDocumentPositionParams: params})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result == nil {
+		t.Fatal("no signature")
+	}
+	var got []string
+	for _, sig := range result.Signatures {
+		var line = fmt.Sprintf("%s %d", sig.Label, sig.ActiveParameter)
+		for _, param := range sig.Parameters {
+			line += fmt.Sprintf(" %v", param.Label)
+		}
+		got = append(got, line)
+	}
+	var want = []string{"f(a: number, b) 1 [2 11] [13 14]", "f(b: string) 1 [2 11]"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,88 @@
+package langserver
+
+import (
+	"context"
+	"mylua-lsp/lsp/check"
+	"mylua-lsp/lsp/common"
+	"mylua-lsp/lsp/protocol"
+)
+
+// outlineKinds 大纲符号在协议里的种类
+var outlineKinds = map[check.OutlineKind]protocol.SymbolKind{
+	check.OutlineVariable:   protocol.Variable,
+	check.OutlineConstant:   protocol.Constant,
+	check.OutlineFunction:   protocol.Function,
+	check.OutlineMethod:     protocol.Method,
+	check.OutlineField:      protocol.Field,
+	check.OutlineTable:      protocol.Object,
+	check.OutlineClass:      protocol.Class,
+	check.OutlineAlias:      protocol.Interface,
+	check.OutlineEnum:       protocol.Enum,
+	check.OutlineEnumMember: protocol.EnumMember,
+	check.OutlineLabel:      protocol.Key,
+}
+
+// TextDocumentDocumentSymbol 文件大纲，客户端不支持层级时返回扁平的列表
+func (s *Server) TextDocumentDocumentSymbol(ctx context.Context, params *protocol.DocumentSymbolParams) (any, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var doc = s.getDocument(params.TextDocument.URI)
+	if doc == nil {
+		return nil, nil
+	}
+	var outline = s.project.DocumentOutline(doc.file)
+	var source = doc.file.Source
+	if !s.clientCaps.hierarchicalSymbol {
+		var result = []protocol.SymbolInformation{}
+		flattenOutline(source, params.TextDocument.URI, outline, "", &result)
+		return result, nil
+	}
+	return documentSymbols(source, outline), nil
+}
+
+func documentSymbols(source *common.LuaSource, list []*check.OutlineSymbol) []protocol.DocumentSymbol {
+	var result = []protocol.DocumentSymbol{}
+	for _, sym := range list {
+		result = append(result, protocol.DocumentSymbol{
+			Name:           sym.Name,
+			Detail:         sym.Detail,
+			Kind:           outlineKinds[sym.Kind],
+			Range:          toRange(source, sym.Loc),
+			SelectionRange: toRange(source, sym.NameLoc),
+			Children:       documentSymbols(source, sym.Children),
+		})
+	}
+	return result
+}
+
+// flattenOutline 展开大纲，子节点的 ContainerName 是父节点的名字
+func flattenOutline(source *common.LuaSource, uri protocol.DocumentURI, list []*check.OutlineSymbol, container string,
+	result *[]protocol.SymbolInformation) {
+	for _, sym := range list {
+		*result = append(*result, protocol.SymbolInformation{
+			Name:          sym.Name,
+			Kind:          outlineKinds[sym.Kind],
+			Location:      protocol.Location{URI: uri, Range: toRange(source, sym.Loc)},
+			ContainerName: container,
+		})
+		flattenOutline(source, uri, sym.Children, sym.Name, result)
+	}
+}
+
+// WorkspaceSymbol 按名字模糊搜索工程里的全局符号和注释类型
+func (s *Server) WorkspaceSymbol(ctx context.Context, params *protocol.WorkspaceSymbolParams) ([]protocol.SymbolInformation, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var result = []protocol.SymbolInformation{}
+	for _, sym := range s.project.SearchSymbols(params.Query) {
+		result = append(result, protocol.SymbolInformation{
+			Name:          sym.Name,
+			Kind:          outlineKinds[sym.Kind],
+			Location:      protocol.Location{URI: pathToURI(sym.File.GetPath()), Range: toRange(sym.File.Source, sym.Loc)},
+			ContainerName: sym.Container,
+		})
+	}
+	return result, nil
+}
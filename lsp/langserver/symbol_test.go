@@ -0,0 +1,63 @@
+package langserver
+
+import (
+	"context"
+	"fmt"
+	"mylua-lsp/lsp/protocol"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// symbolText 大纲测试的文件
+const symbolText = "local M = {}\n\nfunction M.f(x)\n  return x\nend\n\n---@class Point\n---@field x number\nlocal Point = {}\n"
+
+// requestSymbols 请求 symbolText 的大纲，hierarchical 是客户端是否支持层级
+func requestSymbols(t *testing.T, hierarchical bool) any {
+	t.Helper()
+	var s = newTestServer(t, map[string]string{"a.lua": symbolText})
+	s.clientCaps.hierarchicalSymbol = hierarchical
+	var result, err = s.TextDocumentDocumentSymbol(context.Background(), &protocol.DocumentSymbolParams{TextDocument: textDocument("a.lua")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+// dumpSymbols 大纲的文字表示，每行是缩进、名字、种类和范围
+func dumpSymbols(sb *strings.Builder, list []protocol.DocumentSymbol, indent string) {
+	for _, sym := range list {
+		fmt.Fprintf(sb, "%s%s%s %v %s %s\n", indent, sym.Name, sym.Detail, sym.Kind, rangeString(sym.Range), rangeString(sym.SelectionRange))
+		dumpSymbols(sb, sym.Children, indent+"  ")
+	}
+}
+
+func TestDocumentSymbol(t *testing.T) {
+	var list, ok = requestSymbols(t, true).([]protocol.DocumentSymbol)
+	if !ok {
+		t.Fatal("not hierarchical symbols")
+	}
+	var sb strings.Builder
+	dumpSymbols(&sb, list, "")
+	var want = fmt.Sprintf("M %v 0:0-4:3 0:6-0:7\n  f(x) %v 2:0-4:3 2:11-2:12\nPoint %v 6:0-8:16 8:6-8:11\n  x %v 7:10-7:11 7:10-7:11\n",
+		protocol.Object, protocol.Function, protocol.Class, protocol.Field)
+	if sb.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", sb.String(), want)
+	}
+}
+
+// 客户端不支持层级时返回扁平的列表，子节点带上容器的名字
+func TestDocumentSymbolFlat(t *testing.T) {
+	var list, ok = requestSymbols(t, false).([]protocol.SymbolInformation)
+	if !ok {
+		t.Fatal("not flat symbols")
+	}
+	var got []string
+	for _, sym := range list {
+		got = append(got, fmt.Sprintf("%s/%s %s", sym.ContainerName, sym.Name, locationString(sym.Location)))
+	}
+	var want = []string{"/M a.lua 0:0-4:3", "M/f a.lua 2:0-4:3", "/Point a.lua 6:0-8:16", "Point/x a.lua 7:10-7:11"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
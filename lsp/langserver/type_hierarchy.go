@@ -0,0 +1,62 @@
+package langserver
+
+import (
+	"context"
+	"mylua-lsp/lsp/ast"
+	"mylua-lsp/lsp/protocol"
+	"path"
+)
+
+// TextDocumentPrepareTypeHierarchy 光标所在的 @class
+func (s *Server) TextDocumentPrepareTypeHierarchy(ctx context.Context, params *protocol.TypeHierarchyPrepareParams) ([]protocol.TypeHierarchyItem, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var doc = s.getDocument(params.TextDocument.URI)
+	if doc == nil {
+		return nil, nil
+	}
+	var class = s.project.TypeHierarchyClass(doc.file, toPosition(doc.file.Source, params.Position))
+	if class == nil {
+		return nil, nil
+	}
+	return []protocol.TypeHierarchyItem{typeItem(class)}, nil
+}
+
+// TypeHierarchySupertypes 类继承的父类
+func (s *Server) TypeHierarchySupertypes(ctx context.Context, params *protocol.TypeHierarchySupertypesParams) ([]protocol.TypeHierarchyItem, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return typeItems(s.project.Supertypes(params.Item.Name)), nil
+}
+
+// TypeHierarchySubtypes 工程里直接继承了这个类的子类
+func (s *Server) TypeHierarchySubtypes(ctx context.Context, params *protocol.TypeHierarchySubtypesParams) ([]protocol.TypeHierarchyItem, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return typeItems(s.project.Subtypes(params.Item.Name)), nil
+}
+
+// typeItem 类对应的节点，位置是 @class 后面的类名
+func typeItem(class *ast.Type_Class) protocol.TypeHierarchyItem {
+	var source = class.File.Source
+	var r = toRange(source, class.NameAndLoc.Loc)
+	return protocol.TypeHierarchyItem{
+		Name:           class.NameAndLoc.Name,
+		Kind:           protocol.Class,
+		Detail:         path.Base(class.File.GetPath()),
+		URI:            pathToURI(class.File.GetPath()),
+		Range:          r,
+		SelectionRange: r,
+	}
+}
+
+func typeItems(list []*ast.Type_Class) []protocol.TypeHierarchyItem {
+	var result = []protocol.TypeHierarchyItem{}
+	for _, class := range list {
+		result = append(result, typeItem(class))
+	}
+	return result
+}
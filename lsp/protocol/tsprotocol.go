@@ -2299,8 +2299,8 @@ type Hover struct {
 	/**
 	 * The hover's content
 	 */
-	//Contents MarkupContent/*MarkupContent | MarkedString | MarkedString[]*/ `json:"contents"`
-	Contents MarkedString/*MarkupContent | MarkedString | MarkedString[]*/ `json:"contents"`
+	Contents MarkupContent/*MarkupContent | MarkedString | MarkedString[]*/ `json:"contents"`
+	//Contents MarkedString/*MarkupContent | MarkedString | MarkedString[]*/ `json:"contents"`
 	/**
 	 * An optional range
 	 */
@@ -2406,6 +2406,89 @@ type InitializeResult struct {
 type InitializedParams struct {
 }
 
+// InlayHintKind 内嵌提示的种类
+type InlayHintKind int
+
+const (
+	/**
+	 * An inlay hint that for a type annotation.
+	 */
+	TypeHint InlayHintKind = 1
+	/**
+	 * An inlay hint that is for a parameter.
+	 */
+	ParameterHint InlayHintKind = 2
+)
+
+/**
+ * Inlay hint information.
+ *
+ * @since 3.17.0
+ */
+type InlayHint struct {
+	/**
+	 * The position of this hint.
+	 */
+	Position Position `json:"position"`
+	/**
+	 * The label of this hint. A human readable string or an array of
+	 * InlayHintLabelPart label parts.
+	 */
+	Label string/*string | InlayHintLabelPart[]*/ `json:"label"`
+	/**
+	 * The kind of this hint. Can be omitted in which case the client
+	 * should fall back to a reasonable default.
+	 */
+	Kind InlayHintKind `json:"kind,omitempty"`
+	/**
+	 * Optional text edits that are performed when accepting this inlay hint.
+	 */
+	TextEdits []TextEdit `json:"textEdits,omitempty"`
+	/**
+	 * The tooltip text when you hover over this item.
+	 */
+	Tooltip interface{}/*string | MarkupContent*/ `json:"tooltip,omitempty"`
+	/**
+	 * Render padding before the hint.
+	 */
+	PaddingLeft bool `json:"paddingLeft,omitempty"`
+	/**
+	 * Render padding after the hint.
+	 */
+	PaddingRight bool `json:"paddingRight,omitempty"`
+}
+
+/**
+ * Inlay hint options used during static registration.
+ *
+ * @since 3.17.0
+ */
+type InlayHintOptions struct {
+	/**
+	 * The server provides support to resolve additional
+	 * information for an inlay hint item.
+	 */
+	ResolveProvider bool `json:"resolveProvider,omitempty"`
+	WorkDoneProgressOptions
+}
+
+/**
+ * A parameter literal used in inlay hint requests.
+ *
+ * @since 3.17.0
+ */
+type InlayHintParams struct {
+	/**
+	 * The text document.
+	 */
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	/**
+	 * The document range for which inlay hints should be computed.
+	 */
+	Range Range `json:"range"`
+	WorkDoneProgressParams
+}
+
 /**
  * Defines the capabilities provided by the client.
  */
@@ -2629,6 +2712,18 @@ type InnerServerCapabilities struct {
 	 * @since 3.16.0
 	 */
 	MonikerProvider interface{}/* bool | MonikerOptions | MonikerRegistrationOptions*/ `json:"monikerProvider,omitempty"`
+	/**
+	 * The server provides inlay hints.
+	 *
+	 * @since 3.17.0
+	 */
+	InlayHintProvider interface{}/* bool | InlayHintOptions*/ `json:"inlayHintProvider,omitempty"`
+	/**
+	 * The server provides type hierarchy support.
+	 *
+	 * @since 3.17.0
+	 */
+	TypeHierarchyProvider interface{}/* bool | TypeHierarchyOptions | TypeHierarchyRegistrationOptions*/ `json:"typeHierarchyProvider,omitempty"`
 	/**
 	 * Experimental server capabilities.
 	 */
@@ -2960,7 +3055,7 @@ type ParameterInformation struct {
 	 * *Note*: a label of type string should be a substring of its containing signature label.
 	 * Its intended use case is to highlight the parameter label part in the `SignatureInformation.label`.
 	 */
-	Label string/*string | [uinteger, uinteger]*/ `json:"label"`
+	Label interface{}/*string | [uinteger, uinteger]*/ `json:"label"`
 	/**
 	 * The human-readable doc-comment of this signature. Will be shown
 	 * in the UI but can be omitted.
@@ -3007,6 +3102,12 @@ type PrepareRenameParams struct {
 	WorkDoneProgressParams
 }
 
+// PrepareRenameResult prepareRename 的结果，{ range, placeholder } 的形式
+type PrepareRenameResult struct {
+	Range       Range  `json:"range"`
+	Placeholder string `json:"placeholder"`
+}
+
 type PrepareSupportDefaultBehavior = interface{}
 
 type ProgressParams struct {
@@ -3532,6 +3633,14 @@ type SemanticTokensOptions struct {
 	WorkDoneProgressOptions
 }
 
+// SemanticTokensFullOptions full 请求的选项，支持 delta 时使用
+type SemanticTokensFullOptions struct {
+	/**
+	 * The server supports deltas for full documents.
+	 */
+	Delta bool `json:"delta,omitempty"`
+}
+
 /**
  * @since 3.16.0
  */
@@ -4283,6 +4392,82 @@ type TypeDefinitionRegistrationOptions struct {
 	StaticRegistrationOptions
 }
 
+/**
+ * @since 3.17.0
+ */
+type TypeHierarchyItem struct {
+	/**
+	 * The name of this item.
+	 */
+	Name string `json:"name"`
+	/**
+	 * The kind of this item.
+	 */
+	Kind SymbolKind `json:"kind"`
+	/**
+	 * Tags for this item.
+	 */
+	Tags []SymbolTag `json:"tags,omitempty"`
+	/**
+	 * More detail for this item, e.g. the signature of a function.
+	 */
+	Detail string `json:"detail,omitempty"`
+	/**
+	 * The resource identifier of this item.
+	 */
+	URI DocumentURI `json:"uri"`
+	/**
+	 * The range enclosing this symbol not including leading/trailing whitespace
+	 * but everything else, e.g. comments and code.
+	 */
+	Range Range `json:"range"`
+	/**
+	 * The range that should be selected and revealed when this symbol is being
+	 * picked, e.g. the name of a function. Must be contained by the
+	 * [`range`](#TypeHierarchyItem.range).
+	 */
+	SelectionRange Range `json:"selectionRange"`
+	/**
+	 * A data entry field that is preserved between a type hierarchy prepare and
+	 * supertypes or subtypes requests. It could also be used to identify the
+	 * type hierarchy in the server, helping improve the performance on
+	 * resolving supertypes and subtypes.
+	 */
+	Data interface{} `json:"data,omitempty"`
+}
+
+/**
+ * The parameter of a `textDocument/prepareTypeHierarchy` request.
+ *
+ * @since 3.17.0
+ */
+type TypeHierarchyPrepareParams struct {
+	TextDocumentPositionParams
+	WorkDoneProgressParams
+}
+
+/**
+ * The parameter of a `typeHierarchy/subtypes` request.
+ *
+ * @since 3.17.0
+ */
+type TypeHierarchySubtypesParams struct {
+	Item TypeHierarchyItem `json:"item"`
+	WorkDoneProgressParams
+	PartialResultParams
+}
+
+/**
+ * The parameter of a `typeHierarchy/supertypes` request.
+ *
+ * @since 3.17.0
+ */
+type TypeHierarchySupertypesParams struct {
+	Item TypeHierarchyItem `json:"item"`
+	WorkDoneProgressParams
+	PartialResultParams
+}
+
 /**
  * A tagging type for string properties that are actually URIs
  *
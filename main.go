@@ -1,32 +1,95 @@
 package main
 
 import (
-	"mylua-lsp/lsp/ast"
-	"mylua-lsp/lsp/common"
-	"strings"
+	"context"
+	"log"
+	"mylua-lsp/lsp/langserver"
+	"mylua-lsp/lsp/protocol"
+	"os"
+
+	"github.com/yinfei8/jrpc2"
+	"github.com/yinfei8/jrpc2/channel"
+	"github.com/yinfei8/jrpc2/handler"
 )
 
+// rpcClient 通过 jrpc2 的服务端推送给客户端发送通知
+type rpcClient struct {
+	server *jrpc2.Server
+}
+
+func (c rpcClient) PublishDiagnostics(ctx context.Context, params *protocol.PublishDiagnosticsParams) error {
+	return c.server.Notify(ctx, "textDocument/publishDiagnostics", params)
+}
+
+// noop 不需要处理的通知和请求
+func noop(ctx context.Context) error {
+	return nil
+}
+
+// exit 客户端要求退出，停止服务
+func exit(ctx context.Context) error {
+	jrpc2.ServerFromContext(ctx).Stop()
+	return nil
+}
+
+// newHandlers 协议里的方法名到语言服务方法的映射
+func newHandlers(s *langserver.Server) handler.Map {
+	return handler.Map{
+		"initialize":      handler.New(s.Initialize),
+		"initialized":     handler.New(noop),
+		"shutdown":        handler.New(noop),
+		"exit":            handler.New(exit),
+		"$/cancelRequest": handler.New(noop),
+		"$/setTrace":      handler.New(noop),
+
+		"textDocument/didOpen":   handler.New(s.TextDocumentDidOpen),
+		"textDocument/didChange": handler.New(s.TextDocumentDidChange),
+		"textDocument/didClose":  handler.New(s.TextDocumentDidClose),
+		"textDocument/didSave":   handler.New(noop),
+
+		"textDocument/hover":                     handler.New(s.TextDocumentHover),
+		"textDocument/definition":                handler.New(s.TextDocumentDefinition),
+		"textDocument/declaration":               handler.New(s.TextDocumentDeclaration),
+		"textDocument/references":                handler.New(s.TextDocumentReferences),
+		"textDocument/documentHighlight":         handler.New(s.TextDocumentDocumentHighlight),
+		"textDocument/completion":                handler.New(s.TextDocumentCompletion),
+		"completionItem/resolve":                 handler.New(s.CompletionItemResolve),
+		"textDocument/signatureHelp":             handler.New(s.TextDocumentSignatureHelp),
+		"textDocument/prepareRename":             handler.New(s.TextDocumentPrepareRename),
+		"textDocument/rename":                    handler.New(s.TextDocumentRename),
+		"textDocument/documentSymbol":            handler.New(s.TextDocumentDocumentSymbol),
+		"workspace/symbol":                       handler.New(s.WorkspaceSymbol),
+		"textDocument/semanticTokens/full":       handler.New(s.TextDocumentSemanticTokensFull),
+		"textDocument/semanticTokens/full/delta": handler.New(s.TextDocumentSemanticTokensFullDelta),
+		"textDocument/semanticTokens/range":      handler.New(s.TextDocumentSemanticTokensRange),
+		"textDocument/inlayHint":                 handler.New(s.TextDocumentInlayHint),
+		"textDocument/prepareCallHierarchy":      handler.New(s.TextDocumentPrepareCallHierarchy),
+		"callHierarchy/incomingCalls":            handler.New(s.CallHierarchyIncomingCalls),
+		"callHierarchy/outgoingCalls":            handler.New(s.CallHierarchyOutgoingCalls),
+		"textDocument/prepareTypeHierarchy":      handler.New(s.TextDocumentPrepareTypeHierarchy),
+		"typeHierarchy/supertypes":               handler.New(s.TypeHierarchySupertypes),
+		"typeHierarchy/subtypes":                 handler.New(s.TypeHierarchySubtypes),
+		"textDocument/foldingRange":              handler.New(s.TextDocumentFoldingRange),
+		"textDocument/selectionRange":            handler.New(s.TextDocumentSelectionRange),
+		"textDocument/formatting":                handler.New(s.TextDocumentFormatting),
+		"textDocument/rangeFormatting":           handler.New(s.TextDocumentRangeFormatting),
+		"textDocument/onTypeFormatting":          handler.New(s.TextDocumentOnTypeFormatting),
+		"textDocument/codeAction":                handler.New(s.TextDocumentCodeAction),
+	}
+}
+
+// startServer 在 ch 上启动语言服务，诊断通过同一个连接推送给客户端。
+// jrpc2 保证通知在后面的请求之前处理完，修改文件之后的请求看到的是修改后的内容
+func startServer(s *langserver.Server, ch channel.Channel) *jrpc2.Server {
+	var server = jrpc2.NewServer(newHandlers(s), &jrpc2.ServerOptions{AllowPush: true})
+	s.SetClient(rpcClient{server: server})
+	return server.Start(ch)
+}
+
+// main 通过标准输入输出提供 lua 语言服务
 func main() {
-	println("hello world")
-	println(common.StringToBytes("hi"))
-	println(common.BytesToString([]byte("hi")))
-	var builder strings.Builder
-	println(builder.String())
-
-	var stat ast.Stat = &ast.Block{}
-	var stat2 ast.Stat = &ast.BreakStat{}
-	stat2.SetParent(stat)
-	var stat3 ast.Stat = &ast.TrueExp{}
-	var exp ast.Exp = &ast.TrueExp{}
-	var exp2 ast.Exp = &ast.NilExp{}
-	exp2.SetParent(exp)
-	println("exp info:", exp, exp.IsExp(), exp.GetParentExp())
-	println("exp2 info:", exp2.IsExp(), exp2.GetParentExp())
-	println("stat2 info:", stat2.IsExp(), stat2.GetParent())
-	x1, _ := stat.(ast.Exp)
-	println(x1, x1 == nil)
-	x2, _ := stat3.(ast.Exp)
-	println(x2, x2 == nil)
-	x3, _ := exp.(ast.Stat)
-	println(x3, x3 == nil, &x3, &stat, stat)
+	var server = startServer(langserver.NewServer(), channel.LSP(os.Stdin, os.Stdout))
+	if err := server.Wait(); err != nil {
+		log.Fatal(err)
+	}
 }
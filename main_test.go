@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"mylua-lsp/lsp/langserver"
+	"mylua-lsp/lsp/protocol"
+	"strings"
+	"testing"
+
+	"github.com/yinfei8/jrpc2"
+	"github.com/yinfei8/jrpc2/channel"
+)
+
+// 通过 jrpc2 的连接初始化、打开文件、请求悬停，服务端推送诊断
+func TestServeHover(t *testing.T) {
+	var clientCh, serverCh = channel.Direct()
+	var server = startServer(langserver.NewServer(), serverCh)
+	var published = make(chan *protocol.PublishDiagnosticsParams, 1)
+	var client = jrpc2.NewClient(clientCh, &jrpc2.ClientOptions{
+		OnNotify: func(req *jrpc2.Request) {
+			var params protocol.PublishDiagnosticsParams
+			if req.Method() != "textDocument/publishDiagnostics" || req.UnmarshalParams(&params) != nil {
+				t.Errorf("unexpected notification %s", req.Method())
+				return
+			}
+			published <- &params
+		},
+	})
+	var ctx = context.Background()
+
+	var initResult protocol.InitializeResult
+	if err := client.CallResult(ctx, "initialize", &protocol.ParamInitialize{}, &initResult); err != nil {
+		t.Fatal(err)
+	}
+	if !initResult.Capabilities.HoverProvider {
+		t.Error("hover is not provided")
+	}
+	if err := client.Notify(ctx, "initialized", struct{}{}); err != nil {
+		t.Fatal(err)
+	}
+
+	var uri = protocol.DocumentURI("file:///mylua-test/a.lua")
+	var open = &protocol.DidOpenTextDocumentParams{}
+	open.TextDocument = protocol.TextDocumentItem{URI: uri, LanguageID: "lua", Version: 1, Text: "local count = 1\nprint(count, unknown)\n"}
+	if err := client.Notify(ctx, "textDocument/didOpen", open); err != nil {
+		t.Fatal(err)
+	}
+	var diagnostics = <-published
+	if diagnostics.URI != uri || len(diagnostics.Diagnostics) != 1 || !strings.Contains(diagnostics.Diagnostics[0].Message, "unknown") {
+		t.Errorf("got diagnostics %+v", diagnostics)
+	}
+
+	var hover = &protocol.HoverParams{}
+	hover.TextDocument.URI = uri
+	hover.Position = protocol.Position{Line: 1, Character: 8}
+	var result json.RawMessage
+	if err := client.CallResult(ctx, "textDocument/hover", hover, &result); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(result), "local count: integer") {
+		t.Errorf("got hover %s", result)
+	}
+
+	if _, err := client.Call(ctx, "shutdown", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Notify(ctx, "exit", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	client.Close()
+}